@@ -0,0 +1,212 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/monitor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+)
+
+// Client talks to the control API of a running daemon over its unix socket
+type Client struct {
+	http *http.Client
+}
+
+// NewUnixClient creates a client for the daemon listening on socketPath.
+// Starting a stream includes URL extraction, so requests get a generous
+// timeout.
+func NewUnixClient(socketPath string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return &Client{
+		http: &http.Client{Transport: transport, Timeout: 2 * time.Minute},
+	}
+}
+
+// Ping checks that the daemon is answering
+func (c *Client) Ping() error {
+	return c.do(http.MethodGet, "/healthz", nil, nil)
+}
+
+// List returns all streams known to the daemon
+func (c *Client) List() ([]stream.Info, error) {
+	var infos []stream.Info
+	if err := c.do(http.MethodGet, "/streams", nil, &infos); err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// Status returns a single stream
+func (c *Client) Status(name string) (*stream.Info, error) {
+	var info stream.Info
+	if err := c.do(http.MethodGet, "/streams/"+url.PathEscape(name), nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Start starts a stream in the daemon, or registers it with MediaMTX if
+// onDemand is set. With an empty name, the daemon names the stream after
+// the video title; the returned info has the name.
+func (c *Client) Start(youtubeURL, name string, port int, opts stream.StartOptions, onDemand bool) (*stream.Info, error) {
+	req := startRequest{
+		URL:       youtubeURL,
+		Name:      name,
+		Port:      port,
+		AudioOnly: opts.AudioOnly,
+		Loop:      opts.Loop,
+		Transport: opts.Transport,
+		OnDemand:  onDemand,
+		NoWait:    opts.NoWait,
+		Format:    opts.Format,
+
+		MaxBitrate: opts.MaxBitrate,
+		Outputs:    opts.Outputs,
+		Substream:  opts.Substream,
+
+		RequireH264: opts.RequireH264,
+		AudioCopy:   opts.AudioCopy,
+	}
+	var info stream.Info
+	if err := c.do(http.MethodPost, "/streams", req, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Stop stops a stream
+func (c *Client) Stop(name string) error {
+	return c.do(http.MethodDelete, "/streams/"+url.PathEscape(name), nil, nil)
+}
+
+// Reconnect asks the daemon's monitor to reconnect a stream
+func (c *Client) Reconnect(name string) error {
+	return c.do(http.MethodPost, "/streams/"+url.PathEscape(name)+"/reconnect", nil, nil)
+}
+
+// SetLogLevel sets the level of a stream's log ("" = logging.level)
+func (c *Client) SetLogLevel(name, level string) error {
+	return c.do(http.MethodPut, "/streams/"+url.PathEscape(name)+"/log-level", logLevelRequest{Level: level}, nil)
+}
+
+// ReconnectWait forces a stream to reconnect and waits until it has
+// reconnected or the daemon gave up, passing each attempt to report. The
+// returned error is why the reconnect failed.
+func (c *Client) ReconnectWait(name string, report monitor.ProgressFunc) error {
+	req, err := http.NewRequest(http.MethodPost, "http://daemon/streams/"+url.PathEscape(name)+"/reconnect?wait=1", nil)
+	if err != nil {
+		return err
+	}
+
+	// Backoff between attempts can outlast any request timeout
+	client := *c.http
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("daemon unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&e); err == nil && e.Error != "" {
+			return fmt.Errorf("%s", e.Error)
+		}
+		return fmt.Errorf("daemon returned status %d", resp.StatusCode)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var p monitor.ReconnectProgress
+		if err := dec.Decode(&p); err != nil {
+			return fmt.Errorf("lost the reconnect progress: %w", err)
+		}
+		if p.Done {
+			if p.Error != "" {
+				return fmt.Errorf("%s", p.Error)
+			}
+			return nil
+		}
+		report(p)
+	}
+}
+
+// Apply asks the daemon to reconcile the streams declared in its config
+// file, returning its report of the changes. A non-nil error with output
+// means some of the changes failed. With force, declared streams stopped by
+// hand are started too.
+func (c *Client) Apply(force bool) (string, error) {
+	path := "/apply"
+	if force {
+		path += "?force=1"
+	}
+	var resp applyResponse
+	if err := c.do(http.MethodPost, path, nil, &resp); err != nil {
+		return "", err
+	}
+	if resp.Error != "" {
+		return resp.Output, fmt.Errorf("%s", resp.Error)
+	}
+	return resp.Output, nil
+}
+
+// Drain makes the daemon reject new streams while keeping its running ones
+func (c *Client) Drain() error {
+	return c.do(http.MethodPost, "/drain", nil, nil)
+}
+
+// do sends a request with an optional JSON body and decodes the JSON
+// response into out, if given. Error responses are returned as errors.
+func (c *Client) do(method, path string, body, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	// The host is ignored; the transport always dials the socket
+	req, err := http.NewRequest(method, "http://daemon"+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("daemon unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&e); err == nil && e.Error != "" {
+			return fmt.Errorf("%s", e.Error)
+		}
+		return fmt.Errorf("daemon returned status %d", resp.StatusCode)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	return nil
+}
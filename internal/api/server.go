@@ -0,0 +1,399 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/logger"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/monitor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+)
+
+// Server is the HTTP control API for managing streams
+type Server struct {
+	manager *stream.Manager
+	monitor *monitor.Monitor
+	token   string
+
+	httpServer *http.Server
+	ctx        context.Context
+
+	// apply reconciles declared streams (nil = not supported)
+	apply ApplyFunc
+}
+
+// ApplyFunc reconciles the declared streams, writing progress to w. With
+// force, declared streams stopped by hand are started too.
+type ApplyFunc func(ctx context.Context, w io.Writer, force bool) error
+
+// NewServer creates a new control API server listening on addr.
+// If token is non-empty, every request except /healthz must carry
+// an "Authorization: Bearer <token>" header.
+func NewServer(addr, token string, manager *stream.Manager, mon *monitor.Monitor) *Server {
+	s := &Server{
+		manager: manager,
+		monitor: mon,
+		token:   token,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /streams", s.requireAuth(s.handleListStreams))
+	mux.HandleFunc("POST /streams", s.requireAuth(s.handleStartStream))
+	mux.HandleFunc("GET /streams/{name}", s.requireAuth(s.handleStreamStatus))
+	mux.HandleFunc("DELETE /streams/{name}", s.requireAuth(s.handleStopStream))
+	mux.HandleFunc("POST /streams/{name}/reconnect", s.requireAuth(s.handleReconnect))
+	mux.HandleFunc("GET /streams/{name}/logs", s.requireAuth(s.handleLogs))
+	mux.HandleFunc("PUT /streams/{name}/log-level", s.requireAuth(s.handleSetLogLevel))
+	mux.HandleFunc("POST /apply", s.requireAuth(s.handleApply))
+	mux.HandleFunc("POST /drain", s.requireAuth(s.handleDrain))
+
+	s.httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return s
+}
+
+// Start starts serving in the background. ctx bounds operations that outlive
+// a single request, such as reconnections.
+func (s *Server) Start(ctx context.Context) error {
+	s.ctx = ctx
+
+	ln, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.httpServer.Addr, err)
+	}
+
+	go s.httpServer.Serve(ln)
+	return nil
+}
+
+// StartUnix starts serving on a unix socket at path in the background, for
+// CLI invocations on the same host. The socket is only accessible to the
+// owner. A stale socket left by a crashed process is replaced.
+func (s *Server) StartUnix(ctx context.Context, path string) error {
+	s.ctx = ctx
+
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return fmt.Errorf("another daemon is listening on %s", path)
+		}
+		os.Remove(path)
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+
+	go s.httpServer.Serve(ln)
+	return nil
+}
+
+// Stop gracefully shuts down the server
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
+
+// SetApply enables POST /apply, which runs apply
+func (s *Server) SetApply(apply ApplyFunc) {
+	s.apply = apply
+}
+
+// startRequest is the body of POST /streams
+type startRequest struct {
+	URL  string `json:"url"`
+	Name string `json:"name"`
+	Port int    `json:"port"`
+
+	AudioOnly bool   `json:"audio_only"`
+	Loop      bool   `json:"loop"`
+	Transport string `json:"transport"`
+	OnDemand  bool   `json:"on_demand"`
+	NoWait    bool   `json:"no_wait,omitempty"`
+	Format    string `json:"format,omitempty"` // yt-dlp format selector
+	// Profile is a quality shorthand such as "720p" or "audio", as start
+	// --quality takes it; Format overrides it
+	Profile string `json:"profile,omitempty"`
+
+	MaxBitrate int64    `json:"max_bitrate,omitempty"` // bits/s
+	Outputs    []string `json:"outputs,omitempty"`
+	Substream  string   `json:"substream,omitempty"` // e.g. 640x360@15
+
+	RequireH264 bool `json:"require_h264,omitempty"`
+	AudioCopy   bool `json:"audio_copy,omitempty"`
+}
+
+// logLevelRequest is the body of PUT /streams/{name}/log-level. An empty
+// level restores logging.level.
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// applyResponse is the body returned by POST /apply. Error is set if some
+// of the changes failed; Output describes all of them.
+type applyResponse struct {
+	Output string `json:"output"`
+	Error  string `json:"error,omitempty"`
+}
+
+// errorResponse is the body returned for failed requests
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleListStreams(w http.ResponseWriter, r *http.Request) {
+	infos := s.manager.List()
+	if infos == nil {
+		infos = []stream.Info{}
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (s *Server) handleStartStream(w http.ResponseWriter, r *http.Request) {
+	var req startRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if req.URL == "" {
+		writeError(w, http.StatusBadRequest, errors.New("url is required"))
+		return
+	}
+
+	format := req.Format
+	if format == "" && req.Profile != "" {
+		var err error
+		if format, err = extractor.QualityFormat(req.Profile); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if strings.EqualFold(req.Profile, "audio") {
+			req.AudioOnly = true
+		}
+	}
+
+	opts := stream.StartOptions{AudioOnly: req.AudioOnly, Loop: req.Loop, Transport: req.Transport, NoWait: req.NoWait, Format: format, MaxBitrate: req.MaxBitrate, Outputs: req.Outputs, Substream: req.Substream, RequireH264: req.RequireH264, AudioCopy: req.AudioCopy}
+	if req.Name == "" {
+		// Named after the video title; a failed extraction fails the start
+		req.Name, _ = s.manager.NameFor(r.Context(), req.URL, opts)
+	}
+	var err error
+	if req.OnDemand {
+		err = s.manager.StartOnDemand(req.URL, req.Name, req.Port, opts)
+	} else {
+		err = s.manager.Start(r.Context(), req.URL, req.Name, req.Port, opts)
+	}
+	if errors.Is(err, stream.ErrInvalidName) {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, stream.ErrDraining) {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	info, err := s.manager.Status(req.Name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, info)
+}
+
+func (s *Server) handleStreamStatus(w http.ResponseWriter, r *http.Request) {
+	info, err := s.manager.Status(r.PathValue("name"))
+	if err != nil {
+		writeError(w, statusForError(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) handleStopStream(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := s.manager.Stop(name); err != nil {
+		writeError(w, statusForError(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleReconnect(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if r.URL.Query().Get("wait") == "" {
+		if err := s.monitor.ForceReconnect(s.ctx, name, nil); err != nil {
+			writeError(w, statusForError(err), err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "reconnecting"})
+		return
+	}
+
+	// Stream the progress as JSON lines until the reconnect is done. The
+	// reconnect goes on if the client leaves.
+	progress := make(chan monitor.ReconnectProgress)
+	report := func(p monitor.ReconnectProgress) {
+		select {
+		case progress <- p:
+		case <-r.Context().Done():
+		}
+	}
+	if err := s.monitor.ForceReconnect(s.ctx, name, report); err != nil {
+		writeError(w, statusForError(err), err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case p := <-progress:
+			if err := enc.Encode(p); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			if p.Done {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if _, err := s.manager.Status(name); err != nil {
+		writeError(w, statusForError(err), err)
+		return
+	}
+
+	lines := 50
+	if v := r.URL.Query().Get("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid lines value: %s", v))
+			return
+		}
+		lines = n
+	}
+
+	logLines, err := s.manager.GetLoggerManager().GetLogger(name).ReadLast(lines)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"name": name, "lines": logLines})
+}
+
+func (s *Server) handleSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	var req logLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if req.Level != "" {
+		if _, err := logger.ParseLevel(req.Level); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := s.manager.SetLogLevel(name, req.Level); err != nil {
+		writeError(w, statusForError(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"name": name, "level": req.Level})
+}
+
+func (s *Server) handleApply(w http.ResponseWriter, r *http.Request) {
+	if s.apply == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("apply is not supported by this server"))
+		return
+	}
+
+	var output bytes.Buffer
+	resp := applyResponse{}
+	force := r.URL.Query().Get("force") == "1"
+	if err := s.apply(r.Context(), &output, force); err != nil {
+		resp.Error = err.Error()
+	}
+	resp.Output = output.String()
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	s.manager.Drain()
+	writeJSON(w, http.StatusOK, map[string]string{"status": "draining"})
+}
+
+// requireAuth wraps a handler with bearer token authentication
+func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+				writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// statusForError maps manager errors to HTTP status codes
+func statusForError(err error) int {
+	if errors.Is(err, stream.ErrStreamNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
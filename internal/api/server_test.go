@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/monitor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+)
+
+// newTestServer returns the handler of a control API over an empty data dir
+func newTestServer(t *testing.T) http.Handler {
+	t.Helper()
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("storage:\n  data_dir: "+dir+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := storage.NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	manager := stream.NewManager(cfg, nil, nil, store, log)
+	t.Cleanup(manager.GetLoggerManager().CloseAll)
+	return NewServer("", "", manager, monitor.NewMonitor(&cfg.Monitor, manager, nil, log)).httpServer.Handler
+}
+
+func TestReconnectUnknownStream(t *testing.T) {
+	ts := httptest.NewServer(newTestServer(t))
+	defer ts.Close()
+
+	for _, path := range []string{"/streams/missing/reconnect", "/streams/missing/reconnect?wait=1"} {
+		resp, err := http.Post(ts.URL+path, "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body errorResponse
+		json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("POST %s: status code %d, want %d", path, resp.StatusCode, http.StatusNotFound)
+		}
+		if !strings.Contains(body.Error, "not found") {
+			t.Errorf("POST %s: error %q, want a not found error", path, body.Error)
+		}
+	}
+}
+
+func TestDrainRejectsStarts(t *testing.T) {
+	ts := httptest.NewServer(newTestServer(t))
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/drain", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /drain: status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	for _, body := range []string{
+		`{"url": "https://youtu.be/abc123", "name": "news"}`,
+		`{"url": "https://youtu.be/abc123", "name": "news", "on_demand": true}`,
+	} {
+		resp, err := http.Post(ts.URL+"/streams", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got errorResponse
+		json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusServiceUnavailable || !strings.Contains(got.Error, "draining") {
+			t.Errorf("POST /streams %s while draining: status code %d, error %q; want %d and a draining error",
+				body, resp.StatusCode, got.Error, http.StatusServiceUnavailable)
+		}
+	}
+}
+
+func TestStartRejectsInvalidName(t *testing.T) {
+	ts := httptest.NewServer(newTestServer(t))
+	defer ts.Close()
+
+	for _, body := range []string{
+		`{"url": "https://youtu.be/abc123", "name": "../x"}`,
+		`{"url": "https://youtu.be/abc123", "name": "../x", "on_demand": true}`,
+	} {
+		resp, err := http.Post(ts.URL+"/streams", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got errorResponse
+		json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest || !strings.Contains(got.Error, "invalid stream name") {
+			t.Errorf("POST /streams %s: status code %d, error %q; want %d and an invalid name error",
+				body, resp.StatusCode, got.Error, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/reconcile"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+)
+
+var (
+	applyDryRun bool
+	applyForce  bool
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Reconcile running streams with the config file",
+	Long: `Re-read the streams section of the config file and reconcile the
+running streams with it: declared streams are started, streams whose URL
+or options changed are restarted, and streams previously started from the
+config but no longer declared are removed. Streams started by hand are
+left alone, and declared streams stopped by hand stay stopped unless
+--force is given.
+
+If the foreground server is running, it applies the changes itself. The
+foreground server also applies them on SIGHUP.
+
+Examples:
+  youtube-rtsp-proxy apply
+  youtube-rtsp-proxy apply --dry-run
+  youtube-rtsp-proxy apply --force`,
+	Args: cobra.NoArgs,
+	RunE: runApply,
+}
+
+func init() {
+	applyCmd.Flags().BoolVar(&applyDryRun, "dry-run", false, "show the plan without changing anything (same as server plan)")
+	applyCmd.Flags().BoolVar(&applyForce, "force", false, "also start declared streams that were stopped by hand")
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	if applyDryRun {
+		return runServerPlan(cmd, args)
+	}
+
+	if daemon := connectDaemon(); daemon != nil {
+		fmt.Println("Applying declared streams in the running daemon...")
+		output, err := daemon.Apply(applyForce)
+		fmt.Print(output)
+		if err != nil {
+			return fmt.Errorf("failed to apply some changes: %w", err)
+		}
+		return nil
+	}
+
+	if err := checkDependencies(); err != nil {
+		return fmt.Errorf("dependency check failed:\n  %v", err)
+	}
+	if !srv.IsRunning() {
+		fmt.Println("Starting MediaMTX server...")
+		if err := srv.Start(getContext()); err != nil {
+			return fmt.Errorf("failed to start MediaMTX: %w", err)
+		}
+		manager.SyncOnDemandPaths()
+	}
+
+	if err := applyDeclared(getContext(), os.Stdout, applyForce); err != nil {
+		return fmt.Errorf("failed to apply some changes: %w", err)
+	}
+	return nil
+}
+
+// applyMu serializes reconciliations triggered by SIGHUP and the API
+var applyMu sync.Mutex
+
+// applyDeclared re-reads the streams section of the config file and
+// reconciles the running streams with it. Other settings only take effect
+// on restart. With force, declared streams stopped by hand are started.
+func applyDeclared(ctx context.Context, w io.Writer, force bool) error {
+	applyMu.Lock()
+	defer applyMu.Unlock()
+
+	loaded, err := config.Load(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+	cfg.Streams = loaded.Streams
+
+	plan := reconcile.Compute(cfg.Streams, knownStreams(), cfg.Server.RTSPPort, force)
+	if !plan.HasChanges() {
+		fmt.Fprintln(w, "No changes.")
+		return nil
+	}
+	return plan.Apply(ctx, manager, w)
+}
+
+// knownStreams returns the streams to reconcile: the running ones and the
+// stopped ones, which are only kept in storage
+func knownStreams() []stream.Info {
+	return append(manager.List(), manager.ListStopped()...)
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+)
+
+var (
+	cleanupOrphans bool
+	cleanupYes     bool
+)
+
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Remove stale stream entries and orphaned ffmpeg processes",
+	Long: `Remove the entries of streams that should be running but whose ffmpeg
+process is gone, and report them. Stopped streams, on-demand streams and
+streams waiting to reconnect are kept. Other commands never remove
+entries: they show a stream whose process is gone as reconnecting, for a
+running server's monitor to restart.
+
+With --orphans, also find ffmpeg processes publishing to the MediaMTX RTSP
+port that belong to no known stream, e.g. because the stream's files in the
+data directory were deleted, and offer to kill them.
+
+Examples:
+  youtube-rtsp-proxy cleanup
+  youtube-rtsp-proxy cleanup --orphans
+  youtube-rtsp-proxy cleanup --orphans --yes`,
+	Args: cobra.NoArgs,
+	RunE: runCleanup,
+}
+
+func init() {
+	cleanupCmd.Flags().BoolVar(&cleanupOrphans, "orphans", false, "find ffmpeg processes that belong to no stream")
+	cleanupCmd.Flags().BoolVarP(&cleanupYes, "yes", "y", false, "kill orphans without asking")
+}
+
+func runCleanup(cmd *cobra.Command, args []string) error {
+	removed, err := manager.CleanupStorage()
+	for _, name := range removed {
+		fmt.Printf("Removed stale stream entry '%s'\n", name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to clean up stream entries: %w", err)
+	}
+	if len(removed) == 0 {
+		fmt.Println("No stale stream entries found.")
+	}
+
+	if !cleanupOrphans {
+		return nil
+	}
+
+	orphans, err := manager.FindOrphans()
+	if err != nil {
+		return fmt.Errorf("failed to list processes: %w", err)
+	}
+	if len(orphans) == 0 {
+		fmt.Println("No orphaned ffmpeg processes found.")
+		return nil
+	}
+
+	fmt.Printf("Found %d orphaned ffmpeg process(es):\n\n", len(orphans))
+	for _, o := range orphans {
+		fmt.Printf("  PID %-8d %s\n", o.PID, o.Path)
+		printVerbose("    %s\n", strings.Join(stream.RedactHeaders(o.Args), " "))
+	}
+	fmt.Println()
+
+	if !cleanupYes {
+		answer, err := PromptInput("Kill them? [y/N]: ")
+		if err != nil {
+			return err
+		}
+		if answer != "y" && answer != "Y" {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	for _, o := range orphans {
+		stream.KillByPID(o.PID, o.StartTime, o.Target)
+		fmt.Printf("  Killed PID %d (%s)\n", o.PID, o.Path)
+	}
+	return nil
+}
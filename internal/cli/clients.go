@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/server"
+)
+
+var clientsKick string
+
+var clientsCmd = &cobra.Command{
+	Use:   "clients <stream-name>",
+	Short: "List or disconnect clients reading a stream",
+	Long: `List the RTSP client sessions reading a stream from MediaMTX.
+
+Examples:
+  youtube-rtsp-proxy clients lofi
+  youtube-rtsp-proxy clients lofi --kick 8c8b3a4e-1f2d-4c5b-9a6e-2f1d0c9b8a7e`,
+	Args: cobra.ExactArgs(1),
+	RunE: runClients,
+}
+
+func init() {
+	clientsCmd.Flags().StringVar(&clientsKick, "kick", "", "disconnect the client with this session ID")
+}
+
+func runClients(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	info, err := manager.Status(name)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := streamReaders(info.RTSPPath)
+	if err != nil {
+		return err
+	}
+
+	if clientsKick != "" {
+		return kickClient(name, sessions, clientsKick)
+	}
+
+	fmt.Println()
+	fmt.Printf("Clients of stream: %s\n", name)
+	fmt.Println("══════════════════════════════════════════════════════════════")
+
+	if len(sessions) == 0 {
+		fmt.Println()
+		fmt.Println("  No clients connected")
+		fmt.Println()
+		fmt.Println("══════════════════════════════════════════════════════════════")
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Printf("  %-36s  %-10s  %-21s  %s\n", "SESSION", "PROTOCOL", "REMOTE", "SENT")
+	for _, sess := range sessions {
+		fmt.Printf("  %-36s  %-10s  %-21s  %s\n", sess.ID, sessionProtocol(sess), sess.RemoteAddr, formatBytes(sess.BytesSent))
+	}
+	fmt.Println()
+	fmt.Println("══════════════════════════════════════════════════════════════")
+
+	return nil
+}
+
+// streamReaders returns the RTSP sessions reading the given path
+func streamReaders(rtspPath string) ([]server.RTSPSession, error) {
+	all, err := srv.ListRTSPSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	path := strings.TrimPrefix(rtspPath, "/")
+	var sessions []server.RTSPSession
+	for _, sess := range all {
+		if sess.Path == path && sess.State == "read" {
+			sessions = append(sessions, sess)
+		}
+	}
+	return sessions, nil
+}
+
+// kickClient disconnects a session, refusing sessions of other streams
+func kickClient(name string, sessions []server.RTSPSession, id string) error {
+	for _, sess := range sessions {
+		if sess.ID != id {
+			continue
+		}
+		if err := srv.KickRTSPSession(id); err != nil {
+			return err
+		}
+		fmt.Printf("Disconnected client %s (%s) from '%s'.\n", id, sess.RemoteAddr, name)
+		return nil
+	}
+	return fmt.Errorf("no client with session ID %s is reading '%s'", id, name)
+}
+
+// sessionProtocol describes how a session receives the stream
+func sessionProtocol(sess server.RTSPSession) string {
+	if sess.Transport == "" {
+		return "rtsp"
+	}
+	return "rtsp/" + strings.ToLower(sess.Transport)
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+)
+
+var (
+	configInitPath  string
+	configInitForce bool
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config <init|show|validate>",
+	Short: "Manage the configuration file",
+	Long: `Manage the configuration file.
+
+Commands:
+  init - Write a commented config file with all default values
+  show - Print the effective configuration
+  validate - Check the configuration, listing every problem
+
+Examples:
+  youtube-rtsp-proxy config init
+  youtube-rtsp-proxy config init --path ./config.yaml --force
+  youtube-rtsp-proxy config show -v
+  youtube-rtsp-proxy config validate -c ./config.yaml`,
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Generate a commented default config file",
+	RunE:  runConfigInit,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration",
+	Long: `Print the effective configuration: the defaults merged with the config
+file and YTRTSP_* environment variables. Tokens and passwords are masked.
+With --verbose, each value is annotated with where it came from (default,
+file or env).`,
+	Args: cobra.NoArgs,
+	RunE: runConfigShow,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration for errors",
+	Long: `Check the effective configuration (defaults + config file + environment)
+and list every problem with the key it concerns, such as out-of-range or
+conflicting ports, durations without a unit and empty binary paths. Exits
+non-zero if the configuration is invalid.`,
+	Args: cobra.NoArgs,
+	RunE: runConfigValidate,
+}
+
+func init() {
+	configInitCmd.Flags().StringVar(&configInitPath, "path", "", "output path (default: ~/.youtube-rtsp-proxy/config.yaml)")
+	configInitCmd.Flags().BoolVar(&configInitForce, "force", false, "overwrite an existing file")
+
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configValidateCmd)
+}
+
+func runConfigInit(cmd *cobra.Command, args []string) error {
+	path := configInitPath
+	if path == "" {
+		var err error
+		path, err = config.DefaultConfigPath()
+		if err != nil {
+			return fmt.Errorf("failed to resolve default config path: %w", err)
+		}
+	}
+
+	if _, err := os.Stat(path); err == nil && !configInitForce {
+		return fmt.Errorf("%s already exists (use --force to overwrite)", path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, config.GenerateDefault(), 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	fmt.Printf("Config file written: %s\n", path)
+	return nil
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	c, sources, err := config.LoadWithSources(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !verbose {
+		sources = nil
+	}
+
+	os.Stdout.Write(config.Show(c, sources))
+	return nil
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	_, sources, err := config.LoadWithSources(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	if file := sources.File(); file != "" {
+		fmt.Printf("Configuration is valid: %s\n", file)
+	} else {
+		fmt.Println("Configuration is valid (no config file found, defaults and environment only)")
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/api"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+)
+
+// connectDaemon returns a client for the daemon started with
+// "server start --foreground", or nil if none is running and the command
+// should do its work in-process
+func connectDaemon() *api.Client {
+	path := cfg.GetDaemonSocketPath()
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+
+	client := api.NewUnixClient(path)
+	if err := client.Ping(); err != nil {
+		printVerbose("Ignoring stale daemon socket %s: %v\n", path, err)
+		return nil
+	}
+	return client
+}
+
+// listStreams lists streams from the daemon if one is running
+func listStreams() ([]stream.Info, error) {
+	if daemon := connectDaemon(); daemon != nil {
+		return daemon.List()
+	}
+	return manager.List(), nil
+}
+
+// streamStatus returns a stream from the daemon if one is running
+func streamStatus(name string) (*stream.Info, error) {
+	if daemon := connectDaemon(); daemon != nil {
+		return daemon.Status(name)
+	}
+	return manager.Status(name)
+}
@@ -0,0 +1,238 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Validate the environment",
+	Long: `Check that all dependencies, ports, and directories are usable.
+
+Reports the detected yt-dlp, ffmpeg, and mediamtx versions and checks them
+against the oldest versions known to work, whether the configured RTSP and
+API ports are free, whether the data directory is writable, and whether
+YouTube can be reached over HTTPS. Exits with a non-zero status listing the
+failed checks if any fail, for use in healthchecks and install scripts.
+
+Examples:
+  youtube-rtsp-proxy doctor
+  youtube-rtsp-proxy doctor --offline`,
+	SilenceUsage: true,
+	RunE:         runDoctor,
+}
+
+var doctorOffline bool
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorOffline, "offline", false, "skip the YouTube connectivity check")
+}
+
+// minimumVersions are the oldest versions known to work. YouTube changes
+// regularly break older yt-dlp releases; MediaMTX 1.0 introduced the v3 API.
+var minimumVersions = map[string]string{
+	"yt-dlp":   "2024.12.23",
+	"ffmpeg":   "4.0",
+	"mediamtx": "1.0.0",
+}
+
+// youtubeCheckURL is requested to check that YouTube is reachable
+const youtubeCheckURL = "https://www.youtube.com/"
+
+// doctorCheck is the result of a single doctor check
+type doctorCheck struct {
+	Name   string
+	Detail string
+	Err    error
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	var checks []doctorCheck
+
+	// Dependencies
+	version, err := ytdlpExt.Version()
+	checks = append(checks, versionCheck("yt-dlp", version, err))
+
+	version, err = stream.NewFFmpegManager(&cfg.FFmpeg).CheckBinary()
+	checks = append(checks, versionCheck("ffmpeg", version, err))
+
+	version, err = srv.CheckBinary()
+	checks = append(checks, versionCheck("mediamtx", version, err))
+
+	// Ports (in use by our own MediaMTX is fine)
+	mediamtxRunning := srv.IsRunning()
+	for _, p := range []struct {
+		name    string
+		address string
+		port    int
+	}{
+		{"RTSP port", cfg.Server.RTSPAddress, cfg.Server.RTSPPort},
+		{"API port", cfg.Server.APIAddress, cfg.Server.APIPort},
+	} {
+		check := doctorCheck{Name: p.name, Detail: config.ListenAddress(p.address, p.port)}
+		if mediamtxRunning {
+			check.Detail += " (in use by MediaMTX)"
+		} else {
+			check.Err = checkPortAvailable(p.address, p.port)
+		}
+		checks = append(checks, check)
+	}
+
+	// Data directory
+	checks = append(checks, doctorCheck{
+		Name:   "Data dir",
+		Detail: cfg.Storage.DataDir,
+		Err:    checkDirWritable(cfg.Storage.DataDir),
+	})
+
+	// Network
+	if !doctorOffline {
+		checks = append(checks, doctorCheck{
+			Name:   "YouTube",
+			Detail: youtubeCheckURL,
+			Err:    checkHTTPS(youtubeCheckURL),
+		})
+	}
+
+	// Report
+	fmt.Println()
+	fmt.Println("Environment Check")
+	fmt.Println("══════════════════════════════════════════════════════════════")
+
+	var failed []string
+	for _, c := range checks {
+		if c.Err != nil {
+			failed = append(failed, c.Name)
+			fmt.Printf("  ✗ %-10s %v\n", c.Name, c.Err)
+		} else {
+			fmt.Printf("  ✓ %-10s %s\n", c.Name, c.Detail)
+		}
+	}
+
+	fmt.Println("══════════════════════════════════════════════════════════════")
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d check(s) failed: %s", len(failed), strings.Join(failed, ", "))
+	}
+
+	fmt.Println("All checks passed.")
+	return nil
+}
+
+// checkPortAvailable verifies that a TCP port can be bound on address
+// (empty = all interfaces)
+func checkPortAvailable(address string, port int) error {
+	ln, err := net.Listen("tcp", config.ListenAddress(address, port))
+	if err != nil {
+		return fmt.Errorf("port %d is not available: %w", port, err)
+	}
+	return ln.Close()
+}
+
+// checkDirWritable verifies that a directory exists (creating it if needed)
+// and that files can be written to it
+func checkDirWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create %s: %w", dir, err)
+	}
+
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
+// versionCheck reports a binary's version, failing if it could not be run
+// or is older than its minimum. Versions that cannot be parsed, such as
+// ffmpeg git builds, pass without comparison.
+func versionCheck(name, version string, err error) doctorCheck {
+	check := doctorCheck{Name: name, Detail: version, Err: err}
+	if err != nil {
+		return check
+	}
+
+	minimum := minimumVersions[name]
+	cmp, ok := compareVersions(version, minimum)
+	switch {
+	case !ok:
+		check.Detail += " (not compared with minimum " + minimum + ")"
+	case cmp < 0:
+		check.Err = fmt.Errorf("%s is older than the minimum %s", version, minimum)
+	}
+	return check
+}
+
+// compareVersions compares the leading dotted numbers of two versions,
+// ignoring a "v" or "n" prefix and anything after the numbers (e.g.
+// "6.1.1-3ubuntu5"). ok is false if either has no leading number.
+func compareVersions(a, b string) (cmp int, ok bool) {
+	pa, pb := versionNumbers(a), versionNumbers(b)
+	if len(pa) == 0 || len(pb) == 0 {
+		return 0, false
+	}
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, true
+}
+
+// versionNumbers returns the leading dotted numbers of a version
+func versionNumbers(version string) []int {
+	version = strings.TrimLeft(strings.TrimSpace(version), "vn")
+	var numbers []int
+	for _, part := range strings.Split(version, ".") {
+		end := 0
+		for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+			end++
+		}
+		if end == 0 {
+			break
+		}
+		n, err := strconv.Atoi(part[:end])
+		if err != nil {
+			break
+		}
+		numbers = append(numbers, n)
+		if end < len(part) {
+			break // e.g. "1-3ubuntu5"
+		}
+	}
+	return numbers
+}
+
+// checkHTTPS verifies that url answers over HTTPS. Any HTTP response will
+// do; only connection and TLS failures count.
+func checkHTTPS(url string) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Head(url)
+	if err != nil {
+		return fmt.Errorf("cannot reach %s: %w", url, err)
+	}
+	resp.Body.Close()
+	return nil
+}
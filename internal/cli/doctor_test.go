@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckPortAvailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	if err := checkPortAvailable("127.0.0.1", port); err == nil {
+		t.Errorf("port %d in use: checkPortAvailable passed, want an error", port)
+	}
+	ln.Close()
+	if err := checkPortAvailable("127.0.0.1", port); err != nil {
+		t.Errorf("port %d free: %v", port, err)
+	}
+	// The check must not keep the port
+	if err := checkPortAvailable("127.0.0.1", port); err != nil {
+		t.Errorf("port %d after a check: %v", port, err)
+	}
+}
+
+func TestCheckDirWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := checkDirWritable(dir); err != nil {
+		t.Errorf("existing dir: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("check left %d files behind", len(entries))
+	}
+
+	missing := filepath.Join(dir, "data", "streams")
+	if err := checkDirWritable(missing); err != nil {
+		t.Errorf("missing dir: %v", err)
+	}
+	if info, err := os.Stat(missing); err != nil || !info.IsDir() {
+		t.Errorf("missing dir was not created: %v", err)
+	}
+
+	// A path below a file can be neither created nor written, even by root
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkDirWritable(filepath.Join(file, "data")); err == nil {
+		t.Error("dir below a file: checkDirWritable passed, want an error")
+	}
+}
+
+func TestVersionCheck(t *testing.T) {
+	tests := []struct {
+		name, version string
+		err           error
+		wantErr       bool
+	}{
+		{"yt-dlp", "2025.01.15", nil, false},
+		{"yt-dlp", "2024.12.23", nil, false},
+		{"yt-dlp", "2023.07.06", nil, true},
+		{"ffmpeg", "6.1.1-3ubuntu5", nil, false},
+		{"ffmpeg", "n7.1", nil, false},
+		{"ffmpeg", "3.4.8", nil, true},
+		{"ffmpeg", "N-118049-g4cbdb5b", nil, false}, // git build, not compared
+		{"mediamtx", "v1.9.3", nil, false},
+		{"mediamtx", "v0.23.8", nil, true},
+		{"mediamtx", "", errors.New("mediamtx not found"), true},
+	}
+	for _, tt := range tests {
+		check := versionCheck(tt.name, tt.version, tt.err)
+		if (check.Err != nil) != tt.wantErr {
+			t.Errorf("versionCheck(%s, %q) error = %v, want error %v", tt.name, tt.version, check.Err, tt.wantErr)
+		}
+	}
+}
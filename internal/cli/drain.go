@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+)
+
+var drainCmd = &cobra.Command{
+	Use:   "drain",
+	Short: "Stop accepting new streams, keeping running ones",
+	Long: `Put the foreground server in drain mode for a rolling restart.
+
+New streams are rejected with "manager draining" while running streams
+keep going, reconnecting as usual, until they are stopped or the server
+shuts down. /readyz of the health probes reports not ready while draining.
+Sending SIGUSR1 to the foreground server does the same.
+
+Examples:
+  youtube-rtsp-proxy drain
+  kill -USR1 <server-pid>`,
+	Args: cobra.NoArgs,
+	RunE: runDrain,
+}
+
+func runDrain(cmd *cobra.Command, args []string) error {
+	daemon := connectDaemon()
+	if daemon == nil {
+		return fmt.Errorf("no server is running in the foreground (server start --foreground)")
+	}
+
+	if err := daemon.Drain(); err != nil {
+		return fmt.Errorf("failed to drain: %w", err)
+	}
+
+	fmt.Println("Draining: new streams are rejected, running streams keep going.")
+	return nil
+}
+
+// checkNotDraining fails the readiness probe while the manager is draining
+func checkNotDraining() error {
+	if manager.IsDraining() {
+		return stream.ErrDraining
+	}
+	return nil
+}
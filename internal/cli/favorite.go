@@ -2,13 +2,15 @@ package cli
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
 )
 
-var favStore *storage.FavoritesStorage
+var favStore storage.Favorites
 
 var favCmd = &cobra.Command{
 	Use:     "fav",
@@ -19,8 +21,11 @@ var favCmd = &cobra.Command{
 Examples:
   youtube-rtsp-proxy fav                                                    # Interactive mode
   youtube-rtsp-proxy fav add "https://www.youtube.com/watch?v=jfKfPfyJRdk" --name lofi
+  youtube-rtsp-proxy fav add "https://www.youtube.com/live/xyz" --name news --autostart
+  youtube-rtsp-proxy fav set-autostart lofi on
   youtube-rtsp-proxy fav list
   youtube-rtsp-proxy fav start lofi
+  youtube-rtsp-proxy fav run lofi news
   youtube-rtsp-proxy fav remove lofi`,
 	RunE: runFavInteractive,
 }
@@ -54,18 +59,68 @@ var favStartCmd = &cobra.Command{
 	RunE:  runFavStart,
 }
 
-var favName string
+var favRunCmd = &cobra.Command{
+	Use:   "run <name>... | --all",
+	Short: "Start several favorites at once",
+	Long: `Start the named favorites, or all of them with --all, and keep them
+monitored until Ctrl+C, as "fav start" does for one. A favorite that fails
+to start is reported and the others are started anyway; the command then
+exits with an error once stopped.
+
+Examples:
+  youtube-rtsp-proxy fav run lofi news
+  youtube-rtsp-proxy fav run --all`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if favRunAll && len(args) > 0 {
+			return fmt.Errorf("give favorite names or --all, not both")
+		}
+		if !favRunAll && len(args) == 0 {
+			return fmt.Errorf("requires at least one favorite name, or --all")
+		}
+		return nil
+	},
+	SilenceUsage: true,
+	RunE:         runFavRun,
+}
+
+var favSetAutoStartCmd = &cobra.Command{
+	Use:   "set-autostart <name> <on|off>",
+	Short: "Set whether a favorite is started by server start",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runFavSetAutoStart,
+}
+
+var (
+	favName      string
+	favAudioOnly bool
+	favAutoStart bool
+	favQuality   string
+	favFormat    string
+	favRunAll    bool
+)
 
 func init() {
 	favAddCmd.Flags().StringVarP(&favName, "name", "n", "", "name for the favorite (required)")
 	favAddCmd.MarkFlagRequired("name")
+	favAddCmd.Flags().BoolVar(&favAudioOnly, "audio-only", false, "proxy the audio track only when started")
+	favAddCmd.Flags().BoolVar(&favAutoStart, "autostart", false, "start this favorite when the server starts in the foreground")
+	favAddCmd.Flags().StringVar(&favQuality, "quality", "", qualityUsage)
+	favAddCmd.Flags().StringVar(&favFormat, "format", "", "yt-dlp format selector, overriding --quality (default: ytdlp.format)")
 
 	favStartCmd.Flags().IntVarP(&streamPort, "port", "p", 0, "RTSP port (default: from config)")
+	favRunCmd.Flags().BoolVar(&favRunAll, "all", false, "start all favorites")
 
 	favCmd.AddCommand(favAddCmd)
 	favCmd.AddCommand(favListCmd)
 	favCmd.AddCommand(favRemoveCmd)
 	favCmd.AddCommand(favStartCmd)
+	favCmd.AddCommand(favRunCmd)
+	favCmd.AddCommand(favSetAutoStartCmd)
+}
+
+// favoriteStartOptions returns the stream options saved with a favorite
+func favoriteStartOptions(fav *storage.Favorite) stream.StartOptions {
+	return stream.StartOptions{AudioOnly: fav.AudioOnly, Format: fav.Format}
 }
 
 func initFavStore() error {
@@ -74,7 +129,7 @@ func initFavStore() error {
 	}
 
 	var err error
-	favStore, err = storage.NewFavoritesStorage(cfg.Storage.DataDir)
+	favStore, err = storage.OpenFavorites(cfg.Storage.Backend, cfg.Storage.DataDir)
 	if err != nil {
 		return fmt.Errorf("failed to initialize favorites storage: %w", err)
 	}
@@ -87,13 +142,60 @@ func runFavAdd(cmd *cobra.Command, args []string) error {
 	}
 
 	url := args[0]
+	format, err := ytdlpFormat(favFormat, favQuality)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(favQuality, "audio") && favFormat == "" {
+		favAudioOnly = true
+	}
 
-	if err := favStore.Add(favName, url); err != nil {
+	fav := &storage.Favorite{
+		Name:      favName,
+		URL:       url,
+		AudioOnly: favAudioOnly,
+		AutoStart: favAutoStart,
+		Format:    format,
+	}
+	if err := favStore.Add(fav); err != nil {
 		return err
 	}
 
 	fmt.Printf("Added favorite '%s'\n", favName)
 	fmt.Printf("  URL: %s\n", url)
+	if favAudioOnly {
+		fmt.Println("  Mode: audio only")
+	}
+	if format != "" {
+		fmt.Printf("  Format: %s\n", format)
+	}
+	if favAutoStart {
+		fmt.Println("  Autostart: on")
+	}
+	return nil
+}
+
+func runFavSetAutoStart(cmd *cobra.Command, args []string) error {
+	if err := initFavStore(); err != nil {
+		return err
+	}
+
+	name := args[0]
+	var autoStart bool
+	switch args[1] {
+	case "on":
+		autoStart = true
+	case "off":
+		autoStart = false
+	default:
+		return fmt.Errorf("invalid value %q: must be on or off", args[1])
+	}
+
+	if err := favStore.SetAutoStart(name, autoStart); err != nil {
+		return err
+	}
+
+	fmt.Printf("Autostart for favorite '%s' is now %s\n", name, args[1])
 	return nil
 }
 
@@ -118,6 +220,15 @@ func runFavList(cmd *cobra.Command, args []string) error {
 	for _, fav := range favorites {
 		fmt.Printf("  %s\n", fav.Name)
 		fmt.Printf("    URL: %s\n", fav.URL)
+		if fav.AudioOnly {
+			fmt.Println("    Mode: audio only")
+		}
+		if fav.Format != "" {
+			fmt.Printf("    Format: %s\n", fav.Format)
+		}
+		if fav.AutoStart {
+			fmt.Println("    Autostart: on")
+		}
 		fmt.Printf("    Created: %s\n", fav.CreatedAt.Format(time.RFC3339))
 		if !fav.LastUsed.IsZero() {
 			fmt.Printf("    Last used: %s\n", fav.LastUsed.Format(time.RFC3339))
@@ -169,6 +280,7 @@ func runFavStart(cmd *cobra.Command, args []string) error {
 		if err := srv.Start(getContext()); err != nil {
 			return fmt.Errorf("failed to start MediaMTX: %w", err)
 		}
+		manager.SyncOnDemandPaths()
 	}
 
 	// Start monitoring if not already running
@@ -185,14 +297,12 @@ func runFavStart(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Starting favorite '%s'...\n", name)
 	fmt.Printf("  URL: %s\n", fav.URL)
 
-	if err := manager.Start(getContext(), fav.URL, name, port); err != nil {
-		return fmt.Errorf("failed to start stream: %w", err)
+	if err := manager.Start(getContext(), fav.URL, name, port, favoriteStartOptions(fav)); err != nil {
+		return withHint(fmt.Errorf("failed to start stream: %w", err))
 	}
 
-	// Get local IP for display
-	localIP := getLocalIP()
 	fmt.Printf("\nStream started!\n")
-	fmt.Printf("  RTSP URL: rtsp://%s:%d/%s\n", localIP, port, name)
+	fmt.Printf("  RTSP URL: %s\n", networkRTSPURL(port, name))
 
 	// Stay in foreground to keep monitor alive for auto-reconnection
 	fmt.Println("\nPress Ctrl+C to stop and exit.")
@@ -206,6 +316,70 @@ func runFavStart(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runFavRun(cmd *cobra.Command, args []string) error {
+	if err := initFavStore(); err != nil {
+		return err
+	}
+
+	names := args
+	if favRunAll {
+		favorites, err := favStore.List()
+		if err != nil {
+			return err
+		}
+		if len(favorites) == 0 {
+			fmt.Println("No favorites saved yet.")
+			return nil
+		}
+		names = make([]string, len(favorites))
+		for i, fav := range favorites {
+			names[i] = fav.Name
+		}
+	}
+
+	started, failed := startEach(names, runFavStartByName)
+	if len(started) == 0 {
+		return fmt.Errorf("no favorite could be started")
+	}
+
+	// Stay in foreground to keep monitor alive for auto-reconnection
+	fmt.Println("\nPress Ctrl+C to stop and exit.")
+	ctx := getContext()
+	<-ctx.Done()
+
+	fmt.Println("\nShutting down...")
+	for _, name := range started {
+		manager.Stop(name)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d favorite(s) failed to start: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// startEach starts each of names with start, one after another so the
+// output of each stays together, reporting the ones that fail and carrying
+// on with the rest
+func startEach(names []string, start func(name string) error) (started, failed []string) {
+	for _, name := range names {
+		if err := start(name); err != nil {
+			fmt.Printf("Failed to start '%s': %v\n", name, err)
+			failed = append(failed, name)
+		} else {
+			started = append(started, name)
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("Started %d of %d favorite(s)", len(started), len(names))
+	if len(failed) > 0 {
+		fmt.Printf(", failed: %s", strings.Join(failed, ", "))
+	}
+	fmt.Println()
+	return started, failed
+}
+
 // runFavInteractive provides interactive favorite selection with start/stop toggle
 func runFavInteractive(cmd *cobra.Command, args []string) error {
 	if err := initFavStore(); err != nil {
@@ -311,7 +485,7 @@ func runFavInteractiveAdd() error {
 		return nil
 	}
 
-	if err := favStore.Add(name, url); err != nil {
+	if err := favStore.Add(&storage.Favorite{Name: name, URL: url}); err != nil {
 		return err
 	}
 
@@ -351,6 +525,7 @@ func runFavStartByName(name string) error {
 		if err := srv.Start(getContext()); err != nil {
 			return fmt.Errorf("failed to start MediaMTX: %w", err)
 		}
+		manager.SyncOnDemandPaths()
 	}
 
 	// Start monitoring if not already running
@@ -364,14 +539,12 @@ func runFavStartByName(name string) error {
 	fmt.Printf("Starting '%s'...\n", name)
 	fmt.Printf("  URL: %s\n", fav.URL)
 
-	if err := manager.Start(getContext(), fav.URL, name, port); err != nil {
-		return fmt.Errorf("failed to start stream: %w", err)
+	if err := manager.Start(getContext(), fav.URL, name, port, favoriteStartOptions(fav)); err != nil {
+		return withHint(fmt.Errorf("failed to start stream: %w", err))
 	}
 
-	// Get local IP for display
-	localIP := getLocalIP()
 	fmt.Printf("\nStream started!\n")
-	fmt.Printf("  RTSP URL: rtsp://%s:%d/%s\n", localIP, port, name)
+	fmt.Printf("  RTSP URL: %s\n", networkRTSPURL(port, name))
 
 	return nil
 }
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
+)
+
+// useFavorites points favStore at a new store holding favorites with names
+func useFavorites(t *testing.T, names ...string) {
+	t.Helper()
+	store, err := storage.OpenFavorites(storage.BackendFile, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if err := store.Add(&storage.Favorite{Name: name, URL: "https://youtu.be/" + name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	favStore = store
+	t.Cleanup(func() { favStore = nil })
+}
+
+func TestStartEachContinuesPastUnknownFavorite(t *testing.T) {
+	useFavorites(t, "lofi", "news")
+
+	// Starts the favorite as far as looking it up, as runFavStartByName does
+	var attempted []string
+	start := func(name string) error {
+		attempted = append(attempted, name)
+		_, err := favStore.Get(name)
+		return err
+	}
+	started, failed := startEach([]string{"lofi", "missing", "news"}, start)
+
+	if want := []string{"lofi", "missing", "news"}; !slices.Equal(attempted, want) {
+		t.Errorf("attempted %q, want %q", attempted, want)
+	}
+	if want := []string{"lofi", "news"}; !slices.Equal(started, want) {
+		t.Errorf("started %q, want %q", started, want)
+	}
+	if want := []string{"missing"}; !slices.Equal(failed, want) {
+		t.Errorf("failed %q, want %q", failed, want)
+	}
+}
+
+func TestFavStartByNameUnknown(t *testing.T) {
+	useFavorites(t, "lofi")
+
+	// Fails before touching the server, which is not set up here
+	err := runFavStartByName("missing")
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("runFavStartByName(missing) = %v, want an error naming it", err)
+	}
+}
+
+func TestFavRunNoneStarted(t *testing.T) {
+	useFavorites(t, "lofi")
+
+	// Returns instead of waiting in the foreground with nothing started
+	err := runFavRun(favRunCmd, []string{"missing", "gone"})
+	if err == nil || !strings.Contains(err.Error(), "no favorite could be started") {
+		t.Errorf("runFavRun = %v, want no favorite started", err)
+	}
+}
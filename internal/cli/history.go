@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
+)
+
+var (
+	historySort    string
+	historyFormat  string
+	historyReverse bool
+)
+
+// historySorts are the orders --sort accepts
+var historySorts = []string{"time", "duration"}
+
+var historyCmd = &cobra.Command{
+	Use:   "history <stream-name>",
+	Short: "Show past runs of a stream",
+	Long: `Show when a stream ran, for how long and why each run ended.
+
+A run lasts from the stream becoming ready until it is stopped, restarted
+by a reconnect or URL refresh, switched to another video by its source
+(such as a 24/7 channel starting a new live video) or reaches the end of
+its video. The run in progress is listed as running. The last 100 runs are
+kept.
+
+Runs can be ordered with --sort: by time (latest first) or by duration
+(longest first); --reverse flips the order. --format json prints an array
+of objects instead of the table, for scripts.
+
+Examples:
+  youtube-rtsp-proxy history lofi
+  youtube-rtsp-proxy history lofi --sort duration --reverse
+  youtube-rtsp-proxy history lofi --format json | jq '.[] | select(.reason != "stopped")'`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHistory,
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historySort, "sort", "time", "order of the runs: "+strings.Join(historySorts, ", "))
+	historyCmd.Flags().StringVar(&historyFormat, "format", "table", "output format: "+strings.Join(outputFormats, ", "))
+	historyCmd.Flags().BoolVar(&historyReverse, "reverse", false, "reverse the order")
+}
+
+// historyRun is one run of a stream; end is zero while it is running
+type historyRun struct {
+	start, end time.Time
+	duration   time.Duration
+	reason     string
+}
+
+// historyEntry is one run in the output of --format json
+type historyEntry struct {
+	Start           time.Time  `json:"start"`
+	End             *time.Time `json:"end"` // null while running
+	DurationSeconds int64      `json:"duration_seconds"`
+	Reason          string     `json:"reason"`
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	if err := checkChoice("sort", historySort, historySorts); err != nil {
+		return err
+	}
+	if err := checkChoice("format", historyFormat, outputFormats); err != nil {
+		return err
+	}
+
+	info, err := streamStatus(args[0])
+	if err != nil {
+		return err
+	}
+
+	runs := sortedRuns(historyRuns(info.Sessions(), time.Now()), historySort, historyReverse)
+	if historyFormat == "json" {
+		return writeJSON(os.Stdout, historyEntries(runs))
+	}
+	renderHistory(os.Stdout, args[0], runs)
+	return nil
+}
+
+// historyRuns converts stored sessions to runs in local time; a session
+// without an end is running and lasts until now
+func historyRuns(sessions []storage.Session, now time.Time) []historyRun {
+	runs := make([]historyRun, 0, len(sessions))
+	for _, s := range sessions {
+		run := historyRun{start: s.Start.Local(), reason: s.Reason}
+		if s.End.IsZero() {
+			run.duration = now.Sub(s.Start)
+			run.reason = "running"
+		} else {
+			run.end = s.End.Local()
+			run.duration = s.End.Sub(s.Start)
+		}
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// sortedRuns returns runs in the order by, one of historySorts, reversed
+// if reverse. Ties keep the latest run first.
+func sortedRuns(runs []historyRun, by string, reverse bool) []historyRun {
+	list := slices.Clone(runs)
+	sort.SliceStable(list, func(i, j int) bool { return list[i].start.After(list[j].start) })
+	if by == "duration" {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].duration > list[j].duration })
+	}
+	if reverse {
+		slices.Reverse(list)
+	}
+	return list
+}
+
+// historyEntries converts runs to their JSON form
+func historyEntries(runs []historyRun) []historyEntry {
+	entries := make([]historyEntry, 0, len(runs))
+	for _, run := range runs {
+		entry := historyEntry{
+			Start:           run.start,
+			DurationSeconds: int64(run.duration.Seconds()),
+			Reason:          run.reason,
+		}
+		if !run.end.IsZero() {
+			end := run.end
+			entry.End = &end
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// renderHistory prints the runs of a stream as a table
+func renderHistory(w io.Writer, name string, runs []historyRun) {
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Stream History: %s\n", name)
+	fmt.Fprintln(w, "══════════════════════════════════════════════════════════════")
+	fmt.Fprintln(w)
+
+	if len(runs) == 0 {
+		fmt.Fprintln(w, "  No runs recorded yet")
+	} else {
+		fmt.Fprintf(w, "  %-19s  %-19s  %9s  %s\n", "STARTED", "ENDED", "DURATION", "REASON")
+		for _, run := range runs {
+			end := "-"
+			if !run.end.IsZero() {
+				end = run.end.Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "  %-19s  %-19s  %9s  %s\n",
+				run.start.Format(time.DateTime), end, formatDuration(run.duration), truncateText(run.reason, 40))
+		}
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "══════════════════════════════════════════════════════════════")
+}
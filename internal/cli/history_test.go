@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
+)
+
+func testRuns() []historyRun {
+	at := func(day, hour, min int) time.Time { return time.Date(2026, 10, day, hour, min, 0, 0, time.UTC) }
+	return []historyRun{
+		{start: at(14, 9, 0), end: at(14, 9, 45), duration: 45 * time.Minute, reason: "stopped"},
+		{start: at(14, 20, 0), end: at(15, 2, 30), duration: 6*time.Hour + 30*time.Minute, reason: "restarted"},
+		{start: at(15, 2, 31), end: at(15, 2, 31).Add(40 * time.Second), duration: 40 * time.Second, reason: "video ended"},
+		{start: at(16, 8, 0), duration: 26*time.Hour + 5*time.Minute, reason: "running"},
+	}
+}
+
+func TestRenderHistoryGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		sortBy  string
+		reverse bool
+	}{
+		{"history_time", "time", false},
+		{"history_time_reverse", "time", true},
+		{"history_duration", "duration", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			renderHistory(&buf, "news", sortedRuns(testRuns(), tt.sortBy, tt.reverse))
+			checkGolden(t, tt.name, buf.Bytes())
+		})
+	}
+}
+
+func TestRenderHistoryEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	renderHistory(&buf, "news", nil)
+	checkGolden(t, "history_empty", buf.Bytes())
+}
+
+func TestSortedRuns(t *testing.T) {
+	tests := []struct {
+		by      string
+		reverse bool
+		want    []string
+	}{
+		{"time", false, []string{"running", "video ended", "restarted", "stopped"}},
+		{"time", true, []string{"stopped", "restarted", "video ended", "running"}},
+		{"duration", false, []string{"running", "restarted", "stopped", "video ended"}},
+		{"duration", true, []string{"video ended", "stopped", "restarted", "running"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, run := range sortedRuns(testRuns(), tt.by, tt.reverse) {
+			got = append(got, run.reason)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("sortedRuns(%q, reverse %v) = %v, want %v", tt.by, tt.reverse, got, tt.want)
+		}
+	}
+}
+
+func TestHistoryRunsRunning(t *testing.T) {
+	start := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	runs := historyRuns([]storage.Session{
+		{Start: start.Add(-time.Hour), End: start.Add(-time.Minute), Reason: "stopped"},
+		{Start: start},
+	}, start.Add(90*time.Second))
+
+	if runs[0].duration != 59*time.Minute || runs[0].reason != "stopped" {
+		t.Errorf("ended run = %+v, want 59m stopped", runs[0])
+	}
+	if !runs[1].end.IsZero() || runs[1].duration != 90*time.Second || runs[1].reason != "running" {
+		t.Errorf("open run = %+v, want a running run of 90s", runs[1])
+	}
+}
+
+func TestHistoryEntriesJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, historyEntries(sortedRuns(testRuns(), "time", false))); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "history_json", buf.Bytes())
+}
+
+func TestHistorySortChoice(t *testing.T) {
+	err := checkChoice("sort", "size", historySorts)
+	want := `invalid --sort "size" (must be one of: time, duration)`
+	if err == nil || err.Error() != want {
+		t.Errorf("checkChoice(size) = %v, want %q", err, want)
+	}
+}
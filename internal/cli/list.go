@@ -1,86 +1,513 @@
 package cli
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/server"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
 )
 
+var (
+	listWatch    bool
+	listInterval time.Duration
+	listWide     bool
+	listAll      bool
+	listState    string
+	listSort     string
+)
+
+// listStates are the states --state accepts
+var listStates = []string{"running", "reconnecting", "error", "stopped"}
+
+// listSorts are the orders --sort accepts
+var listSorts = []string{"name", "uptime", "errors"}
+
 var listCmd = &cobra.Command{
-	Use:     "list",
+	Use:     "list [--watch [interval]]",
 	Aliases: []string{"ls"},
 	Short:   "List all active streams",
-	Long:    `List all active RTSP proxy streams with their status and URLs.`,
-	RunE:    runList,
+	Long: `List all active RTSP proxy streams with their status and URLs, one line
+per stream. --wide shows each stream in detail instead.
+
+With --watch, the list is redrawn in place every 2 seconds, or at the
+interval given after it, until q or Ctrl+C is pressed. Streams can be
+limited to one state with --state and ordered with --sort: by name, by
+uptime (longest first) or by errors (most first).
+
+Examples:
+  youtube-rtsp-proxy list
+  youtube-rtsp-proxy list --watch
+  youtube-rtsp-proxy list --watch 5s
+  youtube-rtsp-proxy list --state error --sort errors
+  youtube-rtsp-proxy list --wide
+  youtube-rtsp-proxy list --all`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE:         runList,
+}
+
+func init() {
+	listCmd.Flags().BoolVarP(&listWatch, "watch", "w", false, "redraw the list periodically until q or Ctrl+C")
+	listCmd.Flags().DurationVar(&listInterval, "interval", 2*time.Second, "refresh interval for --watch")
+	listCmd.Flags().BoolVar(&listWide, "wide", false, "show each stream in detail, with ingest usage over the last 24 hours")
+	listCmd.Flags().BoolVarP(&listAll, "all", "a", false, "include stopped streams")
+	listCmd.Flags().StringVar(&listState, "state", "", "only show streams in this state: "+strings.Join(listStates, ", "))
+	listCmd.Flags().StringVar(&listSort, "sort", "name", "order of the streams: "+strings.Join(listSorts, ", "))
+}
+
+// byteSnapshot is the bytes-received counter of a stream at a point in time
+type byteSnapshot struct {
+	bytes int64
+	at    time.Time
+}
+
+// listView holds the data rendered by renderList
+type listView struct {
+	streams     []stream.Info
+	networkHost string
+	// viewers holds the reader count per stream, if MediaMTX reported it
+	viewers map[string]int
+	// rates holds the bytes/sec received per stream (watch mode only)
+	rates map[string]float64
+	// wide shows each stream in detail rather than on one line
+	wide bool
+	// state is the state streams were filtered by, if any
+	state string
 }
 
 func runList(cmd *cobra.Command, args []string) error {
-	streams := manager.List()
+	listState = strings.ToLower(strings.TrimSpace(listState))
+	if listState != "" {
+		if err := checkChoice("state", listState, listStates); err != nil {
+			return err
+		}
+	}
+	if err := checkChoice("sort", listSort, listSorts); err != nil {
+		return err
+	}
 
-	fmt.Println()
-	fmt.Println("Active RTSP Proxy Streams")
-	fmt.Println("══════════════════════════════════════════════════════════════")
+	if len(args) > 0 {
+		if !listWatch {
+			return fmt.Errorf("unexpected argument %q (an interval is only accepted after --watch)", args[0])
+		}
+		interval, err := parseWatchInterval(args[0])
+		if err != nil {
+			return err
+		}
+		listInterval = interval
+	}
+	if listWatch {
+		return watchList(os.Stdout)
+	}
 
-	if len(streams) == 0 {
-		fmt.Println()
-		fmt.Println("  No active streams")
-		fmt.Println()
-		fmt.Println("  Start one with:")
-		fmt.Println("    youtube-rtsp-proxy start <youtube-url> --name <name>")
-		fmt.Println()
-		fmt.Println("══════════════════════════════════════════════════════════════")
-		return nil
+	streams, err := listStreams()
+	if err != nil {
+		return err
 	}
+	paths := fetchPathInfos(streams)
+	renderList(os.Stdout, listView{
+		streams:     selectStreams(streams),
+		networkHost: advertisedHost(),
+		viewers:     pathViewers(paths),
+		wide:        listWide,
+		state:       listState,
+	})
+	return nil
+}
 
-	localIP := getLocalIP()
+// parseWatchInterval parses the interval given after --watch, either a
+// duration such as "5s" or a number of seconds as watch -n takes it
+func parseWatchInterval(s string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q (e.g. 5s or 5)", s)
+	}
+	return d, nil
+}
 
-	for _, s := range streams {
-		fmt.Println()
-		fmt.Printf("Stream: %s\n", s.Name)
-
-		// Status with icon
-		var statusIcon string
-		switch s.StateString {
-		case "running":
-			statusIcon = "●" // Green circle
-		case "reconnecting":
-			statusIcon = "◐" // Half circle
-		case "error":
-			statusIcon = "○" // Empty circle
+// watchList redraws the stream list every listInterval until interrupted
+func watchList(w io.Writer) error {
+	if listInterval <= 0 {
+		return fmt.Errorf("invalid interval: %v", listInterval)
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	resizeCh := make(chan os.Signal, 1)
+	signal.Notify(resizeCh, syscall.SIGWINCH)
+	defer signal.Stop(sigCh)
+	defer signal.Stop(resizeCh)
+
+	// Hide the cursor while redrawing and restore it on exit
+	fmt.Fprint(w, "\033[?25l")
+	defer fmt.Fprint(w, "\033[?25h")
+
+	// Read key presses for q, if stdin is a terminal. The terminal mode is
+	// restored on exit, before the cursor.
+	exitHint := "Press Ctrl+C to exit."
+	keys := make(chan byte, 1)
+	if restore, err := cbreakInput(os.Stdin); err == nil {
+		defer restore()
+		go readKeys(os.Stdin, keys)
+		exitHint = "Press q or Ctrl+C to exit."
+	}
+
+	ticker := time.NewTicker(listInterval)
+	defer ticker.Stop()
+
+	networkHost := advertisedHost()
+	previous := make(map[string]byteSnapshot)
+	var last bytes.Buffer
+
+	draw := func(refresh bool) {
+		if refresh {
+			streams, err := listStreams()
+			paths := fetchPathInfos(streams)
+			last.Reset()
+			renderList(&last, listView{
+				streams:     selectStreams(streams),
+				networkHost: networkHost,
+				viewers:     pathViewers(paths),
+				rates:       sampleRates(paths, previous, time.Now()),
+				wide:        listWide,
+				state:       listState,
+			})
+			if err != nil {
+				fmt.Fprintf(&last, "\nError: %v\n", err)
+			}
+			fmt.Fprintf(&last, "\nRefreshing every %v. %s\n", listInterval, exitHint)
+		}
+		// Clear screen and move cursor home before drawing
+		fmt.Fprint(w, "\033[H\033[2J")
+		w.Write(last.Bytes())
+	}
+
+	draw(true)
+	for {
+		select {
+		case <-sigCh:
+			fmt.Fprintln(w)
+			return nil
+		case key := <-keys:
+			if key == 'q' || key == 'Q' {
+				fmt.Fprintln(w)
+				return nil
+			}
+		case <-resizeCh:
+			draw(false)
+		case <-ticker.C:
+			draw(true)
+		}
+	}
+}
+
+// readKeys sends the bytes read from r to keys until reading fails. Keys
+// pressed faster than they are handled are dropped.
+func readKeys(r io.Reader, keys chan<- byte) {
+	buf := make([]byte, 1)
+	for {
+		if _, err := r.Read(buf); err != nil {
+			return
+		}
+		select {
+		case keys <- buf[0]:
 		default:
-			statusIcon = "○"
 		}
-		fmt.Printf("  Status:    %s %s (PID: %d)\n", statusIcon, s.StateString, s.FFmpegPID)
+	}
+}
+
+// selectStreams returns the streams to list: with the stopped ones if --all
+// is set or --state asks for them, filtered by --state and ordered by
+// --sort
+func selectStreams(streams []stream.Info) []stream.Info {
+	if listAll || listState == stream.StateStopped.String() {
+		streams = withStopped(streams)
+	}
+
+	if listState != "" {
+		var filtered []stream.Info
+		for _, s := range streams {
+			if s.StateString == listState {
+				filtered = append(filtered, s)
+			}
+		}
+		streams = filtered
+	}
+
+	sortStreams(streams, listSort)
+	return streams
+}
+
+// sortStreams orders streams by name, then by the given key: uptime puts
+// the longest running first and errors the most errors first
+func sortStreams(streams []stream.Info, by string) {
+	sort.Slice(streams, func(i, j int) bool { return streams[i].Name < streams[j].Name })
+	switch by {
+	case "uptime":
+		// Streams not running have no start time and go last
+		sort.SliceStable(streams, func(i, j int) bool {
+			a, b := streams[i].StartedAt, streams[j].StartedAt
+			if a.IsZero() || b.IsZero() {
+				return !a.IsZero() && b.IsZero()
+			}
+			return a.Before(b)
+		})
+	case "errors":
+		sort.SliceStable(streams, func(i, j int) bool { return streams[i].ErrorCount > streams[j].ErrorCount })
+	}
+}
+
+// withStopped appends the stopped streams to streams. Their definitions are
+// read from storage, which a daemon shares.
+func withStopped(streams []stream.Info) []stream.Info {
+	return append(streams, manager.ListStopped()...)
+}
+
+// fetchPathInfos reads the MediaMTX path of each stream, keyed by stream
+// name. Streams whose path is unavailable are left out. MediaMTX is queried
+// even if this process did not start it.
+func fetchPathInfos(streams []stream.Info) map[string]*server.PathInfo {
+	paths := make(map[string]*server.PathInfo)
+	for _, s := range streams {
+		if pathInfo, err := srv.GetPathInfo(s.RTSPPath); err == nil {
+			paths[s.Name] = pathInfo
+		}
+	}
+	return paths
+}
+
+// pathViewers returns the reader count of each path
+func pathViewers(paths map[string]*server.PathInfo) map[string]int {
+	viewers := make(map[string]int, len(paths))
+	for name, pathInfo := range paths {
+		viewers[name] = pathInfo.ReaderCount()
+	}
+	return viewers
+}
+
+// sampleRates returns per-stream rates of the bytes-received counters
+// relative to the previous samples, which are updated
+func sampleRates(paths map[string]*server.PathInfo, previous map[string]byteSnapshot, now time.Time) map[string]float64 {
+	rates := make(map[string]float64)
+	for name := range previous {
+		if _, ok := paths[name]; !ok {
+			delete(previous, name)
+		}
+	}
+	for name, pathInfo := range paths {
+		current := byteSnapshot{bytes: pathInfo.BytesReceived, at: now}
+		if prev, ok := previous[name]; ok {
+			rates[name] = byteRate(prev, current)
+		}
+		previous[name] = current
+	}
+	return rates
+}
+
+// byteRate computes bytes per second between two snapshots
+func byteRate(prev, current byteSnapshot) float64 {
+	elapsed := current.at.Sub(prev.at).Seconds()
+	if elapsed <= 0 || current.bytes < prev.bytes {
+		return 0
+	}
+	return float64(current.bytes-prev.bytes) / elapsed
+}
+
+// renderList writes the stream list
+func renderList(w io.Writer, view listView) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Active RTSP Proxy Streams")
+	fmt.Fprintln(w, "══════════════════════════════════════════════════════════════")
+
+	if len(view.streams) == 0 {
+		fmt.Fprintln(w)
+		if view.state != "" {
+			fmt.Fprintf(w, "  No %s streams\n", view.state)
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "══════════════════════════════════════════════════════════════")
+			return
+		}
+		fmt.Fprintln(w, "  No active streams")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "  Start one with:")
+		fmt.Fprintln(w, "    youtube-rtsp-proxy start <youtube-url> --name <name>")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "══════════════════════════════════════════════════════════════")
+		return
+	}
+
+	if !view.wide {
+		renderCompact(w, view)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "══════════════════════════════════════════════════════════════")
+		return
+	}
+
+	for _, s := range view.streams {
+		fmt.Fprintln(w)
+		if s.StateString == stream.StateStopped.String() {
+			renderStopped(w, s)
+			continue
+		}
+		fmt.Fprintf(w, "Stream: %s\n", s.Name)
+		fmt.Fprintf(w, "  Status:    %s %s (PID: %d)\n", stateIcon(s.StateString), s.StateString, s.FFmpegPID)
+		if mode := formatMode(s); mode != "" {
+			fmt.Fprintf(w, "  Mode:      %s\n", mode)
+		}
 
 		// RTSP URLs
-		fmt.Printf("  RTSP URL:  rtsp://localhost:%d%s\n", s.Port, s.RTSPPath)
-		if localIP != "" {
-			fmt.Printf("  Network:   rtsp://%s:%d%s\n", localIP, s.Port, s.RTSPPath)
+		if stream.PublishesLocally(s.Outputs) {
+			fmt.Fprintf(w, "  RTSP URL:  %s\n", cfg.GetRTSPURL(s.Port, s.RTSPPath))
+			if view.networkHost != "" {
+				fmt.Fprintf(w, "  Network:   %s\n", cfg.GetRTSPURLForHost(view.networkHost, s.Port, s.RTSPPath))
+			}
+			if s.Substream != "" {
+				fmt.Fprintf(w, "  Sub URL:   %s (%s)\n", cfg.GetRTSPURL(s.Port, stream.SubstreamPath(s.RTSPPath)), s.Substream)
+			}
+			if s.AudioCopy {
+				fmt.Fprintf(w, "  Audio URL: %s\n", cfg.GetRTSPURL(s.Port, stream.AudioCopyPath(s.RTSPPath)))
+			}
+		}
+		for _, output := range s.Outputs {
+			if output != stream.LocalOutput {
+				fmt.Fprintf(w, "  Output:    %s\n", output)
+			}
 		}
 
 		// Source
-		fmt.Printf("  Source:    %s\n", truncateURL(s.YouTubeURL, 60))
+		if s.Title != "" {
+			fmt.Fprintf(w, "  Title:     %s\n", truncateText(s.Title, 60))
+		}
+		if s.Resolution != "" {
+			fmt.Fprintf(w, "  Video:     %s\n", s.Resolution)
+		}
+		fmt.Fprintf(w, "  Source:    %s\n", truncateURL(s.YouTubeURL, 60))
 
 		// Timing info
 		if !s.StartedAt.IsZero() {
 			uptime := time.Since(s.StartedAt).Round(time.Second)
-			fmt.Printf("  Uptime:    %s\n", formatDuration(uptime))
+			fmt.Fprintf(w, "  Uptime:    %s\n", formatDuration(uptime))
+		}
+
+		// Viewers
+		if viewers, ok := view.viewers[s.Name]; ok {
+			fmt.Fprintf(w, "  Viewers:   %d\n", viewers)
+		}
+
+		// Ingest rate, sampled in watch mode or by the daemon's monitor
+		if rate, ok := ingestRate(view, s); ok {
+			fmt.Fprintf(w, "  Ingest:    %s/s\n", formatBytes(int64(rate)))
 		}
 
+		fmt.Fprintf(w, "  Usage 24h: %s\n", formatUsage(s.IngestBytes, s.WastedBytes))
+
 		// Error info if any
 		if s.ErrorCount > 0 {
-			fmt.Printf("  Errors:    %d total, %d consecutive\n", s.ErrorCount, s.ConsecutiveErrors)
+			fmt.Fprintf(w, "  Errors:    %d total, %d consecutive\n", s.ErrorCount, s.ConsecutiveErrors)
 			if s.LastError != "" {
-				fmt.Printf("  Last Error: %s\n", s.LastError)
+				fmt.Fprintf(w, "  Last Error: %s\n", s.LastError)
 			}
 		}
 	}
 
-	fmt.Println()
-	fmt.Println("══════════════════════════════════════════════════════════════")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "══════════════════════════════════════════════════════════════")
+}
 
-	return nil
+// renderCompact writes one line per stream
+func renderCompact(w io.Writer, view listView) {
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "    %-16s %-17s %7s %12s %8s %6s  %s\n",
+		"STREAM", "STATE", "VIEWERS", "INGEST", "UPTIME", "ERRORS", "URL")
+	for _, s := range view.streams {
+		viewers, ingest, uptime := "-", "-", "-"
+		if n, ok := view.viewers[s.Name]; ok {
+			viewers = strconv.Itoa(n)
+		}
+		if rate, ok := ingestRate(view, s); ok {
+			ingest = formatBytes(int64(rate)) + "/s"
+		}
+		if !s.StartedAt.IsZero() {
+			uptime = formatDuration(time.Since(s.StartedAt).Round(time.Second))
+		}
+
+		var url string
+		switch {
+		case s.StateString == stream.StateStopped.String():
+		case stream.PublishesLocally(s.Outputs):
+			url = cfg.GetRTSPURL(s.Port, s.RTSPPath)
+		case len(s.Outputs) > 0:
+			url = s.Outputs[0]
+		}
+
+		row := fmt.Sprintf("  %s %-16s %-17s %7s %12s %8s %6d  %s",
+			stateIcon(s.StateString), truncateText(s.Name, 16), s.StateString,
+			viewers, ingest, uptime, s.ErrorCount, url)
+		row = strings.TrimRight(row, " ")
+		if s.StateString == stream.StateStopped.String() {
+			row = "\033[2m" + row + "\033[0m"
+		}
+		fmt.Fprintln(w, row)
+	}
+}
+
+// stateIcon returns the status icon of a stream state
+func stateIcon(state string) string {
+	switch state {
+	case "running":
+		return "●" // Green circle
+	case "reconnecting":
+		return "◐" // Half circle
+	default:
+		return "○" // Empty circle
+	}
+}
+
+// ingestRate returns the bytes/sec a stream receives, sampled in watch mode
+// or by the daemon's monitor
+func ingestRate(view listView, s stream.Info) (float64, bool) {
+	if rate, ok := view.rates[s.Name]; ok {
+		return rate, true
+	}
+	return s.ByteRate, s.ByteRate > 0
+}
+
+// renderStopped writes a stopped stream greyed out, with how to start it
+func renderStopped(w io.Writer, s stream.Info) {
+	fmt.Fprint(w, "\033[2m")
+	fmt.Fprintf(w, "Stream: %s\n", s.Name)
+	fmt.Fprintf(w, "  Status:    ○ stopped\n")
+	if s.Title != "" {
+		fmt.Fprintf(w, "  Title:     %s\n", truncateText(s.Title, 60))
+	}
+	fmt.Fprintf(w, "  Source:    %s\n", truncateURL(s.YouTubeURL, 60))
+	fmt.Fprintf(w, "  Start:     youtube-rtsp-proxy start %s\n", s.Name)
+	fmt.Fprint(w, "\033[0m")
+}
+
+// formatMode describes the non-default options of a stream, or returns ""
+func formatMode(s stream.Info) string {
+	var modes []string
+	if s.AudioOnly {
+		modes = append(modes, "audio only")
+	}
+	if s.Loop {
+		modes = append(modes, "loop")
+	}
+	return strings.Join(modes, ", ")
 }
 
 // truncateURL truncates a URL to maxLen characters
@@ -91,6 +518,16 @@ func truncateURL(url string, maxLen int) string {
 	return url[:maxLen-3] + "..."
 }
 
+// truncateText truncates text to maxLen characters without splitting
+// multi-byte characters
+func truncateText(text string, maxLen int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLen {
+		return text
+	}
+	return string(runes[:maxLen-3]) + "..."
+}
+
 // formatDuration formats a duration in a human-readable way
 func formatDuration(d time.Duration) string {
 	if d < time.Minute {
@@ -108,3 +545,26 @@ func formatDuration(d time.Duration) string {
 	hours = hours % 24
 	return fmt.Sprintf("%dd %dh", days, hours)
 }
+
+// formatUsage describes ingested bytes and how much of it had no readers
+func formatUsage(ingest, wasted int64) string {
+	if ingest == 0 {
+		return "no data yet"
+	}
+	return fmt.Sprintf("%s ingested, %s with no readers (%d%%)",
+		formatBytes(ingest), formatBytes(wasted), wasted*100/ingest)
+}
+
+// formatBytes formats a byte count in a human-readable way
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/server"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+)
+
+func TestByteRate(t *testing.T) {
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		prev, current byteSnapshot
+		want          float64
+	}{
+		{"steady", byteSnapshot{1000, t0}, byteSnapshot{6000, t0.Add(5 * time.Second)}, 1000},
+		{"sub-second interval", byteSnapshot{0, t0}, byteSnapshot{500, t0.Add(250 * time.Millisecond)}, 2000},
+		{"idle", byteSnapshot{4096, t0}, byteSnapshot{4096, t0.Add(2 * time.Second)}, 0},
+		{"counter reset", byteSnapshot{9000, t0}, byteSnapshot{100, t0.Add(time.Second)}, 0},
+		{"no time elapsed", byteSnapshot{0, t0}, byteSnapshot{100, t0}, 0},
+		{"clock went back", byteSnapshot{0, t0}, byteSnapshot{100, t0.Add(-time.Second)}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := byteRate(tt.prev, tt.current); got != tt.want {
+				t.Errorf("byteRate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSampleRates(t *testing.T) {
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	previous := make(map[string]byteSnapshot)
+
+	// The first sample is only a baseline
+	rates := sampleRates(map[string]*server.PathInfo{
+		"news": {BytesReceived: 1000},
+		"lofi": {BytesReceived: 0},
+	}, previous, t0)
+	if len(rates) != 0 {
+		t.Errorf("rates of the first sample = %v, want none", rates)
+	}
+
+	rates = sampleRates(map[string]*server.PathInfo{
+		"news": {BytesReceived: 21000},
+		"new":  {BytesReceived: 500},
+	}, previous, t0.Add(2*time.Second))
+	if rates["news"] != 10000 {
+		t.Errorf("news rate = %v, want 10000", rates["news"])
+	}
+	if _, ok := rates["new"]; ok {
+		t.Error("a path without a baseline got a rate")
+	}
+	if _, ok := previous["lofi"]; ok {
+		t.Error("the baseline of a vanished path was kept")
+	}
+	if previous["new"].bytes != 500 {
+		t.Errorf("baseline of a new path = %+v, want 500 bytes", previous["new"])
+	}
+}
+
+// listLine returns the line of out that mentions name
+func listLine(t *testing.T, out, name string) string {
+	t.Helper()
+	for _, line := range strings.Split(out, "\n") {
+		if strings.Contains(line, " "+name+" ") {
+			return line
+		}
+	}
+	t.Fatalf("no line for %s in:\n%s", name, out)
+	return ""
+}
+
+func TestRenderListAcrossSnapshots(t *testing.T) {
+	cfg = &config.Config{Server: config.ServerConfig{RTSPPort: 8554}}
+	t.Cleanup(func() { cfg = nil })
+
+	t0 := time.Now()
+	previous := make(map[string]byteSnapshot)
+	news := stream.Info{Name: "news", RTSPPath: "/news", StateString: "reconnecting"}
+
+	// First refresh: the stream is reconnecting and has no rate yet
+	var first bytes.Buffer
+	paths := map[string]*server.PathInfo{"news": {BytesReceived: 1000}}
+	renderList(&first, listView{
+		streams: []stream.Info{news},
+		viewers: pathViewers(paths),
+		rates:   sampleRates(paths, previous, t0),
+	})
+	line := listLine(t, first.String(), "news")
+	if !strings.Contains(line, "reconnecting") || !strings.Contains(line, " - ") {
+		t.Errorf("first snapshot line = %q, want reconnecting without a rate", line)
+	}
+
+	// Second refresh: it is running with a viewer, another stream came
+	// up, and the rate follows from the counters of both samples
+	news.StateString = "running"
+	news.ErrorCount = 2
+	lofi := stream.Info{Name: "lofi", RTSPPath: "/lofi", StateString: "running"}
+	var second bytes.Buffer
+	paths = map[string]*server.PathInfo{
+		"news": {BytesReceived: 21480, Readers: []server.PathPeer{{Type: "rtspSession", ID: "1"}}},
+		"lofi": {BytesReceived: 50},
+	}
+	renderList(&second, listView{
+		streams: []stream.Info{lofi, news},
+		viewers: pathViewers(paths),
+		rates:   sampleRates(paths, previous, t0.Add(2*time.Second)),
+	})
+
+	if first.String() == second.String() {
+		t.Fatal("the second snapshot rendered the same output")
+	}
+	line = listLine(t, second.String(), "news")
+	for _, want := range []string{"running", "10.0 KiB/s", "rtsp://localhost:8554/news"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("second snapshot line %q lacks %q", line, want)
+		}
+	}
+	if fields := strings.Fields(line); len(fields) < 8 || fields[3] != "1" || fields[7] != "2" {
+		t.Errorf("second snapshot line %q, want 1 viewer and 2 errors", line)
+	}
+	if line := listLine(t, second.String(), "lofi"); !strings.Contains(line, "running") {
+		t.Errorf("new stream line = %q, want running", line)
+	}
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs <stream-name>",
+	Short: "Show a stream's log or set its log level",
+	Long: `Show the last lines of a stream's log.
+
+With --set-level, set the level below which the stream's messages are not
+logged, overriding logging.level for this stream only: "debug" adds
+details such as the chosen format and reconnect backoff, "warn" keeps
+only problems. The level is kept with the stream until set to "default".
+With a foreground server running, it applies at once.
+
+Examples:
+  youtube-rtsp-proxy logs lofi
+  youtube-rtsp-proxy logs lofi -n 200
+  youtube-rtsp-proxy logs lofi --set-level debug
+  youtube-rtsp-proxy logs lofi --set-level default`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runLogs,
+}
+
+var (
+	logsLines    int
+	logsSetLevel string
+)
+
+func init() {
+	logsCmd.Flags().IntVarP(&logsLines, "lines", "n", 50, "number of lines to show")
+	logsCmd.Flags().StringVar(&logsSetLevel, "set-level", "", "set the stream's log level: debug, info, warn, error or default")
+}
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	if cmd.Flags().Changed("set-level") {
+		level := strings.ToLower(strings.TrimSpace(logsSetLevel))
+		if level == "default" {
+			level = ""
+		}
+
+		var err error
+		if daemon := connectDaemon(); daemon != nil {
+			err = daemon.SetLogLevel(name, level)
+		} else {
+			err = manager.SetLogLevel(name, level)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to set log level: %w", err)
+		}
+
+		if level == "" {
+			fmt.Printf("Log level of '%s' reset to the default (%s)\n", name, cfg.Logging.Level)
+		} else {
+			fmt.Printf("Log level of '%s' set to %s\n", name, level)
+		}
+		return nil
+	}
+
+	if logsLines <= 0 {
+		return fmt.Errorf("--lines must be positive, got %d", logsLines)
+	}
+	lines, err := manager.GetLoggerManager().GetLogger(name).ReadLast(logsLines)
+	if err != nil {
+		return fmt.Errorf("failed to read log: %w", err)
+	}
+	if len(lines) == 0 {
+		fmt.Printf("No log entries for '%s'.\n", name)
+		return nil
+	}
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// outputFormats are the formats --format accepts
+var outputFormats = []string{"table", "json"}
+
+// checkChoice returns an error listing the allowed values if value of the
+// flag named flag is not one of them
+func checkChoice(flag, value string, allowed []string) error {
+	if slices.Contains(allowed, value) {
+		return nil
+	}
+	return fmt.Errorf("invalid --%s %q (must be one of: %s)", flag, value, strings.Join(allowed, ", "))
+}
+
+// writeJSON writes v to w as indented JSON, for piping into jq
+func writeJSON(w io.Writer, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// publishCmd is run by MediaMTX (runOnDemand) when a client connects to an
+// on-demand stream. It is not meant to be run by hand.
+var publishCmd = &cobra.Command{
+	Use:    "_publish <stream-name>",
+	Short:  "Publish an on-demand stream in the foreground (used by MediaMTX)",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return manager.Publish(getContext(), args[0])
+	},
+}
+
+// publishCommand returns the command line MediaMTX runs to publish the
+// on-demand stream name
+func publishCommand(name string) string {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = os.Args[0]
+	}
+
+	args := []string{exe}
+	if cfgFile != "" {
+		path := cfgFile
+		if abs, err := filepath.Abs(cfgFile); err == nil {
+			path = abs
+		}
+		args = append(args, "--config", path)
+	}
+	args = append(args, "_publish", name)
+
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote quotes s for use as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
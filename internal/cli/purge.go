@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var purgeDryRun bool
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Remove stale stream entries and leftover files",
+	Long: `Remove the entries of streams that should be running but whose ffmpeg
+process is gone, like cleanup, and the .pid and .log files left in the data
+directory by streams that no longer exist, e.g. after a crash.
+
+Stopped streams, on-demand streams, streams waiting to reconnect, the
+MediaMTX files and the application log (logging.file) are kept.
+
+Examples:
+  youtube-rtsp-proxy purge --dry-run
+  youtube-rtsp-proxy purge`,
+	Args: cobra.NoArgs,
+	RunE: runPurge,
+}
+
+func init() {
+	purgeCmd.Flags().BoolVar(&purgeDryRun, "dry-run", false, "list what would be removed without removing it")
+}
+
+func runPurge(cmd *cobra.Command, args []string) error {
+	var keep []string
+	if cfg.Logging.File != "" {
+		keep = append(keep, cfg.Logging.File)
+	}
+
+	result, err := manager.Purge(purgeDryRun, keep...)
+
+	verb := "Removed"
+	if purgeDryRun {
+		verb = "Would remove"
+	}
+	for _, name := range result.Entries {
+		fmt.Printf("%s stale stream entry '%s'\n", verb, name)
+	}
+	for _, path := range result.Files {
+		fmt.Printf("%s leftover file %s\n", verb, path)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(result.Entries) == 0 && len(result.Files) == 0 {
+		fmt.Println("Nothing to purge.")
+		return nil
+	}
+	fmt.Printf("%s %d orphaned entries and %d leftover files.\n", verb, len(result.Entries), len(result.Files))
+	return nil
+}
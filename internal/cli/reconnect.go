@@ -6,8 +6,11 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/monitor"
 )
 
+var reconnectWait bool
+
 var reconnectCmd = &cobra.Command{
 	Use:   "reconnect <stream-name>",
 	Short: "Force reconnect a stream",
@@ -16,30 +19,66 @@ var reconnectCmd = &cobra.Command{
 This is useful for testing the reconnection logic or recovering
 from a stale stream state.
 
+With a foreground server running, the server reconnects the stream with
+its usual backoff and the command returns at once; --wait follows each
+attempt until the stream is back or the server gives up. Without one,
+the command reconnects the stream itself and always waits.
+
 Example:
-  youtube-rtsp-proxy reconnect lofi`,
+  youtube-rtsp-proxy reconnect lofi
+  youtube-rtsp-proxy reconnect lofi --wait`,
 	Args: cobra.ExactArgs(1),
 	RunE: runReconnect,
 }
 
+func init() {
+	reconnectCmd.Flags().BoolVar(&reconnectWait, "wait", false, "wait for the server to finish reconnecting, printing each attempt")
+}
+
 func runReconnect(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
-	// Check if stream exists
-	s := manager.GetStream(name)
-	if s == nil {
-		return fmt.Errorf("stream '%s' not found", name)
+	// The daemon's monitor keeps running after this command exits
+	if daemon := connectDaemon(); daemon != nil {
+		fmt.Printf("Forcing reconnection for stream '%s'...\n", name)
+		if reconnectWait {
+			if err := daemon.ReconnectWait(name, printReconnectProgress); err != nil {
+				return fmt.Errorf("failed to reconnect: %w", err)
+			}
+			fmt.Printf("Stream reconnected. Check status with: youtube-rtsp-proxy status %s\n", name)
+			return nil
+		}
+		if err := daemon.Reconnect(name); err != nil {
+			return fmt.Errorf("failed to trigger reconnection: %w", err)
+		}
+		fmt.Printf("Reconnection triggered. Check status with: youtube-rtsp-proxy status %s\n", name)
+		return nil
 	}
 
+	// Without a daemon there is no monitor to hand this to, so reconnect
+	// here before exiting. The stream may have been started by another
+	// process and only be known from storage.
 	fmt.Printf("Forcing reconnection for stream '%s'...\n", name)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(getContext(), cfg.Ytdlp.ExtractTimeout()+30*time.Second)
 	defer cancel()
 
-	if err := mon.ForceReconnect(ctx, name); err != nil {
-		return fmt.Errorf("failed to trigger reconnection: %w", err)
+	if err := manager.Reconnect(ctx, name); err != nil {
+		return fmt.Errorf("failed to reconnect: %w", err)
 	}
 
-	fmt.Printf("Reconnection triggered. Check status with: youtube-rtsp-proxy status %s\n", name)
+	fmt.Printf("Stream reconnected. Check status with: youtube-rtsp-proxy status %s\n", name)
 	return nil
 }
+
+// printReconnectProgress prints an attempt of a reconnect loop
+func printReconnectProgress(p monitor.ReconnectProgress) {
+	if p.Error == "" {
+		fmt.Printf("  Attempt %d/%d...\n", p.Attempt, p.MaxAttempts)
+		return
+	}
+	fmt.Printf("  Attempt %d/%d failed: %s\n", p.Attempt, p.MaxAttempts, p.Error)
+	if p.Attempt < p.MaxAttempts {
+		fmt.Printf("  Retrying in %v\n", p.Delay)
+	}
+}
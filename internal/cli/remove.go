@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var removeCmd = &cobra.Command{
+	Use:     "remove <stream-name>",
+	Aliases: []string{"rm"},
+	Short:   "Stop a stream and delete its definition",
+	Long: `Stop a stream if it is running and delete its stored definition.
+
+A stopped stream is kept and can be started again with
+"youtube-rtsp-proxy start <stream-name>"; remove forgets it entirely.
+
+Example:
+  youtube-rtsp-proxy remove lofi`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRemove,
+}
+
+func runRemove(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	// Stop it in the daemon first, so its monitor does not bring it back
+	if daemon := connectDaemon(); daemon != nil {
+		if info, err := daemon.Status(name); err == nil && info.StateString != "stopped" {
+			if err := daemon.Stop(name); err != nil {
+				return fmt.Errorf("failed to stop stream: %w", err)
+			}
+		}
+	}
+
+	if err := manager.Remove(name); err != nil {
+		return fmt.Errorf("failed to remove stream: %w", err)
+	}
+	fmt.Printf("Stream '%s' removed.\n", name)
+	return nil
+}
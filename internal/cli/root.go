@@ -3,6 +3,8 @@ package cli
 import (
 	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
@@ -10,6 +12,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/logger"
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/monitor"
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/server"
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
@@ -17,14 +20,25 @@ import (
 )
 
 var (
-	cfgFile   string
-	verbose   bool
-	cfg       *config.Config
-	store     *storage.FileStorage
-	srv       *server.MediaMTXServer
-	ext       extractor.Extractor
-	manager   *stream.Manager
-	mon       *monitor.Monitor
+	cfgFile  string
+	verbose  bool
+	quiet    bool
+	cfg      *config.Config
+	store    storage.Storage
+	srv      *server.MediaMTXServer
+	ext      extractor.Extractor
+	ytdlpExt *extractor.YtdlpExtractor
+	manager  *stream.Manager
+	mon      *monitor.Monitor
+	appLog   *slog.Logger
+	closeLog func() error
+
+	// Host shown in network RTSP URLs, overriding server.advertise_host,
+	// or the network interface whose address is shown, and whether a
+	// detected IPv6 address is preferred over an IPv4 one
+	advertiseHost      string
+	advertiseInterface string
+	preferIPv6         bool
 
 	// Version info (set by build flags)
 	Version   = "dev"
@@ -45,33 +59,62 @@ Features:
   - Health monitoring and auto-reconnection
   - Multiple stream support`,
 	PersistentPreRunE: initApp,
-	Version:           fmt.Sprintf("%s (built at %s)", Version, BuildTime),
 }
 
 // Execute runs the CLI
 func Execute() error {
-	return rootCmd.Execute()
+	// Version is set by main after this package is initialized
+	rootCmd.Version = fmt.Sprintf("%s (built at %s)", Version, BuildTime)
+
+	err := rootCmd.Execute()
+	finishApp()
+	return err
 }
 
 func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file path")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "log only warnings and errors, overriding logging.level (stream levels set with logs --set-level still apply)")
+	rootCmd.PersistentFlags().StringVar(&advertiseHost, "host", "", "host or address shown in network RTSP URLs (default: server.advertise_host, else detected)")
+	rootCmd.PersistentFlags().StringVar(&advertiseInterface, "interface", "", "network interface whose address is shown in network RTSP URLs, e.g. eth0 (default: server.advertise_interface)")
+	rootCmd.PersistentFlags().BoolVar(&preferIPv6, "prefer-ipv6", false, "show an IPv6 address in network RTSP URLs when this machine has both")
 
 	// Add subcommands
 	rootCmd.AddCommand(startCmd)
 	rootCmd.AddCommand(stopCmd)
+	rootCmd.AddCommand(removeCmd)
+	rootCmd.AddCommand(cleanupCmd)
+	rootCmd.AddCommand(purgeCmd)
+	rootCmd.AddCommand(urlCmd)
+	rootCmd.AddCommand(applyCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(clientsCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(historyCmd)
 	rootCmd.AddCommand(serverCmd)
 	rootCmd.AddCommand(favCmd)
 	rootCmd.AddCommand(reconnectCmd)
+	rootCmd.AddCommand(serviceCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(publishCmd)
+	rootCmd.AddCommand(sealCmd)
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(updateCmd)
+	rootCmd.AddCommand(drainCmd)
+	rootCmd.AddCommand(testCmd)
+	rootCmd.AddCommand(logsCmd)
 }
 
 // initApp initializes the application components
 func initApp(cmd *cobra.Command, args []string) error {
-	// Skip init for help commands
-	if cmd.Name() == "help" || cmd.Name() == "version" {
+	// Skip init for help commands, for config init so a broken config
+	// file can be regenerated, and for config show and validate, which
+	// load it themselves, and for _seal, which MediaMTX runs for every
+	// recording segment and needs no config
+	if cmd.Name() == "help" || cmd.Name() == "version" || cmd == configInitCmd || cmd == configShowCmd || cmd == configValidateCmd || cmd == sealCmd {
 		return nil
 	}
 
@@ -81,35 +124,71 @@ func initApp(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	if quiet {
+		cfg.Logging.Level = "warn"
+	}
 
 	// Initialize storage
-	store, err = storage.NewFileStorage(cfg.Storage.DataDir)
+	store, err = storage.Open(cfg.Storage.Backend, cfg.Storage.DataDir)
 	if err != nil {
 		return fmt.Errorf("failed to initialize storage: %w", err)
 	}
 
-	// Initialize extractor
-	ext = extractor.NewYtdlpExtractor(
+	// Initialize application logger
+	appLog, closeLog, err = logger.NewAppLogger(&cfg.Logging)
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+
+	// Initialize extractor (yt-dlp, except for direct media URLs)
+	ytdlpExt = extractor.NewYtdlpExtractor(
 		cfg.Ytdlp.BinaryPath,
 		cfg.Ytdlp.Timeout,
 		cfg.Ytdlp.Format,
 	)
+	ytdlpExt.UserAgent = cfg.Ytdlp.UserAgent
+	ytdlpExt.Headers = cfg.Ytdlp.Headers
+	ext = extractor.NewDefaultRegistry(extractor.NewLimiter(ytdlpExt,
+		cfg.Ytdlp.MaxConcurrent, cfg.Ytdlp.MinInterval, cfg.Ytdlp.RateLimitCooldown, appLog))
 
 	// Initialize MediaMTX server manager
-	srv = server.NewMediaMTXServer(&cfg.MediaMTX, &cfg.Server, cfg.Storage.DataDir)
+	srv = server.NewMediaMTXServer(&cfg.MediaMTX, &cfg.Server, cfg.Storage.DataDir, appLog)
+	srv.SetSegmentCommand(sealCommand())
 
 	// Initialize stream manager
-	manager = stream.NewManager(cfg, ext, srv, store)
+	manager = stream.NewManager(cfg, ext, srv, store, appLog)
+	manager.SetPublishCommand(publishCommand)
 
 	// Initialize monitor
-	mon = monitor.NewMonitor(&cfg.Monitor, manager, srv, ext)
+	mon = monitor.NewMonitor(&cfg.Monitor, manager, srv, appLog)
+	if cfg.Ytdlp.AutoUpdate {
+		mon.SetYtdlpUpdater(func(ctx context.Context) (string, error) {
+			return ytdlpExt.Update(ctx, extractor.ManagedYtdlpPath(cfg.Storage.DataDir), io.Discard)
+		})
+	}
 
-	// Recover streams from previous session
-	manager.RecoverStreams()
+	// Recover streams from previous session. cleanup and purge look at the
+	// stored entries themselves, as recovered streams count as alive, and
+	// test leaves them alone.
+	if cmd != cleanupCmd && cmd != purgeCmd && cmd != testCmd {
+		manager.RecoverStreams()
+	}
 
 	return nil
 }
 
+// finishApp lets lifecycle hooks started by the command finish and flushes
+// stream logs before the process exits
+func finishApp() {
+	if manager != nil {
+		manager.WaitHooks()
+		manager.GetLoggerManager().CloseAll()
+	}
+	if closeLog != nil {
+		closeLog()
+	}
+}
+
 // getContext returns a context that's cancelled on interrupt
 func getContext() context.Context {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -126,20 +205,19 @@ func getContext() context.Context {
 
 // checkDependencies verifies all required binaries exist
 func checkDependencies() error {
-	// Check yt-dlp
-	ytdlp := extractor.NewYtdlpExtractor(cfg.Ytdlp.BinaryPath, 0, "")
-	if err := ytdlp.CheckBinary(); err != nil {
-		return fmt.Errorf("yt-dlp: %w\n  Install with: pip install yt-dlp", err)
+	// Check yt-dlp, recording its version
+	if _, err := ytdlpExt.Version(); err != nil {
+		return fmt.Errorf("yt-dlp: %w\n  Install with: pip install yt-dlp\n  or: youtube-rtsp-proxy update ytdlp --download", err)
 	}
 
 	// Check ffmpeg
 	ffmpegMgr := stream.NewFFmpegManager(&cfg.FFmpeg)
-	if err := ffmpegMgr.CheckBinary(); err != nil {
+	if _, err := ffmpegMgr.CheckBinary(); err != nil {
 		return fmt.Errorf("ffmpeg: %w\n  Install with: apt install ffmpeg", err)
 	}
 
 	// Check mediamtx
-	if err := srv.CheckBinary(); err != nil {
+	if _, err := srv.CheckBinary(); err != nil {
 		return fmt.Errorf("mediamtx: %w\n  Download from: https://github.com/bluenviron/mediamtx/releases", err)
 	}
 
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/server"
+)
+
+// sealCmd is run by MediaMTX (runOnRecordSegmentComplete) when it finishes
+// a recording segment. It is not meant to be run by hand.
+var sealCmd = &cobra.Command{
+	Use:    "_seal <segment>",
+	Short:  "Write the SHA-256 sidecar of a finished recording segment (used by MediaMTX)",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return server.WriteSegmentChecksum(args[0])
+	},
+}
+
+// sealCommand returns the command line MediaMTX runs for a finished
+// recording segment. MediaMTX expands $MTX_SEGMENT_PATH itself.
+func sealCommand() string {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = os.Args[0]
+	}
+	return strings.Join([]string{shellQuote(exe), "_seal", `"$MTX_SEGMENT_PATH"`}, " ")
+}
@@ -6,16 +6,25 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/api"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/health"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/reconcile"
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/systemd"
 )
 
 var (
 	foreground   bool
 	favorites    string
 	allFavorites bool
+	trustBinary  bool
+	showPlan     bool
+	applyOnStart bool
 )
 
 var serverCmd = &cobra.Command{
@@ -27,12 +36,14 @@ Commands:
   start   - Start the MediaMTX server
   stop    - Stop the MediaMTX server
   restart - Restart the MediaMTX server
+  plan    - Show how declared streams would be reconciled
 
 Examples:
   youtube-rtsp-proxy server start
   youtube-rtsp-proxy server start --foreground
   youtube-rtsp-proxy server stop
-  youtube-rtsp-proxy server restart`,
+  youtube-rtsp-proxy server restart
+  youtube-rtsp-proxy server plan`,
 }
 
 var serverStartCmd = &cobra.Command{
@@ -53,22 +64,56 @@ var serverRestartCmd = &cobra.Command{
 	RunE:  runServerRestart,
 }
 
+var serverPlanCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show how declared streams would be reconciled",
+	Long: `Compare the streams section of the config file with the streams
+currently running and print what reconciling them would do, without
+changing anything.
+
+  +  stream would be started
+  =  stream is running and matches its declaration
+  ~  stream is running with different options and would be restarted
+  -  stream was started from the config but is no longer declared`,
+	RunE: runServerPlan,
+}
+
 func init() {
 	serverStartCmd.Flags().BoolVarP(&foreground, "foreground", "f", false, "run in foreground (blocking)")
-	serverStartCmd.Flags().StringVar(&favorites, "favorites", "", "comma-separated favorite names to start")
-	serverStartCmd.Flags().BoolVar(&allFavorites, "all-favorites", false, "start all favorites")
+	serverStartCmd.Flags().StringVar(&favorites, "favorites", "", "comma-separated favorite names to start instead of the autostart ones")
+	serverStartCmd.Flags().BoolVar(&allFavorites, "all-favorites", false, "start all favorites instead of the autostart ones")
+	serverStartCmd.Flags().BoolVar(&trustBinary, "trust", false, "accept a changed mediamtx binary and record its hash")
+	serverStartCmd.Flags().BoolVar(&showPlan, "plan", false, "show the reconciliation plan for declared streams and exit")
+	serverStartCmd.Flags().BoolVar(&applyOnStart, "apply", false, "apply the declared streams on startup (foreground only)")
 
 	serverCmd.AddCommand(serverStartCmd)
 	serverCmd.AddCommand(serverStopCmd)
 	serverCmd.AddCommand(serverRestartCmd)
+	serverCmd.AddCommand(serverPlanCmd)
 }
 
 func runServerStart(cmd *cobra.Command, args []string) error {
+	if showPlan {
+		return runServerPlan(cmd, args)
+	}
+
 	// Check dependencies
 	if err := checkDependencies(); err != nil {
 		return fmt.Errorf("dependency check failed:\n  %v", err)
 	}
 
+	// Only one foreground server may manage the data directory
+	if foreground {
+		lock, err := storage.LockInstance(cfg.Storage.DataDir)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+
+		version, _ := ytdlpExt.Version()
+		appLog.Info("using yt-dlp", "version", version)
+	}
+
 	if srv.IsRunning() {
 		fmt.Println("MediaMTX server is already running.")
 		return nil
@@ -76,14 +121,32 @@ func runServerStart(cmd *cobra.Command, args []string) error {
 
 	fmt.Println("Starting MediaMTX server...")
 	ctx := getContext()
+	srv.SetTrustBinary(trustBinary)
+
+	// Take reloads (SIGHUP) and drains (SIGUSR1) before systemd is told the
+	// service is ready, as their default action would kill the process
+	var sigCh chan os.Signal
+	if foreground {
+		sigCh = make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1)
+		defer signal.Stop(sigCh)
+	}
 
 	if err := srv.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start MediaMTX: %w", err)
 	}
 
+	// MediaMTX answers; tell systemd we're ready (no-op outside systemd)
+	if foreground {
+		if _, err := systemd.Notify(systemd.StateReady); err != nil {
+			fmt.Printf("Warning: sd_notify failed: %v\n", err)
+		}
+	}
+	manager.SyncOnDemandPaths()
+
 	fmt.Printf("MediaMTX server started (PID: %d)\n", srv.GetPID())
-	fmt.Printf("  RTSP: rtsp://localhost:%d\n", cfg.Server.RTSPPort)
-	fmt.Printf("  API:  http://localhost:%d\n", cfg.Server.APIPort)
+	fmt.Printf("  RTSP: rtsp://%s\n", config.ListenAddress(config.DialHost(cfg.Server.RTSPAddress), cfg.Server.RTSPPort))
+	fmt.Printf("  API:  %s\n", cfg.Server.APIURL(""))
 
 	if foreground {
 		fmt.Println()
@@ -95,20 +158,95 @@ func runServerStart(cmd *cobra.Command, args []string) error {
 		// Recover any existing streams
 		manager.RecoverStreams()
 
-		// Start favorites if specified
-		if allFavorites || favorites != "" {
-			if err := startFavorites(ctx); err != nil {
-				fmt.Printf("Warning: failed to start some favorites: %v\n", err)
+		// Start the autostart favorites, or the ones specified
+		if err := startFavorites(ctx); err != nil {
+			fmt.Printf("Warning: failed to start some favorites: %v\n", err)
+		}
+
+		// Reconcile declared streams if requested
+		if applyOnStart {
+			fmt.Println("Applying declared streams...")
+			if err := applyDeclared(ctx, os.Stdout, false); err != nil {
+				fmt.Printf("Warning: failed to apply some changes: %v\n", err)
+			}
+		}
+
+		// Start control API if enabled
+		var controlAPI *api.Server
+		if cfg.Server.ControlAPIPort > 0 {
+			controlAPI = api.NewServer(
+				config.ListenAddress(cfg.Server.ControlAPIAddress, cfg.Server.ControlAPIPort),
+				cfg.Server.ControlAPIToken,
+				manager, mon,
+			)
+			controlAPI.SetApply(applyDeclared)
+			if err := controlAPI.Start(ctx); err != nil {
+				fmt.Printf("Warning: failed to start control API: %v\n", err)
+				controlAPI = nil
+			} else {
+				fmt.Printf("  Control API: http://%s\n", config.ListenAddress(config.DialHost(cfg.Server.ControlAPIAddress), cfg.Server.ControlAPIPort))
 			}
 		}
 
-		// Wait for interrupt
-		sigCh := make(chan os.Signal, 1)
-		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-		<-sigCh
+		// Start health probes if enabled
+		var healthServer *health.Server
+		if cfg.Health.Port > 0 {
+			healthServer = health.NewServer(
+				config.ListenAddress(cfg.Health.Address, cfg.Health.Port),
+				health.Component{Name: "mediamtx", Check: srv.HealthCheck},
+				health.Component{Name: "drain", Check: checkNotDraining},
+			)
+			if err := healthServer.Start(); err != nil {
+				fmt.Printf("Warning: failed to start health probes: %v\n", err)
+				healthServer = nil
+			} else {
+				fmt.Printf("  Health probes: http://%s/healthz, /readyz\n", config.ListenAddress(config.DialHost(cfg.Health.Address), cfg.Health.Port))
+			}
+		}
+
+		// Serve CLI invocations on this host, so they share this process's
+		// streams and monitor state
+		daemonAPI := api.NewServer("", "", manager, mon)
+		daemonAPI.SetApply(applyDeclared)
+		if err := daemonAPI.StartUnix(ctx, cfg.GetDaemonSocketPath()); err != nil {
+			fmt.Printf("Warning: failed to start daemon socket: %v\n", err)
+			daemonAPI = nil
+		} else {
+			fmt.Printf("  Daemon socket: %s\n", cfg.GetDaemonSocketPath())
+		}
+
+		// Wait for interrupt, re-applying declared streams on SIGHUP and
+		// draining on SIGUSR1
+	wait:
+		for sig := range sigCh {
+			switch sig {
+			case syscall.SIGHUP:
+				fmt.Println("SIGHUP received, applying declared streams...")
+				if err := applyDeclared(ctx, os.Stdout, false); err != nil {
+					fmt.Printf("Warning: failed to apply some changes: %v\n", err)
+				}
+			case syscall.SIGUSR1:
+				fmt.Println("SIGUSR1 received, draining: new streams are rejected")
+				manager.Drain()
+			default:
+				break wait
+			}
+		}
 
 		fmt.Println()
 		fmt.Println("Shutting down...")
+		systemd.Notify(systemd.StateStopping)
+
+		// Stop control API and daemon socket
+		if controlAPI != nil {
+			controlAPI.Stop()
+		}
+		if daemonAPI != nil {
+			daemonAPI.Stop()
+		}
+		if healthServer != nil {
+			healthServer.Stop()
+		}
 
 		// Stop monitor
 		mon.Stop()
@@ -155,52 +293,95 @@ func runServerRestart(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// startFavorites starts streams for specified favorites
+func runServerPlan(cmd *cobra.Command, args []string) error {
+	plan := reconcile.Compute(cfg.Streams, knownStreams(), cfg.Server.RTSPPort, applyForce)
+
+	fmt.Println()
+	fmt.Println("Stream Reconciliation Plan")
+	fmt.Println("══════════════════════════════════════════════════════════════")
+	plan.Print(os.Stdout)
+	fmt.Println("══════════════════════════════════════════════════════════════")
+
+	if !plan.HasChanges() {
+		fmt.Println("No changes.")
+	}
+	return nil
+}
+
+// favoriteStartWorkers bounds how many favorites are extracted and started
+// at once
+const favoriteStartWorkers = 4
+
+// startFavorites starts the favorites named by --favorites, all favorites
+// with --all-favorites, or otherwise the favorites marked autostart. They
+// are started concurrently by a bounded pool of workers.
 func startFavorites(ctx context.Context) error {
-	favStore, err := storage.NewFavoritesStorage(cfg.Storage.DataDir)
+	favStore, err := storage.OpenFavorites(cfg.Storage.Backend, cfg.Storage.DataDir)
 	if err != nil {
 		return err
 	}
 
-	var names []string
-	if allFavorites {
+	var favs []*storage.Favorite
+	if favorites != "" && !allFavorites {
+		for _, name := range strings.Split(favorites, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			fav, err := favStore.Get(name)
+			if err != nil {
+				fmt.Printf("  Warning: favorite '%s' not found\n", name)
+				continue
+			}
+			favs = append(favs, fav)
+		}
+	} else {
 		favList, err := favStore.List()
 		if err != nil {
 			return fmt.Errorf("failed to list favorites: %w", err)
 		}
-		for _, f := range favList {
-			names = append(names, f.Name)
+		for _, fav := range favList {
+			if allFavorites || fav.AutoStart {
+				favs = append(favs, fav)
+			}
 		}
-	} else {
-		names = strings.Split(favorites, ",")
 	}
 
-	if len(names) == 0 {
-		fmt.Println("No favorites to start.")
+	if len(favs) == 0 {
+		if allFavorites || favorites != "" {
+			fmt.Println("No favorites to start.")
+		}
 		return nil
 	}
 
-	fmt.Printf("Starting %d favorite(s)...\n", len(names))
-
-	for _, name := range names {
-		name = strings.TrimSpace(name)
-		if name == "" {
-			continue
-		}
-
-		fav, err := favStore.Get(name)
-		if err != nil {
-			fmt.Printf("  Warning: favorite '%s' not found\n", name)
-			continue
-		}
-
-		fmt.Printf("  Starting '%s'...\n", name)
-		if err := manager.Start(ctx, fav.URL, name, cfg.Server.RTSPPort); err != nil {
-			fmt.Printf("    Failed: %v\n", err)
-		} else {
-			fmt.Printf("    Started: rtsp://localhost:%d/%s\n", cfg.Server.RTSPPort, name)
-		}
+	fmt.Printf("Starting %d favorite(s)...\n", len(favs))
+
+	jobs := make(chan *storage.Favorite)
+	var (
+		wg    sync.WaitGroup
+		outMu sync.Mutex
+	)
+	for i := 0; i < min(favoriteStartWorkers, len(favs)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fav := range jobs {
+				err := manager.Start(ctx, fav.URL, fav.Name, cfg.Server.RTSPPort, favoriteStartOptions(fav))
+				outMu.Lock()
+				if err != nil {
+					fmt.Printf("  Failed '%s': %v\n", fav.Name, err)
+				} else {
+					fmt.Printf("  Started '%s': %s\n", fav.Name, cfg.GetRTSPURL(cfg.Server.RTSPPort, fav.Name))
+				}
+				outMu.Unlock()
+			}
+		}()
+	}
+	for _, fav := range favs {
+		jobs <- fav
 	}
+	close(jobs)
+	wg.Wait()
 
 	return nil
 }
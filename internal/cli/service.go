@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/systemd"
+)
+
+var serviceUser bool
+
+var serviceCmd = &cobra.Command{
+	Use:   "service <install>",
+	Short: "Manage the systemd service",
+	Long: `Manage the systemd service running the proxy server.
+
+Commands:
+  install - Write a systemd unit file for the current binary and config
+
+Examples:
+  sudo youtube-rtsp-proxy service install
+  youtube-rtsp-proxy service install --user`,
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install a systemd unit file",
+	RunE:  runServiceInstall,
+}
+
+func init() {
+	serviceInstallCmd.Flags().BoolVar(&serviceUser, "user", false, "install as a user unit (~/.config/systemd/user)")
+
+	serviceCmd.AddCommand(serviceInstallCmd)
+}
+
+func runServiceInstall(cmd *cobra.Command, args []string) error {
+	binaryPath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to resolve binary path: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(binaryPath); err == nil {
+		binaryPath = resolved
+	}
+
+	configPath := cfgFile
+	if configPath != "" {
+		if abs, err := filepath.Abs(configPath); err == nil {
+			configPath = abs
+		}
+	}
+
+	path, err := systemd.InstallUnit(systemd.UnitOptions{
+		BinaryPath: binaryPath,
+		ConfigPath: configPath,
+		User:       serviceUser,
+	})
+	if err != nil {
+		return err
+	}
+
+	systemctl := "sudo systemctl"
+	if serviceUser {
+		systemctl = "systemctl --user"
+	}
+
+	fmt.Printf("Unit file written: %s\n", path)
+	fmt.Println()
+	fmt.Println("Enable and start with:")
+	fmt.Printf("  %s daemon-reload\n", systemctl)
+	fmt.Printf("  %s enable --now %s\n", systemctl, systemd.UnitName)
+
+	return nil
+}
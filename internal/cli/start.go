@@ -1,53 +1,163 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
 
 	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
 )
 
 var (
-	streamName string
-	streamPort int
+	streamName      string
+	streamPort      int
+	streamAudioOnly bool
+	streamForce     bool
+	streamDryRun    bool
+	streamShowURLs  bool
+	streamLoop      bool
+	streamOnDemand  bool
+	streamTransport string
+	streamWait      bool
+	streamNoWait    bool
+	streamQuality   string
+	streamFormat    string
+	streamBitrate   string
+	streamOutputs   []string
+	streamSubstream string
+	streamH264      bool
+	streamAudioCopy bool
 )
 
 var startCmd = &cobra.Command{
-	Use:   "start <youtube-url>",
+	Use:   "start <youtube-url|stopped-stream-name>",
 	Short: "Start proxying a YouTube stream",
 	Long: `Start proxying a YouTube stream to RTSP.
 
+The command waits until MediaMTX receives the stream, failing with
+ffmpeg's output if ffmpeg exits or ffmpeg.start_timeout lapses first.
+With --no-wait it returns as soon as ffmpeg runs.
+
+Without --name (or with the default --name stream), the stream is named
+after the video title, e.g. "lofi-hip-hop-radio", with a numeric suffix if
+that name is taken.
+
+Given the name of a stopped stream instead of a URL, the stream is started
+again with its stored URL and options. Flags given explicitly override them.
+
+--output publishes to another RTSP server (rtsp://, rtsps://), an RTMP
+ingest (rtmp://, rtmps://) or an SRT listener (srt://) instead of the local
+MediaMTX. Repeat it to publish to several targets at once; "--output local"
+keeps the local MediaMTX path as one of them.
+
+--substream also publishes a low-resolution, re-encoded copy to
+<name>_sub, for NVRs that record a main stream and show a substream. Both
+come from one ffmpeg, so they are stopped and reconnected together.
+
 Examples:
   youtube-rtsp-proxy start "https://www.youtube.com/watch?v=jfKfPfyJRdk" --name lofi
-  youtube-rtsp-proxy start "https://www.youtube.com/live/xyz" --name news --port 8555`,
+  youtube-rtsp-proxy start "https://www.youtube.com/live/xyz" --name news --port 8555
+  youtube-rtsp-proxy start "https://www.youtube.com/watch?v=jfKfPfyJRdk" --name radio --audio-only
+  youtube-rtsp-proxy start "https://www.youtube.com/live/xyz" --name news --quality 720p
+  youtube-rtsp-proxy start "https://www.youtube.com/watch?v=jfKfPfyJRdk" --name signage --loop
+  youtube-rtsp-proxy start "https://www.youtube.com/live/xyz" --name news --on-demand
+  youtube-rtsp-proxy start "https://www.youtube.com/live/xyz" --name nas --output rtsp://nas:8554/news
+  youtube-rtsp-proxy start "https://www.youtube.com/live/xyz" --name cam --substream 640x360@15
+  youtube-rtsp-proxy start "https://www.youtube.com/live/xyz" --name news --output local --output rtmp://a.rtmp.youtube.com/live2/KEY
+  youtube-rtsp-proxy start "https://www.youtube.com/live/xyz" --dry-run
+  youtube-rtsp-proxy start "https://www.youtube.com/watch?v=jfKfPfyJRdk"
+  youtube-rtsp-proxy start lofi`,
 	Args: cobra.ExactArgs(1),
 	RunE: runStart,
 }
 
 func init() {
-	startCmd.Flags().StringVarP(&streamName, "name", "n", "stream", "stream name (used in RTSP path)")
+	startCmd.Flags().StringVarP(&streamName, "name", "n", stream.DefaultName, "stream name (used in RTSP path, default: from the video title)")
 	startCmd.Flags().IntVarP(&streamPort, "port", "p", 0, "RTSP port (default: from config)")
+	startCmd.Flags().BoolVar(&streamAudioOnly, "audio-only", false, "proxy the audio track only (no video)")
+	startCmd.Flags().BoolVar(&streamLoop, "loop", false, "loop non-live videos forever instead of ending")
+	startCmd.Flags().BoolVar(&streamOnDemand, "on-demand", false, "only run ffmpeg while RTSP clients are connected")
+	startCmd.Flags().StringVar(&streamTransport, "transport", "", "RTSP transport for publishing: tcp or udp (default: from config)")
+	startCmd.Flags().StringVar(&streamQuality, "quality", "", qualityUsage)
+	startCmd.Flags().StringVar(&streamFormat, "format", "", "yt-dlp format selector, overriding --quality (default: ytdlp.format)")
+	startCmd.Flags().StringVar(&streamBitrate, "max-bitrate", "", "cap the video bitrate, e.g. 4M (needs a re-encoding ffmpeg.output_options)")
+	startCmd.Flags().StringArrayVar(&streamOutputs, "output", nil, "publish to this RTSP/RTMP/SRT URL instead of local MediaMTX (repeatable; \"local\" adds the local path)")
+	startCmd.Flags().StringVar(&streamSubstream, "substream", "", "also publish a low-resolution copy to <name>_sub, e.g. 640x360@15")
+	startCmd.Flags().BoolVar(&streamAudioCopy, "audio-copy", false, "also publish the audio alone to <name>_audio, e.g. for a speaker")
+	startCmd.Flags().BoolVar(&streamH264, "require-h264", false, "re-encode sources whose video is not H.264 (e.g. VP9, AV1) instead of failing, while ffmpeg.output_options copy video")
+	startCmd.Flags().BoolVar(&streamDryRun, "dry-run", false, "extract and print stream info and the ffmpeg command without starting ffmpeg")
+	startCmd.Flags().BoolVar(&streamShowURLs, "show-urls", false, "with --dry-run, print the extracted URLs in full")
+	startCmd.Flags().BoolVar(&streamWait, "wait", true, "wait until MediaMTX receives the stream (up to ffmpeg.start_timeout)")
+	startCmd.Flags().BoolVar(&streamNoWait, "no-wait", false, "return as soon as ffmpeg runs; the monitor handles later failures")
+	startCmd.Flags().BoolVar(&streamForce, "force", false, "don't warn when the URL is already proxied under another name")
 }
 
 func runStart(cmd *cobra.Command, args []string) error {
 	youtubeURL := args[0]
 
-	// Check dependencies first
-	if err := checkDependencies(); err != nil {
-		return fmt.Errorf("dependency check failed:\n  %v", err)
+	// A bare name starts a stopped stream again
+	restarting := false
+	if !strings.Contains(youtubeURL, "://") {
+		if url, ok := loadStoppedStream(cmd, youtubeURL); ok {
+			youtubeURL = url
+			restarting = true
+		}
 	}
 
-	// Ensure MediaMTX server is running
-	if !srv.IsRunning() {
-		fmt.Println("Starting MediaMTX server...")
-		if err := srv.Start(getContext()); err != nil {
-			return fmt.Errorf("failed to start MediaMTX: %w", err)
-		}
+	format, err := ytdlpFormat(streamFormat, streamQuality)
+	if err != nil {
+		return err
+	}
+	streamFormat = format
+	if strings.EqualFold(streamQuality, "audio") && !cmd.Flags().Changed("format") {
+		streamAudioOnly = true
 	}
 
-	// Start monitoring if not already running
-	if !mon.IsRunning() {
-		mon.Start(getContext())
+	maxBitrate, err := config.ParseBitrate(streamBitrate)
+	if err != nil {
+		return err
+	}
+	if maxBitrate > 0 && (streamAudioOnly || stream.CopiesVideo(cfg.FFmpeg.OutputOptions)) {
+		fmt.Println("Warning: --max-bitrate has no effect while video is copied (-c:v copy)")
+		fmt.Println("  Set a video encoder in ffmpeg.output_options, e.g. -c:v libx264, to enforce it")
+	}
+
+	// Without a name, or with the default one, the stream is named after
+	// the video title
+	derive := !restarting && streamName == stream.DefaultName
+
+	if streamDryRun {
+		return runStartDryRun(youtubeURL, maxBitrate, derive)
+	}
+
+	// A running daemon owns MediaMTX and the monitor; otherwise run them here
+	daemon := connectDaemon()
+	if daemon == nil {
+		// Check dependencies first
+		if err := checkDependencies(); err != nil {
+			return fmt.Errorf("dependency check failed:\n  %v", err)
+		}
+
+		// Ensure MediaMTX server is running
+		if !srv.IsRunning() {
+			fmt.Println("Starting MediaMTX server...")
+			if err := srv.Start(getContext()); err != nil {
+				return fmt.Errorf("failed to start MediaMTX: %w", err)
+			}
+			manager.SyncOnDemandPaths()
+		}
+
+		// Start monitoring if not already running
+		if !mon.IsRunning() {
+			mon.Start(getContext())
+		}
 	}
 
 	// Use default port if not specified
@@ -56,42 +166,466 @@ func runStart(cmd *cobra.Command, args []string) error {
 		port = cfg.Server.RTSPPort
 	}
 
-	fmt.Printf("Extracting stream URL from YouTube...\n")
-	printVerbose("  URL: %s\n", youtubeURL)
+	// Warn about accidental duplicates; serving one URL on several paths is allowed
+	if !streamForce {
+		if names := manager.FindByURL(youtubeURL); len(names) > 0 {
+			fmt.Printf("Warning: this URL is already proxied as: %s\n", strings.Join(names, ", "))
+			fmt.Println("  Starting another copy anyway (use --force to silence this warning)")
+		}
+	}
 
-	// Start the stream
-	ctx := getContext()
-	if err := manager.Start(ctx, youtubeURL, streamName, port); err != nil {
-		return fmt.Errorf("failed to start stream: %w", err)
+	opts := stream.StartOptions{
+		AudioOnly: streamAudioOnly,
+		Loop:      streamLoop,
+		Transport: streamTransport,
+		NoWait:    streamNoWait || !streamWait,
+		Format:    streamFormat,
+
+		MaxBitrate: maxBitrate,
+		Outputs:    streamOutputs,
+		Substream:  streamSubstream,
+
+		RequireH264: streamH264,
+		AudioCopy:   streamAudioCopy,
+	}
+	if err := stream.ValidateOutputs(opts.Outputs); err != nil {
+		return err
 	}
 
-	// Get local IP for network access URL
-	localIP := getLocalIP()
+	// The daemon names the stream itself, from the extraction it starts
+	// the stream with
+	if derive && daemon == nil {
+		streamName = deriveStreamName(getContext(), youtubeURL, opts)
+	}
+
+	switch {
+	case daemon != nil:
+		fmt.Printf("Starting stream in the running daemon...\n")
+		name := streamName
+		if derive {
+			name = ""
+		}
+		info, err := daemon.Start(youtubeURL, name, port, opts, streamOnDemand)
+		if err != nil {
+			return withHint(fmt.Errorf("failed to start stream: %w", err))
+		}
+		if derive {
+			streamName = info.Name
+			fmt.Printf("Stream name: %s\n", streamName)
+		}
+
+		fmt.Println()
+		if streamOnDemand {
+			fmt.Println("On-demand stream registered!")
+			fmt.Println("FFmpeg starts when the first client connects.")
+		} else {
+			printStarted(opts)
+			printBackground(streamName, info.FFmpegPID, true)
+		}
+	case streamOnDemand:
+		if err := manager.StartOnDemand(youtubeURL, streamName, port, opts); err != nil {
+			return withHint(fmt.Errorf("failed to register on-demand stream: %w", err))
+		}
+		fmt.Println()
+		fmt.Println("On-demand stream registered!")
+		fmt.Println("FFmpeg starts when the first client connects.")
+	default:
+		fmt.Printf("Extracting stream URL from YouTube...\n")
+		printVerbose("  URL: %s\n", youtubeURL)
+
+		// Start the stream
+		ctx := getContext()
+		if err := manager.Start(ctx, youtubeURL, streamName, port, opts); err != nil {
+			return withHint(fmt.Errorf("failed to start stream: %w", err))
+		}
+
+		if proc := manager.GetProcess(streamName); proc != nil {
+			printVerbose("  FFmpeg: %s\n", ffmpegCommandLine(stream.RedactCommand(proc.GetArgs())))
+		}
+
+		fmt.Println()
+		printStarted(opts)
+		var pid int
+		if s := manager.GetStream(streamName); s != nil {
+			pid = s.GetFFmpegPID()
+		}
+		printBackground(streamName, pid, false)
+		if err := checkRecorded(streamName, pid); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			fmt.Println("  Later commands and the server will not find this stream")
+		}
+	}
+
+	// Streams published elsewhere are read from their targets
+	if !stream.PublishesLocally(opts.Outputs) {
+		fmt.Println()
+		fmt.Println("Publishing to:")
+		for _, output := range opts.Outputs {
+			fmt.Printf("  %s\n", output)
+		}
+		return nil
+	}
+
+	// Host other machines reach this one by
+	networkHost := advertisedHost()
 
-	fmt.Println()
-	fmt.Println("Stream started successfully!")
 	fmt.Println()
 	fmt.Printf("RTSP URLs:\n")
-	fmt.Printf("  Local:   rtsp://localhost:%d/%s\n", port, streamName)
-	if localIP != "" {
-		fmt.Printf("  Network: rtsp://%s:%d/%s\n", localIP, port, streamName)
+	localURL := cfg.GetRTSPURL(port, streamName)
+	fmt.Printf("  Local:   %s\n", localURL)
+	if networkHost != "" {
+		fmt.Printf("  Network: %s\n", cfg.GetRTSPURLForHost(networkHost, port, streamName))
+	}
+	if opts.Substream != "" {
+		subPath := stream.SubstreamPath(streamName)
+		fmt.Printf("  Sub:     %s\n", cfg.GetRTSPURL(port, subPath))
+		if networkHost != "" {
+			fmt.Printf("  Sub Net: %s\n", cfg.GetRTSPURLForHost(networkHost, port, subPath))
+		}
+	}
+	if opts.AudioCopy {
+		audioPath := stream.AudioCopyPath(streamName)
+		fmt.Printf("  Audio:   %s\n", cfg.GetRTSPURL(port, audioPath))
+		if networkHost != "" {
+			fmt.Printf("  Aud Net: %s\n", cfg.GetRTSPURLForHost(networkHost, port, audioPath))
+		}
 	}
 	fmt.Println()
 	fmt.Println("Test with:")
-	fmt.Printf("  ffplay rtsp://localhost:%d/%s\n", port, streamName)
-	fmt.Printf("  vlc rtsp://localhost:%d/%s\n", port, streamName)
+	fmt.Printf("  ffplay %s\n", localURL)
+	fmt.Printf("  vlc %s\n", localURL)
 
 	return nil
 }
 
-// getLocalIP returns the local IP address
+// loadStoppedStream fills in the start flags from the stored definition of
+// a stopped stream, keeping flags set on the command line, and returns its
+// URL. ok is false if there is no stopped stream with that name.
+func loadStoppedStream(cmd *cobra.Command, name string) (url string, ok bool) {
+	data, err := store.Load(name)
+	if err != nil || !data.Stopped {
+		return "", false
+	}
+
+	flags := cmd.Flags()
+	streamName = name
+	if !flags.Changed("port") {
+		streamPort = data.Port
+	}
+	if !flags.Changed("audio-only") {
+		streamAudioOnly = data.AudioOnly
+	}
+	if !flags.Changed("loop") {
+		streamLoop = data.Loop
+	}
+	if !flags.Changed("on-demand") {
+		streamOnDemand = data.OnDemand
+	}
+	if !flags.Changed("transport") {
+		streamTransport = data.Transport
+	}
+	if !flags.Changed("format") && !flags.Changed("quality") {
+		streamFormat = data.YtdlpFormat
+	}
+	if !flags.Changed("max-bitrate") && data.MaxBitrate > 0 {
+		streamBitrate = strconv.FormatInt(data.MaxBitrate, 10)
+	}
+	if !flags.Changed("output") {
+		streamOutputs = data.Outputs
+	}
+	if !flags.Changed("substream") {
+		streamSubstream = data.Substream
+	}
+	if !flags.Changed("require-h264") {
+		streamH264 = data.RequireH264
+	}
+	if !flags.Changed("audio-copy") {
+		streamAudioCopy = data.AudioCopy
+	}
+	// Starting it again is not an accidental duplicate
+	streamForce = true
+
+	printVerbose("Starting stopped stream '%s' (%s)\n", name, data.YouTubeURL)
+	return data.YouTubeURL, true
+}
+
+// qualityUsage describes the --quality flag
+var qualityUsage = "video quality: " + strings.Join(extractor.Qualities(), ", ") + " (default: ytdlp.format)"
+
+// ytdlpFormat returns the yt-dlp format selector given with --format, or
+// else the one for --quality, or "" for the configured default
+func ytdlpFormat(format, quality string) (string, error) {
+	if format != "" {
+		return format, nil
+	}
+	if quality == "" {
+		return "", nil
+	}
+	return extractor.QualityFormat(quality)
+}
+
+// printStarted reports a started stream, which may not be ready yet if
+// the start did not wait for it
+func printStarted(opts stream.StartOptions) {
+	if opts.NoWait {
+		fmt.Println("FFmpeg started; the stream is not confirmed ready yet.")
+		return
+	}
+	fmt.Println("Stream started successfully!")
+}
+
+// printBackground reports the ffmpeg process of a started stream, which
+// keeps running after this command exits. Only a foreground server
+// monitors and reconnects it; one started later recovers it from storage.
+func printBackground(name string, pid int, monitored bool) {
+	fmt.Println()
+	fmt.Printf("Running in the background (ffmpeg PID: %d)\n", pid)
+	if monitored {
+		fmt.Println("  Monitored and reconnected by the foreground server")
+	} else {
+		fmt.Println("  Not monitored: no foreground server is running. Start one with")
+		fmt.Println("  \"youtube-rtsp-proxy server start --foreground\" to have it reconnected")
+		fmt.Println("  on failure; it takes over running streams.")
+	}
+	fmt.Printf("  Stop with: youtube-rtsp-proxy stop %s\n", name)
+}
+
+// checkRecorded verifies that a stream started in this process was stored
+// with its ffmpeg PID, which is how later commands find it
+func checkRecorded(name string, pid int) error {
+	if pid == 0 {
+		return fmt.Errorf("ffmpeg PID of stream '%s' is unknown", name)
+	}
+	data, err := store.Load(name)
+	if err != nil {
+		return fmt.Errorf("stream '%s' was not recorded: %w", name, err)
+	}
+	if data.FFmpegPID != pid {
+		return fmt.Errorf("stream '%s' was recorded with PID %d, not %d", name, data.FFmpegPID, pid)
+	}
+	return nil
+}
+
+// withHint adds a suggestion for what to do to an extraction failure
+func withHint(err error) error {
+	if hint := extractor.Hint(err); hint != "" {
+		return fmt.Errorf("%w\n  Hint: %s", err, hint)
+	}
+	return err
+}
+
+// deriveStreamName names a stream after its video title (see
+// Manager.NameFor) and prints the name. The extraction is kept for the
+// start that follows.
+func deriveStreamName(ctx context.Context, youtubeURL string, opts stream.StartOptions) string {
+	name, err := manager.NameFor(ctx, youtubeURL, opts)
+	if err != nil {
+		printVerbose("  Could not read the video title: %v\n", err)
+	}
+	fmt.Printf("Stream name: %s\n", name)
+	return name
+}
+
+// runStartDryRun extracts the stream and prints what would be proxied and
+// the ffmpeg command doing it, without starting MediaMTX or ffmpeg or
+// touching stored state
+func runStartDryRun(youtubeURL string, maxBitrate int64, derive bool) error {
+	ctx := getContext()
+
+	fmt.Printf("Extracting stream URL from YouTube...\n")
+	opts := stream.StartOptions{
+		AudioOnly: streamAudioOnly,
+		Loop:      streamLoop,
+		Transport: streamTransport,
+		Format:    streamFormat,
+
+		MaxBitrate: maxBitrate,
+		Outputs:    streamOutputs,
+		Substream:  streamSubstream,
+
+		RequireH264: streamH264,
+		AudioCopy:   streamAudioCopy,
+	}
+	if derive {
+		streamName = deriveStreamName(ctx, youtubeURL, opts)
+	}
+	plan, err := manager.PlanStart(ctx, youtubeURL, streamName, streamPort, opts)
+	if err != nil {
+		return withHint(err)
+	}
+	info := plan.Info
+	s := plan.Stream
+
+	fmt.Println()
+	fmt.Println("Dry run: stream was not started")
+	fmt.Println()
+	fmt.Printf("  Title:       %s\n", valueOrUnknown(info.Title))
+	fmt.Printf("  Video ID:    %s\n", valueOrUnknown(info.ID))
+	fmt.Printf("  Live:        %v\n", s.GetIsLive())
+	fmt.Printf("  Resolution:  %s\n", valueOrUnknown(info.Resolution))
+	fmt.Printf("  Format:      %s\n", valueOrUnknown(info.Format))
+	fmt.Printf("  Video codec: %s\n", valueOrUnknown(info.VideoCodec))
+	if streamAudioOnly {
+		fmt.Printf("  Mode:        audio only\n")
+	}
+	fmt.Printf("  Stream URL:  %s\n", dryRunURL(info.URL))
+	if info.AudioURL != "" {
+		fmt.Printf("  Audio URL:   %s\n", dryRunURL(info.AudioURL))
+	}
+
+	// Where it would be published
+	if stream.PublishesLocally(s.Outputs) {
+		fmt.Printf("  RTSP path:   %s\n", s.RTSPPath)
+		for _, c := range stream.Companions(s.RTSPPath, s.Substream, s.AudioCopy) {
+			label := strings.ToUpper(c.Name[:1]) + c.Name[1:] + ":"
+			fmt.Printf("  %-12s %s\n", label, c.Path)
+		}
+	}
+	for _, output := range s.Outputs {
+		if output != stream.LocalOutput {
+			fmt.Printf("  Output:      %s\n", output)
+		}
+	}
+
+	fmt.Println()
+	for _, warning := range plan.Warnings {
+		fmt.Printf("Note: %s\n", warning)
+	}
+	if plan.ArgsErr != nil {
+		fmt.Printf("FFmpeg would not be started: %v\n", plan.ArgsErr)
+		return nil
+	}
+	args := stream.RedactCommand(plan.Args)
+	if streamShowURLs {
+		args = stream.RedactHeaders(plan.Args)
+	}
+	fmt.Println("FFmpeg command:")
+	fmt.Printf("  %s\n", ffmpegCommandLine(args))
+
+	return nil
+}
+
+// ffmpegCommandLine returns the command line running ffmpeg with args, for
+// printing
+func ffmpegCommandLine(args []string) string {
+	quoted := []string{displayArg(cfg.FFmpeg.BinaryPath)}
+	for _, arg := range args {
+		quoted = append(quoted, displayArg(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// dryRunURLLength is how much of an extracted URL a dry run prints without
+// --show-urls
+const dryRunURLLength = 60
+
+// dryRunURL returns an extracted URL shortened for a dry run, as it is
+// signed for this host and expires, unless --show-urls is given
+func dryRunURL(u string) string {
+	if streamShowURLs || len(u) <= dryRunURLLength {
+		return u
+	}
+	return u[:dryRunURLLength] + "... (--show-urls prints it in full)"
+}
+
+// displayArg returns an argument of a printed command line, quoted only
+// if the shell would otherwise split or expand it
+func displayArg(s string) string {
+	plain := s != "" && !strings.ContainsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_./:=,+@%", r)
+	})
+	if plain {
+		return s
+	}
+	return shellQuote(s)
+}
+
+// valueOrUnknown returns v, or "unknown" when it is empty
+func valueOrUnknown(v string) string {
+	if v == "" {
+		return "unknown"
+	}
+	return v
+}
+
+// advertisedHost returns the host shown in "Network" RTSP URLs: --host,
+// server.advertise_host, the address of the --interface or
+// server.advertise_interface network interface, or else this machine's
+// address. It returns "" if there is none.
+func advertisedHost() string {
+	host := advertiseHost
+	if host == "" {
+		host = cfg.Server.AdvertiseHost
+	}
+	if host != "" {
+		// RTSP URLs bracket IPv6 addresses themselves
+		return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	}
+
+	name := advertiseInterface
+	if name == "" {
+		name = cfg.Server.AdvertiseInterface
+	}
+	if name != "" {
+		ip, err := interfaceIP(name)
+		if err == nil {
+			return ip
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v, using the detected address\n", err)
+	}
+	return getLocalIP()
+}
+
+// interfaceIP returns the address of a network interface, preferring IPv4
+// unless --prefer-ipv6 is given
+func interfaceIP(name string) (string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", fmt.Errorf("network interface %s: %w", name, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", fmt.Errorf("network interface %s: %w", name, err)
+	}
+	ip := preferredIP(addrs)
+	if ip == "" {
+		return "", fmt.Errorf("network interface %s has no usable address", name)
+	}
+	return ip, nil
+}
+
+// networkRTSPURL returns the RTSP URL of a path for other machines, or the
+// local one if this machine has no address to advertise
+func networkRTSPURL(port int, path string) string {
+	if host := advertisedHost(); host != "" {
+		return cfg.GetRTSPURLForHost(host, port, path)
+	}
+	return cfg.GetRTSPURL(port, path)
+}
+
+// defaultRouteTargets are dialed to find the address of the default route
+// of each IP version
+var defaultRouteTargets = map[bool]string{
+	false: "8.8.8.8:80",
+	true:  "[2001:4860:4860::8888]:80",
+}
+
+// getLocalIP returns the local IP address: that of the IPv4 default route,
+// else of the IPv6 one, else of any interface. --prefer-ipv6 tries IPv6
+// first.
 func getLocalIP() string {
-	// Try to get default route IP
-	conn, err := net.Dial("udp", "8.8.8.8:80")
-	if err == nil {
-		defer conn.Close()
+	// Try to get default route IP. Dialing UDP sends nothing; it fails at
+	// once when there is no route.
+	for _, ipv6 := range []bool{preferIPv6, !preferIPv6} {
+		conn, err := net.Dial("udp", defaultRouteTargets[ipv6])
+		if err != nil {
+			continue
+		}
 		localAddr := conn.LocalAddr().(*net.UDPAddr)
-		return localAddr.IP.String()
+		conn.Close()
+		if localAddr.IP.IsGlobalUnicast() {
+			return localAddr.IP.String()
+		}
 	}
 
 	// Fallback: iterate interfaces
@@ -99,14 +633,26 @@ func getLocalIP() string {
 	if err != nil {
 		return ""
 	}
+	return preferredIP(addrs)
+}
 
+// preferredIP returns the first IPv4 address of addrs, or else the first
+// IPv6 one (the other way round with --prefer-ipv6), skipping loopback and
+// link-local addresses
+func preferredIP(addrs []net.Addr) string {
+	var other string
 	for _, addr := range addrs {
-		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-			if ipnet.IP.To4() != nil {
-				return ipnet.IP.String()
-			}
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || !ipnet.IP.IsGlobalUnicast() {
+			continue
+		}
+		if isIPv6 := ipnet.IP.To4() == nil; isIPv6 == preferIPv6 {
+			return ipnet.IP.String()
+		}
+		if other == "" {
+			other = ipnet.IP.String()
 		}
 	}
 
-	return ""
+	return other
 }
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"net"
+	"testing"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
+)
+
+// usableInterface returns a network interface with an address to
+// advertise and that address, skipping the test if there is none
+func usableInterface(t *testing.T) (string, string) {
+	t.Helper()
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, iface := range ifaces {
+		if ip, err := interfaceIP(iface.Name); err == nil {
+			return iface.Name, ip
+		}
+	}
+	t.Skip("no network interface with a global address")
+	return "", ""
+}
+
+func TestAdvertisedHostPrecedence(t *testing.T) {
+	iface, ifaceIP := usableInterface(t)
+	detected := getLocalIP()
+
+	tests := []struct {
+		name                string
+		flagHost, cfgHost   string
+		flagIface, cfgIface string
+		want                string
+	}{
+		{"flag host over all", "flag.lan", "cfg.lan", iface, iface, "flag.lan"},
+		{"config host over interfaces", "", "cfg.lan", iface, iface, "cfg.lan"},
+		{"bracketed IPv6 host", "[fd00::20]", "", "", "", "fd00::20"},
+		{"flag interface over config", "", "", iface, "missing0", ifaceIP},
+		{"config interface", "", "", "", iface, ifaceIP},
+		{"unknown interface falls back", "", "", "missing0", "", detected},
+		{"detected", "", "", "", "", detected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg = &config.Config{Server: config.ServerConfig{AdvertiseHost: tt.cfgHost, AdvertiseInterface: tt.cfgIface}}
+			advertiseHost, advertiseInterface = tt.flagHost, tt.flagIface
+			t.Cleanup(func() {
+				cfg = nil
+				advertiseHost, advertiseInterface = "", ""
+			})
+
+			if got := advertisedHost(); got != tt.want {
+				t.Errorf("advertisedHost = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// ipNets returns addrs as interface addresses
+func ipNets(t *testing.T, addrs ...string) []net.Addr {
+	t.Helper()
+	var nets []net.Addr
+	for _, addr := range addrs {
+		ip, ipnet, err := net.ParseCIDR(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ipnet.IP = ip
+		nets = append(nets, ipnet)
+	}
+	return nets
+}
+
+func TestPreferredIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		addrs      []string
+		preferIPv6 bool
+		want       string
+	}{
+		{"IPv4 first", []string{"127.0.0.1/8", "fd00::2/64", "192.0.2.2/24"}, false, "192.0.2.2"},
+		{"IPv6 only", []string{"::1/128", "fe80::1/64", "2001:db8::2/64"}, false, "2001:db8::2"},
+		{"prefer IPv6", []string{"192.0.2.2/24", "fe80::1/64", "2001:db8::2/64"}, true, "2001:db8::2"},
+		{"prefer IPv6 without one", []string{"192.0.2.2/24", "fe80::1/64"}, true, "192.0.2.2"},
+		{"loopback and link-local only", []string{"127.0.0.1/8", "::1/128", "fe80::1/64", "169.254.1.1/16"}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preferIPv6 = tt.preferIPv6
+			t.Cleanup(func() { preferIPv6 = false })
+
+			if got := preferredIP(ipNets(t, tt.addrs...)); got != tt.want {
+				t.Errorf("preferredIP(%v) = %q, want %q", tt.addrs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetworkRTSPURLBracketsIPv6(t *testing.T) {
+	cfg = &config.Config{Server: config.ServerConfig{RTSPPort: 8554}}
+	t.Cleanup(func() {
+		cfg = nil
+		advertiseHost = ""
+	})
+
+	for host, want := range map[string]string{
+		"192.0.2.2":     "rtsp://192.0.2.2:8554/news",
+		"2001:db8::2":   "rtsp://[2001:db8::2]:8554/news",
+		"[2001:db8::2]": "rtsp://[2001:db8::2]:8554/news",
+	} {
+		advertiseHost = host
+		if got := networkRTSPURL(0, "/news"); got != want {
+			t.Errorf("networkRTSPURL with host %s = %s, want %s", host, got, want)
+		}
+	}
+}
+
+func TestCheckRecorded(t *testing.T) {
+	fs, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := store
+	store = fs
+	t.Cleanup(func() { store = prev })
+
+	if err := fs.Save(&storage.StreamData{Name: "news", YouTubeURL: "https://youtu.be/abc123", FFmpegPID: 4242}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkRecorded("news", 4242); err != nil {
+		t.Errorf("stream stored with its PID: %v", err)
+	}
+	for _, tt := range []struct {
+		name string
+		pid  int
+	}{
+		{"news", 0},      // PID unknown
+		{"news", 1234},   // stored with another PID
+		{"weather", 999}, // not stored
+	} {
+		if err := checkRecorded(tt.name, tt.pid); err == nil {
+			t.Errorf("checkRecorded(%s, %d) passed, want an error", tt.name, tt.pid)
+		}
+	}
+}
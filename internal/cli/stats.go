@@ -0,0 +1,253 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsInterval time.Duration
+	statsSort     string
+	statsFormat   string
+)
+
+// statsSorts are the orders --sort accepts
+var statsSorts = []string{"name", "bitrate", "errors", "uptime"}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show bandwidth handled by the proxy",
+	Long: `Show bytes received and sent by MediaMTX, in total and per stream, with
+the bytes each stream ingested over the last 24 hours and how many of them
+were wasted, ingested while nobody was watching.
+
+With --interval, the counters are sampled twice to show per-second rates.
+Paths can be ordered with --sort: by name, by bitrate (most traffic per
+second first, needs --interval), by errors (most first) or by uptime
+(longest first). --format json prints an array of objects instead of the
+table, for scripts.
+
+Examples:
+  youtube-rtsp-proxy stats
+  youtube-rtsp-proxy stats --interval 5s
+  youtube-rtsp-proxy stats --interval 5s --sort bitrate
+  youtube-rtsp-proxy stats --format json | jq '.[] | select(.errors > 0)'`,
+	Args: cobra.NoArgs,
+	RunE: runStats,
+}
+
+func init() {
+	statsCmd.Flags().DurationVar(&statsInterval, "interval", 0, "sample twice this far apart to show rates (0 = totals only)")
+	statsCmd.Flags().StringVar(&statsSort, "sort", "name", "order of the paths: "+strings.Join(statsSorts, ", "))
+	statsCmd.Flags().StringVar(&statsFormat, "format", "table", "output format: "+strings.Join(outputFormats, ", "))
+}
+
+// pathStats is the traffic of one MediaMTX path
+type pathStats struct {
+	name           string
+	received, sent int64
+	inRate         float64 // bytes/sec, with --interval
+	outRate        float64
+	readers        int
+
+	// stream is the stream publishing to the path, if any; errors,
+	// uptime and the usage of the last 24 hours are its own
+	stream         string
+	errors         int
+	uptime         time.Duration
+	ingest, wasted int64
+}
+
+// statsEntry is one path in the output of --format json
+type statsEntry struct {
+	Path          string  `json:"path"`
+	Stream        string  `json:"stream"`
+	BytesReceived int64   `json:"bytes_received"`
+	BytesSent     int64   `json:"bytes_sent"`
+	Viewers       int     `json:"viewers"`
+	InRate        float64 `json:"in_rate"` // bytes/sec, 0 without --interval
+	OutRate       float64 `json:"out_rate"`
+	Errors        int     `json:"errors"`
+	UptimeSeconds int64   `json:"uptime_seconds"`
+	IngestBytes   int64   `json:"ingest_bytes"` // within the last 24 hours
+	WastedBytes   int64   `json:"wasted_bytes"` // ingested with no readers
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	if statsInterval < 0 {
+		return fmt.Errorf("invalid interval: %v", statsInterval)
+	}
+	if err := checkChoice("sort", statsSort, statsSorts); err != nil {
+		return err
+	}
+	if statsSort == "bitrate" && statsInterval == 0 {
+		return fmt.Errorf("--sort bitrate needs --interval to measure rates")
+	}
+	if err := checkChoice("format", statsFormat, outputFormats); err != nil {
+		return err
+	}
+
+	first, err := srv.ListPaths()
+	if err != nil {
+		return fmt.Errorf("failed to read MediaMTX paths: %w", err)
+	}
+	firstAt := time.Now()
+
+	stats := make(map[string]*pathStats)
+	for _, p := range first {
+		stats[p.Name] = &pathStats{name: p.Name, received: p.BytesReceived, sent: p.BytesSent, readers: p.ReaderCount()}
+	}
+
+	if statsInterval > 0 {
+		// Keep stdout parseable with --format json
+		progress := io.Writer(os.Stdout)
+		if statsFormat == "json" {
+			progress = os.Stderr
+		}
+		fmt.Fprintf(progress, "Sampling for %v...\n", statsInterval)
+		time.Sleep(statsInterval)
+
+		second, err := srv.ListPaths()
+		if err != nil {
+			return fmt.Errorf("failed to read MediaMTX paths: %w", err)
+		}
+		now := time.Now()
+		for _, p := range second {
+			st, ok := stats[p.Name]
+			if !ok {
+				continue // appeared during sampling, no baseline
+			}
+			st.inRate = byteRate(byteSnapshot{st.received, firstAt}, byteSnapshot{p.BytesReceived, now})
+			st.outRate = byteRate(byteSnapshot{st.sent, firstAt}, byteSnapshot{p.BytesSent, now})
+			st.received, st.sent, st.readers = p.BytesReceived, p.BytesSent, p.ReaderCount()
+		}
+	}
+
+	// Errors and uptime come from the stream publishing to each path
+	now := time.Now()
+	for _, info := range manager.List() {
+		st, ok := stats[strings.TrimPrefix(info.RTSPPath, "/")]
+		if !ok {
+			continue
+		}
+		st.stream, st.errors = info.Name, info.ErrorCount
+		st.ingest, st.wasted = info.IngestBytes, info.WastedBytes
+		if !info.StartedAt.IsZero() {
+			st.uptime = now.Sub(info.StartedAt)
+		}
+	}
+
+	list := sortedStats(stats, statsSort)
+	if statsFormat == "json" {
+		return writeJSON(os.Stdout, statsEntries(list))
+	}
+	renderStats(os.Stdout, list, statsInterval > 0)
+	return nil
+}
+
+// sortedStats returns path stats in the order by, one of statsSorts. Ties
+// keep the order of the path names.
+func sortedStats(stats map[string]*pathStats, by string) []*pathStats {
+	list := make([]*pathStats, 0, len(stats))
+	for _, st := range stats {
+		list = append(list, st)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	switch by {
+	case "bitrate":
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].inRate+list[i].outRate > list[j].inRate+list[j].outRate
+		})
+	case "errors":
+		sort.SliceStable(list, func(i, j int) bool { return list[i].errors > list[j].errors })
+	case "uptime":
+		sort.SliceStable(list, func(i, j int) bool { return list[i].uptime > list[j].uptime })
+	}
+	return list
+}
+
+// statsEntries converts path stats to their JSON form
+func statsEntries(list []*pathStats) []statsEntry {
+	entries := make([]statsEntry, 0, len(list))
+	for _, st := range list {
+		entries = append(entries, statsEntry{
+			Path:          st.name,
+			Stream:        st.stream,
+			BytesReceived: st.received,
+			BytesSent:     st.sent,
+			Viewers:       st.readers,
+			InRate:        st.inRate,
+			OutRate:       st.outRate,
+			Errors:        st.errors,
+			UptimeSeconds: int64(st.uptime.Seconds()),
+			IngestBytes:   st.ingest,
+			WastedBytes:   st.wasted,
+		})
+	}
+	return entries
+}
+
+// renderStats prints the per-stream breakdown and the totals
+func renderStats(w io.Writer, list []*pathStats, withRates bool) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Proxy Bandwidth")
+	fmt.Fprintln(w, "══════════════════════════════════════════════════════════════")
+	fmt.Fprintln(w)
+
+	if len(list) == 0 {
+		fmt.Fprintln(w, "  No active paths")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "══════════════════════════════════════════════════════════════")
+		return
+	}
+
+	header := fmt.Sprintf("  %-16s %12s %12s %8s %7s %8s %12s %12s",
+		"STREAM", "RECEIVED", "SENT", "VIEWERS", "ERRORS", "UPTIME", "INGEST 24h", "WASTED 24h")
+	if withRates {
+		header += fmt.Sprintf(" %12s %12s", "IN/s", "OUT/s")
+	}
+	fmt.Fprintln(w, header)
+
+	var total pathStats
+	for _, st := range list {
+		fmt.Fprintln(w, formatStatsRow(st, withRates))
+		total.received += st.received
+		total.sent += st.sent
+		total.readers += st.readers
+		total.errors += st.errors
+		total.ingest += st.ingest
+		total.wasted += st.wasted
+		total.inRate += st.inRate
+		total.outRate += st.outRate
+	}
+
+	total.name = "TOTAL"
+	fmt.Fprintln(w, "  "+strings.Repeat("─", len(header)-2))
+	fmt.Fprintln(w, formatStatsRow(&total, withRates))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "══════════════════════════════════════════════════════════════")
+}
+
+// formatStatsRow formats one line of the stats table
+func formatStatsRow(st *pathStats, withRates bool) string {
+	uptime := "-"
+	if st.uptime > 0 {
+		uptime = formatDuration(st.uptime)
+	}
+	ingest, wasted := "-", "-"
+	if st.ingest > 0 {
+		ingest, wasted = formatBytes(st.ingest), formatBytes(st.wasted)
+	}
+	row := fmt.Sprintf("  %-16s %12s %12s %8d %7d %8s %12s %12s",
+		truncateText(st.name, 16), formatBytes(st.received), formatBytes(st.sent), st.readers, st.errors, uptime, ingest, wasted)
+	if withRates {
+		row += fmt.Sprintf(" %12s %12s", formatBytes(int64(st.inRate))+"/s", formatBytes(int64(st.outRate))+"/s")
+	}
+	return row
+}
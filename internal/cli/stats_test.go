@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with testdata/<name>.golden, rewriting the file
+// instead with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch\n--- got\n%s\n--- want\n%s", path, got, want)
+	}
+}
+
+func testStats() map[string]*pathStats {
+	return map[string]*pathStats{
+		"news": {
+			name: "news", received: 512 * 1024 * 1024, sent: 3 * 1024 * 1024 * 1024, readers: 6,
+			inRate: 400 * 1024, outRate: 2400 * 1024,
+			stream: "news", errors: 1, uptime: 26*time.Hour + 5*time.Minute,
+			ingest: 4 * 1024 * 1024 * 1024, wasted: 256 * 1024 * 1024,
+		},
+		"lofi": {
+			name: "lofi", received: 80 * 1024 * 1024, sent: 0, readers: 0,
+			inRate: 20 * 1024,
+			stream: "lofi", errors: 7, uptime: 90 * time.Second,
+			ingest: 80 * 1024 * 1024, wasted: 80 * 1024 * 1024,
+		},
+		"a-very-long-stream-name": {
+			name: "a-very-long-stream-name", received: 900, sent: 1800, readers: 2,
+		},
+	}
+}
+
+func TestRenderStatsGolden(t *testing.T) {
+	tests := []struct {
+		name      string
+		sortBy    string
+		withRates bool
+	}{
+		{"stats_name", "name", false},
+		{"stats_bitrate_rates", "bitrate", true},
+		{"stats_errors", "errors", false},
+		{"stats_uptime", "uptime", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			renderStats(&buf, sortedStats(testStats(), tt.sortBy), tt.withRates)
+			checkGolden(t, tt.name, buf.Bytes())
+		})
+	}
+}
+
+func TestRenderStatsEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	renderStats(&buf, nil, true)
+	checkGolden(t, "stats_empty", buf.Bytes())
+}
+
+func TestRenderStatsColumnsAligned(t *testing.T) {
+	var buf bytes.Buffer
+	renderStats(&buf, sortedStats(testStats(), "name"), true)
+
+	// The header, every row and the total are one table of equal width
+	var widths []int
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "  ") && !strings.HasPrefix(line, "  ─") {
+			widths = append(widths, len([]rune(line)))
+		}
+	}
+	if len(widths) != 5 {
+		t.Fatalf("got %d table lines, want 5:\n%s", len(widths), buf.String())
+	}
+	for _, w := range widths[1:] {
+		if w != widths[0] {
+			t.Errorf("line widths %v differ:\n%s", widths, buf.String())
+			break
+		}
+	}
+}
+
+func TestSortedStats(t *testing.T) {
+	tests := []struct {
+		by   string
+		want []string
+	}{
+		{"name", []string{"a-very-long-stream-name", "lofi", "news"}},
+		{"bitrate", []string{"news", "lofi", "a-very-long-stream-name"}},
+		{"errors", []string{"lofi", "news", "a-very-long-stream-name"}},
+		{"uptime", []string{"news", "lofi", "a-very-long-stream-name"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.by, func(t *testing.T) {
+			var got []string
+			for _, st := range sortedStats(testStats(), tt.by) {
+				got = append(got, st.name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("sortedStats(%q) = %v, want %v", tt.by, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckChoice(t *testing.T) {
+	if err := checkChoice("sort", "errors", statsSorts); err != nil {
+		t.Errorf("checkChoice(errors) = %v, want nil", err)
+	}
+	err := checkChoice("sort", "size", statsSorts)
+	if err == nil {
+		t.Fatal("checkChoice(size) = nil, want an error")
+	}
+	want := `invalid --sort "size" (must be one of: name, bitrate, errors, uptime)`
+	if err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
+
+func TestStatsEntriesJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, statsEntries(sortedStats(testStats(), "name"))); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "stats_json", buf.Bytes())
+}
@@ -2,9 +2,11 @@ package cli
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
 )
 
 var statusCmd = &cobra.Command{
@@ -55,8 +57,22 @@ func showServerStatus() error {
 
 	fmt.Println()
 
+	// yt-dlp breaks whenever YouTube changes, so its version matters
+	if version, err := ytdlpExt.Version(); err == nil {
+		fmt.Printf("  yt-dlp:      %s\n", version)
+	} else {
+		fmt.Printf("  yt-dlp:      ○ Not found\n")
+	}
+
+	fmt.Println()
+
 	// Monitor status
-	if mon.IsRunning() {
+	daemon := connectDaemon()
+	if daemon != nil {
+		fmt.Printf("  Monitor:     ● Running (daemon: %s)\n", cfg.GetDaemonSocketPath())
+		fmt.Printf("  Check Interval: %v\n", cfg.Monitor.HealthCheckInterval)
+		fmt.Printf("  URL Refresh:    %v\n", cfg.Monitor.URLRefreshInterval)
+	} else if mon.IsRunning() {
 		fmt.Printf("  Monitor:     ● Running\n")
 		fmt.Printf("  Check Interval: %v\n", cfg.Monitor.HealthCheckInterval)
 		fmt.Printf("  URL Refresh:    %v\n", cfg.Monitor.URLRefreshInterval)
@@ -67,7 +83,10 @@ func showServerStatus() error {
 	fmt.Println()
 
 	// Active streams count
-	streams := manager.List()
+	streams, err := listStreams()
+	if err != nil {
+		return err
+	}
 	runningCount := 0
 	for _, s := range streams {
 		if s.StateString == "running" {
@@ -83,7 +102,7 @@ func showServerStatus() error {
 }
 
 func showStreamStatus(name string) error {
-	info, err := manager.Status(name)
+	info, err := streamStatus(name)
 	if err != nil {
 		return err
 	}
@@ -106,17 +125,78 @@ func showStreamStatus(name string) error {
 	}
 
 	fmt.Printf("  Status:       %s %s\n", statusIcon, info.StateString)
+	if retry := formatRetry(*info); retry != "" {
+		fmt.Printf("  Reconnect:    %s\n", retry)
+	}
 	fmt.Printf("  Stream ID:    %s\n", info.ID)
 	fmt.Printf("  FFmpeg PID:   %d\n", info.FFmpegPID)
+	if info.Transport != "" {
+		fmt.Printf("  Transport:    %s\n", info.Transport)
+	}
+	if mode := formatMode(*info); mode != "" {
+		fmt.Printf("  Mode:         %s\n", mode)
+	}
+	if info.LogLevel != "" {
+		fmt.Printf("  Log level:    %s\n", info.LogLevel)
+	}
+
+	if info.Title != "" || info.Resolution != "" {
+		fmt.Println()
+		fmt.Println("Source:")
+		if info.Title != "" {
+			fmt.Printf("  Title:        %s\n", info.Title)
+		}
+		if info.Resolution != "" {
+			fmt.Printf("  Resolution:   %s\n", info.Resolution)
+		}
+		if info.Format != "" {
+			fmt.Printf("  Format:       %s\n", info.Format)
+		}
+		if info.VideoCodec != "" {
+			codec := info.VideoCodec
+			if info.RequireH264 && codec != "none" && !stream.IsH264(codec) && stream.CopiesVideo(cfg.FFmpeg.OutputOptions) {
+				codec += " (re-encoded to H.264)"
+			}
+			fmt.Printf("  Video codec:  %s\n", codec)
+		}
+		fmt.Printf("  Live:         %v\n", info.IsLive)
+	}
 
 	fmt.Println()
 	fmt.Println("URLs:")
-	localIP := getLocalIP()
-	fmt.Printf("  RTSP Local:   rtsp://localhost:%d%s\n", info.Port, info.RTSPPath)
-	if localIP != "" {
-		fmt.Printf("  RTSP Network: rtsp://%s:%d%s\n", localIP, info.Port, info.RTSPPath)
+	if stream.PublishesLocally(info.Outputs) {
+		networkHost := advertisedHost()
+		fmt.Printf("  RTSP Local:   %s\n", cfg.GetRTSPURL(info.Port, info.RTSPPath))
+		if networkHost != "" {
+			fmt.Printf("  RTSP Network: %s\n", cfg.GetRTSPURLForHost(networkHost, info.Port, info.RTSPPath))
+		}
+		if info.Substream != "" {
+			subPath := stream.SubstreamPath(info.RTSPPath)
+			fmt.Printf("  Sub Local:    %s (%s)\n", cfg.GetRTSPURL(info.Port, subPath), info.Substream)
+			if networkHost != "" {
+				fmt.Printf("  Sub Network:  %s\n", cfg.GetRTSPURLForHost(networkHost, info.Port, subPath))
+			}
+		}
+		if info.AudioCopy {
+			audioPath := stream.AudioCopyPath(info.RTSPPath)
+			fmt.Printf("  Audio Local:  %s\n", cfg.GetRTSPURL(info.Port, audioPath))
+			if networkHost != "" {
+				fmt.Printf("  Audio Net:    %s\n", cfg.GetRTSPURLForHost(networkHost, info.Port, audioPath))
+			}
+		}
+	}
+	for _, output := range info.Outputs {
+		if output != stream.LocalOutput {
+			fmt.Printf("  Output:       %s\n", output)
+		}
 	}
 	fmt.Printf("  YouTube:      %s\n", info.YouTubeURL)
+	if info.VideoID != "" {
+		fmt.Printf("  Video ID:     %s\n", info.VideoID)
+		if !info.VideoIDChangedAt.IsZero() {
+			fmt.Printf("  ID Changed:   %s ago\n", formatDuration(time.Since(info.VideoIDChangedAt).Round(time.Second)))
+		}
+	}
 
 	fmt.Println()
 	fmt.Println("Timing:")
@@ -133,6 +213,25 @@ func showStreamStatus(name string) error {
 		fmt.Printf("  Last Check:   %s ago\n", formatDuration(time.Since(info.LastChecked).Round(time.Second)))
 	}
 
+	if p := info.Progress; p != nil {
+		fmt.Println()
+		fmt.Println("FFmpeg:")
+		fmt.Printf("  Speed:        %s\n", formatProgressValue(p.Speed, "%.2fx"))
+		fmt.Printf("  FPS:          %s\n", formatProgressValue(p.FPS, "%.1f"))
+		fmt.Printf("  Bitrate:      %s\n", formatProgressValue(p.BitrateKbps, "%.1f kbit/s"))
+		if p.Frame > 0 {
+			fmt.Printf("  Frames:       %d\n", p.Frame)
+		}
+		fmt.Printf("  Reported:     %s ago\n", formatDuration(time.Since(p.UpdatedAt).Round(time.Second)))
+	}
+
+	fmt.Println()
+	fmt.Println("Usage (24h):")
+	fmt.Printf("  Ingest:       %s\n", formatUsage(info.IngestBytes, info.WastedBytes))
+	if info.ByteRate > 0 {
+		fmt.Printf("  Ingest Rate:  %s/s\n", formatBytes(int64(info.ByteRate)))
+	}
+
 	if info.ErrorCount > 0 {
 		fmt.Println()
 		fmt.Println("Errors:")
@@ -153,9 +252,51 @@ func showStreamStatus(name string) error {
 		fmt.Printf("  Ready:          %v\n", pathInfo.Ready)
 		fmt.Printf("  Bytes Received: %d\n", pathInfo.BytesReceived)
 		fmt.Printf("  Bytes Sent:     %d\n", pathInfo.BytesSent)
+		if pathInfo.Source != nil {
+			fmt.Printf("  Source:         %s\n", pathInfo.Source.Type)
+		}
+		if len(pathInfo.Tracks) > 0 {
+			fmt.Printf("  Tracks:         %s\n", strings.Join(pathInfo.Tracks, ", "))
+		}
+		fmt.Printf("  Viewers:        %d\n", pathInfo.ReaderCount())
+		for _, c := range stream.Companions(info.RTSPPath, info.Substream, info.AudioCopy) {
+			subInfo, err := srv.GetPathInfo(c.Path)
+			fmt.Println()
+			fmt.Printf("  %s:\n", strings.ToUpper(c.Name[:1])+c.Name[1:])
+			if err != nil {
+				fmt.Printf("    Ready:          false (%v)\n", err)
+			} else {
+				fmt.Printf("    Ready:          %v\n", subInfo.Ready)
+				fmt.Printf("    Bytes Received: %d\n", subInfo.BytesReceived)
+				fmt.Printf("    Viewers:        %d\n", subInfo.ReaderCount())
+			}
+		}
 		fmt.Println()
 		fmt.Println("══════════════════════════════════════════════════════════════")
 	}
 
 	return nil
 }
+
+// formatProgressValue formats an ffmpeg progress value, which is zero when
+// ffmpeg did not report it
+func formatProgressValue(v float64, format string) string {
+	if v <= 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf(format, v)
+}
+
+// formatRetry describes a pending reconnect, e.g. "next retry in 42s
+// (attempt 4/10)", or returns "" if none is pending
+func formatRetry(info stream.Info) string {
+	if info.ReconnectAttempt == 0 {
+		return ""
+	}
+	attempt := fmt.Sprintf("(attempt %d/%d)", info.ReconnectAttempt, cfg.Monitor.Reconnect.MaxAttempts)
+	wait := time.Until(info.NextRetryAt).Round(time.Second)
+	if wait <= 0 {
+		return "retrying now " + attempt
+	}
+	return fmt.Sprintf("next retry in %s %s", formatDuration(wait), attempt)
+}
@@ -4,13 +4,21 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/api"
 )
 
+var stopKeepData bool
+
 var stopCmd = &cobra.Command{
 	Use:   "stop <stream-name|all>",
 	Short: "Stop a stream or all streams",
 	Long: `Stop a specific stream or all running streams.
 
+Stopped streams are kept and can be started again by name, or deleted
+with "youtube-rtsp-proxy remove". --keep-data is accepted for scripts
+written for older versions, which deleted the stream data on stop; the
+data is always kept now.
+
 Examples:
   youtube-rtsp-proxy stop lofi
   youtube-rtsp-proxy stop all`,
@@ -18,9 +26,17 @@ Examples:
 	RunE: runStop,
 }
 
+func init() {
+	stopCmd.Flags().BoolVar(&stopKeepData, "keep-data", false, "keep the stored stream data (always done; accepted for compatibility)")
+}
+
 func runStop(cmd *cobra.Command, args []string) error {
 	target := args[0]
 
+	if daemon := connectDaemon(); daemon != nil {
+		return stopViaDaemon(daemon, target)
+	}
+
 	if target == "all" {
 		fmt.Println("Stopping all streams...")
 		if err := manager.StopAll(); err != nil {
@@ -39,3 +55,32 @@ func runStop(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// stopViaDaemon stops streams in the running daemon
+func stopViaDaemon(daemon *api.Client, target string) error {
+	if target != "all" {
+		fmt.Printf("Stopping stream '%s'...\n", target)
+		if err := daemon.Stop(target); err != nil {
+			return fmt.Errorf("failed to stop stream: %w", err)
+		}
+		fmt.Printf("Stream '%s' stopped.\n", target)
+		return nil
+	}
+
+	fmt.Println("Stopping all streams...")
+	streams, err := daemon.List()
+	if err != nil {
+		return fmt.Errorf("failed to stop streams: %w", err)
+	}
+	var lastErr error
+	for _, s := range streams {
+		if err := daemon.Stop(s.Name); err != nil {
+			lastErr = err
+		}
+	}
+	if lastErr != nil {
+		return fmt.Errorf("failed to stop streams: %w", lastErr)
+	}
+	fmt.Println("All streams stopped.")
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// cbreakInput reports that reading single key presses is not supported on
+// this platform
+func cbreakInput(f *os.File) (func(), error) {
+	return nil, fmt.Errorf("key input is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+)
+
+var testCmd = &cobra.Command{
+	Use:   "test <youtube-url>",
+	Short: "Check that a URL can be proxied, without starting a stream",
+	Long: `Extract a URL as "start" would and print what it resolves to: title,
+live status, resolution, format and when the extracted URL expires.
+
+With --probe, FFmpeg also reads a few seconds of the source to confirm it
+actually plays, reporting its codecs and bitrate. Neither MediaMTX nor any
+stream is started and nothing is stored. Exits with a non-zero status if
+the URL does not work, for checking favorites in scripts.
+
+Examples:
+  youtube-rtsp-proxy test "https://www.youtube.com/watch?v=jfKfPfyJRdk"
+  youtube-rtsp-proxy test --probe "https://www.youtube.com/watch?v=jfKfPfyJRdk"
+  youtube-rtsp-proxy test --probe --format "best[height<=720]" <url>`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runTest,
+}
+
+var (
+	testProbe     bool
+	testProbeTime time.Duration
+	testAudioOnly bool
+	testFormat    string
+)
+
+func init() {
+	testCmd.Flags().BoolVar(&testProbe, "probe", false, "read the source with FFmpeg to check that it plays")
+	testCmd.Flags().DurationVar(&testProbeTime, "probe-time", 5*time.Second, "how much of the source --probe reads")
+	testCmd.Flags().BoolVar(&testAudioOnly, "audio-only", false, "extract audio only, as start --audio-only does")
+	testCmd.Flags().StringVar(&testFormat, "format", "", "yt-dlp format selector (default: ytdlp.format)")
+}
+
+func runTest(cmd *cobra.Command, args []string) error {
+	youtubeURL := args[0]
+	ctx := getContext()
+
+	fmt.Printf("Extracting stream URL...\n")
+	info, err := ext.Extract(ctx, youtubeURL, extractor.ExtractOptions{AudioOnly: testAudioOnly, Format: testFormat})
+	if err != nil {
+		return withHint(fmt.Errorf("failed to extract stream URL: %w", err))
+	}
+
+	// Extract only reports live status when the metadata fetch succeeded
+	isLive := info.IsLive
+	if info.ID == "" {
+		if isLive, err = ext.IsLiveStream(ctx, youtubeURL); err != nil {
+			printVerbose("  Live check failed: %v\n", err)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("  Title:       %s\n", valueOrUnknown(info.Title))
+	fmt.Printf("  Video ID:    %s\n", valueOrUnknown(info.ID))
+	fmt.Printf("  Live:        %v\n", isLive)
+	fmt.Printf("  Resolution:  %s\n", valueOrUnknown(info.Resolution))
+	fmt.Printf("  Format:      %s\n", valueOrUnknown(info.Format))
+	fmt.Printf("  Video codec: %s\n", valueOrUnknown(info.VideoCodec))
+	if expires, ok := extractor.URLExpiry(info.URL); ok {
+		left := "expired"
+		if d := time.Until(expires); d > 0 {
+			left = "in " + formatDuration(d)
+		}
+		fmt.Printf("  Expires:     %s (%s)\n", expires.Format("2006-01-02 15:04:05"), left)
+	} else {
+		fmt.Printf("  Expires:     unknown\n")
+	}
+	fmt.Printf("  Stream URL:  %s\n", info.URL)
+	if info.AudioURL != "" {
+		fmt.Printf("  Audio URL:   %s\n", info.AudioURL)
+	}
+
+	if !testProbe {
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Printf("Reading %v of the source with FFmpeg...\n", testProbeTime)
+	ffmpegMgr := stream.NewFFmpegManager(&cfg.FFmpeg)
+	result, err := ffmpegMgr.Probe(ctx, info.URL, info.AudioURL, info.HTTPHeaders, testProbeTime)
+	if err != nil {
+		return fmt.Errorf("probe failed: %w", err)
+	}
+
+	fmt.Println()
+	for _, s := range result.Streams {
+		fmt.Printf("  %s\n", s)
+	}
+	fmt.Printf("  Read:        %s\n", formatDuration(result.Read))
+	if result.BitrateKbps > 0 {
+		fmt.Printf("  Bitrate:     %.0f kbit/s\n", result.BitrateKbps)
+	} else {
+		fmt.Printf("  Bitrate:     unknown\n")
+	}
+	fmt.Println()
+	fmt.Println("Source is playable.")
+	return nil
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+)
+
+var updateDownload bool
+
+var updateCmd = &cobra.Command{
+	Use:   "update <ytdlp>",
+	Short: "Update external dependencies",
+	Long: `Update the external binaries the proxy depends on.
+
+Commands:
+  ytdlp - Update yt-dlp to its latest release
+
+Examples:
+  youtube-rtsp-proxy update ytdlp
+  youtube-rtsp-proxy update ytdlp --download`,
+}
+
+var updateYtdlpCmd = &cobra.Command{
+	Use:   "ytdlp",
+	Short: "Update yt-dlp to its latest release",
+	Long: `Update yt-dlp to its latest release. YouTube regularly changes in ways
+that break older yt-dlp releases, failing extraction with errors such as
+"Unable to extract" or "Please update yt-dlp".
+
+yt-dlp is updated with "yt-dlp -U", unless it is the copy installed in the
+data dir by --download, which is replaced with a freshly downloaded release.
+A yt-dlp installed with pip is updated with: pip install -U yt-dlp
+
+--download installs the latest release binary into <data_dir>/bin/yt-dlp;
+point ytdlp.binary_path at it to have the proxy use and update that copy.
+
+Examples:
+  youtube-rtsp-proxy update ytdlp
+  youtube-rtsp-proxy update ytdlp --download`,
+	Args: cobra.NoArgs,
+	RunE: runUpdateYtdlp,
+}
+
+func init() {
+	updateYtdlpCmd.Flags().BoolVar(&updateDownload, "download", false, "install the latest release binary into the data dir")
+
+	updateCmd.AddCommand(updateYtdlpCmd)
+}
+
+func runUpdateYtdlp(cmd *cobra.Command, args []string) error {
+	ctx := getContext()
+	managed := extractor.ManagedYtdlpPath(cfg.Storage.DataDir)
+
+	if updateDownload && !extractor.IsManagedYtdlp(cfg.Ytdlp.BinaryPath, managed) {
+		fmt.Println("Downloading the latest yt-dlp release...")
+		if err := extractor.DownloadYtdlp(ctx, managed); err != nil {
+			return err
+		}
+		version, err := extractor.NewYtdlpExtractor(managed, 0, "").CheckBinary()
+		if err != nil {
+			return fmt.Errorf("downloaded yt-dlp does not run: %w", err)
+		}
+		fmt.Printf("yt-dlp %s installed: %s\n", version, managed)
+		fmt.Println()
+		fmt.Println("Use it by setting in the config file:")
+		fmt.Println("  ytdlp:")
+		fmt.Printf("    binary_path: %q\n", managed)
+		return nil
+	}
+
+	previous, err := ytdlpExt.Version()
+	if err != nil {
+		return fmt.Errorf("yt-dlp: %w\n  Install the latest release with: youtube-rtsp-proxy update ytdlp --download", err)
+	}
+
+	fmt.Printf("Updating yt-dlp %s...\n", previous)
+	version, err := ytdlpExt.Update(ctx, managed, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	if version == previous {
+		fmt.Printf("yt-dlp is up to date (%s)\n", version)
+	} else {
+		fmt.Printf("yt-dlp updated: %s -> %s\n", previous, version)
+	}
+	return nil
+}
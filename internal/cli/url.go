@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+)
+
+var (
+	urlProto   string
+	urlNetwork bool
+	urlCopy    bool
+)
+
+var urlCmd = &cobra.Command{
+	Use:   "url <stream-name>",
+	Short: "Print the URL of a stream",
+	Long: `Print only the URL a stream is read at, for capture in scripts.
+
+--proto selects the protocol: rtsp (default), rtsps, hls (the .m3u8
+playlist) or webrtc (the player page; WHEP clients append /whep). Protocols
+other than plain RTSP are looked up in the MediaMTX configuration, and the
+command fails if the listener is not enabled there.
+
+The host is localhost, or with --network or --host the address other
+machines reach this one by (see server.advertise_host).
+
+Examples:
+  youtube-rtsp-proxy url lofi
+  ffplay "$(youtube-rtsp-proxy url lofi --proto hls)"
+  youtube-rtsp-proxy url lofi --network --copy`,
+	Args: cobra.ExactArgs(1),
+	RunE: runURL,
+}
+
+func init() {
+	urlCmd.Flags().StringVar(&urlProto, "proto", "rtsp", "protocol: rtsp, rtsps, hls or webrtc")
+	urlCmd.Flags().BoolVar(&urlNetwork, "network", false, "use the address other machines reach this one by")
+	urlCmd.Flags().BoolVar(&urlCopy, "copy", false, "also copy the URL to the clipboard (pbcopy, wl-copy or xclip)")
+}
+
+func runURL(cmd *cobra.Command, args []string) error {
+	info, err := streamStatus(args[0])
+	if err != nil {
+		return err
+	}
+	if !stream.PublishesLocally(info.Outputs) {
+		return fmt.Errorf("stream '%s' is published to remote targets only: %s", info.Name, strings.Join(info.Outputs, ", "))
+	}
+
+	host := "localhost"
+	if urlNetwork || advertiseHost != "" {
+		if h := advertisedHost(); h != "" {
+			host = h
+		}
+	}
+
+	url, err := streamURL(urlProto, host, info.Port, info.RTSPPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(url)
+
+	if urlCopy {
+		if err := copyToClipboard(url); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// streamURL returns the URL of a path for a protocol. Plain RTSP is known
+// from the config; the other protocols are looked up in MediaMTX.
+func streamURL(proto, host string, port int, path string) (string, error) {
+	path = strings.TrimPrefix(path, "/")
+
+	if proto != "rtsp" && proto != "rtsps" && proto != "hls" && proto != "webrtc" {
+		return "", fmt.Errorf("unknown protocol %q (use rtsp, rtsps, hls or webrtc)", proto)
+	}
+
+	listeners, err := srv.GetListeners()
+	if err != nil {
+		if proto == "rtsp" {
+			return cfg.GetRTSPURLForHost(host, port, path), nil
+		}
+		return "", fmt.Errorf("cannot tell whether %s is enabled, is MediaMTX running? (%w)", proto, err)
+	}
+
+	switch proto {
+	case "rtsp":
+		if !listeners.RTSP || listeners.RTSPEncryption == "strict" {
+			return "", fmt.Errorf("plain RTSP is not enabled in MediaMTX (rtsp, rtspEncryption)")
+		}
+		return cfg.GetRTSPURLForHost(host, port, path), nil
+	case "rtsps":
+		if !listeners.RTSP || listeners.RTSPEncryption == "no" || listeners.RTSPEncryption == "" {
+			return "", fmt.Errorf("RTSPS is not enabled in MediaMTX (rtspEncryption: optional or strict)")
+		}
+		return listenerURL("rtsps", host, listeners.RTSPSAddress, path)
+	case "hls":
+		if !listeners.HLS {
+			return "", fmt.Errorf("HLS is not enabled in MediaMTX (hls)")
+		}
+		return listenerURL(httpScheme(listeners.HLSEncryption), host, listeners.HLSAddress, path+"/index.m3u8")
+	default:
+		if !listeners.WebRTC {
+			return "", fmt.Errorf("WebRTC is not enabled in MediaMTX (webrtc)")
+		}
+		return listenerURL(httpScheme(listeners.WebRTCEncryption), host, listeners.WebRTCAddress, path)
+	}
+}
+
+// listenerURL returns the URL of a path on a MediaMTX listener address such
+// as ":8888", reached through host
+func listenerURL(scheme, host, address, path string) (string, error) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid MediaMTX listener address %q: %w", address, err)
+	}
+	if _, err := strconv.Atoi(port); err != nil {
+		return "", fmt.Errorf("invalid MediaMTX listener address %q", address)
+	}
+	return scheme + "://" + net.JoinHostPort(host, port) + "/" + path, nil
+}
+
+// httpScheme returns the scheme of an HTTP listener
+func httpScheme(encryption bool) string {
+	if encryption {
+		return "https"
+	}
+	return "http"
+}
+
+// copyToClipboard puts text on the clipboard with the first clipboard tool
+// found
+func copyToClipboard(text string) error {
+	tools := [][]string{
+		{"pbcopy"},
+		{"wl-copy"},
+		{"xclip", "-selection", "clipboard"},
+	}
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(tool[0], tool[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s failed: %w", tool[0], err)
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot copy to the clipboard: install xclip, wl-copy or pbcopy")
+}
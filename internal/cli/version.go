@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/server"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+)
+
+// notFound is reported for a component whose binary cannot be run
+const notFound = "not found"
+
+var versionJSON bool
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Show version information",
+	Long: `Show the version of youtube-rtsp-proxy and of the yt-dlp, ffmpeg and
+mediamtx binaries it is configured to use. A binary that cannot be run is
+reported as "not found".
+
+Examples:
+  youtube-rtsp-proxy version
+  youtube-rtsp-proxy version --json`,
+	Args: cobra.NoArgs,
+	RunE: runVersion,
+}
+
+func init() {
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "print as JSON")
+}
+
+// versionInfo is the output of the version command
+type versionInfo struct {
+	App       string `json:"app"`
+	BuildTime string `json:"buildTime"`
+	Ytdlp     string `json:"ytdlp"`
+	FFmpeg    string `json:"ffmpeg"`
+	MediaMTX  string `json:"mediamtx"`
+}
+
+func runVersion(cmd *cobra.Command, args []string) error {
+	// The version command skips initApp so that it works without a usable
+	// data dir; a broken config file falls back to the default binaries
+	c, err := config.Load(cfgFile)
+	if err != nil {
+		c = &config.Config{
+			Ytdlp:    config.YtdlpConfig{BinaryPath: "yt-dlp"},
+			FFmpeg:   config.FFmpegConfig{BinaryPath: "ffmpeg"},
+			MediaMTX: config.MediaMTXConfig{BinaryPath: "mediamtx"},
+		}
+	}
+
+	info := versionInfo{App: Version, BuildTime: BuildTime}
+	info.Ytdlp = componentVersion(extractor.NewYtdlpExtractor(c.Ytdlp.BinaryPath, 0, "").CheckBinary())
+	info.FFmpeg = componentVersion(stream.NewFFmpegManager(&c.FFmpeg).CheckBinary())
+	info.MediaMTX = componentVersion(server.NewMediaMTXServer(&c.MediaMTX, &c.Server, c.Storage.DataDir, slog.New(slog.DiscardHandler)).CheckBinary())
+
+	if versionJSON {
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("youtube-rtsp-proxy %s (built at %s)\n", info.App, info.BuildTime)
+	fmt.Printf("  yt-dlp:   %s\n", info.Ytdlp)
+	fmt.Printf("  ffmpeg:   %s\n", info.FFmpeg)
+	fmt.Printf("  mediamtx: %s\n", info.MediaMTX)
+	return nil
+}
+
+// componentVersion returns the version a CheckBinary call reported, or
+// notFound
+func componentVersion(version string, err error) string {
+	if err != nil {
+		return notFound
+	}
+	if version == "" {
+		return "unknown"
+	}
+	return version
+}
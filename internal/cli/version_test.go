@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestComponentVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		err     error
+		want    string
+	}{
+		{"2024.12.13", nil, "2024.12.13"},
+		{"", nil, "unknown"},
+		{"", errors.New("exec: \"yt-dlp\": executable file not found in $PATH"), notFound},
+	}
+	for _, tt := range tests {
+		if got := componentVersion(tt.version, tt.err); got != tt.want {
+			t.Errorf("componentVersion(%q, %v) = %q, want %q", tt.version, tt.err, got, tt.want)
+		}
+	}
+}
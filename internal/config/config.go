@@ -1,8 +1,10 @@
 package config
 
 import (
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,12 +20,44 @@ type Config struct {
 	Monitor  MonitorConfig  `mapstructure:"monitor"`
 	Storage  StorageConfig  `mapstructure:"storage"`
 	Logging  LoggingConfig  `mapstructure:"logging"`
+	Hooks    HooksConfig    `mapstructure:"hooks"`
+	Health   HealthConfig   `mapstructure:"health"`
+
+	Streams []StreamDefinition `mapstructure:"streams"`
+}
+
+// HealthConfig holds health probe settings
+type HealthConfig struct {
+	// Port serving /healthz and /readyz from the foreground server
+	// (0 = disabled)
+	Port int `mapstructure:"port"`
+
+	// Address the probes bind to (empty = all interfaces, so the
+	// orchestrator can reach them)
+	Address string `mapstructure:"address"`
 }
 
 // ServerConfig holds RTSP server settings
 type ServerConfig struct {
 	RTSPPort int `mapstructure:"rtsp_port"`
 	APIPort  int `mapstructure:"api_port"`
+
+	// Addresses MediaMTX binds its RTSP and API listeners to (empty = all
+	// interfaces), e.g. 127.0.0.1 to keep the API local
+	RTSPAddress string `mapstructure:"rtsp_address"`
+	APIAddress  string `mapstructure:"api_address"`
+
+	// Control API (served by the foreground server, 0 = disabled). It binds
+	// to loopback by default; other addresses require a token.
+	ControlAPIPort    int    `mapstructure:"control_api_port"`
+	ControlAPIAddress string `mapstructure:"control_api_address"`
+	ControlAPIToken   string `mapstructure:"control_api_token"`
+
+	// Host or address shown in "Network" RTSP URLs (empty = detected), or
+	// else the network interface whose address is shown, for hosts with
+	// several networks
+	AdvertiseHost      string `mapstructure:"advertise_host"`
+	AdvertiseInterface string `mapstructure:"advertise_interface"`
 }
 
 // MediaMTXConfig holds MediaMTX binary and config settings
@@ -31,6 +65,30 @@ type MediaMTXConfig struct {
 	BinaryPath string `mapstructure:"binary_path"`
 	ConfigPath string `mapstructure:"config_path"`
 	LogLevel   string `mapstructure:"log_level"`
+
+	// Verify the binary against the hash recorded in the data dir
+	VerifyChecksum bool `mapstructure:"verify_checksum"`
+
+	// Idle time after the last reader leaves before an on-demand stream
+	// stops publishing
+	OnDemandCloseAfter time.Duration `mapstructure:"on_demand_close_after"`
+
+	// How long to wait for the API to answer after starting MediaMTX
+	ReadyTimeout time.Duration `mapstructure:"ready_timeout"`
+
+	// Readers allowed per path at once, written into the generated
+	// mediamtx.yml (0 = unlimited)
+	MaxReaders int `mapstructure:"max_readers"`
+
+	// Record every stream into segments, written into the generated
+	// mediamtx.yml. RecordPath uses MediaMTX's placeholders (empty =
+	// recordings/%path/%Y-%m-%d_%H-%M-%S-%f in the data dir).
+	Record                bool          `mapstructure:"record"`
+	RecordPath            string        `mapstructure:"record_path"`
+	RecordSegmentDuration time.Duration `mapstructure:"record_segment_duration"`
+
+	// Write a SHA-256 sidecar (<segment>.sha256) for each finished segment
+	RecordChecksums bool `mapstructure:"record_checksums"`
 }
 
 // FFmpegConfig holds FFmpeg settings
@@ -38,6 +96,30 @@ type FFmpegConfig struct {
 	BinaryPath    string   `mapstructure:"binary_path"`
 	InputOptions  []string `mapstructure:"input_options"`
 	OutputOptions []string `mapstructure:"output_options"`
+	RTSPTransport string   `mapstructure:"rtsp_transport"`
+
+	// How long to wait for MediaMTX to receive a started stream
+	StartTimeout time.Duration `mapstructure:"start_timeout"`
+
+	// Scheduling priority of FFmpeg processes (0 = unchanged, 19 = lowest)
+	Nice int `mapstructure:"nice"`
+
+	// Address space limit of FFmpeg processes, e.g. "1G" (empty = unlimited)
+	MemoryLimit string `mapstructure:"memory_limit"`
+
+	// HTTP request headers for reading HTTP(S) sources, such as a Referer
+	// some hosts require ("Name: value" each)
+	UserAgent string   `mapstructure:"user_agent"`
+	Headers   []string `mapstructure:"headers"`
+
+	// Stop FFmpeg by typing "q" on its stdin, so it tears down the RTSP
+	// session cleanly, before falling back to signals
+	GracefulQuit bool `mapstructure:"graceful_quit"`
+
+	// How much of an input FFmpeg reads, e.g. "5M", and for how long,
+	// to detect its stream parameters (empty/0 = FFmpeg's defaults)
+	Probesize       string        `mapstructure:"probesize"`
+	AnalyzeDuration time.Duration `mapstructure:"analyzeduration"`
 }
 
 // YtdlpConfig holds yt-dlp settings
@@ -45,14 +127,51 @@ type YtdlpConfig struct {
 	BinaryPath string        `mapstructure:"binary_path"`
 	Timeout    time.Duration `mapstructure:"timeout"`
 	Format     string        `mapstructure:"format"`
+
+	// Attempts at extracting a stream before giving up, and the delay
+	// before the first retry, doubled for each later one
+	RetryAttempts int           `mapstructure:"retry_attempts"`
+	RetryBackoff  time.Duration `mapstructure:"retry_backoff"`
+
+	// Update yt-dlp when extraction fails because it is outdated
+	AutoUpdate bool `mapstructure:"auto_update"`
+
+	// HTTP request headers for extraction ("Name: value" each)
+	UserAgent string   `mapstructure:"user_agent"`
+	Headers   []string `mapstructure:"headers"`
+
+	// Extractions run at once at most, the minimum delay between their
+	// starts, and how long extractions pause after YouTube answered one
+	// with HTTP 429 (0 = no pause)
+	MaxConcurrent     int           `mapstructure:"max_concurrent"`
+	MinInterval       time.Duration `mapstructure:"min_interval"`
+	RateLimitCooldown time.Duration `mapstructure:"rate_limit_cooldown"`
+}
+
+// ExtractTimeout returns how long extracting a stream can take at most,
+// counting every retry and the backoff between them
+func (c YtdlpConfig) ExtractTimeout() time.Duration {
+	total := c.Timeout
+	backoff := c.RetryBackoff
+	for i := 1; i < c.RetryAttempts; i++ {
+		total += backoff + c.Timeout
+		backoff *= 2
+	}
+	return total
 }
 
 // MonitorConfig holds monitoring settings
 type MonitorConfig struct {
-	HealthCheckInterval  time.Duration   `mapstructure:"health_check_interval"`
-	URLRefreshInterval   time.Duration   `mapstructure:"url_refresh_interval"`
-	MaxConsecutiveErrors int             `mapstructure:"max_consecutive_errors"`
-	Reconnect            ReconnectConfig `mapstructure:"reconnect"`
+	HealthCheckInterval  time.Duration `mapstructure:"health_check_interval"`
+	URLRefreshInterval   time.Duration `mapstructure:"url_refresh_interval"`
+	MaxConsecutiveErrors int           `mapstructure:"max_consecutive_errors"`
+
+	// Checks without new data before a stream counts as stalled, and
+	// consecutive healthy checks that clear its consecutive errors
+	StallThreshold    int `mapstructure:"stall_threshold"`
+	RecoveryThreshold int `mapstructure:"recovery_threshold"`
+
+	Reconnect ReconnectConfig `mapstructure:"reconnect"`
 }
 
 // ReconnectConfig holds reconnection settings
@@ -61,11 +180,15 @@ type ReconnectConfig struct {
 	MaxDelay     time.Duration `mapstructure:"max_delay"`
 	Multiplier   float64       `mapstructure:"multiplier"`
 	MaxAttempts  int           `mapstructure:"max_attempts"`
+
+	// Random spread applied to each delay, as a fraction of it (0.2 = ±20%)
+	Jitter float64 `mapstructure:"jitter"`
 }
 
 // StorageConfig holds storage settings
 type StorageConfig struct {
 	DataDir string `mapstructure:"data_dir"`
+	Backend string `mapstructure:"backend"` // file or sqlite
 }
 
 // LoggingConfig holds logging settings
@@ -73,10 +196,69 @@ type LoggingConfig struct {
 	Level  string `mapstructure:"level"`
 	Format string `mapstructure:"format"`
 	File   string `mapstructure:"file"`
+
+	// Maximum length of a single stream log line (0 = unlimited)
+	MaxLineLength int `mapstructure:"max_line_length"`
+
+	// Redact the signed query strings of URLs in logs and stream errors
+	RedactURLs bool `mapstructure:"redact_urls"`
+
+	// How stream logs are kept from growing: "size" moves a log reaching
+	// MaxSize to numbered archives, keeping MaxArchives of them; "lines"
+	// keeps the last lines of the file
+	Rotation         string `mapstructure:"rotation"`
+	MaxSize          string `mapstructure:"max_size"`
+	MaxArchives      int    `mapstructure:"max_archives"`
+	CompressArchives bool   `mapstructure:"compress_archives"`
+}
+
+// HooksConfig holds executables run on stream lifecycle events
+type HooksConfig struct {
+	OnStart   string        `mapstructure:"on_start"`
+	OnError   string        `mapstructure:"on_error"`
+	OnRecover string        `mapstructure:"on_recover"`
+	OnStop    string        `mapstructure:"on_stop"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+}
+
+// StreamDefinition declares a stream in the config file's streams section
+type StreamDefinition struct {
+	Name string `mapstructure:"name"`
+	URL  string `mapstructure:"url"`
+	Port int    `mapstructure:"port"`
+
+	// Same options as the start command's flags
+	AudioOnly bool   `mapstructure:"audio_only"`
+	Loop      bool   `mapstructure:"loop"`
+	OnDemand  bool   `mapstructure:"on_demand"`
+	Transport string `mapstructure:"transport"`
+	Format    string `mapstructure:"format"`
+
+	MaxBitrate  string   `mapstructure:"max_bitrate"`
+	Outputs     []string `mapstructure:"outputs"`
+	Substream   string   `mapstructure:"substream"`
+	AudioCopy   bool     `mapstructure:"audio_copy"`
+	RequireH264 bool     `mapstructure:"require_h264"`
+
+	// Start the stream when applying (default true). A declared stream
+	// without autostart is only kept in line once it is running.
+	Autostart *bool `mapstructure:"autostart"`
+}
+
+// ShouldAutostart reports whether applying starts the stream
+func (d *StreamDefinition) ShouldAutostart() bool {
+	return d.Autostart == nil || *d.Autostart
 }
 
 // Load loads configuration from file and environment variables
 func Load(configPath string) (*Config, error) {
+	cfg, _, err := load(configPath)
+	return cfg, err
+}
+
+// load loads configuration, also returning the viper instance it was
+// merged in, which knows where each value came from
+func load(configPath string) (*Config, *viper.Viper, error) {
 	v := viper.New()
 
 	// Set defaults
@@ -94,27 +276,31 @@ func Load(configPath string) (*Config, error) {
 	}
 
 	// Environment variables
-	v.SetEnvPrefix("YTRTSP")
+	v.SetEnvPrefix(envPrefix)
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
 
 	// Read config file
 	if err := v.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, err
+			return nil, nil, err
 		}
 		// Config file not found, use defaults
 	}
 
 	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Resolve paths
 	cfg.resolveDataDir()
 
-	return &cfg, nil
+	if err := validateRaw(&cfg, v); err != nil {
+		return nil, nil, err
+	}
+
+	return &cfg, v, nil
 }
 
 // setDefaults sets default values for configuration
@@ -122,11 +308,26 @@ func setDefaults(v *viper.Viper) {
 	// Server defaults
 	v.SetDefault("server.rtsp_port", 8554)
 	v.SetDefault("server.api_port", 9997)
+	v.SetDefault("server.rtsp_address", "")
+	v.SetDefault("server.api_address", "")
+	v.SetDefault("server.control_api_port", 0)
+	v.SetDefault("server.control_api_address", "127.0.0.1")
+	v.SetDefault("server.control_api_token", "")
+	v.SetDefault("server.advertise_host", "")
+	v.SetDefault("server.advertise_interface", "")
 
 	// MediaMTX defaults
 	v.SetDefault("mediamtx.binary_path", "mediamtx")
 	v.SetDefault("mediamtx.config_path", "")
 	v.SetDefault("mediamtx.log_level", "info")
+	v.SetDefault("mediamtx.verify_checksum", true)
+	v.SetDefault("mediamtx.on_demand_close_after", 10*time.Second)
+	v.SetDefault("mediamtx.ready_timeout", 5*time.Second)
+	v.SetDefault("mediamtx.max_readers", 0)
+	v.SetDefault("mediamtx.record", false)
+	v.SetDefault("mediamtx.record_path", "")
+	v.SetDefault("mediamtx.record_segment_duration", time.Hour)
+	v.SetDefault("mediamtx.record_checksums", true)
 
 	// FFmpeg defaults
 	v.SetDefault("ffmpeg.binary_path", "ffmpeg")
@@ -140,28 +341,69 @@ func setDefaults(v *viper.Viper) {
 		"-c:a", "aac",
 		"-f", "rtsp",
 	})
+	v.SetDefault("ffmpeg.rtsp_transport", "tcp")
+	v.SetDefault("ffmpeg.start_timeout", 15*time.Second)
+	v.SetDefault("ffmpeg.nice", 0)
+	v.SetDefault("ffmpeg.memory_limit", "")
+	v.SetDefault("ffmpeg.user_agent", "")
+	v.SetDefault("ffmpeg.headers", []string{})
+	v.SetDefault("ffmpeg.graceful_quit", false)
+	v.SetDefault("ffmpeg.probesize", "")
+	v.SetDefault("ffmpeg.analyzeduration", time.Duration(0))
 
 	// yt-dlp defaults
 	v.SetDefault("ytdlp.binary_path", "yt-dlp")
 	v.SetDefault("ytdlp.timeout", 30*time.Second)
 	v.SetDefault("ytdlp.format", "best[protocol=https]/best")
+	v.SetDefault("ytdlp.retry_attempts", 3)
+	v.SetDefault("ytdlp.retry_backoff", 2*time.Second)
+	v.SetDefault("ytdlp.auto_update", false)
+	v.SetDefault("ytdlp.user_agent", "")
+	v.SetDefault("ytdlp.headers", []string{})
+	v.SetDefault("ytdlp.max_concurrent", 2)
+	v.SetDefault("ytdlp.min_interval", time.Second)
+	v.SetDefault("ytdlp.rate_limit_cooldown", 5*time.Minute)
 
 	// Monitor defaults
 	v.SetDefault("monitor.health_check_interval", 30*time.Second)
 	v.SetDefault("monitor.url_refresh_interval", 30*time.Minute)
 	v.SetDefault("monitor.max_consecutive_errors", 3)
+	v.SetDefault("monitor.stall_threshold", 3)
+	v.SetDefault("monitor.recovery_threshold", 1)
 	v.SetDefault("monitor.reconnect.initial_delay", 5*time.Second)
 	v.SetDefault("monitor.reconnect.max_delay", 5*time.Minute)
 	v.SetDefault("monitor.reconnect.multiplier", 2.0)
 	v.SetDefault("monitor.reconnect.max_attempts", 10)
+	v.SetDefault("monitor.reconnect.jitter", 0.2)
 
 	// Storage defaults
 	v.SetDefault("storage.data_dir", "")
+	v.SetDefault("storage.backend", "file")
 
 	// Logging defaults
 	v.SetDefault("logging.level", "info")
 	v.SetDefault("logging.format", "text")
 	v.SetDefault("logging.file", "")
+	v.SetDefault("logging.max_line_length", 2048)
+	v.SetDefault("logging.redact_urls", true)
+	v.SetDefault("logging.rotation", "size")
+	v.SetDefault("logging.max_size", "256K")
+	v.SetDefault("logging.max_archives", 1)
+	v.SetDefault("logging.compress_archives", false)
+
+	// Hook defaults
+	v.SetDefault("hooks.on_start", "")
+	v.SetDefault("hooks.on_error", "")
+	v.SetDefault("hooks.on_recover", "")
+	v.SetDefault("hooks.on_stop", "")
+	v.SetDefault("hooks.timeout", 10*time.Second)
+
+	// Health probe defaults
+	v.SetDefault("health.port", 0)
+	v.SetDefault("health.address", "")
+
+	// Declarative streams
+	v.SetDefault("streams", []StreamDefinition{})
 }
 
 // resolveDataDir resolves the data directory path
@@ -184,7 +426,60 @@ func (c *Config) GetMediaMTXConfigPath() string {
 	return filepath.Join(c.Storage.DataDir, "mediamtx.yml")
 }
 
-// GetRTSPURL returns the full RTSP URL for a given path
-func (c *Config) GetRTSPURL(path string) string {
-	return "rtsp://localhost:" + strings.TrimPrefix(path, "/") + "/" + path
+// GetRTSPURL returns the RTSP URL of a path served on this host. A zero
+// port means server.rtsp_port.
+func (c *Config) GetRTSPURL(port int, path string) string {
+	return c.GetRTSPURLForHost(DialHost(c.Server.RTSPAddress), port, path)
+}
+
+// GetRTSPURLForHost returns the RTSP URL of a path as reached through host,
+// such as the machine's network address. A zero port means
+// server.rtsp_port.
+func (c *Config) GetRTSPURLForHost(host string, port int, path string) string {
+	if port == 0 {
+		port = c.Server.RTSPPort
+	}
+	return RTSPURL(host, port, path)
+}
+
+// ListenAddress returns the listen address for port on the bind address
+// host, where an empty host means all interfaces
+func ListenAddress(host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// IsLoopback reports whether the bind address host only accepts
+// connections from this machine
+func IsLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// DialHost returns the host to connect to for a listener bound to the bind
+// address host: localhost for all interfaces, else host itself
+func DialHost(host string) string {
+	switch host {
+	case "", "0.0.0.0", "::":
+		return "localhost"
+	}
+	return host
+}
+
+// APIURL returns the URL of path on the MediaMTX API
+func (s *ServerConfig) APIURL(path string) string {
+	return "http://" + ListenAddress(DialHost(s.APIAddress), s.APIPort) + path
+}
+
+// RTSPURL returns the RTSP URL of a path on host and port. The path may be
+// given with or without its leading slash.
+func RTSPURL(host string, port int, path string) string {
+	return "rtsp://" + net.JoinHostPort(host, strconv.Itoa(port)) + "/" + strings.TrimPrefix(path, "/")
+}
+
+// GetDaemonSocketPath returns the control socket of the foreground server
+func (c *Config) GetDaemonSocketPath() string {
+	return filepath.Join(c.Storage.DataDir, "daemon.sock")
 }
@@ -0,0 +1,67 @@
+package config
+
+import "testing"
+
+func TestGetRTSPURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		rtspAddress string
+		port        int
+		path        string
+		want        string
+	}{
+		{"leading slash", "", 0, "/lofi", "rtsp://localhost:8554/lofi"},
+		{"no slash", "", 0, "lofi", "rtsp://localhost:8554/lofi"},
+		{"nested path", "", 0, "/cams/front", "rtsp://localhost:8554/cams/front"},
+		{"stream port", "", 9554, "/lofi", "rtsp://localhost:9554/lofi"},
+		{"all interfaces", "0.0.0.0", 0, "/lofi", "rtsp://localhost:8554/lofi"},
+		{"bound address", "127.0.0.2", 0, "/lofi", "rtsp://127.0.0.2:8554/lofi"},
+		{"bound IPv6 address", "::1", 0, "lofi", "rtsp://[::1]:8554/lofi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Server: ServerConfig{RTSPAddress: tt.rtspAddress, RTSPPort: 8554}}
+			if got := c.GetRTSPURL(tt.port, tt.path); got != tt.want {
+				t.Errorf("GetRTSPURL(%d, %q) = %s, want %s", tt.port, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRTSPURLForHost(t *testing.T) {
+	c := &Config{Server: ServerConfig{RTSPPort: 8554}}
+	tests := []struct {
+		host string
+		port int
+		path string
+		want string
+	}{
+		{"192.168.1.20", 0, "/lofi", "rtsp://192.168.1.20:8554/lofi"},
+		{"192.168.1.20", 9554, "/lofi", "rtsp://192.168.1.20:9554/lofi"},
+		{"camera.lan", 0, "lofi", "rtsp://camera.lan:8554/lofi"},
+		{"fd00::20", 0, "/lofi", "rtsp://[fd00::20]:8554/lofi"},
+	}
+	for _, tt := range tests {
+		if got := c.GetRTSPURLForHost(tt.host, tt.port, tt.path); got != tt.want {
+			t.Errorf("GetRTSPURLForHost(%q, %d, %q) = %s, want %s", tt.host, tt.port, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRTSPURLBracketsIPv6(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"localhost", "rtsp://localhost:8554/news"},
+		{"192.0.2.2", "rtsp://192.0.2.2:8554/news"},
+		{"2001:db8::2", "rtsp://[2001:db8::2]:8554/news"},
+		{"::1", "rtsp://[::1]:8554/news"},
+		{"fe80::1%eth0", "rtsp://[fe80::1%eth0]:8554/news"},
+	}
+	for _, tt := range tests {
+		if got := RTSPURL(tt.host, 8554, "/news"); got != tt.want {
+			t.Errorf("RTSPURL(%q) = %s, want %s", tt.host, got, tt.want)
+		}
+	}
+}
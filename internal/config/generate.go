@@ -0,0 +1,206 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// keyComments documents configuration keys in generated config files.
+// Keys are dotted mapstructure paths; sections may be documented too.
+var keyComments = map[string]string{
+	"server":                     "Server settings",
+	"server.rtsp_port":           "RTSP server port",
+	"server.api_port":            "MediaMTX API port (for health checks)",
+	"server.rtsp_address":        "Address the RTSP server binds to, e.g. 192.168.1.10 (empty = all interfaces)",
+	"server.api_address":         "Address the MediaMTX API binds to, e.g. 127.0.0.1 (empty = all interfaces)",
+	"server.control_api_port":    "Control API port for managing streams over HTTP (0 = disabled)",
+	"server.control_api_address": "Address the control API binds to (default 127.0.0.1; other addresses, or empty for all interfaces, require control_api_token)",
+	"server.control_api_token":   "Bearer token required by the control API (empty = no authentication)",
+	"server.advertise_interface": "Network interface whose address is shown in network RTSP URLs, e.g. eth0 (empty = detected)",
+	"server.advertise_host":      "Host or address shown in network RTSP URLs, e.g. a DNS name (empty = detected)",
+
+	"mediamtx":                         "MediaMTX settings",
+	"mediamtx.binary_path":             "Path to MediaMTX binary",
+	"mediamtx.config_path":             "Custom config file (optional, auto-generated if empty)",
+	"mediamtx.log_level":               "Log level: debug, info, warn, error",
+	"mediamtx.verify_checksum":         "Refuse to start a MediaMTX binary whose SHA-256 changed since it was recorded",
+	"mediamtx.on_demand_close_after":   "Stop an on-demand stream this long after its last reader leaves",
+	"mediamtx.ready_timeout":           "How long to wait for MediaMTX to answer after starting it",
+	"mediamtx.max_readers":             "Clients allowed to read one stream at once (0 = unlimited)",
+	"mediamtx.record":                  "Record every stream into segment files",
+	"mediamtx.record_path":             "Segment path with MediaMTX placeholders (empty = recordings/%path/... in the data dir)",
+	"mediamtx.record_segment_duration": "Length of one recording segment",
+	"mediamtx.record_checksums":        "Write a SHA-256 sidecar (<segment>.sha256) for each finished segment",
+
+	"ffmpeg":                 "FFmpeg settings",
+	"ffmpeg.binary_path":     "Path to FFmpeg binary",
+	"ffmpeg.input_options":   "Input options (applied before -i)",
+	"ffmpeg.output_options":  "Output options (applied after -i, before output URL)",
+	"ffmpeg.rtsp_transport":  "Transport for publishing to MediaMTX: tcp or udp (start --transport overrides it)",
+	"ffmpeg.nice":            "Scheduling priority of FFmpeg processes: 0 (unchanged) to 19 (lowest); negative values need root",
+	"ffmpeg.memory_limit":    "Address space limit of FFmpeg processes, e.g. \"1G\" (empty = unlimited, Linux only)",
+	"ffmpeg.start_timeout":   "How long to wait for MediaMTX to receive a started stream before giving up",
+	"ffmpeg.user_agent":      "User-Agent for reading HTTP(S) sources (empty = yt-dlp's recommended one, else FFmpeg's own)",
+	"ffmpeg.headers":         "Extra HTTP headers for reading HTTP(S) sources, each \"Name: value\" (e.g. a Referer), overriding yt-dlp's recommended ones",
+	"ffmpeg.graceful_quit":   "Stop FFmpeg by sending \"q\" on its stdin before falling back to signals",
+	"ffmpeg.probesize":       "Input data FFmpeg reads to detect stream parameters, e.g. \"5M\" (empty = FFmpeg's default)",
+	"ffmpeg.analyzeduration": "Input duration FFmpeg analyzes to detect stream parameters, e.g. \"5s\" (0 = FFmpeg's default)",
+
+	"ytdlp":                     "yt-dlp settings",
+	"ytdlp.binary_path":         "Path to yt-dlp binary",
+	"ytdlp.timeout":             "Timeout for URL extraction",
+	"ytdlp.format":              "Video format selection",
+	"ytdlp.retry_attempts":      "Attempts at extracting a stream before giving up (unavailable or private videos are not retried)",
+	"ytdlp.retry_backoff":       "Delay before the first extraction retry, doubled for each later one",
+	"ytdlp.user_agent":          "User-Agent for extraction requests (empty = yt-dlp's own)",
+	"ytdlp.headers":             "Extra HTTP headers for extraction requests, each \"Name: value\" (e.g. a Referer)",
+	"ytdlp.auto_update":         "Run \"update ytdlp\" when extraction fails because yt-dlp is outdated (at most once an hour)",
+	"ytdlp.max_concurrent":      "yt-dlp extractions run at once at most, across all streams",
+	"ytdlp.min_interval":        "Minimum delay between the starts of two yt-dlp extractions",
+	"ytdlp.rate_limit_cooldown": "How long extractions pause after YouTube rate limits this host (HTTP 429, 0 = no pause)",
+
+	"monitor":                         "Monitoring and auto-reconnect settings",
+	"monitor.health_check_interval":   "How often to check stream health",
+	"monitor.url_refresh_interval":    "How often to refresh stream URLs (for live streams)",
+	"monitor.max_consecutive_errors":  "Number of consecutive errors before triggering URL refresh",
+	"monitor.stall_threshold":         "Consecutive health checks without new data before a stream counts as stalled",
+	"monitor.recovery_threshold":      "Consecutive healthy checks needed to clear a stream's consecutive errors",
+	"monitor.reconnect":               "Reconnection settings",
+	"monitor.reconnect.initial_delay": "Initial delay before first reconnect attempt",
+	"monitor.reconnect.max_delay":     "Maximum delay between reconnect attempts",
+	"monitor.reconnect.multiplier":    "Multiplier for exponential backoff",
+	"monitor.reconnect.max_attempts":  "Maximum number of reconnect attempts",
+	"monitor.reconnect.jitter":        "Random spread of each delay as a fraction of it (0.2 = ±20%), so streams don't retry in lockstep",
+
+	"storage":          "Storage settings",
+	"storage.data_dir": "Directory for storing stream state and logs (default: ~/.local/share/youtube-rtsp-proxy)",
+	"storage.backend":  "Where stream state and favorites are kept: file (JSON files) or sqlite (state.db, imports the JSON files on first use)",
+
+	"logging":                   "Logging settings",
+	"logging.level":             "Log level: debug, info, warn, error",
+	"logging.format":            "Application log format: text, json",
+	"logging.file":              "Application log file (empty for stderr)",
+	"logging.max_line_length":   "Maximum length of a single stream log line in bytes (0 = unlimited)",
+	"logging.redact_urls":       "Hide the signed query strings of extracted URLs in logs and stream errors",
+	"logging.rotation":          "Stream log rotation: size (archive at max_size), lines (keep the last 100 lines)",
+	"logging.max_size":          "Size at which a stream log is moved to <name>.log.1, e.g. 256K",
+	"logging.max_archives":      "Archived stream logs kept per stream (0 = none)",
+	"logging.compress_archives": "Gzip archived stream logs",
+
+	"hooks":            "Executables run on stream lifecycle events with STREAM_NAME, STREAM_STATE, RTSP_URL and REASON set (empty = disabled)",
+	"hooks.on_start":   "Run when a stream is started",
+	"hooks.on_error":   "Run when a stream gives up after exhausting reconnect attempts",
+	"hooks.on_recover": "Run when a stream reconnects after a failure",
+	"hooks.on_stop":    "Run when a stream is stopped",
+	"hooks.timeout":    "Kill a hook that runs longer than this",
+
+	"health":         "Liveness and readiness probes for container orchestrators",
+	"health.port":    "Port serving /healthz and /readyz from the foreground server (0 = disabled)",
+	"health.address": "Address the probes bind to, e.g. 127.0.0.1 (empty = all interfaces)",
+
+	"streams": "Declarative streams (name, url, optional port, audio_only, loop, on_demand, transport, format, max_bitrate, outputs, substream, audio_copy, require_h264, autostart), applied with `apply`",
+}
+
+// DefaultConfigPath returns the per-user config file location searched by Load
+func DefaultConfigPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".youtube-rtsp-proxy", "config.yaml"), nil
+}
+
+// GenerateDefault renders a fully commented YAML config file containing
+// every key of Config with its default value from setDefaults
+func GenerateDefault() []byte {
+	v := viper.New()
+	setDefaults(v)
+
+	var b strings.Builder
+	b.WriteString("# YouTube to RTSP Proxy Configuration\n")
+	b.WriteString("# Generated by `youtube-rtsp-proxy config init`\n")
+
+	writeSection(&b, v, reflect.TypeOf(Config{}), "", 0)
+	return []byte(b.String())
+}
+
+// writeSection writes the keys of a struct type at the given indentation
+func writeSection(b *strings.Builder, v *viper.Viper, t reflect.Type, prefix string, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		if depth == 0 {
+			b.WriteString("\n")
+		}
+		if comment, ok := keyComments[key]; ok {
+			fmt.Fprintf(b, "%s# %s\n", indent, comment)
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			fmt.Fprintf(b, "%s%s:\n", indent, name)
+			writeSection(b, v, field.Type, key, depth+1)
+			continue
+		}
+
+		writeValue(b, indent, name, v.Get(key))
+	}
+}
+
+// writeValue writes a single key/value pair as YAML
+func writeValue(b *strings.Builder, indent, name string, value interface{}) {
+	switch val := value.(type) {
+	case []string:
+		if len(val) == 0 {
+			fmt.Fprintf(b, "%s%s: []\n", indent, name)
+			return
+		}
+		fmt.Fprintf(b, "%s%s:\n", indent, name)
+		for _, item := range val {
+			fmt.Fprintf(b, "%s  - %s\n", indent, strconv.Quote(item))
+		}
+	case string:
+		fmt.Fprintf(b, "%s%s: %s\n", indent, name, strconv.Quote(val))
+	case time.Duration:
+		fmt.Fprintf(b, "%s%s: %s\n", indent, name, strconv.Quote(formatDuration(val)))
+	case float64:
+		formatted := strconv.FormatFloat(val, 'f', -1, 64)
+		if !strings.ContainsAny(formatted, ".e") {
+			formatted += ".0"
+		}
+		fmt.Fprintf(b, "%s%s: %s\n", indent, name, formatted)
+	case nil:
+		fmt.Fprintf(b, "%s%s:\n", indent, name)
+	default:
+		fmt.Fprintf(b, "%s%s: %v\n", indent, name, val)
+	}
+}
+
+// formatDuration formats a duration compactly ("5m" rather than "5m0s")
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
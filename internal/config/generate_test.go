@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// loadFile writes content to a config file and loads it
+func loadFile(t *testing.T, content []byte) *Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return cfg
+}
+
+func TestGenerateDefaultReloadsAsDefaults(t *testing.T) {
+	defaults := loadFile(t, nil)
+	generated := loadFile(t, GenerateDefault())
+
+	if !reflect.DeepEqual(generated, defaults) {
+		t.Errorf("generated config loads as\n%+v\nwant the defaults\n%+v", generated, defaults)
+	}
+}
+
+func TestGenerateDefaultCoversEveryKey(t *testing.T) {
+	out := string(GenerateDefault())
+
+	var check func(t reflect.Type, prefix string)
+	check = func(typ reflect.Type, prefix string) {
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			name := field.Tag.Get("mapstructure")
+			if name == "" || name == "-" {
+				continue
+			}
+			key := name
+			if prefix != "" {
+				key = prefix + "." + name
+			}
+			if !strings.Contains(out, name+":") {
+				t.Errorf("key %s missing from the generated config", key)
+			}
+			if _, ok := keyComments[key]; !ok && field.Type.Kind() != reflect.Struct {
+				t.Errorf("key %s has no comment", key)
+			}
+			if field.Type.Kind() == reflect.Struct {
+				check(field.Type, key)
+			}
+		}
+	}
+	check(reflect.TypeOf(Config{}), "")
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// envPrefix prefixes the environment variables overriding config keys
+const envPrefix = "YTRTSP"
+
+// maskedValue replaces secrets in Show's output
+const maskedValue = "********"
+
+// Sources tells where the effective values of a loaded config came from
+type Sources struct {
+	v *viper.Viper
+}
+
+// LoadWithSources loads configuration like Load, also returning where each
+// value came from
+func LoadWithSources(configPath string) (*Config, *Sources, error) {
+	cfg, v, err := load(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, &Sources{v: v}, nil
+}
+
+// File returns the config file that was read, or "" if none was found
+func (s *Sources) File() string {
+	return s.v.ConfigFileUsed()
+}
+
+// Of returns where the value of a dotted key came from: "env", "file" or
+// "default"
+func (s *Sources) Of(key string) string {
+	env := envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+	if _, ok := os.LookupEnv(env); ok {
+		return "env"
+	}
+	if s.v.InConfig(key) {
+		return "file"
+	}
+	return "default"
+}
+
+// Show renders cfg as YAML in the layout of GenerateDefault, with secrets
+// such as tokens and passwords masked. If sources is non-nil, each value
+// is annotated with where it came from.
+func Show(cfg *Config, sources *Sources) []byte {
+	var b strings.Builder
+	b.WriteString("# Effective configuration (defaults + config file + environment)\n")
+	if sources != nil {
+		if file := sources.File(); file != "" {
+			fmt.Fprintf(&b, "# Config file: %s\n", file)
+		} else {
+			b.WriteString("# Config file: none found\n")
+		}
+	}
+
+	showSection(&b, reflect.ValueOf(*cfg), "", 0, sources)
+	return []byte(b.String())
+}
+
+// showSection writes the keys of a struct value at the given indentation
+func showSection(b *strings.Builder, val reflect.Value, prefix string, depth int, sources *Sources) {
+	indent := strings.Repeat("  ", depth)
+	t := val.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		if depth == 0 {
+			b.WriteString("\n")
+		}
+
+		field := val.Field(i)
+		if field.Kind() == reflect.Struct {
+			fmt.Fprintf(b, "%s%s:\n", indent, name)
+			showSection(b, field, key, depth+1, sources)
+			continue
+		}
+
+		var line strings.Builder
+		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct {
+			showList(&line, indent, name, field)
+		} else {
+			writeValue(&line, indent, name, shownValue(name, field))
+		}
+
+		out := line.String()
+		if sources != nil {
+			first, rest, _ := strings.Cut(out, "\n")
+			out = fmt.Sprintf("%s  # %s\n%s", first, sources.Of(key), rest)
+		}
+		b.WriteString(out)
+	}
+}
+
+// showList writes a list of structs, such as the declared streams, leaving
+// out unset fields
+func showList(b *strings.Builder, indent, name string, list reflect.Value) {
+	if list.Len() == 0 {
+		fmt.Fprintf(b, "%s%s: []\n", indent, name)
+		return
+	}
+
+	fmt.Fprintf(b, "%s%s:\n", indent, name)
+	itemIndent := indent + "    "
+	for i := 0; i < list.Len(); i++ {
+		item := list.Index(i)
+		var fields strings.Builder
+		for j := 0; j < item.NumField(); j++ {
+			fieldName := item.Type().Field(j).Tag.Get("mapstructure")
+			field := item.Field(j)
+			if fieldName == "" || fieldName == "-" || field.IsZero() {
+				continue
+			}
+			writeValue(&fields, itemIndent, fieldName, shownValue(fieldName, field))
+		}
+		b.WriteString(strings.Replace(fields.String(), itemIndent, indent+"  - ", 1))
+	}
+}
+
+// shownValue returns the value of a field as shown, dereferencing pointers
+// and masking secrets
+func shownValue(name string, field reflect.Value) interface{} {
+	if field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+	if isSecret(name) && !field.IsZero() {
+		return maskedValue
+	}
+	return field.Interface()
+}
+
+// isSecret reports whether a key holds a credential that must not be shown
+func isSecret(name string) bool {
+	for _, suffix := range []string{"token", "password", "secret"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseByteSize parses a size such as "512M", "1.5GiB" or "1048576".
+// Suffixes are binary (K = 1024) and case-insensitive. An empty string
+// parses as 0.
+func ParseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	upper := strings.ToUpper(s)
+	upper = strings.TrimSuffix(upper, "B")
+	upper = strings.TrimSuffix(upper, "I")
+
+	multiplier := int64(1)
+	if n := len(upper); n > 0 {
+		switch upper[n-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			upper = upper[:n-1]
+		}
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(upper), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// ParseBitrate parses a bitrate in bits per second such as "4M", "800k" or
+// "2500000". Suffixes are decimal (k = 1000) and case-insensitive; a
+// trailing "bps" or "b" is allowed. An empty string parses as 0.
+func ParseBitrate(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	lower := strings.ToLower(s)
+	lower = strings.TrimSuffix(lower, "ps")
+	lower = strings.TrimSuffix(lower, "b")
+
+	multiplier := 1.0
+	if n := len(lower); n > 0 {
+		switch lower[n-1] {
+		case 'k':
+			multiplier = 1e3
+		case 'm':
+			multiplier = 1e6
+		case 'g':
+			multiplier = 1e9
+		}
+		if multiplier > 1 {
+			lower = lower[:n-1]
+		}
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(lower), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid bitrate %q", s)
+	}
+	return int64(value * multiplier), nil
+}
@@ -0,0 +1,27 @@
+package config
+
+import "testing"
+
+func TestParseBitrate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"2500000", 2500000, false},
+		{"800k", 800000, false},
+		{"4M", 4000000, false},
+		{"4Mbps", 4000000, false},
+		{"1.5m", 1500000, false},
+		{"1G", 1000000000, false},
+		{"fast", 0, true},
+		{"-4M", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBitrate(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseBitrate(%q) = %d, %v, want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
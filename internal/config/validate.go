@@ -0,0 +1,303 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+// Error returns all problems, one per line
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// validator collects configuration problems
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) port(key string, port int) {
+	if port < 1 || port > 65535 {
+		v.addf("%s: port %d out of range (1-65535)", key, port)
+	}
+}
+
+func (v *validator) positiveDuration(key string, d time.Duration) {
+	if d <= 0 {
+		v.addf("%s: must be a positive duration (e.g. \"30s\"), got %v", key, d)
+	}
+}
+
+func (v *validator) notEmpty(key, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.addf("%s: must not be empty", key)
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.addf("%s: unrecognized value %q (allowed: %s)", key, value, strings.Join(allowed, ", "))
+}
+
+// bindAddress checks a listener bind address: empty or a host or IP
+// address, without a port
+func (v *validator) bindAddress(key, address string) {
+	if address == "" {
+		return
+	}
+	if strings.ContainsAny(address, " \t/[]") || (strings.Contains(address, ":") && net.ParseIP(address) == nil) {
+		v.addf("%s: %q must be a host or IP address without a port (e.g. 127.0.0.1)", key, address)
+	}
+}
+
+func (v *validator) headers(key string, headers []string) {
+	for i, h := range headers {
+		name, _, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" || strings.ContainsAny(name, " \t\r\n") {
+			v.addf("%s[%d]: %q is not a \"Name: value\" header", key, i, h)
+		}
+	}
+}
+
+// distinctPorts reports ports used by more than one key. Zero ports are
+// disabled and skipped.
+func (v *validator) distinctPorts(ports map[string]int) {
+	keys := make([]string, 0, len(ports))
+	for key := range ports {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	used := make(map[int]string)
+	for _, key := range keys {
+		port := ports[key]
+		if port == 0 {
+			continue
+		}
+		if other, ok := used[port]; ok {
+			v.addf("%s: port %d is already used by %s", key, port, other)
+			continue
+		}
+		used[port] = key
+	}
+}
+
+// unitlessDurations reports duration keys given a bare number in the
+// config file, such as "timeout: 30", which would silently be read as
+// nanoseconds. A bare 0 is the same in any unit and accepted.
+func (v *validator) unitlessDurations(raw *viper.Viper, t reflect.Type, prefix string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			v.unitlessDurations(raw, field.Type, key)
+		case field.Type == reflect.TypeOf(time.Duration(0)):
+			switch n := raw.Get(key).(type) {
+			case int, int64, float64:
+				if fmt.Sprint(n) == "0" {
+					continue // zero needs no unit
+				}
+				v.addf("%s: %v has no unit (e.g. \"%vs\" or \"%vm\")", key, n, n, n)
+			}
+		}
+	}
+}
+
+// Validate checks configuration values and returns a *ValidationError
+// listing every problem, or nil if the configuration is valid
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	// Server
+	v.port("server.rtsp_port", c.Server.RTSPPort)
+	v.port("server.api_port", c.Server.APIPort)
+	v.bindAddress("server.rtsp_address", c.Server.RTSPAddress)
+	v.bindAddress("server.api_address", c.Server.APIAddress)
+	if c.Server.ControlAPIPort != 0 {
+		v.port("server.control_api_port", c.Server.ControlAPIPort)
+		v.bindAddress("server.control_api_address", c.Server.ControlAPIAddress)
+		if c.Server.ControlAPIToken == "" && !IsLoopback(c.Server.ControlAPIAddress) {
+			v.addf("server.control_api_token: required when the control API listens beyond loopback (server.control_api_address is %q)", c.Server.ControlAPIAddress)
+		}
+	}
+	if c.Health.Port != 0 {
+		v.port("health.port", c.Health.Port)
+		v.bindAddress("health.address", c.Health.Address)
+	}
+	v.distinctPorts(map[string]int{
+		"server.rtsp_port":        c.Server.RTSPPort,
+		"server.api_port":         c.Server.APIPort,
+		"server.control_api_port": c.Server.ControlAPIPort,
+		"health.port":             c.Health.Port,
+	})
+
+	// Binaries
+	v.notEmpty("mediamtx.binary_path", c.MediaMTX.BinaryPath)
+	v.notEmpty("ffmpeg.binary_path", c.FFmpeg.BinaryPath)
+	v.oneOf("ffmpeg.rtsp_transport", c.FFmpeg.RTSPTransport, "tcp", "udp")
+	v.positiveDuration("ffmpeg.start_timeout", c.FFmpeg.StartTimeout)
+	if c.FFmpeg.Nice < -20 || c.FFmpeg.Nice > 19 {
+		v.addf("ffmpeg.nice: must be between -20 and 19, got %d", c.FFmpeg.Nice)
+	}
+	if _, err := ParseByteSize(c.FFmpeg.MemoryLimit); err != nil {
+		v.addf("ffmpeg.memory_limit: %v (e.g. \"512M\", \"1G\")", err)
+	}
+	v.headers("ffmpeg.headers", c.FFmpeg.Headers)
+	if size, err := ParseByteSize(c.FFmpeg.Probesize); err != nil {
+		v.addf("ffmpeg.probesize: %v (e.g. \"5M\")", err)
+	} else if c.FFmpeg.Probesize != "" && size < 32 {
+		v.addf("ffmpeg.probesize: must be at least 32 bytes, got %q", c.FFmpeg.Probesize)
+	}
+	if c.FFmpeg.AnalyzeDuration < 0 {
+		v.addf("ffmpeg.analyzeduration: must not be negative, got %v", c.FFmpeg.AnalyzeDuration)
+	}
+	v.notEmpty("ytdlp.binary_path", c.Ytdlp.BinaryPath)
+	v.headers("ytdlp.headers", c.Ytdlp.Headers)
+	v.oneOf("mediamtx.log_level", c.MediaMTX.LogLevel, "debug", "info", "warn", "error")
+	v.positiveDuration("mediamtx.on_demand_close_after", c.MediaMTX.OnDemandCloseAfter)
+	v.positiveDuration("mediamtx.ready_timeout", c.MediaMTX.ReadyTimeout)
+	if c.MediaMTX.MaxReaders < 0 {
+		v.addf("mediamtx.max_readers: must not be negative (0 = unlimited), got %d", c.MediaMTX.MaxReaders)
+	}
+	if c.MediaMTX.Record {
+		v.positiveDuration("mediamtx.record_segment_duration", c.MediaMTX.RecordSegmentDuration)
+		if c.MediaMTX.RecordPath != "" && !strings.Contains(c.MediaMTX.RecordPath, "%path") {
+			v.addf("mediamtx.record_path: %q must contain %%path, so streams record into separate files", c.MediaMTX.RecordPath)
+		}
+	}
+
+	// yt-dlp
+	v.positiveDuration("ytdlp.timeout", c.Ytdlp.Timeout)
+	if c.Ytdlp.RetryAttempts < 1 {
+		v.addf("ytdlp.retry_attempts: must be at least 1, got %d", c.Ytdlp.RetryAttempts)
+	}
+	v.positiveDuration("ytdlp.retry_backoff", c.Ytdlp.RetryBackoff)
+	if c.Ytdlp.MaxConcurrent < 1 {
+		v.addf("ytdlp.max_concurrent: must be at least 1, got %d", c.Ytdlp.MaxConcurrent)
+	}
+	if c.Ytdlp.MinInterval < 0 {
+		v.addf("ytdlp.min_interval: must not be negative, got %v", c.Ytdlp.MinInterval)
+	}
+	if c.Ytdlp.RateLimitCooldown < 0 {
+		v.addf("ytdlp.rate_limit_cooldown: must not be negative, got %v", c.Ytdlp.RateLimitCooldown)
+	}
+
+	// Monitor
+	v.positiveDuration("monitor.health_check_interval", c.Monitor.HealthCheckInterval)
+	v.positiveDuration("monitor.url_refresh_interval", c.Monitor.URLRefreshInterval)
+	if c.Monitor.MaxConsecutiveErrors < 1 {
+		v.addf("monitor.max_consecutive_errors: must be at least 1, got %d", c.Monitor.MaxConsecutiveErrors)
+	}
+	if c.Monitor.StallThreshold < 1 {
+		v.addf("monitor.stall_threshold: must be at least 1, got %d", c.Monitor.StallThreshold)
+	}
+	if c.Monitor.RecoveryThreshold < 1 {
+		v.addf("monitor.recovery_threshold: must be at least 1, got %d", c.Monitor.RecoveryThreshold)
+	}
+	v.positiveDuration("monitor.reconnect.initial_delay", c.Monitor.Reconnect.InitialDelay)
+	v.positiveDuration("monitor.reconnect.max_delay", c.Monitor.Reconnect.MaxDelay)
+	if c.Monitor.Reconnect.MaxDelay < c.Monitor.Reconnect.InitialDelay {
+		v.addf("monitor.reconnect.max_delay: %v is less than initial_delay %v",
+			c.Monitor.Reconnect.MaxDelay, c.Monitor.Reconnect.InitialDelay)
+	}
+	if c.Monitor.Reconnect.Multiplier <= 1 {
+		v.addf("monitor.reconnect.multiplier: must be greater than 1, got %v", c.Monitor.Reconnect.Multiplier)
+	}
+	if c.Monitor.Reconnect.MaxAttempts < 1 {
+		v.addf("monitor.reconnect.max_attempts: must be at least 1, got %d", c.Monitor.Reconnect.MaxAttempts)
+	}
+	if c.Monitor.Reconnect.Jitter < 0 || c.Monitor.Reconnect.Jitter >= 1 {
+		v.addf("monitor.reconnect.jitter: must be between 0 and 1 (exclusive), got %v", c.Monitor.Reconnect.Jitter)
+	}
+
+	// Storage
+	v.oneOf("storage.backend", c.Storage.Backend, "file", "sqlite")
+
+	// Logging
+	v.oneOf("logging.level", c.Logging.Level, "debug", "info", "warn", "error")
+	v.oneOf("logging.format", c.Logging.Format, "text", "json")
+	if c.Logging.MaxLineLength < 0 {
+		v.addf("logging.max_line_length: must not be negative, got %d", c.Logging.MaxLineLength)
+	}
+	v.oneOf("logging.rotation", c.Logging.Rotation, "size", "lines")
+	if size, err := ParseByteSize(c.Logging.MaxSize); err != nil {
+		v.addf("logging.max_size: %v (e.g. \"256K\")", err)
+	} else if c.Logging.Rotation == "size" && size < 1024 {
+		v.addf("logging.max_size: must be at least 1K, got %q", c.Logging.MaxSize)
+	}
+	if c.Logging.MaxArchives < 0 {
+		v.addf("logging.max_archives: must not be negative, got %d", c.Logging.MaxArchives)
+	}
+
+	// Hooks
+	v.positiveDuration("hooks.timeout", c.Hooks.Timeout)
+
+	// Declarative streams
+	seen := make(map[string]bool)
+	for i, s := range c.Streams {
+		key := fmt.Sprintf("streams[%d]", i)
+		v.notEmpty(key+".name", s.Name)
+		v.notEmpty(key+".url", s.URL)
+		if s.Port != 0 {
+			v.port(key+".port", s.Port)
+		}
+		if s.Transport != "" {
+			v.oneOf(key+".transport", s.Transport, "tcp", "udp")
+		}
+		if _, err := ParseBitrate(s.MaxBitrate); err != nil {
+			v.addf("%s.max_bitrate: %v", key, err)
+		}
+		if s.Name != "" && seen[s.Name] {
+			v.addf("%s.name: duplicate stream name %q", key, s.Name)
+		}
+		seen[s.Name] = true
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// validateRaw validates a configuration along with the values it was
+// unmarshaled from, catching mistakes lost in unmarshaling
+func validateRaw(c *Config, raw *viper.Viper) error {
+	v := &validator{}
+	v.unitlessDurations(raw, reflect.TypeOf(Config{}), "")
+
+	err := c.Validate()
+	if len(v.problems) == 0 {
+		return err
+	}
+	if verr, ok := err.(*ValidationError); ok {
+		v.problems = append(v.problems, verr.Problems...)
+	}
+	return &ValidationError{Problems: v.problems}
+}
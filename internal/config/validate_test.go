@@ -0,0 +1,294 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadYAML writes content to a config file and loads it
+func loadYAML(t *testing.T, content string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestUnitlessDurations(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    time.Duration
+		wantErr string
+	}{
+		{"with unit", "ffmpeg:\n  analyzeduration: \"10s\"\n", 10 * time.Second, ""},
+		{"bare zero", "ffmpeg:\n  analyzeduration: 0\n", 0, ""},
+		{"quoted zero with unit", "ffmpeg:\n  analyzeduration: \"0s\"\n", 0, ""},
+		{"bare number", "ffmpeg:\n  analyzeduration: 10\n", 0, "ffmpeg.analyzeduration: 10 has no unit"},
+		{"bare float", "ffmpeg:\n  analyzeduration: 1.5\n", 0, "ffmpeg.analyzeduration: 1.5 has no unit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadYAML(t, tt.yaml)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Load = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.FFmpeg.AnalyzeDuration != tt.want {
+				t.Errorf("analyzeduration = %v, want %v", cfg.FFmpeg.AnalyzeDuration, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"defaults", "mediamtx:\n  record: true\n", ""},
+		{"path with placeholder", "mediamtx:\n  record: true\n  record_path: /srv/rec/%path/%Y-%m-%d_%H-%M-%S-%f\n", ""},
+		{"path without placeholder", "mediamtx:\n  record: true\n  record_path: /srv/rec/%Y\n", "mediamtx.record_path: \"/srv/rec/%Y\" must contain %path"},
+		{"zero segment", "mediamtx:\n  record: true\n  record_segment_duration: 0\n", "mediamtx.record_segment_duration: must be a positive duration"},
+		{"not recording", "mediamtx:\n  record_path: /srv/rec\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadYAML(t, tt.yaml)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStartupTimeouts(t *testing.T) {
+	tests := []struct {
+		name                 string
+		yaml                 string
+		wantStart, wantReady time.Duration
+		wantErr              string
+	}{
+		{"defaults", "", 15 * time.Second, 5 * time.Second, ""},
+		{"custom", "ffmpeg:\n  start_timeout: 1m\nmediamtx:\n  ready_timeout: 20s\n", time.Minute, 20 * time.Second, ""},
+		{"zero start", "ffmpeg:\n  start_timeout: 0s\n", 0, 0, "ffmpeg.start_timeout: must be a positive duration"},
+		{"negative ready", "mediamtx:\n  ready_timeout: -5s\n", 0, 0, "mediamtx.ready_timeout: must be a positive duration"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadYAML(t, tt.yaml)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Load = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.FFmpeg.StartTimeout != tt.wantStart || cfg.MediaMTX.ReadyTimeout != tt.wantReady {
+				t.Errorf("timeouts: start %v, ready %v, want %v, %v",
+					cfg.FFmpeg.StartTimeout, cfg.MediaMTX.ReadyTimeout, tt.wantStart, tt.wantReady)
+			}
+		})
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	tests := []struct {
+		name                    string
+		yaml                    string
+		wantStall, wantRecovery int
+		wantErr                 string
+	}{
+		{"defaults", "", 3, 1, ""},
+		{"custom", "monitor:\n  stall_threshold: 6\n  recovery_threshold: 4\n", 6, 4, ""},
+		{"zero stall", "monitor:\n  stall_threshold: 0\n", 0, 0, "monitor.stall_threshold: must be at least 1, got 0"},
+		{"negative recovery", "monitor:\n  recovery_threshold: -2\n", 0, 0, "monitor.recovery_threshold: must be at least 1, got -2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadYAML(t, tt.yaml)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Load = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Monitor.StallThreshold != tt.wantStall || cfg.Monitor.RecoveryThreshold != tt.wantRecovery {
+				t.Errorf("thresholds: stall %d, recovery %d, want %d, %d",
+					cfg.Monitor.StallThreshold, cfg.Monitor.RecoveryThreshold, tt.wantStall, tt.wantRecovery)
+			}
+		})
+	}
+}
+
+func TestRTSPTransport(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    string
+		wantErr string
+	}{
+		{"default", "", "tcp", ""},
+		{"udp", "ffmpeg:\n  rtsp_transport: udp\n", "udp", ""},
+		{"unknown", "ffmpeg:\n  rtsp_transport: http\n", "", `ffmpeg.rtsp_transport: unrecognized value "http"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadYAML(t, tt.yaml)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Load = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.FFmpeg.RTSPTransport != tt.want {
+				t.Errorf("rtsp_transport = %q, want %q", cfg.FFmpeg.RTSPTransport, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxReaders(t *testing.T) {
+	for _, tt := range []struct {
+		yaml    string
+		want    int
+		wantErr string
+	}{
+		{"", 0, ""},
+		{"mediamtx:\n  max_readers: 10\n", 10, ""},
+		{"mediamtx:\n  max_readers: -1\n", 0, "mediamtx.max_readers: must not be negative (0 = unlimited), got -1"},
+	} {
+		cfg, err := loadYAML(t, tt.yaml)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load(%q) = %v, want an error containing %q", tt.yaml, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Load(%q): %v", tt.yaml, err)
+		}
+		if cfg.MediaMTX.MaxReaders != tt.want {
+			t.Errorf("Load(%q): max_readers = %d, want %d", tt.yaml, cfg.MediaMTX.MaxReaders, tt.want)
+		}
+	}
+}
+
+func TestAPIURL(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{"", "http://localhost:9997/v3/paths/list"},
+		{"0.0.0.0", "http://localhost:9997/v3/paths/list"},
+		{"127.0.0.1", "http://127.0.0.1:9997/v3/paths/list"},
+		{"10.0.0.5", "http://10.0.0.5:9997/v3/paths/list"},
+		{"::1", "http://[::1]:9997/v3/paths/list"},
+	}
+	for _, tt := range tests {
+		s := ServerConfig{APIAddress: tt.address, APIPort: 9997}
+		if got := s.APIURL("/v3/paths/list"); got != tt.want {
+			t.Errorf("APIURL with api_address %q = %s, want %s", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"rtsp port out of range", "server:\n  rtsp_port: 99999\n", "server.rtsp_port: port 99999 out of range (1-65535)"},
+		{"zero multiplier", "monitor:\n  reconnect:\n    multiplier: 0\n", "monitor.reconnect.multiplier: must be greater than 1, got 0"},
+		{"negative interval", "monitor:\n  health_check_interval: -10s\n", "monitor.health_check_interval: must be a positive duration"},
+		{"negative timeout", "ytdlp:\n  timeout: -1m\n", "ytdlp.timeout: must be a positive duration"},
+		{"negative min interval", "ytdlp:\n  min_interval: -1s\n", "ytdlp.min_interval: must not be negative, got -1s"},
+		{"empty ffmpeg path", "ffmpeg:\n  binary_path: \"\"\n", "ffmpeg.binary_path: must not be empty"},
+		{"blank mediamtx path", "mediamtx:\n  binary_path: \"  \"\n", "mediamtx.binary_path: must not be empty"},
+		{"empty ytdlp path", "ytdlp:\n  binary_path: \"\"\n", "ytdlp.binary_path: must not be empty"},
+		{"unknown log format", "logging:\n  format: xml\n", `logging.format: unrecognized value "xml" (allowed: text, json)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadYAML(t, tt.yaml)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidationErrorListsEveryProblem(t *testing.T) {
+	_, err := loadYAML(t, "server:\n  rtsp_port: 0\nlogging:\n  format: xml\n  level: loud\nmonitor:\n  reconnect:\n    multiplier: 0.5\n")
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Load = %v, want a *ValidationError", err)
+	}
+	want := []string{"server.rtsp_port:", "monitor.reconnect.multiplier:", "logging.level:", "logging.format:"}
+	if len(verr.Problems) != len(want) {
+		t.Fatalf("problems %q, want one each for %q", verr.Problems, want)
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(verr.Problems[i], prefix) {
+			t.Errorf("problem %d = %q, want it about %s", i, verr.Problems[i], prefix)
+		}
+		if !strings.Contains(err.Error(), "\n  - "+verr.Problems[i]) {
+			t.Errorf("error %q does not list %q on its own line", err, verr.Problems[i])
+		}
+	}
+}
+
+func TestValidConfigLoads(t *testing.T) {
+	cfg, err := loadYAML(t, `server:
+  rtsp_port: 8555
+  api_port: 9998
+ffmpeg:
+  binary_path: /usr/bin/ffmpeg
+  nice: 5
+  memory_limit: 1G
+monitor:
+  health_check_interval: 10s
+  reconnect:
+    initial_delay: 2s
+    max_delay: 1m
+    multiplier: 1.5
+logging:
+  level: debug
+  format: json
+streams:
+  - name: news
+    url: https://youtu.be/abc123
+`)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.RTSPPort != 8555 || cfg.FFmpeg.Nice != 5 || cfg.Monitor.Reconnect.Multiplier != 1.5 || cfg.Logging.Format != "json" || len(cfg.Streams) != 1 {
+		t.Errorf("loaded %+v, want the values from the file", cfg)
+	}
+}
@@ -0,0 +1,29 @@
+package extractor
+
+import (
+	"context"
+)
+
+// DirectExtractor passes URLs that ffmpeg can read directly through
+// unchanged, without running yt-dlp
+type DirectExtractor struct{}
+
+// NewDirectExtractor creates a pass-through extractor
+func NewDirectExtractor() *DirectExtractor {
+	return &DirectExtractor{}
+}
+
+// Extract returns the URL as the stream URL. No metadata is available, so
+// the ID is left empty.
+func (e *DirectExtractor) Extract(ctx context.Context, rawURL string, opts ExtractOptions) (*StreamInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &StreamInfo{URL: rawURL}, nil
+}
+
+// IsLiveStream reports direct sources as live; there is no way to tell
+// without reading them, and live keeps the stream reconnecting as before
+func (e *DirectExtractor) IsLiveStream(ctx context.Context, rawURL string) (bool, error) {
+	return true, nil
+}
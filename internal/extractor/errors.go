@@ -0,0 +1,202 @@
+package extractor
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// Extraction failures recognised from yt-dlp's error messages. Match them
+// with errors.Is.
+var (
+	// ErrVideoUnavailable means the video does not exist or was removed
+	ErrVideoUnavailable = errors.New("video unavailable")
+	// ErrPrivate means the video is private
+	ErrPrivate = errors.New("private video")
+	// ErrGeoBlocked means the video is blocked in this host's country
+	ErrGeoBlocked = errors.New("blocked in this country")
+	// ErrAuthRequired means the video is age-restricted or members-only
+	ErrAuthRequired = errors.New("sign-in required")
+	// ErrLiveEnded means the live event has ended
+	ErrLiveEnded = errors.New("live event has ended")
+	// ErrRateLimited means YouTube is throttling requests from this host
+	ErrRateLimited = errors.New("rate limited")
+	// ErrOutdated means yt-dlp no longer understands YouTube's pages and
+	// needs updating
+	ErrOutdated = errors.New("yt-dlp is outdated")
+	// ErrURLExpired means an extracted media URL stopped working, as it
+	// does once its signature expires; extracting again fixes it. It is
+	// recognised from ffmpeg's output by ClassifyMediaFailure.
+	ErrURLExpired = errors.New("stream URL expired")
+)
+
+// errorPatterns maps lowercase fragments of yt-dlp error messages to the
+// failures they indicate. yt-dlp prefixes most reasons with "Video
+// unavailable", so the specific ones come first.
+var errorPatterns = []struct {
+	fragment string
+	err      error
+}{
+	{"this live event has ended", ErrLiveEnded},
+	{"private video", ErrPrivate},
+	{"this video is private", ErrPrivate},
+	{"sign in to confirm your age", ErrAuthRequired},
+	{"members-only", ErrAuthRequired},
+	{"http error 429", ErrRateLimited},
+	{"too many requests", ErrRateLimited},
+	{"sign in to confirm you're not a bot", ErrRateLimited},
+	{"not available in your country", ErrGeoBlocked},
+	{"blocked it in your country", ErrGeoBlocked},
+	{"not made this video available in your country", ErrGeoBlocked},
+	{"video unavailable", ErrVideoUnavailable},
+	{"has been removed", ErrVideoUnavailable},
+	{"unsupported url", ErrVideoUnavailable},
+	{"is not a valid url", ErrVideoUnavailable},
+	{"unable to extract", ErrOutdated},
+	{"please update yt-dlp", ErrOutdated},
+	{"nsig extraction failed", ErrOutdated},
+}
+
+// mediaFailurePatterns maps lowercase fragments of the errors ffmpeg and
+// yt-dlp print when reading an extracted media URL to the failures they
+// indicate
+var mediaFailurePatterns = []struct {
+	fragment string
+	err      error
+}{
+	{"403 forbidden", ErrURLExpired},
+	{"http error 403", ErrURLExpired},
+	{"404 not found", ErrURLExpired},
+	{"http error 404", ErrURLExpired},
+	{"410 gone", ErrURLExpired},
+	{"http error 410", ErrURLExpired},
+	{"expired", ErrURLExpired},
+}
+
+// IsPermanent reports whether err is an extraction failure that retrying
+// cannot fix, such as an unavailable, private or ended video
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrVideoUnavailable) ||
+		errors.Is(err, ErrPrivate) ||
+		errors.Is(err, ErrGeoBlocked) ||
+		errors.Is(err, ErrAuthRequired) ||
+		errors.Is(err, ErrLiveEnded)
+}
+
+// Hint suggests what to do about an extraction failure, or returns "".
+// Errors that lost their type, such as ones relayed by the daemon, are
+// recognised from their message.
+func Hint(err error) string {
+	if err == nil {
+		return ""
+	}
+	if kind := classify(err.Error()); kind != nil {
+		err = errors.Join(err, kind)
+	}
+	switch {
+	case errors.Is(err, ErrLiveEnded):
+		return "the live event is over; start the stream again when the next one begins"
+	case errors.Is(err, ErrPrivate):
+		return "the video is private and cannot be proxied"
+	case errors.Is(err, ErrGeoBlocked):
+		return "the video is not available in this host's country"
+	case errors.Is(err, ErrAuthRequired):
+		return "the video is age-restricted or members-only and cannot be proxied without signing in"
+	case errors.Is(err, ErrRateLimited):
+		return "YouTube is rate limiting this host; wait a few minutes before trying again"
+	case errors.Is(err, ErrVideoUnavailable):
+		return "check that the URL is correct and the video is available in your country"
+	case errors.Is(err, ErrOutdated):
+		return "YouTube changed in a way this yt-dlp does not understand; update it with: youtube-rtsp-proxy update ytdlp"
+	}
+	return ""
+}
+
+// YtdlpError is a failed yt-dlp run with the message it printed
+type YtdlpError struct {
+	// Message is yt-dlp's error message, without the "ERROR:" prefix
+	Message string
+	// Kind is one of the Err* failures, or nil if unrecognised
+	Kind error
+	// Err is the error of the process
+	Err error
+}
+
+func (e *YtdlpError) Error() string {
+	return e.Message
+}
+
+func (e *YtdlpError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// withStderr turns a failed run's error into a *YtdlpError carrying the
+// error yt-dlp printed
+func withStderr(err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+	msg := stderrMessage(string(exitErr.Stderr))
+	if msg == "" {
+		return err
+	}
+	return &YtdlpError{Message: msg, Kind: classify(msg), Err: err}
+}
+
+// classify returns the failure a yt-dlp error message indicates, or nil.
+// YouTube's messages use typographic apostrophes, as in "you’re".
+func classify(msg string) error {
+	msg = strings.ReplaceAll(strings.ToLower(msg), "’", "'")
+	for _, p := range errorPatterns {
+		if strings.Contains(msg, p.fragment) {
+			return p.err
+		}
+	}
+	return nil
+}
+
+// ClassifyMediaFailure returns the failure that an error printed while
+// reading an extracted media URL indicates, such as ErrURLExpired for the
+// HTTP 403 of an expired signature, or nil
+func ClassifyMediaFailure(msg string) error {
+	msg = strings.ToLower(msg)
+	for _, p := range mediaFailurePatterns {
+		if strings.Contains(msg, p.fragment) {
+			return p.err
+		}
+	}
+	return nil
+}
+
+// stderrTailLines is how many of yt-dlp's last stderr lines describe a
+// failure without an "ERROR:" line, such as a Python traceback
+const stderrTailLines = 3
+
+// stderrMessage returns the last "ERROR:" line of yt-dlp's stderr, or the
+// last few non-empty lines if there is none
+func stderrMessage(stderr string) string {
+	var tail []string
+	var lastError string
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "ERROR:") {
+			lastError = strings.TrimSpace(strings.TrimPrefix(line, "ERROR:"))
+			continue
+		}
+		tail = append(tail, line)
+		if len(tail) > stderrTailLines {
+			tail = tail[1:]
+		}
+	}
+	if lastError != "" {
+		return lastError
+	}
+	return strings.Join(tail, " | ")
+}
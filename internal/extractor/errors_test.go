@@ -0,0 +1,105 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// ytdlpErrors are yt-dlp's messages for failures it reports, with the
+// failure each indicates (nil = unrecognised)
+var ytdlpErrors = []struct {
+	stderr string
+	want   error
+}{
+	{"ERROR: [youtube] abc123: Video unavailable. This video has been removed by the uploader", ErrVideoUnavailable},
+	{"ERROR: [youtube] abc123: Video unavailable", ErrVideoUnavailable},
+	{"ERROR: Unsupported URL: https://example.com/", ErrVideoUnavailable},
+	{"ERROR: [generic] 'abc' is not a valid URL. Set --default-search \"ytsearch\" (or run  yt-dlp \"ytsearch:abc\" ) to search YouTube", ErrVideoUnavailable},
+	{"ERROR: [youtube] abc123: Private video. Sign in if you've been granted access to this video", ErrPrivate},
+	{"ERROR: [youtube] abc123: Video unavailable. This video is private", ErrPrivate},
+	{"ERROR: [youtube] abc123: Video unavailable. The uploader has not made this video available in your country", ErrGeoBlocked},
+	{"ERROR: [youtube] abc123: Sign in to confirm your age. This video may be inappropriate for some users.", ErrAuthRequired},
+	{"ERROR: [youtube] abc123: Join this channel to get access to members-only content like this video, and other exclusive perks.", ErrAuthRequired},
+	{"ERROR: [youtube] abc123: This live event has ended.", ErrLiveEnded},
+	{"ERROR: [youtube] abc123: Sign in to confirm you’re not a bot. Use --cookies-from-browser or --cookies for the authentication.", ErrRateLimited},
+	{"ERROR: unable to download video data: HTTP Error 429: Too Many Requests", ErrRateLimited},
+	{"ERROR: [youtube] abc123: Unable to extract uploader id; please report this issue on https://github.com/yt-dlp/yt-dlp/issues", ErrOutdated},
+	{"ERROR: [youtube] abc123: Something went wrong", nil},
+}
+
+func TestExtractClassifiesYtdlpErrors(t *testing.T) {
+	kinds := []error{ErrVideoUnavailable, ErrPrivate, ErrGeoBlocked, ErrAuthRequired, ErrLiveEnded, ErrRateLimited, ErrOutdated}
+	for _, tt := range ytdlpErrors {
+		t.Run(tt.stderr, func(t *testing.T) {
+			e := fakeYtdlp(t, "cat >&2 <<'EOF'\n[youtube] Extracting URL: https://youtu.be/abc123\n"+tt.stderr+"\nEOF\nexit 1\n")
+			_, err := e.Extract(context.Background(), "https://youtu.be/abc123", ExtractOptions{})
+			if err == nil {
+				t.Fatal("Extract succeeded, want an error")
+			}
+			for _, kind := range kinds {
+				if got := errors.Is(err, kind); got != (kind == tt.want) {
+					t.Errorf("errors.Is(%v, %v) = %v, want %v", err, kind, got, !got)
+				}
+			}
+			var ytdlpErr *YtdlpError
+			if !errors.As(err, &ytdlpErr) {
+				t.Fatalf("Extract = %v, want a *YtdlpError", err)
+			}
+			if want := strings.TrimPrefix(tt.stderr, "ERROR: "); ytdlpErr.Message != want {
+				t.Errorf("message = %q, want %q", ytdlpErr.Message, want)
+			}
+		})
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{ErrVideoUnavailable, true},
+		{ErrPrivate, true},
+		{ErrGeoBlocked, true},
+		{ErrAuthRequired, true},
+		{ErrLiveEnded, true},
+		{ErrRateLimited, false},
+		{ErrOutdated, false},
+		{ErrURLExpired, false},
+		{&YtdlpError{Message: "This live event has ended.", Kind: ErrLiveEnded, Err: errors.New("exit status 1")}, true},
+		{errors.New("exit status 1"), false},
+	} {
+		if got := IsPermanent(tt.err); got != tt.want {
+			t.Errorf("IsPermanent(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestHintFromRelayedMessage(t *testing.T) {
+	// The daemon relays errors as text, losing their type
+	err := errors.New("failed to extract stream URL: [youtube] abc123: This live event has ended.")
+	if got := Hint(err); !strings.Contains(got, "live event is over") {
+		t.Errorf("Hint(%v) = %q, want the ended live event hint", err, got)
+	}
+	if got := Hint(errors.New("connection refused")); got != "" {
+		t.Errorf("Hint of an unrecognised error = %q, want none", got)
+	}
+}
+
+func TestClassifyMediaFailure(t *testing.T) {
+	for _, tt := range []struct {
+		stderr string
+		want   error
+	}{
+		{"[https @ 0x5581c0] HTTP error 403 Forbidden", ErrURLExpired},
+		{"https://rr3---sn-abc.googlevideo.com/videoplayback: Server returned 403 Forbidden (access denied)", ErrURLExpired},
+		{"Server returned 404 Not Found", ErrURLExpired},
+		{"ERROR: unable to download video data: HTTP Error 410: Gone", ErrURLExpired},
+		{"Connection timed out", nil},
+	} {
+		if got := ClassifyMediaFailure(tt.stderr); got != tt.want {
+			t.Errorf("ClassifyMediaFailure(%q) = %v, want %v", tt.stderr, got, tt.want)
+		}
+	}
+}
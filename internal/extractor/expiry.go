@@ -0,0 +1,36 @@
+package extractor
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// URLExpiry returns when a direct media URL expires, from the expire
+// parameter YouTube signs into its URLs: a query parameter for media
+// files, a path segment for HLS manifests. ok is false for URLs without
+// one.
+func URLExpiry(rawURL string) (expires time.Time, ok bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	value := u.Query().Get("expire")
+	if value == "" {
+		segments := strings.Split(u.Path, "/")
+		for i := 0; i+1 < len(segments); i++ {
+			if segments[i] == "expire" {
+				value = segments[i+1]
+				break
+			}
+		}
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
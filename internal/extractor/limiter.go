@@ -0,0 +1,137 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Limiter wraps an extractor so it is called sparingly: at most a fixed
+// number of extractions at once, started a minimum interval apart, and
+// none while YouTube is rate limiting this host. Extractions refreshing
+// many streams at once, e.g. after MediaMTX restarts, otherwise trigger
+// HTTP 429 responses that fail every stream. Share one limiter between
+// everything that extracts.
+type Limiter struct {
+	ext      Extractor
+	slots    chan struct{}
+	interval time.Duration
+	cooldown time.Duration
+	log      *slog.Logger
+
+	mu        sync.Mutex
+	next      time.Time // earliest start of the next extraction
+	coolUntil time.Time // end of the rate limit cool-down
+}
+
+// NewLimiter creates a limiter running at most maxConcurrent extractions
+// of ext at once, started at least interval apart, and pausing
+// extractions for cooldown after one was rate limited (0 = no pause)
+func NewLimiter(ext Extractor, maxConcurrent int, interval, cooldown time.Duration, log *slog.Logger) *Limiter {
+	return &Limiter{
+		ext:      ext,
+		slots:    make(chan struct{}, max(maxConcurrent, 1)),
+		interval: interval,
+		cooldown: cooldown,
+		log:      log,
+	}
+}
+
+// Extract extracts the stream URL once the limits allow
+func (l *Limiter) Extract(ctx context.Context, youtubeURL string, opts ExtractOptions) (*StreamInfo, error) {
+	if err := l.acquire(ctx, youtubeURL); err != nil {
+		return nil, err
+	}
+	defer l.release()
+
+	info, err := l.ext.Extract(ctx, youtubeURL, opts)
+	l.observe(err)
+	return info, err
+}
+
+// IsLiveStream checks the live status once the limits allow
+func (l *Limiter) IsLiveStream(ctx context.Context, youtubeURL string) (bool, error) {
+	if err := l.acquire(ctx, youtubeURL); err != nil {
+		return false, err
+	}
+	defer l.release()
+
+	live, err := l.ext.IsLiveStream(ctx, youtubeURL)
+	l.observe(err)
+	return live, err
+}
+
+// acquire waits for a free slot, the end of a rate limit cool-down and the
+// minimum interval since the last extraction started. A cool-down that
+// outlasts ctx fails at once with ErrRateLimited instead of waiting.
+func (l *Limiter) acquire(ctx context.Context, youtubeURL string) error {
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		coolUntil := l.coolUntil
+		start := l.next
+		if now.After(start) {
+			start = now
+		}
+		if coolUntil.After(start) {
+			start = coolUntil
+		}
+		if !start.After(now) {
+			l.next = now.Add(l.interval)
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		if coolUntil.After(now) {
+			if deadline, ok := ctx.Deadline(); ok && deadline.Before(coolUntil) {
+				l.release()
+				return fmt.Errorf("YouTube is rate limiting this host: extractions are paused until %s: %w",
+					coolUntil.Format("15:04:05"), ErrRateLimited)
+			}
+			l.log.Warn("extraction deferred: YouTube is rate limiting this host",
+				"url", youtubeURL, "until", coolUntil.Format(time.RFC3339))
+		}
+
+		// Check again after waiting, as a rate limited extraction may have
+		// extended the cool-down meanwhile
+		timer := time.NewTimer(start.Sub(now))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			l.release()
+			return ctx.Err()
+		}
+	}
+}
+
+// release frees the slot of a finished extraction
+func (l *Limiter) release() {
+	<-l.slots
+}
+
+// observe starts a cool-down if an extraction was rate limited
+func (l *Limiter) observe(err error) {
+	if l.cooldown <= 0 || !errors.Is(err, ErrRateLimited) {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	until := time.Now().Add(l.cooldown)
+	if until.After(l.coolUntil) {
+		l.coolUntil = until
+		l.log.Warn("YouTube is rate limiting this host: pausing extractions",
+			"cooldown", l.cooldown, "until", until.Format(time.RFC3339), "error", err)
+	}
+}
@@ -0,0 +1,186 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeExtractor records how many calls run at once
+type fakeExtractor struct {
+	delay   time.Duration
+	err     error
+	running atomic.Int32
+	peak    atomic.Int32
+	calls   atomic.Int32
+	release chan struct{} // if set, calls block until it is closed
+}
+
+func (f *fakeExtractor) enter() {
+	f.calls.Add(1)
+	n := f.running.Add(1)
+	for {
+		peak := f.peak.Load()
+		if n <= peak || f.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	if f.release != nil {
+		<-f.release
+	}
+	time.Sleep(f.delay)
+	f.running.Add(-1)
+}
+
+func (f *fakeExtractor) Extract(ctx context.Context, youtubeURL string, opts ExtractOptions) (*StreamInfo, error) {
+	f.enter()
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &StreamInfo{URL: youtubeURL}, nil
+}
+
+func (f *fakeExtractor) IsLiveStream(ctx context.Context, youtubeURL string) (bool, error) {
+	f.enter()
+	return true, f.err
+}
+
+func discardLog() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestLimiterBoundsConcurrency(t *testing.T) {
+	for _, maxConcurrent := range []int{1, 3} {
+		t.Run(fmt.Sprint(maxConcurrent), func(t *testing.T) {
+			ext := &fakeExtractor{delay: 5 * time.Millisecond}
+			l := NewLimiter(ext, maxConcurrent, 0, 0, discardLog())
+
+			// Extractions and live checks interleave, as the manager and
+			// the monitor call them
+			var wg sync.WaitGroup
+			for i := range 24 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					url := fmt.Sprintf("https://youtu.be/%d", i)
+					var err error
+					if i%2 == 0 {
+						_, err = l.Extract(context.Background(), url, ExtractOptions{})
+					} else {
+						_, err = l.IsLiveStream(context.Background(), url)
+					}
+					if err != nil {
+						t.Errorf("call %d: %v", i, err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if got := ext.calls.Load(); got != 24 {
+				t.Errorf("%d calls reached the extractor, want 24", got)
+			}
+			if peak := ext.peak.Load(); peak > int32(maxConcurrent) {
+				t.Errorf("%d extractions ran at once, max is %d", peak, maxConcurrent)
+			}
+			if maxConcurrent > 1 && ext.peak.Load() < 2 {
+				t.Errorf("extractions never overlapped (peak %d)", ext.peak.Load())
+			}
+		})
+	}
+}
+
+func TestLimiterNonPositiveMaxAllowsOne(t *testing.T) {
+	ext := &fakeExtractor{delay: 2 * time.Millisecond}
+	l := NewLimiter(ext, 0, 0, 0, discardLog())
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Extract(context.Background(), "https://youtu.be/x", ExtractOptions{})
+		}()
+	}
+	wg.Wait()
+	if peak := ext.peak.Load(); peak != 1 {
+		t.Errorf("%d extractions ran at once, want 1", peak)
+	}
+}
+
+func TestLimiterWaitHonorsContext(t *testing.T) {
+	ext := &fakeExtractor{release: make(chan struct{})}
+	l := NewLimiter(ext, 1, 0, 0, discardLog())
+
+	// Occupy the only slot
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Extract(context.Background(), "https://youtu.be/busy", ExtractOptions{})
+	}()
+	for ext.running.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Extract(ctx, "https://youtu.be/waiting", ExtractOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Extract while the slot is taken = %v, want deadline exceeded", err)
+	}
+
+	close(ext.release)
+	<-done
+
+	// The cancelled wait did not leak the slot
+	ext.release = nil
+	ctx, cancel2 := context.WithTimeout(context.Background(), time.Second)
+	defer cancel2()
+	if _, err := l.Extract(ctx, "https://youtu.be/after", ExtractOptions{}); err != nil {
+		t.Errorf("Extract after the slot was freed: %v", err)
+	}
+}
+
+func TestLimiterInterval(t *testing.T) {
+	ext := &fakeExtractor{}
+	interval := 30 * time.Millisecond
+	l := NewLimiter(ext, 4, interval, 0, discardLog())
+
+	start := time.Now()
+	for range 3 {
+		if _, err := l.Extract(context.Background(), "https://youtu.be/x", ExtractOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 2*interval {
+		t.Errorf("3 extractions took %v, want at least %v apart", elapsed, interval)
+	}
+}
+
+func TestLimiterCooldown(t *testing.T) {
+	ext := &fakeExtractor{err: fmt.Errorf("HTTP Error 429: %w", ErrRateLimited)}
+	l := NewLimiter(ext, 2, 0, time.Hour, discardLog())
+
+	if _, err := l.Extract(context.Background(), "https://youtu.be/x", ExtractOptions{}); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("first Extract = %v, want rate limited", err)
+	}
+
+	// The cool-down outlasts the deadline, so the next call fails at once
+	// without reaching the extractor
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	if _, err := l.Extract(ctx, "https://youtu.be/y", ExtractOptions{}); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Extract during cool-down = %v, want rate limited", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Extract during cool-down waited %v", elapsed)
+	}
+	if calls := ext.calls.Load(); calls != 1 {
+		t.Errorf("%d calls reached the extractor, want 1", calls)
+	}
+}
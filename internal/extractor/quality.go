@@ -0,0 +1,38 @@
+package extractor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// qualities maps the --quality shorthands to yt-dlp format selectors
+var qualities = []struct {
+	name   string
+	format string
+}{
+	{"best", "best[protocol=https]/best"},
+	{"1080p", "best[height<=1080][protocol=https]/best"},
+	{"720p", "best[height<=720][protocol=https]/best"},
+	{"480p", "best[height<=480][protocol=https]/best"},
+	{"audio", AudioOnlyFormat},
+}
+
+// Qualities returns the names accepted by QualityFormat
+func Qualities() []string {
+	names := make([]string, len(qualities))
+	for i, q := range qualities {
+		names[i] = q.name
+	}
+	return names
+}
+
+// QualityFormat returns the yt-dlp format selector for a quality shorthand
+// such as "720p" or "audio"
+func QualityFormat(quality string) (string, error) {
+	for _, q := range qualities {
+		if strings.EqualFold(quality, q.name) {
+			return q.format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown quality %q (allowed: %s)", quality, strings.Join(Qualities(), ", "))
+}
@@ -0,0 +1,37 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQualityFormat(t *testing.T) {
+	tests := []struct {
+		quality string
+		want    string
+		wantErr bool
+	}{
+		{"best", "best[protocol=https]/best", false},
+		{"1080p", "best[height<=1080][protocol=https]/best", false},
+		{"720p", "best[height<=720][protocol=https]/best", false},
+		{"480p", "best[height<=480][protocol=https]/best", false},
+		{"audio", AudioOnlyFormat, false},
+		{"720P", "best[height<=720][protocol=https]/best", false},
+		{"Audio", AudioOnlyFormat, false},
+		{"4k", "", true},
+		{"720", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := QualityFormat(tt.quality)
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "allowed: best, 1080p, 720p, 480p, audio") {
+				t.Errorf("QualityFormat(%q) = %q, %v; want an error listing the qualities", tt.quality, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("QualityFormat(%q) = %q, %v; want %q", tt.quality, got, err, tt.want)
+		}
+	}
+}
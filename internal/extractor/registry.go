@@ -0,0 +1,93 @@
+package extractor
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// Matcher reports whether an extractor handles a URL
+type Matcher func(u *url.URL) bool
+
+// Registry picks the extractor for a URL from registered matchers, falling
+// back to a default. It implements Extractor itself, so callers do not
+// need to know which extractor handles a source.
+type Registry struct {
+	entries  []registryEntry
+	fallback Extractor
+}
+
+type registryEntry struct {
+	match     Matcher
+	extractor Extractor
+}
+
+// NewRegistry creates a registry that uses fallback for unmatched URLs
+func NewRegistry(fallback Extractor) *Registry {
+	return &Registry{fallback: fallback}
+}
+
+// NewDefaultRegistry creates the registry used by the proxy: direct media
+// URLs are passed through as-is and everything else goes to yt-dlp, which
+// covers YouTube, Twitch and the other sites it supports
+func NewDefaultRegistry(ytdlp Extractor) *Registry {
+	r := NewRegistry(ytdlp)
+	r.Register(MatchDirect, NewDirectExtractor())
+	return r
+}
+
+// Register adds an extractor for URLs accepted by match. Extractors are
+// tried in registration order.
+func (r *Registry) Register(match Matcher, ext Extractor) {
+	r.entries = append(r.entries, registryEntry{match: match, extractor: ext})
+}
+
+// For returns the extractor handling rawURL
+func (r *Registry) For(rawURL string) Extractor {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return r.fallback
+	}
+	for _, e := range r.entries {
+		if e.match(u) {
+			return e.extractor
+		}
+	}
+	return r.fallback
+}
+
+// Extract extracts the stream URL with the extractor handling youtubeURL
+func (r *Registry) Extract(ctx context.Context, youtubeURL string, opts ExtractOptions) (*StreamInfo, error) {
+	return r.For(youtubeURL).Extract(ctx, youtubeURL, opts)
+}
+
+// IsLiveStream checks the live status with the extractor handling youtubeURL
+func (r *Registry) IsLiveStream(ctx context.Context, youtubeURL string) (bool, error) {
+	return r.For(youtubeURL).IsLiveStream(ctx, youtubeURL)
+}
+
+// MatchHost matches URLs on any of hosts or their subdomains
+func MatchHost(hosts ...string) Matcher {
+	return func(u *url.URL) bool {
+		host := strings.ToLower(u.Hostname())
+		for _, h := range hosts {
+			h = strings.ToLower(h)
+			if host == h || strings.HasSuffix(host, "."+h) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchDirect matches URLs ffmpeg can read without extraction: RTSP/RTMP
+// sources and HLS playlists
+func MatchDirect(u *url.URL) bool {
+	switch strings.ToLower(u.Scheme) {
+	case "rtsp", "rtsps", "rtmp", "rtmps":
+		return true
+	case "http", "https":
+		return strings.HasSuffix(strings.ToLower(u.Path), ".m3u8")
+	}
+	return false
+}
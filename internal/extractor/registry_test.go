@@ -0,0 +1,110 @@
+package extractor
+
+import (
+	"context"
+	"net/url"
+	"testing"
+)
+
+func TestMatchHost(t *testing.T) {
+	match := MatchHost("twitch.tv", "YouTube.com")
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://twitch.tv/somechannel", true},
+		{"https://www.twitch.tv/somechannel", true},
+		{"https://m.youtube.com/watch?v=abc123", true},
+		{"https://WWW.YOUTUBE.COM/watch?v=abc123", true},
+		{"https://youtube.com:443/live/abc123", true},
+		{"https://nottwitch.tv/somechannel", false},
+		{"https://twitch.tv.example.com/somechannel", false},
+		{"https://example.com/twitch.tv", false},
+		{"twitch.tv/somechannel", false}, // no scheme, so no host
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := match(u); got != tt.want {
+			t.Errorf("MatchHost(%s) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestMatchDirect(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/live/index.m3u8", true},
+		{"http://example.com/live/INDEX.M3U8?token=abc", true},
+		{"rtsp://camera.lan:554/stream1", true},
+		{"rtmps://live.example.com/app/key", true},
+		{"https://youtu.be/abc123", false},
+		{"https://example.com/video.mp4", false},
+		{"file:///srv/video.m3u8", false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := MatchDirect(u); got != tt.want {
+			t.Errorf("MatchDirect(%s) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestRegistryFor(t *testing.T) {
+	fallback, twitch, direct := &fakeExtractor{}, &fakeExtractor{}, &fakeExtractor{}
+	r := NewRegistry(fallback)
+	r.Register(MatchHost("twitch.tv"), twitch)
+	r.Register(MatchDirect, direct)
+
+	tests := []struct {
+		url  string
+		want Extractor
+	}{
+		{"https://www.twitch.tv/somechannel", twitch},
+		// Registration order decides between matching extractors
+		{"https://usher.twitch.tv/api/channel/hls/somechannel.m3u8", twitch},
+		{"https://example.com/live/index.m3u8", direct},
+		{"https://www.youtube.com/watch?v=abc123", fallback},
+		{"://not a url", fallback},
+	}
+	for _, tt := range tests {
+		if got := r.For(tt.url); got != tt.want {
+			t.Errorf("For(%s) picked the wrong extractor", tt.url)
+		}
+	}
+}
+
+func TestDefaultRegistryPassesThroughHLS(t *testing.T) {
+	ytdlp := &fakeExtractor{}
+	r := NewDefaultRegistry(ytdlp)
+
+	const playlist = "https://example.com/live/index.m3u8?token=abc"
+	info, err := r.Extract(context.Background(), playlist, ExtractOptions{})
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if info.URL != playlist || info.AudioURL != "" || info.ID != "" {
+		t.Errorf("Extract = %+v, want the playlist URL as is", info)
+	}
+	live, err := r.IsLiveStream(context.Background(), playlist)
+	if err != nil || !live {
+		t.Errorf("IsLiveStream = %v, %v, want live", live, err)
+	}
+	if calls := ytdlp.calls.Load(); calls != 0 {
+		t.Errorf("yt-dlp ran %d times for a direct URL, want none", calls)
+	}
+
+	if _, err := r.Extract(context.Background(), "https://youtu.be/abc123", ExtractOptions{}); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if calls := ytdlp.calls.Load(); calls != 1 {
+		t.Errorf("yt-dlp ran %d times for a YouTube URL, want once", calls)
+	}
+}
@@ -0,0 +1,127 @@
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// ytdlpReleaseURL is where the binaries of the latest yt-dlp release are
+// downloaded from
+const ytdlpReleaseURL = "https://github.com/yt-dlp/yt-dlp/releases/latest/download/"
+
+// ManagedYtdlpPath returns where yt-dlp is installed in a data dir by
+// "update ytdlp --download". A yt-dlp there is updated by downloading the
+// latest release instead of running yt-dlp -U.
+func ManagedYtdlpPath(dataDir string) string {
+	return filepath.Join(dataDir, "bin", "yt-dlp")
+}
+
+// ytdlpAsset returns the name of the release binary for this platform. The
+// fallback is the zipapp, which runs anywhere python3 is installed.
+func ytdlpAsset() string {
+	switch runtime.GOOS + "/" + runtime.GOARCH {
+	case "linux/amd64":
+		return "yt-dlp_linux"
+	case "linux/arm64":
+		return "yt-dlp_linux_aarch64"
+	case "linux/arm":
+		return "yt-dlp_linux_armv7l"
+	case "darwin/amd64", "darwin/arm64":
+		return "yt-dlp_macos"
+	}
+	return "yt-dlp"
+}
+
+// Update updates yt-dlp to its latest release and returns the new version.
+// A yt-dlp installed at managedPath is replaced with a downloaded release;
+// any other runs yt-dlp -U, whose output is written to w.
+func (e *YtdlpExtractor) Update(ctx context.Context, managedPath string, w io.Writer) (string, error) {
+	e.mu.Lock()
+	e.version = ""
+	e.mu.Unlock()
+
+	if IsManagedYtdlp(e.BinaryPath, managedPath) {
+		if err := DownloadYtdlp(ctx, managedPath); err != nil {
+			return "", err
+		}
+	} else if err := e.selfUpdate(ctx, w); err != nil {
+		return "", err
+	}
+
+	return e.Version()
+}
+
+// IsManagedYtdlp reports whether binaryPath resolves to the yt-dlp
+// installed at managedPath
+func IsManagedYtdlp(binaryPath, managedPath string) bool {
+	resolved, err := exec.LookPath(binaryPath)
+	if err != nil {
+		return false
+	}
+	resolved, err = filepath.Abs(resolved)
+	if err != nil {
+		return false
+	}
+	managed, err := filepath.Abs(managedPath)
+	return err == nil && resolved == managed
+}
+
+// selfUpdate runs yt-dlp -U
+func (e *YtdlpExtractor) selfUpdate(ctx context.Context, w io.Writer) error {
+	cmd := exec.CommandContext(ctx, e.BinaryPath, "-U")
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("yt-dlp -U failed: %w (a yt-dlp installed with pip is updated with: pip install -U yt-dlp)", err)
+	}
+	return nil
+}
+
+// DownloadYtdlp downloads the latest yt-dlp release binary to dest. The
+// binary is replaced atomically, so a running extraction keeps using the
+// old one.
+func DownloadYtdlp(ctx context.Context, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ytdlpReleaseURL+ytdlpAsset(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download yt-dlp: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download yt-dlp: %s", resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".yt-dlp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to download yt-dlp: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("failed to install yt-dlp: %w", err)
+	}
+	return nil
+}
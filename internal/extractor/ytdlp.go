@@ -3,24 +3,56 @@ package extractor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 )
 
 // StreamInfo contains extracted stream information
 type StreamInfo struct {
-	URL        string
+	ID         string // Video ID the URL resolved to
+	URL        string // Direct URL of the video, or of the only stream
 	Format     string
 	Resolution string
 	IsLive     bool
 	Title      string
+
+	// AudioURL is the direct URL of the audio when the format merges
+	// separate video and audio streams, e.g. "bestvideo+bestaudio" (empty
+	// = URL carries both)
+	AudioURL string
+
+	// VideoCodec is the video codec of the selected format as yt-dlp
+	// reports it, e.g. "avc1.64001F" or "vp09.00.41.08" (empty = unknown,
+	// "none" = no video)
+	VideoCodec string
+
+	// HTTPHeaders are the headers yt-dlp recommends for requesting the
+	// URLs, such as the User-Agent it extracted them with (nil = none).
+	// They may carry cookies, so they are never logged.
+	HTTPHeaders map[string]string
+
+	// audioFirst is set when a merged format lists its audio stream
+	// first, e.g. "bestaudio+bestvideo"
+	audioFirst bool
+}
+
+// AudioOnlyFormat is the yt-dlp format used for audio-only streams. It falls
+// back to a muxed format for live streams that offer no separate audio.
+const AudioOnlyFormat = "bestaudio/best"
+
+// ExtractOptions adjusts how a stream URL is extracted
+type ExtractOptions struct {
+	AudioOnly bool   // select an audio format instead of the configured one
+	Format    string // yt-dlp format selector overriding both (empty = none)
 }
 
 // Extractor defines the interface for URL extraction
 type Extractor interface {
-	Extract(ctx context.Context, youtubeURL string) (*StreamInfo, error)
+	Extract(ctx context.Context, youtubeURL string, opts ExtractOptions) (*StreamInfo, error)
 	IsLiveStream(ctx context.Context, youtubeURL string) (bool, error)
 }
 
@@ -29,6 +61,14 @@ type YtdlpExtractor struct {
 	BinaryPath string
 	Timeout    time.Duration
 	Format     string
+
+	// HTTP request headers: User-Agent (empty = yt-dlp's own) and others
+	// as "Name: value"
+	UserAgent string
+	Headers   []string
+
+	mu      sync.Mutex
+	version string // recorded by Version, cleared by Update
 }
 
 // NewYtdlpExtractor creates a new yt-dlp extractor
@@ -50,55 +90,77 @@ func NewYtdlpExtractor(binaryPath string, timeout time.Duration, format string)
 }
 
 // Extract extracts the direct stream URL from a YouTube URL
-func (e *YtdlpExtractor) Extract(ctx context.Context, youtubeURL string) (*StreamInfo, error) {
+func (e *YtdlpExtractor) Extract(ctx context.Context, youtubeURL string, opts ExtractOptions) (*StreamInfo, error) {
 	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
 	defer cancel()
 
+	format := e.Format
+	if opts.AudioOnly {
+		format = AudioOnlyFormat
+	}
+	if opts.Format != "" {
+		format = opts.Format
+	}
+
 	// Get stream URL
-	urlCmd := exec.CommandContext(ctx, e.BinaryPath,
-		"-f", e.Format,
+	urlOutput, err := e.run(ctx,
+		"-f", format,
 		"-g",
 		"--no-warnings",
 		youtubeURL,
 	)
-
-	urlOutput, err := urlCmd.Output()
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, e.contextError(ctx)
+		}
 		return nil, fmt.Errorf("failed to extract URL: %w", err)
 	}
 
-	streamURL := strings.TrimSpace(string(urlOutput))
-	if streamURL == "" {
+	// Merged formats print one URL per stream, in the order requested
+	urls := strings.Fields(string(urlOutput))
+	switch len(urls) {
+	case 0:
 		return nil, fmt.Errorf("empty stream URL returned")
+	case 1, 2:
+	default:
+		return nil, fmt.Errorf("format %q selects %d streams; at most a video and an audio stream are supported", format, len(urls))
 	}
 
-	// Get video info (title, live status, etc.)
-	info, err := e.getVideoInfo(ctx, youtubeURL)
+	// Get video info (title, live status, etc.) of the same format
+	info, err := e.getVideoInfo(ctx, youtubeURL, format)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, e.contextError(ctx)
+		}
 		// Return basic info even if metadata fetch fails
-		return &StreamInfo{
-			URL: streamURL,
-		}, nil
+		info = &StreamInfo{}
 	}
 
-	info.URL = streamURL
+	info.URL = urls[0]
+	if len(urls) == 2 {
+		info.AudioURL = urls[1]
+		if info.audioFirst {
+			info.URL, info.AudioURL = info.AudioURL, info.URL
+		}
+	}
 	return info, nil
 }
 
-// getVideoInfo retrieves video metadata
-func (e *YtdlpExtractor) getVideoInfo(ctx context.Context, youtubeURL string) (*StreamInfo, error) {
-	cmd := exec.CommandContext(ctx, e.BinaryPath,
+// getVideoInfo retrieves video metadata, with the media details of the
+// format selected by format
+func (e *YtdlpExtractor) getVideoInfo(ctx context.Context, youtubeURL, format string) (*StreamInfo, error) {
+	output, err := e.run(ctx,
+		"-f", format,
 		"-j",
 		"--no-warnings",
 		youtubeURL,
 	)
-
-	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get video info: %w", err)
 	}
 
 	var data struct {
+		ID          string `json:"id"`
 		Title       string `json:"title"`
 		IsLive      bool   `json:"is_live"`
 		Format      string `json:"format"`
@@ -106,6 +168,14 @@ func (e *YtdlpExtractor) getVideoInfo(ctx context.Context, youtubeURL string) (*
 		FormatNote  string `json:"format_note"`
 		Height      int    `json:"height"`
 		Width       int    `json:"width"`
+		VCodec      string `json:"vcodec"`
+
+		HTTPHeaders map[string]string `json:"http_headers"`
+
+		// The streams of a merged format, in the order requested
+		RequestedFormats []struct {
+			VCodec string `json:"vcodec"`
+		} `json:"requested_formats"`
 	}
 
 	if err := json.Unmarshal(output, &data); err != nil {
@@ -118,10 +188,16 @@ func (e *YtdlpExtractor) getVideoInfo(ctx context.Context, youtubeURL string) (*
 	}
 
 	return &StreamInfo{
+		ID:         data.ID,
 		Title:      data.Title,
 		IsLive:     data.IsLive,
 		Format:     data.Format,
 		Resolution: resolution,
+		VideoCodec: data.VCodec,
+
+		HTTPHeaders: data.HTTPHeaders,
+		audioFirst: len(data.RequestedFormats) == 2 &&
+			data.RequestedFormats[0].VCodec == "none" && data.RequestedFormats[1].VCodec != "none",
 	}, nil
 }
 
@@ -130,14 +206,15 @@ func (e *YtdlpExtractor) IsLiveStream(ctx context.Context, youtubeURL string) (b
 	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, e.BinaryPath,
+	output, err := e.run(ctx,
 		"-j",
 		"--no-warnings",
 		youtubeURL,
 	)
-
-	output, err := cmd.Output()
 	if err != nil {
+		if ctx.Err() != nil {
+			return false, e.contextError(ctx)
+		}
 		return false, fmt.Errorf("failed to check live status: %w", err)
 	}
 
@@ -152,11 +229,69 @@ func (e *YtdlpExtractor) IsLiveStream(ctx context.Context, youtubeURL string) (b
 	return data.IsLive, nil
 }
 
-// CheckBinary verifies that yt-dlp binary exists and is executable
-func (e *YtdlpExtractor) CheckBinary() error {
+// run runs yt-dlp with args and returns its stdout. yt-dlp is killed as
+// soon as ctx is done, without waiting for child processes it may have
+// left holding its output open. A failed run's error includes the message
+// yt-dlp printed.
+func (e *YtdlpExtractor) run(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, e.BinaryPath, append(e.headerArgs(), args...)...)
+	cmd.WaitDelay = time.Second
+	output, err := cmd.Output()
+	return output, withStderr(err)
+}
+
+// headerArgs returns the options setting the configured request headers
+func (e *YtdlpExtractor) headerArgs() []string {
+	var args []string
+	if e.UserAgent != "" {
+		args = append(args, "--user-agent", e.UserAgent)
+	}
+	for _, h := range e.Headers {
+		args = append(args, "--add-header", h)
+	}
+	return args
+}
+
+// contextError describes why ctx ended: the caller cancelling is reported
+// as is, running out of the extraction timeout is spelled out. Both wrap
+// the context error.
+func (e *YtdlpExtractor) contextError(ctx context.Context) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("yt-dlp timed out after %v: %w", e.Timeout, err)
+	}
+	return err
+}
+
+// CheckBinary verifies that yt-dlp binary exists and is executable,
+// returning the reported version
+func (e *YtdlpExtractor) CheckBinary() (string, error) {
 	cmd := exec.Command(e.BinaryPath, "--version")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("yt-dlp not found or not executable: %w", err)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("yt-dlp not found or not executable: %w", withStderr(err))
+	}
+	return ParseYtdlpVersion(string(output)), nil
+}
+
+// Version returns the yt-dlp version, running yt-dlp only the first time.
+// Later calls report the version recorded then, until yt-dlp is updated.
+func (e *YtdlpExtractor) Version() (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.version != "" {
+		return e.version, nil
+	}
+	version, err := e.CheckBinary()
+	if err != nil {
+		return "", err
 	}
-	return nil
+	e.version = version
+	return version, nil
+}
+
+// ParseYtdlpVersion extracts the version from `yt-dlp --version` output
+func ParseYtdlpVersion(output string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(line)
 }
@@ -0,0 +1,222 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeYtdlp returns an extractor running a shell script with body as
+// yt-dlp
+func fakeYtdlp(t *testing.T, body string) *YtdlpExtractor {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "yt-dlp")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return NewYtdlpExtractor(path, 0, "")
+}
+
+// extractWithin runs Extract, failing if it does not return within limit
+func extractWithin(t *testing.T, ctx context.Context, e *YtdlpExtractor, limit time.Duration) error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		_, err := e.Extract(ctx, "https://youtu.be/abc123", ExtractOptions{})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(limit):
+		t.Fatalf("Extract did not return within %v", limit)
+		return nil
+	}
+}
+
+func TestExtractCancelKillsYtdlp(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	// A hung yt-dlp whose child keeps its output open
+	e := fakeYtdlp(t, "echo $$ > "+pidFile+".tmp && mv "+pidFile+".tmp "+pidFile+"\nsleep 30\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for {
+			if _, err := os.Stat(pidFile); err == nil {
+				cancel()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	err := extractWithin(t, ctx, e, 5*time.Second)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Extract after cancel = %v, want context.Canceled", err)
+	}
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err := syscall.Kill(pid, 0); !errors.Is(err, syscall.ESRCH) {
+		t.Errorf("yt-dlp %d still runs after the cancel", pid)
+	}
+}
+
+func TestExtractTimeout(t *testing.T) {
+	e := fakeYtdlp(t, "sleep 30\n")
+	e.Timeout = 100 * time.Millisecond
+
+	err := extractWithin(t, context.Background(), e, 5*time.Second)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Extract past the timeout = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestParseYtdlpVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"2024.12.13\n", "2024.12.13"},
+		{"2025.01.15.232405\n", "2025.01.15.232405"},
+		{"  2024.12.13  \r\n", "2024.12.13"},
+		// Warnings some installs print after the version
+		{"2024.08.06\nDeprecated Feature: Support for Python version 3.8 has been deprecated\n", "2024.08.06"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ParseYtdlpVersion(tt.output); got != tt.want {
+			t.Errorf("ParseYtdlpVersion(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestCheckBinaryReportsVersion(t *testing.T) {
+	e := fakeYtdlp(t, "echo 2024.12.13\n")
+	if got, err := e.CheckBinary(); err != nil || got != "2024.12.13" {
+		t.Errorf("CheckBinary = %q, %v, want 2024.12.13", got, err)
+	}
+
+	e = NewYtdlpExtractor(filepath.Join(t.TempDir(), "missing"), 0, "")
+	if got, err := e.CheckBinary(); err == nil {
+		t.Errorf("CheckBinary of a missing binary = %q, want an error", got)
+	}
+}
+
+func TestExtractSendsHeaders(t *testing.T) {
+	argsFile := filepath.Join(t.TempDir(), "args")
+	// Record each run's arguments, one per line, with a blank line after
+	e := fakeYtdlp(t, "printf '%s\\n' \"$@\" '' >> "+argsFile+"\necho https://example.com/video.m3u8\n")
+	e.UserAgent = "Mozilla/5.0 (X11; Linux x86_64)"
+	e.Headers = []string{"Referer: https://www.youtube.com/", "Accept-Language: en-US"}
+
+	if _, err := e.Extract(context.Background(), "https://youtu.be/abc123", ExtractOptions{}); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := strings.Split(strings.TrimSuffix(string(data), "\n\n"), "\n\n")
+	if len(runs) != 2 {
+		t.Fatalf("yt-dlp ran %d times, want 2 (URL and metadata)", len(runs))
+	}
+	want := []string{
+		"--user-agent", "Mozilla/5.0 (X11; Linux x86_64)",
+		"--add-header", "Referer: https://www.youtube.com/",
+		"--add-header", "Accept-Language: en-US",
+	}
+	for i, run := range runs {
+		args := strings.Split(run, "\n")
+		if len(args) < len(want) || !slices.Equal(args[:len(want)], want) {
+			t.Errorf("run %d args = %q, want them to start with %q", i+1, args, want)
+		}
+	}
+}
+
+func TestExtractFormat(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts ExtractOptions
+		want string
+	}{
+		{"configured", ExtractOptions{}, "best[height<=720]"},
+		{"audio only", ExtractOptions{AudioOnly: true}, AudioOnlyFormat},
+		{"explicit", ExtractOptions{AudioOnly: true, Format: "bestaudio[ext=m4a]"}, "bestaudio[ext=m4a]"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			argsFile := filepath.Join(t.TempDir(), "args")
+			e := fakeYtdlp(t, "printf '%s\\n' \"$@\" '' >> "+argsFile+"\necho https://example.com/audio.m3u8\n")
+			e.Format = "best[height<=720]"
+
+			if _, err := e.Extract(context.Background(), "https://youtu.be/abc123", tt.opts); err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			data, err := os.ReadFile(argsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Both the URL and the metadata run ask for the format
+			runs := strings.Split(strings.TrimSuffix(string(data), "\n\n"), "\n\n")
+			for i, run := range runs {
+				args := strings.Split(run, "\n")
+				if f := slices.Index(args, "-f"); f < 0 || f+1 == len(args) || args[f+1] != tt.want {
+					t.Errorf("run %d args = %q, want -f %s", i+1, args, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractErrorIncludesStderr(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		want   string
+	}{
+		{
+			name:   "error line",
+			stderr: "[youtube] Extracting URL: https://youtu.be/abc123\nWARNING: [youtube] Falling back to generic n function search\nERROR: [youtube] abc123: Requested format is not available\n",
+			want:   "failed to extract URL: [youtube] abc123: Requested format is not available",
+		},
+		{
+			name:   "last error line",
+			stderr: "ERROR: first attempt failed\nERROR: [youtube] abc123: Video unavailable\n",
+			want:   "failed to extract URL: [youtube] abc123: Video unavailable",
+		},
+		{
+			name:   "traceback",
+			stderr: "Traceback (most recent call last):\n  File \"yt_dlp/__main__.py\", line 17, in <module>\n  File \"yt_dlp/__init__.py\", line 1093, in main\nImportError: cannot import name 'Popen'\n",
+			want:   "failed to extract URL: File \"yt_dlp/__main__.py\", line 17, in <module> | File \"yt_dlp/__init__.py\", line 1093, in main | ImportError: cannot import name 'Popen'",
+		},
+		{
+			name: "no output",
+			want: "failed to extract URL: exit status 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := fakeYtdlp(t, "printf '%s' '"+strings.ReplaceAll(tt.stderr, "'", `'\''`)+"' >&2\nexit 2\n")
+			_, err := e.Extract(context.Background(), "https://youtu.be/abc123", ExtractOptions{})
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Extract = %v, want %q", err, tt.want)
+			}
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) || exitErr.ExitCode() != 2 {
+				t.Errorf("Extract = %v, want it to wrap the exit status", err)
+			}
+		})
+	}
+}
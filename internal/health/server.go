@@ -0,0 +1,95 @@
+// Package health serves liveness and readiness probes for container
+// orchestrators, separately from the control API.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Component is a dependency that must be healthy for the proxy to be ready
+type Component struct {
+	Name  string
+	Check func() error
+}
+
+// Server answers GET /healthz while the process is up and GET /readyz
+// while every component passes its check
+type Server struct {
+	components []Component
+	httpServer *http.Server
+}
+
+// status is the body of both endpoints. Unhealthy maps the names of failing
+// components to their errors.
+type status struct {
+	Status    string            `json:"status"`
+	Unhealthy map[string]string `json:"unhealthy,omitempty"`
+}
+
+// NewServer creates a health server listening on addr
+func NewServer(addr string, components ...Component) *Server {
+	s := &Server{components: components}
+
+	s.httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// Handler returns the HTTP handler serving the probes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+	return mux
+}
+
+// Start starts serving in the background
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.httpServer.Addr, err)
+	}
+
+	go s.httpServer.Serve(ln)
+	return nil
+}
+
+// Stop gracefully shuts the server down
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, http.StatusOK, status{Status: "ok"})
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	unhealthy := make(map[string]string)
+	for _, c := range s.components {
+		if err := c.Check(); err != nil {
+			unhealthy[c.Name] = err.Error()
+		}
+	}
+
+	if len(unhealthy) > 0 {
+		writeStatus(w, http.StatusServiceUnavailable, status{Status: "unavailable", Unhealthy: unhealthy})
+		return
+	}
+	writeStatus(w, http.StatusOK, status{Status: "ok"})
+}
+
+func writeStatus(w http.ResponseWriter, code int, body status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,117 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// check returns a Component.Check func failing with err (nil = passing)
+func check(err error) func() error {
+	return func() error { return err }
+}
+
+// get requests path from a server of components, returning the status
+// code and decoded body
+func get(t *testing.T, path string, components ...Component) (int, status) {
+	t.Helper()
+	ts := httptest.NewServer(NewServer("", components...).Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var body status
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestProbes(t *testing.T) {
+	healthy := []Component{
+		{Name: "mediamtx", Check: check(nil)},
+		{Name: "drain", Check: check(nil)},
+	}
+	unhealthy := []Component{
+		{Name: "mediamtx", Check: check(errors.New("API unreachable"))},
+		{Name: "drain", Check: check(nil)},
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		components []Component
+		wantCode   int
+		want       status
+	}{
+		{"healthz healthy", "/healthz", healthy, http.StatusOK, status{Status: "ok"}},
+		{"healthz unhealthy", "/healthz", unhealthy, http.StatusOK, status{Status: "ok"}},
+		{"readyz healthy", "/readyz", healthy, http.StatusOK, status{Status: "ok"}},
+		{"readyz without components", "/readyz", nil, http.StatusOK, status{Status: "ok"}},
+		{
+			"readyz unhealthy", "/readyz", unhealthy, http.StatusServiceUnavailable,
+			status{Status: "unavailable", Unhealthy: map[string]string{"mediamtx": "API unreachable"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := get(t, tt.path, tt.components...)
+			if code != tt.wantCode {
+				t.Errorf("status code %d, want %d", code, tt.wantCode)
+			}
+			if !reflect.DeepEqual(body, tt.want) {
+				t.Errorf("body %+v, want %+v", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadyzChecksEachRequest(t *testing.T) {
+	var err error
+	ts := httptest.NewServer(NewServer("", Component{Name: "drain", Check: func() error { return err }}).Handler())
+	defer ts.Close()
+
+	for _, tt := range []struct {
+		err      error
+		wantCode int
+	}{
+		{nil, http.StatusOK},
+		{errors.New("draining"), http.StatusServiceUnavailable},
+		{nil, http.StatusOK},
+	} {
+		err = tt.err
+		resp, getErr := http.Get(ts.URL + "/readyz")
+		if getErr != nil {
+			t.Fatal(getErr)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.wantCode {
+			t.Errorf("with check error %v: status code %d, want %d", tt.err, resp.StatusCode, tt.wantCode)
+		}
+	}
+}
+
+func TestProbesRejectOtherMethods(t *testing.T) {
+	ts := httptest.NewServer(NewServer("").Handler())
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/readyz", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST /readyz: status code %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
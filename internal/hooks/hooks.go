@@ -0,0 +1,134 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/logger"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/process"
+)
+
+// Event is a stream lifecycle event that can trigger a hook
+type Event string
+
+const (
+	EventStart   Event = "start"   // stream started by the user or config
+	EventError   Event = "error"   // stream gave up after exhausting reconnect attempts
+	EventRecover Event = "recover" // stream reconnected after a failure
+	EventStop    Event = "stop"    // stream stopped
+)
+
+// DefaultTimeout bounds a hook run when no timeout is configured
+const DefaultTimeout = 10 * time.Second
+
+// Payload describes the stream a hook runs for
+type Payload struct {
+	StreamName  string
+	StreamState string
+	RTSPURL     string
+	Reason      string
+}
+
+// Runner executes the configured hook scripts
+type Runner struct {
+	config *config.HooksConfig
+	wg     sync.WaitGroup
+}
+
+// NewRunner creates a hook runner
+func NewRunner(cfg *config.HooksConfig) *Runner {
+	return &Runner{config: cfg}
+}
+
+// command returns the executable configured for an event
+func (r *Runner) command(event Event) string {
+	switch event {
+	case EventStart:
+		return r.config.OnStart
+	case EventError:
+		return r.config.OnError
+	case EventRecover:
+		return r.config.OnRecover
+	case EventStop:
+		return r.config.OnStop
+	}
+	return ""
+}
+
+// Fire runs the hook for an event in the background. Failures are written to
+// log and never affect the stream operation that triggered the hook.
+func (r *Runner) Fire(event Event, payload Payload, log *logger.StreamLogger) {
+	path := r.command(event)
+	if path == "" {
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if err := r.run(path, event, payload); err != nil {
+			log.Warn("Hook on_%s failed: %v", event, err)
+		}
+	}()
+}
+
+// Wait blocks until all hooks started by Fire have finished. Short-lived CLI
+// invocations call it before exiting so their hooks are not cut off.
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+// outputWaitDelay bounds how long a hook's output is read after it exited
+// or was killed, as processes it started in the background may hold the
+// output open
+const outputWaitDelay = time.Second
+
+// run executes a hook and waits for it. The hook runs in its own process
+// group, which is killed as a whole after the timeout.
+func (r *Runner) run(path string, event Event, payload Payload) error {
+	timeout := r.config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return process.Signal(cmd.Process.Pid, true, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = outputWaitDelay
+	cmd.Env = append(os.Environ(),
+		"STREAM_EVENT="+string(event),
+		"STREAM_NAME="+payload.StreamName,
+		"STREAM_STATE="+payload.StreamState,
+		"RTSP_URL="+payload.RTSPURL,
+		"REASON="+payload.Reason,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s timed out after %v", path, timeout)
+	}
+	// The hook succeeded but left a background process holding its output
+	if errors.Is(err, exec.ErrWaitDelay) {
+		return nil
+	}
+	if err != nil {
+		if out := strings.TrimSpace(string(output)); out != "" {
+			return fmt.Errorf("%s: %w: %s", path, err, out)
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
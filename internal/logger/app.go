@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+)
+
+// NewAppLogger creates the application logger described by the logging
+// config section. It writes text or JSON records to logging.file, or to
+// stderr when no file is set (stdout is reserved for command output).
+// URLs are redacted unless logging.redact_urls is off.
+// The returned function closes the log file.
+func NewAppLogger(cfg *config.LoggingConfig) (*slog.Logger, func() error, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, nil, fmt.Errorf("invalid log level %q: %w", cfg.Level, err)
+	}
+
+	var out io.Writer = os.Stderr
+	closeFn := func() error { return nil }
+	if cfg.File != "" {
+		if err := os.MkdirAll(filepath.Dir(cfg.File), 0755); err != nil {
+			return nil, nil, fmt.Errorf("failed to create log directory: %w", err)
+		}
+		f, err := os.OpenFile(cfg.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		out = f
+		closeFn = f.Close
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(out, opts)
+	} else {
+		handler = slog.NewTextHandler(out, opts)
+	}
+	if cfg.RedactURLs {
+		handler = redactHandler{handler}
+	}
+
+	return slog.New(handler), closeFn, nil
+}
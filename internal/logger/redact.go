@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// redactedMark replaces the signed part of a URL
+const redactedMark = "…redacted…"
+
+// urlPattern matches HTTP(S) URLs in free text, as yt-dlp and ffmpeg print
+// them in errors
+var urlPattern = regexp.MustCompile(`(?i)https?://[^\s"'<>]+`)
+
+// RedactURLs replaces the query strings of the HTTP URLs in s, where
+// extracted media URLs carry their signatures and the IP they are bound
+// to, keeping scheme, host and path for debugging. Media URLs signing
+// their path instead, such as YouTube's HLS manifests, keep the path up to
+// the first signed segment. YouTube page URLs are left alone, as their
+// query is just the video ID.
+func RedactURLs(s string) string {
+	return urlPattern.ReplaceAllStringFunc(s, func(match string) string {
+		// Punctuation ending a sentence or an ffmpeg "url: error" line
+		trimmed := strings.TrimRight(match, ".,:;)]")
+		return redactURL(trimmed) + match[len(trimmed):]
+	})
+}
+
+// redactURL redacts a single URL, leaving it as is if it does not parse
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || isYouTubePage(u.Hostname()) {
+		return rawURL
+	}
+
+	path := u.EscapedPath()
+	if i := strings.Index(path, "/expire/"); i >= 0 {
+		path = path[:i] + "/" + redactedMark
+	}
+	redacted := u.Scheme + "://" + u.Host + path
+	if u.RawQuery != "" {
+		redacted += "?" + redactedMark
+	}
+	return redacted
+}
+
+// isYouTubePage reports whether host serves YouTube pages rather than media
+func isYouTubePage(host string) bool {
+	host = strings.ToLower(host)
+	return host == "youtu.be" || host == "youtube.com" || strings.HasSuffix(host, ".youtube.com")
+}
+
+// redactHandler redacts the URLs in the messages and attributes of records
+// before passing them on
+type redactHandler struct {
+	slog.Handler
+}
+
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, RedactURLs(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, redacted)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+	return redactHandler{h.Handler.WithAttrs(redacted)}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{h.Handler.WithGroup(name)}
+}
+
+// redactAttr redacts the URLs in a string, error or group attribute
+func redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(RedactURLs(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, g := range group {
+			redacted[i] = redactAttr(g)
+		}
+		a.Value = slog.GroupValue(redacted...)
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(RedactURLs(err.Error()))
+		}
+	}
+	return a
+}
@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// archivePath returns the path of the nth archive of a log file, e.g.
+// "lofi.log.1" (or "lofi.log.1.gz" when compressed)
+func archivePath(logPath string, n int, compressed bool) string {
+	path := fmt.Sprintf("%s.%d", logPath, n)
+	if compressed {
+		path += ".gz"
+	}
+	return path
+}
+
+// rotateSize moves the log file to the first archive, shifting the older
+// archives up and dropping the oldest, so that a new file is started
+// (must be called with lock held)
+func (l *StreamLogger) rotateSize() {
+	l.closeFile()
+	l.size = 0
+
+	if l.archives <= 0 {
+		os.Remove(l.filePath)
+		return
+	}
+
+	// Archives are left as they are when compression is toggled, so
+	// shift both kinds
+	for _, compressed := range []bool{false, true} {
+		os.Remove(archivePath(l.filePath, l.archives, compressed))
+		for n := l.archives - 1; n >= 1; n-- {
+			os.Rename(archivePath(l.filePath, n, compressed), archivePath(l.filePath, n+1, compressed))
+		}
+	}
+
+	first := archivePath(l.filePath, 1, false)
+	if err := os.Rename(l.filePath, first); err != nil {
+		return
+	}
+	if l.compress {
+		if err := gzipFile(first, archivePath(l.filePath, 1, true)); err == nil {
+			os.Remove(first)
+		}
+	}
+}
+
+// gzipFile writes a compressed copy of src to dst
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// readArchive reads the last n lines of the most recent archive of a log
+// file, compressed or not. A missing archive has no lines.
+func readArchive(logPath string, n int) ([]string, error) {
+	compressed := false
+	f, err := os.Open(archivePath(logPath, 1, false))
+	if os.IsNotExist(err) {
+		compressed = true
+		f, err = os.Open(archivePath(logPath, 1, true))
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	if !compressed {
+		return readLastLines(f, n)
+	}
+
+	// A compressed archive can only be read from the start
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	lines, err := readLines(zr)
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, err
+}
+
+// readLines reads all lines of r
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
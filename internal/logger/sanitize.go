@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxLineLength is the default maximum length (in bytes) of a log message
+const DefaultMaxLineLength = 2048
+
+// ansiPattern matches ANSI CSI/OSC escape sequences and other ESC-prefixed codes
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// sanitizeMessage makes a message safe to write as a single log line.
+// ANSI escape sequences are stripped, carriage-return progress updates are
+// collapsed to their latest state, remaining control characters are escaped,
+// and the result is truncated to maxLen bytes. It reports whether the message
+// was a carriage-return progress update.
+func sanitizeMessage(msg string, maxLen int) (string, bool) {
+	msg = strings.ToValidUTF8(msg, "�")
+	msg = ansiPattern.ReplaceAllString(msg, "")
+
+	// Progress bars redraw the same line with \r; keep only the latest state
+	isProgress := false
+	if strings.Contains(msg, "\r") {
+		var lines []string
+		for _, line := range strings.Split(msg, "\n") {
+			segments := strings.Split(line, "\r")
+			last := ""
+			for i := len(segments) - 1; i >= 0; i-- {
+				if strings.TrimSpace(segments[i]) != "" {
+					last = segments[i]
+					break
+				}
+			}
+			if len(segments) > 1 {
+				isProgress = true
+			}
+			lines = append(lines, last)
+		}
+		msg = strings.Join(lines, "\n")
+	}
+
+	msg = strings.TrimRight(msg, "\n")
+
+	var b strings.Builder
+	for _, r := range msg {
+		switch {
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteByte(' ')
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		case r >= 0x80 && r < 0xa0:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return truncate(b.String(), maxLen), isProgress
+}
+
+// truncate shortens s to at most maxLen bytes (on a rune boundary),
+// noting how much was dropped
+func truncate(s string, maxLen int) string {
+	if maxLen <= 0 || len(s) <= maxLen {
+		return s
+	}
+
+	cut := maxLen
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s … [truncated %d bytes]", s[:cut], len(s)-cut)
+}
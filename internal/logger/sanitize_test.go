@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeMessage(t *testing.T) {
+	tests := []struct {
+		name, msg    string
+		want         string
+		wantProgress bool
+	}{
+		{"plain", "Opening 'seg-1.ts' for reading", "Opening 'seg-1.ts' for reading", false},
+		{"ansi colour", "\x1b[1;31m[https @ 0x55] HTTP error 403 Forbidden\x1b[0m", "[https @ 0x55] HTTP error 403 Forbidden", false},
+		{"osc title", "\x1b]0;yt-dlp\x07[download] 5.0%", "[download] 5.0%", false},
+		{"progress", "frame=1 fps=30\rframe=2 fps=30\rframe=3 fps=30\r", "frame=3 fps=30", true},
+		{"trailing newline", "done\n", "done", false},
+		{"embedded newline", "first\nsecond", `first\nsecond`, false},
+		{"tab", "a\tb", "a b", false},
+		{"control", "bell\x07 del\x7f", `bell\x07 del\x7f`, false},
+		{"invalid utf-8", "bad \xff byte", "bad � byte", false},
+	}
+	for _, tt := range tests {
+		got, progress := sanitizeMessage(tt.msg, DefaultMaxLineLength)
+		if got != tt.want || progress != tt.wantProgress {
+			t.Errorf("%s: sanitizeMessage(%q) = %q, %v, want %q, %v", tt.name, tt.msg, got, progress, tt.want, tt.wantProgress)
+		}
+	}
+}
+
+func TestSanitizeMessageTruncates(t *testing.T) {
+	got, _ := sanitizeMessage(strings.Repeat("x", 100), 10)
+	if want := "xxxxxxxxxx … [truncated 90 bytes]"; got != want {
+		t.Errorf("sanitizeMessage = %q, want %q", got, want)
+	}
+
+	// The cut never splits a rune
+	got, _ = sanitizeMessage(strings.Repeat("é", 10), 5)
+	if want := "éé … [truncated 16 bytes]"; got != want {
+		t.Errorf("sanitizeMessage = %q, want %q", got, want)
+	}
+}
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,16 +15,71 @@ import (
 type LogLevel string
 
 const (
+	LevelDebug LogLevel = "DEBUG"
 	LevelInfo  LogLevel = "INFO"
 	LevelWarn  LogLevel = "WARN"
 	LevelError LogLevel = "ERROR"
 )
 
-// StreamLogger handles per-stream logging with line rotation
+// levelRanks orders the levels by severity
+var levelRanks = map[LogLevel]int{LevelDebug: 0, LevelInfo: 1, LevelWarn: 2, LevelError: 3}
+
+// ParseLevel parses a level name as used in the config, such as "debug"
+// or "warn", case-insensitively
+func ParseLevel(s string) (LogLevel, error) {
+	level := LogLevel(strings.ToUpper(strings.TrimSpace(s)))
+	if level == "WARNING" {
+		level = LevelWarn
+	}
+	if _, ok := levelRanks[level]; !ok {
+		return "", fmt.Errorf("invalid log level %q (want debug, info, warn or error)", s)
+	}
+	return level, nil
+}
+
+// enabled reports whether messages of level pass the threshold
+func (level LogLevel) enabled(threshold LogLevel) bool {
+	return levelRanks[level] >= levelRanks[threshold]
+}
+
+const (
+	// flushInterval is how long buffered info lines may wait before being
+	// written; warnings and errors are written immediately
+	flushInterval = time.Second
+	// statInterval is how often the open file is checked for having been
+	// removed or replaced underneath the logger
+	statInterval = time.Second
+	// idleTimeout closes the file handle of a logger that stopped writing
+	idleTimeout = time.Minute
+)
+
+// StreamLogger handles per-stream logging with rotation. By default the
+// file keeps its last lines; with size rotation it is moved to numbered
+// archives once it reaches a size instead. The log file is kept open
+// between messages and closed again after idleTimeout.
 type StreamLogger struct {
 	mu       sync.Mutex
 	filePath string
 	maxLines int
+
+	// Size rotation (maxSize 0 = rotate by lines)
+	maxSize  int64
+	archives int
+	compress bool
+
+	level         LogLevel // messages below it are dropped
+	maxLineLength int
+	redactURLs    bool
+	lastProgress  time.Time
+
+	file       *os.File
+	writer     *bufio.Writer
+	lines      int   // lines in the file, counted when it was opened
+	size       int64 // bytes in the file, with size rotation
+	lastStat   time.Time
+	lastWrite  time.Time
+	flushTimer *time.Timer
+	idleTimer  *time.Timer
 }
 
 // NewStreamLogger creates a logger for a specific stream
@@ -32,9 +88,35 @@ func NewStreamLogger(dataDir, streamName string, maxLines int) *StreamLogger {
 		maxLines = 100
 	}
 	return &StreamLogger{
-		filePath: filepath.Join(dataDir, streamName+".log"),
-		maxLines: maxLines,
+		filePath:      filepath.Join(dataDir, streamName+".log"),
+		maxLines:      maxLines,
+		level:         LevelInfo,
+		maxLineLength: DefaultMaxLineLength,
+		redactURLs:    true,
+	}
+}
+
+// SetLevel sets the level below which messages are dropped
+func (l *StreamLogger) SetLevel(level LogLevel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+}
+
+// Level returns the level below which messages are dropped
+func (l *StreamLogger) Level() LogLevel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.level
+}
+
+// Redact returns s with its URLs redacted as by RedactURLs, unless the
+// logger keeps URLs
+func (l *StreamLogger) Redact(s string) string {
+	if !l.redactURLs {
+		return s
 	}
+	return RedactURLs(s)
 }
 
 // Log writes a message with the specified level
@@ -42,20 +124,182 @@ func (l *StreamLogger) Log(level LogLevel, format string, args ...interface{}) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	message := fmt.Sprintf(format, args...)
+	if !level.enabled(l.level) {
+		return
+	}
+	message, isProgress := sanitizeMessage(l.Redact(fmt.Sprintf(format, args...)), l.maxLineLength)
+
+	// Write progress updates at most once per second
+	if isProgress {
+		if time.Since(l.lastProgress) < time.Second {
+			return
+		}
+		l.lastProgress = time.Now()
+	}
+
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 	line := fmt.Sprintf("[%s] [%s] %s\n", timestamp, level, message)
 
-	// Append to file
+	if err := l.open(); err != nil {
+		return
+	}
+	l.writer.WriteString(line)
+	l.lines++
+	l.size += int64(len(line))
+	l.lastWrite = time.Now()
+
+	if level == LevelInfo || level == LevelDebug {
+		l.scheduleFlush()
+	} else {
+		l.writer.Flush()
+	}
+	l.scheduleIdleClose()
+
+	if l.maxSize > 0 {
+		if l.size >= l.maxSize {
+			l.rotateSize()
+		}
+		return
+	}
+
+	// Rotate once the file holds twice the retained lines, so the file is
+	// rewritten every maxLines messages instead of on every message
+	if l.lines >= 2*l.maxLines {
+		l.rotate()
+	}
+}
+
+// open opens the log file if needed, reopening it if it was removed or
+// replaced (must be called with lock held)
+func (l *StreamLogger) open() error {
+	if l.file != nil {
+		if time.Since(l.lastStat) < statInterval {
+			return nil
+		}
+		l.lastStat = time.Now()
+		if !l.fileReplaced() {
+			return nil
+		}
+		l.closeFile()
+	}
+
 	f, err := os.OpenFile(l.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
+		return err
+	}
+
+	l.file = f
+	l.writer = bufio.NewWriter(f)
+	if l.maxSize > 0 {
+		l.size = 0
+		if info, err := f.Stat(); err == nil {
+			l.size = info.Size()
+		}
+	} else {
+		l.lines = countLines(l.filePath)
+	}
+	l.lastStat = time.Now()
+	return nil
+}
+
+// fileReplaced reports whether the path no longer refers to the open file
+func (l *StreamLogger) fileReplaced() bool {
+	pathInfo, err := os.Stat(l.filePath)
+	if err != nil {
+		return true
+	}
+	fileInfo, err := l.file.Stat()
+	if err != nil {
+		return true
+	}
+	return !os.SameFile(pathInfo, fileInfo)
+}
+
+// closeFile flushes and closes the open file (must be called with lock held)
+func (l *StreamLogger) closeFile() {
+	if l.file == nil {
 		return
 	}
-	f.WriteString(line)
-	f.Close()
+	l.writer.Flush()
+	l.file.Close()
+	l.file = nil
+	l.writer = nil
+}
 
-	// Rotate if needed
-	l.rotate()
+// scheduleFlush flushes buffered lines after flushInterval
+func (l *StreamLogger) scheduleFlush() {
+	if l.flushTimer == nil {
+		l.flushTimer = time.AfterFunc(flushInterval, l.flush)
+		return
+	}
+	l.flushTimer.Reset(flushInterval)
+}
+
+// scheduleIdleClose closes the file once no message was written for idleTimeout
+func (l *StreamLogger) scheduleIdleClose() {
+	if l.idleTimer == nil {
+		l.idleTimer = time.AfterFunc(idleTimeout, l.closeIdle)
+		return
+	}
+	l.idleTimer.Reset(idleTimeout)
+}
+
+// flush writes buffered lines to the file
+func (l *StreamLogger) flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.writer != nil {
+		l.writer.Flush()
+	}
+}
+
+// closeIdle closes the file if it has been idle for idleTimeout
+func (l *StreamLogger) closeIdle() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Since(l.lastWrite) >= idleTimeout {
+		l.closeFile()
+	}
+}
+
+// Close flushes and closes the log file. The logger remains usable and
+// reopens the file on the next message.
+func (l *StreamLogger) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.flushTimer != nil {
+		l.flushTimer.Stop()
+	}
+	if l.idleTimer != nil {
+		l.idleTimer.Stop()
+	}
+	l.closeFile()
+}
+
+// countLines counts the lines of a file (0 if it cannot be read)
+func countLines(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	count := 0
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := f.Read(buf)
+		count += bytes.Count(buf[:n], []byte{'\n'})
+		if err != nil {
+			return count
+		}
+	}
+}
+
+// Debug logs a debug-level message, for details such as the chosen format
+// or backoff that are only written when debugging a stream
+func (l *StreamLogger) Debug(format string, args ...interface{}) {
+	l.Log(LevelDebug, format, args...)
 }
 
 // Info logs an info-level message
@@ -73,8 +317,11 @@ func (l *StreamLogger) Error(format string, args ...interface{}) {
 	l.Log(LevelError, format, args...)
 }
 
-// rotate keeps only the last maxLines in the log file
+// rotate keeps only the last maxLines in the log file (must be called with
+// lock held)
 func (l *StreamLogger) rotate() {
+	l.closeFile()
+
 	// Read all lines
 	content, err := os.ReadFile(l.filePath)
 	if err != nil {
@@ -90,11 +337,13 @@ func (l *StreamLogger) rotate() {
 
 	// Only rotate if exceeds maxLines
 	if len(lines) <= l.maxLines {
+		l.lines = len(lines)
 		return
 	}
 
 	// Keep only the last maxLines
 	lines = lines[len(lines)-l.maxLines:]
+	l.lines = len(lines)
 
 	// Write back
 	f, err := os.Create(l.filePath)
@@ -113,24 +362,32 @@ func (l *StreamLogger) GetPath() string {
 	return l.filePath
 }
 
-// ReadLast reads the last n lines from the log file
+// ReadLast reads the last n lines from the log file, continuing into the
+// most recent archive when the file was rotated recently
 func (l *StreamLogger) ReadLast(n int) ([]string, error) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if l.writer != nil {
+		l.writer.Flush()
+	}
+
+	lines := []string{}
 	f, err := os.Open(l.filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return []string{}, nil
-		}
+	if err == nil {
+		lines, err = readLastLines(f, n)
+		f.Close()
+	}
+	if err != nil && !os.IsNotExist(err) {
 		return nil, err
 	}
-	defer f.Close()
 
-	var lines []string
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	if n > len(lines) {
+		archived, err := readArchive(l.filePath, n-len(lines))
+		if err != nil {
+			return nil, err
+		}
+		lines = append(archived, lines...)
 	}
 
 	if n >= len(lines) {
@@ -141,21 +398,73 @@ func (l *StreamLogger) ReadLast(n int) ([]string, error) {
 
 // LoggerManager manages loggers for multiple streams
 type LoggerManager struct {
-	mu      sync.RWMutex
-	loggers map[string]*StreamLogger
-	dataDir string
+	mu       sync.RWMutex
+	loggers  map[string]*StreamLogger
+	dataDir  string
 	maxLines int
+
+	level         LogLevel
+	maxLineLength int
+	redactURLs    bool
+
+	maxSize  int64
+	archives int
+	compress bool
 }
 
 // NewLoggerManager creates a new logger manager
 func NewLoggerManager(dataDir string, maxLines int) *LoggerManager {
 	return &LoggerManager{
-		loggers:  make(map[string]*StreamLogger),
-		dataDir:  dataDir,
-		maxLines: maxLines,
+		loggers:       make(map[string]*StreamLogger),
+		dataDir:       dataDir,
+		maxLines:      maxLines,
+		level:         LevelInfo,
+		maxLineLength: DefaultMaxLineLength,
+		redactURLs:    true,
 	}
 }
 
+// SetLevel sets the level of loggers created afterwards, for streams
+// without a level of their own
+func (m *LoggerManager) SetLevel(level LogLevel) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.level = level
+}
+
+// DefaultLevel returns the level of streams without a level of their own
+func (m *LoggerManager) DefaultLevel() LogLevel {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.level
+}
+
+// SetMaxLineLength sets the maximum message length for loggers created
+// afterwards (0 = unlimited)
+func (m *LoggerManager) SetMaxLineLength(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxLineLength = n
+}
+
+// SetSizeRotation makes loggers created afterwards rotate their file into
+// up to archives numbered archives, gzipped if compress is set, once it
+// reaches maxSize bytes (0 = keep the last lines instead)
+func (m *LoggerManager) SetSizeRotation(maxSize int64, archives int, compress bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxSize = maxSize
+	m.archives = archives
+	m.compress = compress
+}
+
+// SetRedactURLs sets whether loggers created afterwards redact URLs
+func (m *LoggerManager) SetRedactURLs(redact bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.redactURLs = redact
+}
+
 // GetLogger returns (or creates) a logger for the given stream
 func (m *LoggerManager) GetLogger(streamName string) *StreamLogger {
 	m.mu.Lock()
@@ -166,13 +475,34 @@ func (m *LoggerManager) GetLogger(streamName string) *StreamLogger {
 	}
 
 	logger := NewStreamLogger(m.dataDir, streamName, m.maxLines)
+	logger.level = m.level
+	logger.maxLineLength = m.maxLineLength
+	logger.redactURLs = m.redactURLs
+	logger.maxSize = m.maxSize
+	logger.archives = m.archives
+	logger.compress = m.compress
 	m.loggers[streamName] = logger
 	return logger
 }
 
-// RemoveLogger removes a logger from the manager (does not delete the file)
+// RemoveLogger closes a logger and removes it from the manager (does not
+// delete the file)
 func (m *LoggerManager) RemoveLogger(streamName string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	delete(m.loggers, streamName)
+
+	if logger, exists := m.loggers[streamName]; exists {
+		logger.Close()
+		delete(m.loggers, streamName)
+	}
+}
+
+// CloseAll closes the log files of all loggers
+func (m *LoggerManager) CloseAll() {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, logger := range m.loggers {
+		logger.Close()
+	}
 }
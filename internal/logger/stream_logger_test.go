@@ -0,0 +1,143 @@
+package logger
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ffmpegLine is a typical line of ffmpeg's stderr, logged for every segment
+const ffmpegLine = "[hls @ 0x55d0c4a3c2c0] Opening 'https://example.com/seg-%d.ts' for reading"
+
+// readLog returns the contents of a logger's file
+func readLog(t *testing.T, l *StreamLogger) string {
+	t.Helper()
+	data, err := os.ReadFile(l.GetPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestStreamLoggerKeepsFileOpen(t *testing.T) {
+	l := NewStreamLogger(t.TempDir(), "news", 100)
+	defer l.Close()
+
+	l.Warn(ffmpegLine, 1)
+	file := l.file
+	l.Warn(ffmpegLine, 2)
+	if l.file == nil || l.file != file {
+		t.Error("log file was reopened between messages")
+	}
+	if got := strings.Count(readLog(t, l), "\n"); got != 2 {
+		t.Errorf("log has %d lines, want 2", got)
+	}
+}
+
+func TestStreamLoggerPathologicalOutput(t *testing.T) {
+	l := NewStreamLogger(t.TempDir(), "news", 100)
+	l.maxLineLength = 64
+	defer l.Close()
+
+	l.Info("%s", strings.Repeat("x", 1<<20))
+	for i := range 1000 {
+		l.Info("frame=%d fps=30\r", i)
+	}
+	l.Warn("\x1b[1;31mHTTP error 403 Forbidden\x1b[0m")
+	l.Close()
+
+	got := readLog(t, l)
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("log has %d lines, want 3 (long line, one progress update, error):\n%s", len(lines), got)
+	}
+	if len(got) > 512 {
+		t.Errorf("log grew to %d bytes", len(got))
+	}
+	if !strings.Contains(lines[0], "[truncated ") {
+		t.Errorf("long line = %q, want it truncated", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "frame=0 fps=30") {
+		t.Errorf("progress line = %q, want only the first update", lines[1])
+	}
+	if !strings.HasSuffix(lines[2], "[WARN] HTTP error 403 Forbidden") {
+		t.Errorf("error line = %q, want it without escape sequences", lines[2])
+	}
+}
+
+func TestStreamLoggerReopensRemovedFile(t *testing.T) {
+	l := NewStreamLogger(t.TempDir(), "news", 100)
+	defer l.Close()
+
+	l.Warn("before")
+	if err := os.Remove(l.GetPath()); err != nil {
+		t.Fatal(err)
+	}
+	// As if statInterval had passed
+	l.lastStat = time.Time{}
+	l.Warn("after")
+
+	if got := readLog(t, l); !strings.Contains(got, "after") || strings.Contains(got, "before") {
+		t.Errorf("log after removal = %q, want only the new message", got)
+	}
+}
+
+func TestStreamLoggerClosesIdleFile(t *testing.T) {
+	l := NewStreamLogger(t.TempDir(), "news", 100)
+	defer l.Close()
+
+	l.Info("buffered")
+	l.lastWrite = time.Now().Add(-idleTimeout)
+	l.closeIdle()
+	if l.file != nil {
+		t.Error("idle log file is still open")
+	}
+	if got := readLog(t, l); !strings.Contains(got, "buffered") {
+		t.Errorf("log = %q, want the buffered message flushed on close", got)
+	}
+
+	// The next message reopens it
+	l.Warn("again")
+	if l.file == nil || !strings.Contains(readLog(t, l), "again") {
+		t.Error("log file was not reopened for a new message")
+	}
+}
+
+func TestRemoveLoggerClosesFile(t *testing.T) {
+	m := NewLoggerManager(t.TempDir(), 100)
+	l := m.GetLogger("news")
+	l.Info("buffered")
+
+	m.RemoveLogger("news")
+	if l.file != nil {
+		t.Error("removed logger still holds its file open")
+	}
+	if got := readLog(t, l); !strings.Contains(got, "buffered") {
+		t.Errorf("log = %q, want the buffered message flushed on removal", got)
+	}
+	if m.GetLogger("news") == l {
+		t.Error("GetLogger returned the removed logger")
+	}
+}
+
+// BenchmarkStreamLogger logs ffmpeg's stderr as it arrives, with the file
+// kept open, against closing it after every line as loggers did before
+func BenchmarkStreamLogger(b *testing.B) {
+	for _, reopen := range []bool{false, true} {
+		name := "kept open"
+		if reopen {
+			name = "open per line"
+		}
+		b.Run(name, func(b *testing.B) {
+			l := NewStreamLogger(b.TempDir(), "news", 1<<20)
+			defer l.Close()
+			for i := range b.N {
+				l.Info(ffmpegLine, i)
+				if reopen {
+					l.Close()
+				}
+			}
+		})
+	}
+}
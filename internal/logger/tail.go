@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+)
+
+// tailChunkSize is how much of a file readLastLines reads at a time
+const tailChunkSize = 32 * 1024
+
+// readLastLines returns the last n lines of a file, reading it backwards
+// from the end in chunks so that large files cost only what is returned.
+// A final line without a newline counts as a line, and CRLF endings are
+// stripped.
+func readLastLines(f *os.File, n int) ([]string, error) {
+	if n <= 0 {
+		return []string{}, nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect chunks from the end until they hold n complete lines, i.e.
+	// n newlines before the one ending the file
+	var data []byte
+	newlines := 0
+	for offset := info.Size(); offset > 0; {
+		size := min(int64(tailChunkSize), offset)
+		offset -= size
+
+		chunk := make([]byte, size)
+		if _, err := f.ReadAt(chunk, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		if data == nil && bytes.HasSuffix(chunk, []byte{'\n'}) {
+			newlines--
+		}
+		newlines += bytes.Count(chunk, []byte{'\n'})
+		data = append(chunk, data...)
+
+		if newlines >= n {
+			break
+		}
+	}
+
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return []string{}, nil
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines, nil
+}
@@ -0,0 +1,132 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// tailOf writes content to a file and returns readLastLines(n) of it
+func tailOf(t *testing.T, content string, n int) []string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stream.log")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	lines, err := readLastLines(f, n)
+	if err != nil {
+		t.Fatalf("readLastLines: %v", err)
+	}
+	return lines
+}
+
+func TestReadLastLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		n       int
+		want    []string
+	}{
+		{"empty file", "", 10, []string{}},
+		{"only a newline", "\n", 10, []string{}},
+		{"fewer lines than n", "a\nb\n", 10, []string{"a", "b"}},
+		{"exactly n lines", "a\nb\nc\n", 3, []string{"a", "b", "c"}},
+		{"more lines than n", "a\nb\nc\nd\n", 2, []string{"c", "d"}},
+		{"no trailing newline", "a\nb\nc", 2, []string{"b", "c"}},
+		{"single line without newline", "only", 5, []string{"only"}},
+		{"CRLF endings", "a\r\nb\r\nc\r\n", 2, []string{"b", "c"}},
+		{"empty lines kept", "a\n\nb\n", 3, []string{"a", "", "b"}},
+		{"n is zero", "a\nb\n", 0, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tailOf(t, tt.content, tt.n)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("readLastLines(%q, %d) = %q, want %q", tt.content, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+// numberedLines returns count lines "line 0000…", each width bytes long
+// including the newline
+func numberedLines(count, width int) []string {
+	lines := make([]string, count)
+	for i := range lines {
+		prefix := fmt.Sprintf("line %06d ", i)
+		lines[i] = prefix + strings.Repeat("x", width-len(prefix)-1)
+	}
+	return lines
+}
+
+func TestReadLastLinesAcrossChunks(t *testing.T) {
+	// Lines of 100 bytes do not divide the chunk size, so chunk
+	// boundaries fall inside lines
+	lines := numberedLines(3*tailChunkSize/100, 100)
+	content := strings.Join(lines, "\n") + "\n"
+	if len(content) <= 2*tailChunkSize {
+		t.Fatalf("test file of %d bytes spans too few chunks", len(content))
+	}
+
+	for _, n := range []int{1, 327, 328, 329, 700, len(lines), len(lines) + 5} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			want := lines[max(0, len(lines)-n):]
+			if got := tailOf(t, content, n); !slices.Equal(got, want) {
+				t.Errorf("got %d lines (first %q), want %d (first %q)", len(got), first(got), len(want), first(want))
+			}
+		})
+	}
+}
+
+func TestReadLastLinesNewlineAtChunkBoundary(t *testing.T) {
+	// The newline ending a line is the last byte of a chunk read from the
+	// end, so the next line starts exactly at the chunk boundary
+	lines := numberedLines(2*tailChunkSize/64, 64)
+	content := strings.Join(lines, "\n") + "\n"
+	if len(content)%tailChunkSize != 0 {
+		t.Fatalf("test file of %d bytes is not a whole number of chunks", len(content))
+	}
+
+	n := tailChunkSize/64 + 1
+	want := lines[len(lines)-n:]
+	if got := tailOf(t, content, n); !slices.Equal(got, want) {
+		t.Errorf("got %d lines (first %q), want %d (first %q)", len(got), first(got), len(want), first(want))
+	}
+}
+
+func TestReadLastLinesLargeWithoutTrailingNewline(t *testing.T) {
+	lines := numberedLines(2*tailChunkSize/50, 50)
+	lines = append(lines, "partial last line")
+	content := strings.Join(lines, "\n")
+
+	want := lines[len(lines)-3:]
+	if got := tailOf(t, content, 3); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadLastLinesLongLine(t *testing.T) {
+	// A single line longer than a chunk is returned whole
+	long := strings.Repeat("y", 2*tailChunkSize+17)
+	content := "before\n" + long + "\nafter\n"
+
+	if got := tailOf(t, content, 2); !slices.Equal(got, []string{long, "after"}) {
+		t.Errorf("got %d lines, want the long line and \"after\"", len(got))
+	}
+}
+
+func first(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return lines[0]
+}
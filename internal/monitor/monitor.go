@@ -2,16 +2,22 @@ package monitor
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/hooks"
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/logger"
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/server"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/systemd"
 )
 
 // Monitor handles health checking and automatic reconnection
@@ -20,29 +26,68 @@ type Monitor struct {
 
 	config        *config.MonitorConfig
 	streamManager *stream.Manager
-	server        *server.MediaMTXServer
-	extractor     extractor.Extractor
+	server        mediaServer
+	log           *slog.Logger
 
 	running  bool
 	cancel   context.CancelFunc
 	wg       sync.WaitGroup
+
+	// reconnecting holds the streams with a reconnect loop in progress,
+	// and the watchers receiving its progress (see ForceReconnect)
+	reconnecting map[string]bool
+	watchers     map[string][]ProgressFunc
+
+	// updateYtdlp updates yt-dlp when extraction fails because it is
+	// outdated (nil = only suggest updating). lastOutdated is when that
+	// was last reported.
+	updateYtdlp  func(ctx context.Context) (string, error)
+	lastOutdated time.Time
+
+	// atReaderLimit holds the streams last seen with as many readers as
+	// mediamtx.max_readers allows, so reaching it is logged once
+	atReaderLimit map[string]bool
+}
+
+// mediaServer is the part of the MediaMTX server the monitor checks and
+// restarts
+type mediaServer interface {
+	HealthCheck() error
+	GetPathInfo(path string) (*server.PathInfo, error)
+	MaxReaders() int
+	Restart(ctx context.Context) error
 }
 
+// outdatedInterval is how often an outdated yt-dlp is reported, and at
+// most updated
+const outdatedInterval = time.Hour
+
 // NewMonitor creates a new monitor instance
 func NewMonitor(
 	cfg *config.MonitorConfig,
 	manager *stream.Manager,
 	srv *server.MediaMTXServer,
-	ext extractor.Extractor,
+	log *slog.Logger,
 ) *Monitor {
 	return &Monitor{
 		config:        cfg,
 		streamManager: manager,
 		server:        srv,
-		extractor:     ext,
+		log:           log.With("component", "monitor"),
+		reconnecting:  make(map[string]bool),
+		watchers:      make(map[string][]ProgressFunc),
+		atReaderLimit: make(map[string]bool),
 	}
 }
 
+// SetYtdlpUpdater sets how yt-dlp is updated when extraction fails because
+// it is outdated. update returns the new version.
+func (m *Monitor) SetYtdlpUpdater(update func(ctx context.Context) (string, error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updateYtdlp = update
+}
+
 // Start starts the monitoring loop
 func (m *Monitor) Start(ctx context.Context) {
 	m.mu.Lock()
@@ -92,15 +137,26 @@ func (m *Monitor) run(ctx context.Context) {
 	ticker := time.NewTicker(m.config.HealthCheckInterval)
 	defer ticker.Stop()
 
-	log.Printf("[Monitor] Started with health check interval: %v", m.config.HealthCheckInterval)
+	m.log.Info("monitor started", "health_check_interval", m.config.HealthCheckInterval)
+
+	// Ping the systemd watchdog if WatchdogSec is configured
+	var watchdogC <-chan time.Time
+	if interval := systemd.WatchdogInterval(); interval > 0 {
+		watchdog := time.NewTicker(interval)
+		defer watchdog.Stop()
+		watchdogC = watchdog.C
+		m.log.Info("systemd watchdog enabled", "interval", interval)
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf("[Monitor] Stopping...")
+			m.log.Info("monitor stopping")
 			return
 		case <-ticker.C:
 			m.runHealthChecks(ctx)
+		case <-watchdogC:
+			systemd.Notify(systemd.StateWatchdog)
 		}
 	}
 }
@@ -109,41 +165,106 @@ func (m *Monitor) run(ctx context.Context) {
 func (m *Monitor) runHealthChecks(ctx context.Context) {
 	// Check MediaMTX server first
 	if err := m.server.HealthCheck(); err != nil {
-		log.Printf("[Monitor] MediaMTX server unhealthy: %v", err)
+		m.log.Warn("mediamtx server unhealthy", "error", err)
 		m.handleServerFailure(ctx)
 		return
 	}
 
 	// Check each stream
+	type failure struct {
+		stream *stream.Stream
+		reason string
+	}
+	var failures []failure
+	serverWedged := false
+	refreshing := make(map[string]bool)
+
 	streams := m.streamManager.GetAllStreams()
 	for _, s := range streams {
+		// Resume reconnects persisted by an earlier process, and those of
+		// recovered streams whose ffmpeg is gone
+		if attempt, _ := s.GetRetry(); attempt > 0 && s.GetState() == stream.StateReconnecting {
+			go m.reconnectStream(ctx, s, m.streamManager.Generation(s.Name))
+			continue
+		}
 		if s.GetState() != stream.StateRunning {
 			continue
 		}
 
 		status := m.checkStreamHealth(s)
-		if !status.Healthy {
-			log.Printf("[Monitor] Stream '%s' unhealthy: %s", s.Name, status.Reason)
-			go m.handleStreamFailure(ctx, s, status.Reason)
-		} else {
-			s.ResetConsecutiveErrors()
+		switch {
+		case status.Healthy:
+			s.RecordHealthyCheck(m.config.RecoveryThreshold)
 			s.SetLastChecked(time.Now())
+			if s.GetIsLive() && time.Since(s.GetLastURLRefresh()) > m.config.URLRefreshInterval && !refreshing[s.Name] {
+				// Streams of the same source are refreshed together,
+				// sharing one extraction
+				for _, r := range append([]*stream.Stream{s}, m.streamManager.SourceSharers(s.Name)...) {
+					if !refreshing[r.Name] && r.GetState() == stream.StateRunning {
+						refreshing[r.Name] = true
+						go m.swapStreamSource(ctx, r)
+					}
+				}
+			}
+		case status.Ended:
+			m.log.Info("vod stream ended", "stream", s.Name)
+			m.streamManager.MarkEnded(s)
+		case status.ServerSide:
+			m.log.Warn("mediamtx not accepting data from healthy publisher", "stream", s.Name, "reason", status.Reason)
+			m.getStreamLogger(s.Name).Warn("Server-side failure: %s", status.Reason)
+			s.IncrementErrorCount()
+			s.SetLastError(status.Reason)
+			m.streamManager.SaveHealth(s)
+			serverWedged = true
+		default:
+			m.log.Warn("stream unhealthy", "stream", s.Name, "reason", status.Reason)
+			failures = append(failures, failure{stream: s, reason: status.Reason})
 		}
 	}
+
+	// A wedged server is restarted along with all of its streams, which
+	// covers any publisher failures found in the same round
+	if serverWedged {
+		m.handleServerFailure(ctx)
+		return
+	}
+
+	for _, f := range failures {
+		go m.handleStreamFailure(ctx, f.stream, f.reason)
+	}
 }
 
+// minSpeed is the ffmpeg speed below which a stream is considered to be
+// falling behind real time
+const minSpeed = 0.9
+
 // HealthStatus represents the health check result
 type HealthStatus struct {
-	Healthy bool
-	Reason  string
+	Healthy    bool
+	ServerSide bool // ffmpeg is publishing fine but MediaMTX is not taking the data
+	Ended      bool // a VOD stream reached the end of its video
+	Reason     string
 }
 
 // checkStreamHealth checks the health of a single stream
 func (m *Monitor) checkStreamHealth(s *stream.Stream) HealthStatus {
 	// 1. Check if FFmpeg process is alive
 	pid := s.GetFFmpegPID()
-	if pid <= 0 || !stream.IsProcessAlive(pid) {
-		return HealthStatus{Healthy: false, Reason: "ffmpeg process not running"}
+	if pid <= 0 || !s.FFmpegAlive() {
+		// A VOD ends when ffmpeg reaches EOF; that is not a failure
+		if !s.GetIsLive() && m.streamManager.FFmpegExitedCleanly(s.Name) {
+			return HealthStatus{Healthy: false, Ended: true, Reason: "video ended"}
+		}
+		reason := "ffmpeg process not running"
+		if tail := m.streamManager.FFmpegOutput(s.Name, 3); len(tail) > 0 {
+			reason += ": " + strings.Join(tail, " | ")
+		}
+		return HealthStatus{Healthy: false, Reason: reason}
+	}
+
+	// Streams published only to remote targets have no path here
+	if !stream.PublishesLocally(s.Outputs) {
+		return m.checkRemoteHealth(s)
 	}
 
 	// 2. Check MediaMTX path status
@@ -157,182 +278,402 @@ func (m *Monitor) checkStreamHealth(s *stream.Stream) HealthStatus {
 		return HealthStatus{Healthy: false, Reason: "path not ready"}
 	}
 
+	// Companion paths come from the same ffmpeg, but their encoders can
+	// fail on their own
+	for _, c := range stream.Companions(s.RTSPPath, s.Substream, s.AudioCopy) {
+		info, err := m.server.GetPathInfo(c.Path)
+		if err != nil || !info.Ready {
+			return HealthStatus{Healthy: false, Reason: c.Name + " path not ready"}
+		}
+	}
+
+	// Track ingest, and how much of it nobody was watching
+	m.streamManager.RecordUsage(s, pathInfo.BytesReceived, pathInfo.ReaderCount())
+	m.checkReaderLimit(s.Name, pathInfo.ReaderCount())
+
 	// 4. Check for stalled stream (bytes not increasing)
 	if !s.UpdateBytesReceived(pathInfo.BytesReceived) {
 		stallCount := s.GetStallCount()
-		if stallCount >= 3 {
+		if stallCount >= m.config.StallThreshold {
+			// Restarting ffmpeg does not help when it still reports output
+			// at full speed; the path is wedged on the MediaMTX side
+			if progress := s.GetProgress(); m.progressRecent(progress) && progress.Speed >= minSpeed {
+				return HealthStatus{
+					Healthy:    false,
+					ServerSide: true,
+					Reason:     fmt.Sprintf("mediamtx path frozen while ffmpeg publishes (speed %.2fx)", progress.Speed),
+				}
+			}
 			return HealthStatus{Healthy: false, Reason: "stream stalled (no data flow)"}
 		}
 	}
 
+	// 5. Check ffmpeg keeps up with real time
+	progress := s.GetProgress()
+	slow := m.progressRecent(progress) && progress.Speed > 0 && progress.Speed < minSpeed
+	if s.UpdateSlowCount(slow) >= 3 {
+		return HealthStatus{Healthy: false, Reason: fmt.Sprintf("ffmpeg falling behind (speed %.2fx)", progress.Speed)}
+	}
+
 	return HealthStatus{Healthy: true}
 }
 
+// checkReaderLimit logs when a stream reaches the readers allowed per
+// path, as MediaMTX then refuses further clients without telling us
+func (m *Monitor) checkReaderLimit(name string, readers int) {
+	limit := m.server.MaxReaders()
+	if limit <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	full := readers >= limit
+	if full && !m.atReaderLimit[name] {
+		m.log.Warn("stream reached the reader limit, further clients are refused",
+			"stream", name, "readers", readers, "max_readers", limit)
+	}
+	if full {
+		m.atReaderLimit[name] = true
+	} else {
+		delete(m.atReaderLimit, name)
+	}
+}
+
+// checkRemoteHealth checks a stream published only to remote targets by
+// ffmpeg's progress reports, which stop while ffmpeg is stuck on a target
+func (m *Monitor) checkRemoteHealth(s *stream.Stream) HealthStatus {
+	progress := s.GetProgress()
+	if !m.progressRecent(progress) {
+		// ffmpeg may not have reported yet
+		if progress.UpdatedAt.IsZero() && time.Since(s.GetInfo().StartedAt) < 2*m.config.HealthCheckInterval {
+			return HealthStatus{Healthy: true}
+		}
+		return HealthStatus{Healthy: false, Reason: "stream stalled (no progress from ffmpeg)"}
+	}
+
+	slow := progress.Speed > 0 && progress.Speed < minSpeed
+	if s.UpdateSlowCount(slow) >= 3 {
+		return HealthStatus{Healthy: false, Reason: fmt.Sprintf("ffmpeg falling behind (speed %.2fx)", progress.Speed)}
+	}
+
+	return HealthStatus{Healthy: true}
+}
+
+// progressRecent reports whether ffmpeg's progress report is fresh enough
+// to judge the publisher by
+func (m *Monitor) progressRecent(p storage.Progress) bool {
+	return time.Since(p.UpdatedAt) < 2*m.config.HealthCheckInterval
+}
+
 // handleServerFailure handles MediaMTX server failure
 func (m *Monitor) handleServerFailure(ctx context.Context) {
-	log.Printf("[Monitor] Attempting to restart MediaMTX server...")
+	m.log.Info("restarting mediamtx server")
 
 	if err := m.server.Restart(ctx); err != nil {
-		log.Printf("[Monitor] Failed to restart MediaMTX: %v", err)
+		m.log.Error("failed to restart mediamtx", "error", err)
 		return
 	}
 
-	log.Printf("[Monitor] MediaMTX restarted, restarting all streams...")
+	m.log.Info("mediamtx restarted, restarting all streams")
+
+	// The restarted server has lost its runtime path configuration
+	m.streamManager.SyncOnDemandPaths()
 
 	// Restart all streams
 	streams := m.streamManager.GetAllStreams()
 	for _, s := range streams {
+		// Streams published only to remote targets do not need MediaMTX
+		if s.OnDemand || !stream.PublishesLocally(s.Outputs) {
+			continue
+		}
 		go m.restartStream(ctx, s)
 	}
 }
 
 // handleStreamFailure handles a single stream failure
 func (m *Monitor) handleStreamFailure(ctx context.Context, s *stream.Stream, reason string) {
+	generation := m.streamManager.Generation(s.Name)
 	streamLog := m.getStreamLogger(s.Name)
 	s.IncrementErrorCount()
 	s.SetLastError(reason)
 	s.SetState(stream.StateReconnecting)
+	m.streamManager.SaveHealth(s)
 
 	streamLog.Warn("Stream unhealthy: %s", reason)
 
 	// Check if we should refresh URL
 	if m.shouldRefreshURL(s, reason) {
-		log.Printf("[Monitor] Refreshing URL for stream '%s'", s.Name)
+		m.log.Info("refreshing stream URL", "stream", s.Name, "reason", reason)
 		streamLog.Info("Refreshing URL due to: %s", reason)
 		if err := m.refreshStreamURL(ctx, s); err != nil {
-			log.Printf("[Monitor] Failed to refresh URL: %v", err)
+			m.log.Error("failed to refresh stream URL", "stream", s.Name, "error", err)
 			streamLog.Error("URL refresh failed: %v", err)
 		}
 	}
 
 	// Attempt reconnection
-	m.reconnectStream(ctx, s)
+	m.reconnectStream(ctx, s, generation)
 }
 
 // shouldRefreshURL determines if URL should be refreshed
 func (m *Monitor) shouldRefreshURL(s *stream.Stream, reason string) bool {
-	// Condition 1: Periodic refresh
-	if time.Since(s.GetLastURLRefresh()) > m.config.URLRefreshInterval {
-		return true
+	// Refreshing a VOD URL restarts it from the beginning; a reconnect
+	// extracts a fresh URL anyway if one is needed
+	if !s.GetIsLive() {
+		return false
 	}
 
-	// Condition 2: Consecutive errors
-	if s.GetConsecutiveErrors() >= m.config.MaxConsecutiveErrors {
+	// Condition 1: Periodic refresh
+	if age := time.Since(s.GetLastURLRefresh()); age > m.config.URLRefreshInterval {
+		m.getStreamLogger(s.Name).Debug("URL is %v old, refreshing every %v", age.Round(time.Second), m.config.URLRefreshInterval)
 		return true
 	}
 
-	// Condition 3: URL-related error patterns
-	if m.hasURLExpiredError(reason) {
+	// Condition 2: Consecutive errors
+	if errs := s.GetConsecutiveErrors(); errs >= m.config.MaxConsecutiveErrors {
+		m.getStreamLogger(s.Name).Debug("%d consecutive errors reached the refresh threshold", errs)
 		return true
 	}
 
-	return false
-}
-
-// hasURLExpiredError checks for URL expiration error patterns
-func (m *Monitor) hasURLExpiredError(errMsg string) bool {
-	patterns := []string{
-		"403",
-		"404",
-		"forbidden",
-		"not found",
-		"connection refused",
-		"timeout",
-		"expired",
-	}
-
-	errLower := strings.ToLower(errMsg)
-	for _, pattern := range patterns {
-		if strings.Contains(errLower, pattern) {
-			return true
-		}
-	}
-	return false
+	// Condition 3: ffmpeg was refused the URL, as happens once it expires
+	return errors.Is(extractor.ClassifyMediaFailure(reason), extractor.ErrURLExpired)
 }
 
 // refreshStreamURL extracts a new URL for the stream
 func (m *Monitor) refreshStreamURL(ctx context.Context, s *stream.Stream) error {
-	info, err := m.extractor.Extract(ctx, s.YouTubeURL)
+	info, err := m.streamManager.ExtractSource(ctx, s)
 	if err != nil {
+		m.checkOutdated(ctx, err)
 		return err
 	}
 
-	s.SetStreamURL(info.URL)
+	m.streamManager.ApplyRefreshedSource(s, info)
 	return nil
 }
 
-// reconnectStream attempts to reconnect a stream with exponential backoff
-func (m *Monitor) reconnectStream(ctx context.Context, s *stream.Stream) {
+// swapStreamSource refreshes the URL of a healthy stream by handing its
+// path over to a new ffmpeg, falling back to a full reconnect if the
+// handover fails
+func (m *Monitor) swapStreamSource(ctx context.Context, s *stream.Stream) {
+	m.log.Info("refreshing stream URL", "stream", s.Name, "reason", "periodic refresh")
+
+	generation := m.streamManager.Generation(s.Name)
+	err := m.streamManager.SwapSource(ctx, s.Name)
+	if err == nil || errors.Is(err, stream.ErrStreamStopped) || errors.Is(err, stream.ErrStreamBusy) {
+		return
+	}
+
+	// Remote targets may not take a second publisher; restart with a
+	// fresh URL instead
+	if errors.Is(err, stream.ErrNoHandover) {
+		m.getStreamLogger(s.Name).Info("Restarting to refresh the URL (remote targets cannot be handed over)")
+		err = m.streamManager.RestartStream(ctx, s.Name, generation)
+		if err == nil || errors.Is(err, stream.ErrStreamStopped) {
+			return
+		}
+	}
+
+	m.log.Warn("seamless URL refresh failed, reconnecting", "stream", s.Name, "error", err)
+	m.handleStreamFailure(ctx, s, err.Error())
+}
+
+// reconnectStream attempts to reconnect a stream with exponential backoff.
+// It gives up as soon as the stream is stopped (its generation changes).
+// A pending attempt recorded on the stream is resumed at its retry time.
+// Each step is reported to the watchers of the stream.
+func (m *Monitor) reconnectStream(ctx context.Context, s *stream.Stream, generation uint64) {
+	if !m.claimReconnect(s.Name) {
+		return
+	}
+	defer m.releaseReconnect(s.Name)
+
+	// Set by each way out of the loop; what watchers are told at the end
+	outcome := errors.New("reconnect abandoned")
+	defer func() { m.reportDone(s.Name, outcome) }()
+
 	streamLog := m.getStreamLogger(s.Name)
-	backoff := m.config.Reconnect.InitialDelay
+	attempt, retryAt := s.GetRetry()
+	if attempt < 1 {
+		attempt, retryAt = 1, time.Time{}
+	}
+	backoff := m.backoffFor(attempt)
 
-	for attempt := 1; attempt <= m.config.Reconnect.MaxAttempts; attempt++ {
+	for ; attempt <= m.config.Reconnect.MaxAttempts; attempt++ {
+		if wait := time.Until(retryAt); wait > 0 {
+			select {
+			case <-ctx.Done():
+				outcome = ctx.Err()
+				return
+			case <-time.After(wait):
+			}
+		}
 		select {
 		case <-ctx.Done():
+			outcome = ctx.Err()
 			return
 		default:
 		}
 
-		log.Printf("[Monitor] Reconnect attempt %d/%d for stream '%s' (delay: %v)",
-			attempt, m.config.Reconnect.MaxAttempts, s.Name, backoff)
+		if m.streamManager.Generation(s.Name) != generation {
+			m.log.Info("stream stopped, abandoning reconnect", "stream", s.Name)
+			outcome = stream.ErrStreamStopped
+			return
+		}
+
+		m.log.Info("reconnecting stream", "stream", s.Name,
+			"attempt", attempt, "max_attempts", m.config.Reconnect.MaxAttempts, "delay", backoff)
 		streamLog.Warn("Reconnect attempt %d/%d (delay: %v)", attempt, m.config.Reconnect.MaxAttempts, backoff)
+		m.reportProgress(s.Name, ReconnectProgress{Attempt: attempt, MaxAttempts: m.config.Reconnect.MaxAttempts})
 
 		// Stop existing process
-		if pid := s.GetFFmpegPID(); pid > 0 {
-			stream.KillByPID(pid)
+		if s.GetFFmpegPID() > 0 {
+			s.KillFFmpeg()
 			time.Sleep(500 * time.Millisecond)
 		}
 
 		// Restart stream
-		if err := m.streamManager.RestartStream(ctx, s.Name); err != nil {
-			log.Printf("[Monitor] Reconnect failed: %v", err)
-			streamLog.Error("Reconnect attempt %d failed: %v", attempt, err)
-
-			// Wait before next attempt
-			select {
-			case <-ctx.Done():
+		if err := m.streamManager.RestartStream(ctx, s.Name, generation); err != nil {
+			if errors.Is(err, stream.ErrStreamStopped) {
+				m.log.Info("stream stopped, abandoning reconnect", "stream", s.Name)
+				outcome = err
+				return
+			}
+			if extractor.IsPermanent(err) {
+				m.giveUp(s, err)
+				outcome = fmt.Errorf("source is permanently unavailable: %w", err)
 				return
-			case <-time.After(backoff):
 			}
+			m.checkOutdated(ctx, err)
+			m.log.Warn("reconnect failed", "stream", s.Name, "attempt", attempt, "error", err)
+			streamLog.Error("Reconnect attempt %d failed: %v", attempt, err)
 
+			// Schedule the next attempt
+			retryAt = time.Now().Add(m.jittered(backoff))
+			if attempt < m.config.Reconnect.MaxAttempts {
+				m.streamManager.RecordRetry(s.Name, attempt+1, retryAt)
+				streamLog.Debug("Next attempt at %s: backoff %v with jitter, then %v",
+					retryAt.Format("15:04:05"), backoff, m.nextBackoff(backoff))
+			}
+			m.reportProgress(s.Name, ReconnectProgress{
+				Attempt:     attempt,
+				MaxAttempts: m.config.Reconnect.MaxAttempts,
+				Delay:       time.Until(retryAt).Round(time.Second),
+				Error:       err.Error(),
+			})
 			backoff = m.nextBackoff(backoff)
 			continue
 		}
 
 		// Success
-		log.Printf("[Monitor] Stream '%s' reconnected successfully", s.Name)
+		m.log.Info("stream reconnected", "stream", s.Name, "attempts", attempt)
 		streamLog.Info("Reconnected successfully after %d attempt(s)", attempt)
-		s.ResetConsecutiveErrors()
-		s.SetState(stream.StateRunning)
+		m.streamManager.RecordRetry(s.Name, 0, time.Time{})
+		// The restart registered a new stream in s's place, already running
+		if recovered := m.streamManager.GetStream(s.Name); recovered != nil {
+			recovered.ResetConsecutiveErrors()
+			m.streamManager.FireHook(hooks.EventRecover, recovered, s.GetLastError())
+		}
+		outcome = nil
 		return
 	}
 
 	// Max attempts reached
-	log.Printf("[Monitor] Max reconnect attempts reached for stream '%s'", s.Name)
+	m.log.Error("max reconnect attempts reached, giving up", "stream", s.Name, "reason", s.GetLastError())
 	streamLog.Error("Max reconnect attempts (%d) reached, giving up", m.config.Reconnect.MaxAttempts)
+	m.streamManager.RecordRetry(s.Name, 0, time.Time{})
+	s.SetState(stream.StateError)
+	m.streamManager.FireHook(hooks.EventError, s, s.GetLastError())
+	outcome = fmt.Errorf("gave up after %d attempts: %s", m.config.Reconnect.MaxAttempts, s.GetLastError())
+}
+
+// giveUp stops reconnecting a stream whose source is gone for good, such as
+// a live event that has ended
+func (m *Monitor) giveUp(s *stream.Stream, err error) {
+	m.log.Error("source is permanently unavailable, giving up", "stream", s.Name, "error", err)
+	log := m.getStreamLogger(s.Name)
+	log.Error("Source is permanently unavailable, not reconnecting: %v", err)
+	s.SetLastError(log.Redact(err.Error()))
+	m.streamManager.RecordRetry(s.Name, 0, time.Time{})
 	s.SetState(stream.StateError)
+	m.streamManager.FireHook(hooks.EventError, s, s.GetLastError())
+}
+
+// checkOutdated reports an extraction failure caused by an outdated
+// yt-dlp, updating yt-dlp if auto-update is enabled. Every stream fails the
+// same way, so this happens at most once per outdatedInterval.
+func (m *Monitor) checkOutdated(ctx context.Context, err error) {
+	if !errors.Is(err, extractor.ErrOutdated) {
+		return
+	}
+
+	m.mu.Lock()
+	if time.Since(m.lastOutdated) < outdatedInterval {
+		m.mu.Unlock()
+		return
+	}
+	m.lastOutdated = time.Now()
+	update := m.updateYtdlp
+	m.mu.Unlock()
+
+	m.log.Error("!!! yt-dlp is outdated: YouTube extraction fails until it is updated !!!",
+		"error", err, "fix", "youtube-rtsp-proxy update ytdlp")
+	if update == nil {
+		return
+	}
+
+	m.log.Info("updating yt-dlp (ytdlp.auto_update)")
+	version, err := update(ctx)
+	if err != nil {
+		m.log.Error("failed to update yt-dlp", "error", err)
+		return
+	}
+	m.log.Info("yt-dlp updated", "version", version)
 }
 
 // restartStream restarts a stream after server recovery
 func (m *Monitor) restartStream(ctx context.Context, s *stream.Stream) {
+	generation := m.streamManager.Generation(s.Name)
 	streamLog := m.getStreamLogger(s.Name)
-	log.Printf("[Monitor] Restarting stream '%s' after server recovery", s.Name)
+	m.log.Info("restarting stream after server recovery", "stream", s.Name)
 	streamLog.Warn("Server recovery - restarting stream")
 
 	// Refresh URL first
 	if err := m.refreshStreamURL(ctx, s); err != nil {
-		log.Printf("[Monitor] Failed to refresh URL for stream '%s': %v", s.Name, err)
+		m.log.Error("failed to refresh stream URL", "stream", s.Name, "error", err)
 		streamLog.Error("URL refresh failed during recovery: %v", err)
 	}
 
 	// Restart
-	if err := m.streamManager.RestartStream(ctx, s.Name); err != nil {
-		log.Printf("[Monitor] Failed to restart stream '%s': %v", s.Name, err)
+	if err := m.streamManager.RestartStream(ctx, s.Name, generation); err != nil {
+		if errors.Is(err, stream.ErrStreamStopped) {
+			return
+		}
+		m.log.Error("failed to restart stream", "stream", s.Name, "error", err)
 		streamLog.Error("Restart failed during recovery: %v", err)
-		m.reconnectStream(ctx, s)
+		m.reconnectStream(ctx, s, generation)
 	}
 }
 
+// claimReconnect marks a stream as being reconnected, returning false if a
+// reconnect loop is already running for it
+func (m *Monitor) claimReconnect(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.reconnecting[name] {
+		return false
+	}
+	m.reconnecting[name] = true
+	return true
+}
+
+// releaseReconnect ends the reconnect loop claim of a stream
+func (m *Monitor) releaseReconnect(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.reconnecting, name)
+}
+
 // nextBackoff calculates the next backoff duration
 func (m *Monitor) nextBackoff(current time.Duration) time.Duration {
 	next := time.Duration(float64(current) * m.config.Reconnect.Multiplier)
@@ -342,18 +683,43 @@ func (m *Monitor) nextBackoff(current time.Duration) time.Duration {
 	return next
 }
 
+// backoffFor returns the delay that follows a failed attempt
+func (m *Monitor) backoffFor(attempt int) time.Duration {
+	backoff := m.config.Reconnect.InitialDelay
+	for i := 1; i < attempt; i++ {
+		backoff = m.nextBackoff(backoff)
+	}
+	return backoff
+}
+
+// jittered spreads a delay randomly by the configured jitter, so streams
+// that failed together do not all retry at the same moment. The backoff
+// itself grows without jitter.
+func (m *Monitor) jittered(d time.Duration) time.Duration {
+	jitter := m.config.Reconnect.Jitter
+	if jitter <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + jitter*(2*rand.Float64()-1)))
+}
+
 // TriggerHealthCheck manually triggers a health check
 func (m *Monitor) TriggerHealthCheck(ctx context.Context) {
 	m.runHealthChecks(ctx)
 }
 
-// ForceReconnect forces a reconnection for a specific stream
-func (m *Monitor) ForceReconnect(ctx context.Context, name string) error {
-	s := m.streamManager.GetStream(name)
-	if s == nil {
-		return nil
+// ForceReconnect forces a reconnection for a specific stream. If report is
+// not nil, it receives each attempt of the reconnect loop (or of the loop
+// already reconnecting the stream) and finally a report with Done set.
+func (m *Monitor) ForceReconnect(ctx context.Context, name string, report ProgressFunc) error {
+	s, err := m.streamManager.Adopt(name)
+	if err != nil {
+		return err
 	}
 
+	if report != nil {
+		m.watchReconnect(name, report)
+	}
 	go m.handleStreamFailure(ctx, s, "forced reconnection")
 	return nil
 }
@@ -0,0 +1,708 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/server"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+)
+
+// fakeExtractor resolves every URL to a fixed source, live unless vod
+type fakeExtractor struct {
+	vod bool
+}
+
+func (f *fakeExtractor) Extract(ctx context.Context, youtubeURL string, opts extractor.ExtractOptions) (*extractor.StreamInfo, error) {
+	return &extractor.StreamInfo{ID: "abc123", URL: "https://example.com/video.m3u8", IsLive: !f.vod}, nil
+}
+
+func (f *fakeExtractor) IsLiveStream(ctx context.Context, youtubeURL string) (bool, error) {
+	return !f.vod, nil
+}
+
+// fakeMediaMTX serves the parts of the MediaMTX API the monitor and the
+// manager use. Paths are ready unless held, and receive more data on each
+// request unless frozen.
+type fakeMediaMTX struct {
+	mu     sync.Mutex
+	bytes  map[string]int64
+	frozen map[string]bool
+
+	// held paths are reported not ready, and each request for one is
+	// sent on waiting
+	held    map[string]bool
+	waiting chan string
+}
+
+func (f *fakeMediaMTX) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/v3/config/global/get":
+		w.Write([]byte("{}"))
+	case strings.HasPrefix(r.URL.Path, "/v3/paths/get/"):
+		name := strings.TrimPrefix(r.URL.Path, "/v3/paths/get/")
+		f.mu.Lock()
+		if !f.frozen[name] {
+			f.bytes[name] += 1000
+		}
+		received := f.bytes[name]
+		held := f.held[name]
+		f.mu.Unlock()
+		if held {
+			select {
+			case f.waiting <- name:
+			default:
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"name":          name,
+			"ready":         !held,
+			"readyTime":     "2026-10-16T10:00:00Z",
+			"bytesReceived": received,
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// freeze stops the byte count of a path, as a wedged MediaMTX does
+func (f *fakeMediaMTX) freeze(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.frozen[name] = true
+}
+
+// hold reports a path not ready until release is called, as while a new
+// publisher connects
+func (f *fakeMediaMTX) hold(name string) (release func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.held[name] = true
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.held, name)
+	}
+}
+
+// countingServer is a MediaMTX server whose restarts are counted instead
+// of done
+type countingServer struct {
+	*server.MediaMTXServer
+	restarts atomic.Int32
+}
+
+func (s *countingServer) Restart(ctx context.Context) error {
+	s.restarts.Add(1)
+	return nil
+}
+
+// harness is a monitor watching a manager whose ffmpeg is a script that
+// idles until it is killed or the input ends, with MediaMTX answered by
+// fakeMediaMTX
+type harness struct {
+	cfg       *config.Config
+	monitor   *Monitor
+	manager   *stream.Manager
+	store     storage.Storage
+	api       *fakeMediaMTX
+	extractor *fakeExtractor
+	pids      string // file the PID of every ffmpeg started is appended to
+	eof       string // file whose creation makes every ffmpeg exit with status 0
+	server    *countingServer
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	dir := t.TempDir()
+
+	// The script keeps ffmpeg's arguments on its command line, by which
+	// the stream's process is recognized
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	eof := filepath.Join(dir, "eof")
+	script := "#!/bin/sh\necho $$ >> " + filepath.Join(dir, "ffmpeg.pids") + "\nuntil [ -e " + eof + " ]; do sleep 0.05; done\n"
+	if err := os.WriteFile(ffmpeg, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("storage:\n  data_dir: "+dir+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.FFmpeg.BinaryPath = ffmpeg
+	cfg.Monitor.Reconnect.InitialDelay = 10 * time.Millisecond
+	cfg.Monitor.Reconnect.Jitter = 0
+
+	api := &fakeMediaMTX{
+		bytes:   make(map[string]int64),
+		frozen:  make(map[string]bool),
+		held:    make(map[string]bool),
+		waiting: make(chan string, 1),
+	}
+	ts := httptest.NewServer(api)
+	t.Cleanup(ts.Close)
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Server.APIAddress = host
+	cfg.Server.APIPort, _ = strconv.Atoi(port)
+
+	store, err := storage.NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := server.NewMediaMTXServer(&cfg.MediaMTX, &cfg.Server, dir, log)
+	ext := &fakeExtractor{}
+	manager := stream.NewManager(cfg, ext, srv, store, log)
+	t.Cleanup(func() {
+		manager.StopAll()
+		manager.GetLoggerManager().CloseAll()
+	})
+
+	counting := &countingServer{MediaMTXServer: srv}
+	monitor := NewMonitor(&cfg.Monitor, manager, srv, log)
+	monitor.server = counting
+	return &harness{
+		cfg:       cfg,
+		monitor:   monitor,
+		manager:   manager,
+		store:     store,
+		api:       api,
+		extractor: ext,
+		pids:      filepath.Join(dir, "ffmpeg.pids"),
+		eof:       eof,
+		server:    counting,
+	}
+}
+
+// waitFor polls cond until it holds, failing after five seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// waitReconnects waits until no reconnect loop is running
+func (h *harness) waitReconnects(t *testing.T) {
+	t.Helper()
+	waitFor(t, "reconnects to finish", func() bool {
+		h.monitor.mu.Lock()
+		defer h.monitor.mu.Unlock()
+		return len(h.monitor.reconnecting) == 0
+	})
+}
+
+// deadPID returns the PID of a process that has exited
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestRecoveredDeadStreamIsReconnected(t *testing.T) {
+	h := newHarness(t)
+	pid := deadPID(t)
+	err := h.store.Save(&storage.StreamData{
+		ID:         "abc",
+		Name:       "news",
+		YouTubeURL: "https://youtu.be/abc123",
+		RTSPPath:   "/news",
+		Port:       8554,
+		FFmpegPID:  pid,
+		IsLive:     true,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.manager.RecoverStreams()
+	if s := h.manager.GetStream("news"); s == nil || s.GetState() != stream.StateReconnecting {
+		t.Fatalf("recovered stream = %v, want it reconnecting", s)
+	}
+
+	h.monitor.TriggerHealthCheck(context.Background())
+	waitFor(t, "the stream to be restarted", func() bool {
+		s := h.manager.GetStream("news")
+		return s != nil && s.GetState() == stream.StateRunning && s.FFmpegAlive()
+	})
+	h.waitReconnects(t)
+
+	s := h.manager.GetStream("news")
+	if s.GetFFmpegPID() == pid {
+		t.Errorf("stream kept the dead PID %d", pid)
+	}
+	if attempt, _ := s.GetRetry(); attempt != 0 {
+		t.Errorf("pending attempt %d after reconnecting, want none", attempt)
+	}
+}
+
+// startStream starts a stream and returns the PID of its ffmpeg
+func (h *harness) startStream(t *testing.T, name string) int {
+	t.Helper()
+	if err := h.manager.Start(context.Background(), "https://youtu.be/abc123", name, 0, stream.StartOptions{}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return h.manager.GetStream(name).GetFFmpegPID()
+}
+
+// checkRounds runs n health checks, as the monitor loop does every interval
+func (h *harness) checkRounds(n int) {
+	for range n {
+		h.monitor.TriggerHealthCheck(context.Background())
+	}
+}
+
+func TestHealthChecksRestartTheFailedComponent(t *testing.T) {
+	tests := []struct {
+		name string
+		// fail breaks the stream or the server after the stream started
+		fail             func(t *testing.T, h *harness, s *stream.Stream)
+		wantRestarts     int32
+		wantNewPublisher bool
+	}{
+		{
+			name: "both healthy",
+			fail: func(t *testing.T, h *harness, s *stream.Stream) {
+				s.SetProgress(storage.Progress{Speed: 1, UpdatedAt: time.Now()})
+			},
+		},
+		{
+			name: "publisher dead",
+			fail: func(t *testing.T, h *harness, s *stream.Stream) {
+				if err := syscall.Kill(-s.GetFFmpegPID(), syscall.SIGKILL); err != nil {
+					t.Fatal(err)
+				}
+				waitFor(t, "ffmpeg to die", func() bool { return !s.FFmpegAlive() })
+			},
+			wantNewPublisher: true,
+		},
+		{
+			name: "server wedged",
+			fail: func(t *testing.T, h *harness, s *stream.Stream) {
+				// ffmpeg keeps reporting output at full speed while
+				// MediaMTX takes no more data
+				h.api.freeze("news")
+				s.SetProgress(storage.Progress{Speed: 1, UpdatedAt: time.Now()})
+			},
+			wantRestarts: 1,
+			// Streams are restarted along with the server
+			wantNewPublisher: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			pid := h.startStream(t, "news")
+			tt.fail(t, h, h.manager.GetStream("news"))
+
+			// Enough rounds for a frozen path to count as stalled
+			h.checkRounds(h.monitor.config.StallThreshold + 1)
+			if tt.wantNewPublisher {
+				waitFor(t, "the stream to be restarted", func() bool {
+					s := h.manager.GetStream("news")
+					return s != nil && s.GetFFmpegPID() != pid && s.GetState() == stream.StateRunning
+				})
+			}
+			h.waitReconnects(t)
+
+			if got := h.server.restarts.Load(); got != tt.wantRestarts {
+				t.Errorf("mediamtx restarted %d times, want %d", got, tt.wantRestarts)
+			}
+			s := h.manager.GetStream("news")
+			if s == nil {
+				t.Fatal("stream is gone")
+			}
+			if !tt.wantNewPublisher && s.GetFFmpegPID() != pid {
+				t.Errorf("ffmpeg restarted (PID %d -> %d), want it left alone", pid, s.GetFFmpegPID())
+			}
+			if !s.FFmpegAlive() {
+				t.Error("stream has no running ffmpeg")
+			}
+		})
+	}
+}
+
+// killFFmpeg kills the ffmpeg of a stream, as a crash would
+func killFFmpeg(t *testing.T, s *stream.Stream) {
+	t.Helper()
+	if err := syscall.Kill(-s.GetFFmpegPID(), syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "ffmpeg to die", func() bool { return !s.FFmpegAlive() })
+}
+
+// assertStopped checks that a stream is stopped and that no ffmpeg
+// started for it still runs
+func (h *harness) assertStopped(t *testing.T, name string) {
+	t.Helper()
+	if s := h.manager.GetStream(name); s != nil {
+		t.Errorf("stream is back in state %s after being stopped", s.GetState())
+	}
+	data, err := h.store.Load(name)
+	if err != nil {
+		t.Fatalf("stored stream: %v", err)
+	}
+	if !data.Stopped || data.FFmpegPID != 0 {
+		t.Errorf("stored stream: stopped %v, ffmpeg PID %d, want stopped without one", data.Stopped, data.FFmpegPID)
+	}
+
+	pids, err := os.ReadFile(h.pids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range strings.Fields(string(pids)) {
+		pid, _ := strconv.Atoi(field)
+		if stream.IsProcessAlive(pid) {
+			t.Errorf("ffmpeg %d still runs after the stream was stopped", pid)
+		}
+	}
+}
+
+func TestStopDuringReconnect(t *testing.T) {
+	t.Run("while restarting", func(t *testing.T) {
+		h := newHarness(t)
+		h.startStream(t, "news")
+		release := h.api.hold("news")
+		defer release()
+
+		killFFmpeg(t, h.manager.GetStream("news"))
+		h.monitor.TriggerHealthCheck(context.Background())
+
+		// Stop while the restarted ffmpeg waits for MediaMTX
+		select {
+		case <-h.api.waiting:
+		case <-time.After(5 * time.Second):
+			t.Fatal("reconnect did not restart ffmpeg")
+		}
+		if err := h.manager.Stop("news"); err != nil {
+			t.Fatalf("Stop: %v", err)
+		}
+		release()
+		h.waitReconnects(t)
+
+		h.assertStopped(t, "news")
+	})
+
+	t.Run("between attempts", func(t *testing.T) {
+		h := newHarness(t)
+		h.monitor.config.Reconnect.InitialDelay = 200 * time.Millisecond
+		h.startStream(t, "news")
+
+		// Restarts fail until the path is released
+		h.cfg.FFmpeg.StartTimeout = 100 * time.Millisecond
+		release := h.api.hold("news")
+		killFFmpeg(t, h.manager.GetStream("news"))
+		h.monitor.TriggerHealthCheck(context.Background())
+
+		// Stop once the first attempt failed and the next one is pending
+		waitFor(t, "the first attempt to fail", func() bool {
+			s := h.manager.GetStream("news")
+			attempt, _ := s.GetRetry()
+			return attempt == 2
+		})
+		if err := h.manager.Stop("news"); err != nil {
+			t.Fatalf("Stop: %v", err)
+		}
+		release()
+		time.Sleep(2 * h.monitor.config.Reconnect.InitialDelay)
+		h.waitReconnects(t)
+
+		h.assertStopped(t, "news")
+	})
+}
+
+func TestForceReconnectLoadsStoredStream(t *testing.T) {
+	h := newHarness(t)
+
+	// A stream started by another process whose ffmpeg has since died
+	err := h.store.Save(&storage.StreamData{
+		ID:         "abc",
+		Name:       "news",
+		YouTubeURL: "https://youtu.be/abc123",
+		RTSPPath:   "/news",
+		Port:       8554,
+		FFmpegPID:  deadPID(t),
+		IsLive:     true,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.monitor.ForceReconnect(context.Background(), "news", nil); err != nil {
+		t.Fatalf("ForceReconnect: %v", err)
+	}
+	waitFor(t, "the stream to be restarted", func() bool {
+		s := h.manager.GetStream("news")
+		return s != nil && s.GetState() == stream.StateRunning && s.FFmpegAlive()
+	})
+	h.waitReconnects(t)
+
+	err = h.monitor.ForceReconnect(context.Background(), "missing", nil)
+	if !errors.Is(err, stream.ErrStreamNotFound) {
+		t.Errorf("ForceReconnect of an unknown stream = %v, want ErrStreamNotFound", err)
+	}
+}
+
+func TestForceReconnectReportsProgress(t *testing.T) {
+	h := newHarness(t)
+	h.startStream(t, "news")
+	old := h.manager.GetStream("news")
+	events := h.manager.Events()
+
+	// The first attempt times out waiting for MediaMTX, the second succeeds
+	h.cfg.FFmpeg.StartTimeout = 100 * time.Millisecond
+	release := h.api.hold("news")
+	defer release()
+
+	var mu sync.Mutex
+	var got []ReconnectProgress
+	done := make(chan struct{})
+	report := func(p ReconnectProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, p)
+		if p.Error != "" {
+			release()
+		}
+		if p.Done {
+			close(done)
+		}
+	}
+	if err := h.monitor.ForceReconnect(context.Background(), "news", report); err != nil {
+		t.Fatalf("ForceReconnect: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reconnect did not finish")
+	}
+	h.waitReconnects(t)
+
+	mu.Lock()
+	defer mu.Unlock()
+	max := h.cfg.Monitor.Reconnect.MaxAttempts
+	if len(got) != 4 {
+		t.Fatalf("progress = %+v, want 4 reports", got)
+	}
+	if got[0] != (ReconnectProgress{Attempt: 1, MaxAttempts: max}) {
+		t.Errorf("first report = %+v, want attempt 1 starting", got[0])
+	}
+	if got[1].Attempt != 1 || got[1].Error == "" {
+		t.Errorf("second report = %+v, want attempt 1 failing", got[1])
+	}
+	if got[2] != (ReconnectProgress{Attempt: 2, MaxAttempts: max}) {
+		t.Errorf("third report = %+v, want attempt 2 starting", got[2])
+	}
+	if got[3] != (ReconnectProgress{Done: true}) {
+		t.Errorf("last report = %+v, want done without an error", got[3])
+	}
+
+	// The stream now registered is the one running; the replaced one is
+	// left as the restart left it
+	s := h.manager.GetStream("news")
+	if s == old || s.GetState() != stream.StateRunning || s.GetConsecutiveErrors() != 0 {
+		t.Errorf("stream after reconnect: replaced %v, state %v, %d consecutive errors; want a new running stream without errors",
+			s != old, s.GetState(), s.GetConsecutiveErrors())
+	}
+	if state := old.GetState(); state == stream.StateRunning {
+		t.Errorf("replaced stream state = %v, want it no longer running", state)
+	}
+
+	// One start is announced, by the new stream
+	var started int
+	for len(events) > 0 {
+		if event := <-events; event.Type == stream.EventStarted {
+			started++
+		}
+	}
+	if started != 1 {
+		t.Errorf("%d started events after the reconnect, want 1", started)
+	}
+}
+
+func TestEndOfInput(t *testing.T) {
+	tests := []struct {
+		name          string
+		vod           bool
+		wantState     stream.State
+		wantPublisher int // ffmpeg processes started in all
+	}{
+		{name: "vod ends", vod: true, wantState: stream.StateIdle, wantPublisher: 1},
+		{name: "live is reconnected", vod: false, wantState: stream.StateRunning, wantPublisher: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			h.extractor.vod = tt.vod
+			h.startStream(t, "news")
+			s := h.manager.GetStream("news")
+			if s.GetIsLive() == tt.vod {
+				t.Fatalf("stream live = %v, want %v", s.GetIsLive(), !tt.vod)
+			}
+
+			// ffmpeg reaches the end of its input and exits with status 0;
+			// a restarted one would run on
+			if err := os.WriteFile(h.eof, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, "ffmpeg to exit", func() bool { return !s.FFmpegAlive() })
+			if err := os.Remove(h.eof); err != nil {
+				t.Fatal(err)
+			}
+
+			h.monitor.TriggerHealthCheck(context.Background())
+			waitFor(t, "the stream to settle", func() bool {
+				s := h.manager.GetStream("news")
+				return s != nil && s.GetState() == tt.wantState && (tt.vod || s.FFmpegAlive())
+			})
+			h.waitReconnects(t)
+
+			pids, err := os.ReadFile(h.pids)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(strings.Fields(string(pids))); got != tt.wantPublisher {
+				t.Errorf("%d ffmpeg processes started, want %d", got, tt.wantPublisher)
+			}
+			data, err := h.store.Load("news")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.vod {
+				if data.FFmpegPID != 0 || len(data.History) != 1 || data.History[0].Reason != "video ended" {
+					t.Errorf("stored stream: ffmpeg PID %d, history %+v; want no PID and one run ended by the video",
+						data.FFmpegPID, data.History)
+				}
+			}
+		})
+	}
+}
+
+func TestStallThreshold(t *testing.T) {
+	for _, threshold := range []int{1, 5} {
+		t.Run(strconv.Itoa(threshold), func(t *testing.T) {
+			h := newHarness(t)
+			h.monitor.config.StallThreshold = threshold
+			h.startStream(t, "news")
+			s := h.manager.GetStream("news")
+
+			// Data flows on the first check, then stops
+			if status := h.monitor.checkStreamHealth(s); !status.Healthy {
+				t.Fatalf("check with data flowing = %+v, want healthy", status)
+			}
+			h.api.freeze("news")
+			for i := 1; i < threshold; i++ {
+				if status := h.monitor.checkStreamHealth(s); !status.Healthy {
+					t.Fatalf("check %d without new data = %+v, want healthy below the threshold", i, status)
+				}
+			}
+			status := h.monitor.checkStreamHealth(s)
+			if status.Healthy || status.Reason != "stream stalled (no data flow)" {
+				t.Errorf("check %d without new data = %+v, want stalled", threshold, status)
+			}
+			if got := s.GetStallCount(); got != threshold {
+				t.Errorf("stall count %d, want %d", got, threshold)
+			}
+		})
+	}
+}
+
+func TestRecoveryThreshold(t *testing.T) {
+	h := newHarness(t)
+	h.monitor.config.RecoveryThreshold = 3
+	h.startStream(t, "news")
+	s := h.manager.GetStream("news")
+
+	s.IncrementErrorCount()
+	s.IncrementErrorCount()
+	h.checkRounds(2)
+	if got := s.GetConsecutiveErrors(); got != 2 {
+		t.Errorf("%d consecutive errors after 2 healthy checks, want 2 kept", got)
+	}
+
+	// An error in between starts the count again
+	s.IncrementErrorCount()
+	h.checkRounds(2)
+	if got := s.GetConsecutiveErrors(); got != 3 {
+		t.Errorf("%d consecutive errors after an error and 2 healthy checks, want 3 kept", got)
+	}
+	h.checkRounds(1)
+	if got := s.GetConsecutiveErrors(); got != 0 {
+		t.Errorf("%d consecutive errors after 3 healthy checks, want them cleared", got)
+	}
+}
+
+// readerLimitServer is a MediaMTX server allowing limit readers per path
+type readerLimitServer struct {
+	mediaServer
+	limit int
+}
+
+func (s readerLimitServer) MaxReaders() int {
+	return s.limit
+}
+
+func TestReaderLimitLoggedOnce(t *testing.T) {
+	var out strings.Builder
+	m := NewMonitor(&config.MonitorConfig{}, nil, nil, slog.New(slog.NewTextHandler(&out, nil)))
+	m.server = readerLimitServer{limit: 2}
+
+	for i, tt := range []struct {
+		readers  int
+		wantLogs int
+	}{
+		{1, 0},
+		{2, 1}, // reached
+		{3, 1}, // still full, not logged again
+		{1, 1},
+		{2, 2}, // reached again
+	} {
+		m.checkReaderLimit("news", tt.readers)
+		if got := strings.Count(out.String(), "reached the reader limit"); got != tt.wantLogs {
+			t.Fatalf("check %d with %d readers: logged %d times, want %d", i+1, tt.readers, got, tt.wantLogs)
+		}
+	}
+
+	// Unlimited
+	m.server = readerLimitServer{}
+	m.checkReaderLimit("weather", 100)
+	if got := strings.Count(out.String(), "reached the reader limit"); got != 2 {
+		t.Errorf("logged %d times without a limit, want no more", got)
+	}
+}
@@ -0,0 +1,55 @@
+package monitor
+
+import "time"
+
+// ReconnectProgress is a step of a stream's reconnect loop, reported to
+// callers of ForceReconnect
+type ReconnectProgress struct {
+	Attempt     int           `json:"attempt,omitempty"`
+	MaxAttempts int           `json:"max_attempts,omitempty"`
+	Delay       time.Duration `json:"delay,omitempty"` // backoff before the next attempt, after a failure
+	Error       string        `json:"error,omitempty"` // why the attempt, or with Done the reconnect, failed
+
+	// Done marks the last report: the stream reconnected if Error is empty
+	Done bool `json:"done,omitempty"`
+}
+
+// ProgressFunc receives the progress of a reconnect. It is called from the
+// reconnect loop, so it must not block for long.
+type ProgressFunc func(ReconnectProgress)
+
+// watchReconnect adds report to the receivers of the progress of the next
+// or current reconnect loop of a stream
+func (m *Monitor) watchReconnect(name string, report ProgressFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.watchers[name] = append(m.watchers[name], report)
+}
+
+// reportProgress sends a step of the reconnect loop of a stream to its
+// watchers
+func (m *Monitor) reportProgress(name string, p ReconnectProgress) {
+	m.mu.Lock()
+	watchers := m.watchers[name]
+	m.mu.Unlock()
+	for _, report := range watchers {
+		report(p)
+	}
+}
+
+// reportDone ends the reconnect loop of a stream for its watchers, with
+// the error it failed with (nil = reconnected)
+func (m *Monitor) reportDone(name string, err error) {
+	m.mu.Lock()
+	watchers := m.watchers[name]
+	delete(m.watchers, name)
+	m.mu.Unlock()
+
+	p := ReconnectProgress{Done: true}
+	if err != nil {
+		p.Error = err.Error()
+	}
+	for _, report := range watchers {
+		report(p)
+	}
+}
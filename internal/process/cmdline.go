@@ -0,0 +1,46 @@
+package process
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Info is a running process and its command line
+type Info struct {
+	PID  int
+	Args []string
+}
+
+// cmdlineFromPS reads the command line of pid with ps. Arguments are split
+// on whitespace, so ones containing spaces are not preserved.
+func cmdlineFromPS(pid int) ([]string, error) {
+	output, err := exec.Command("ps", "-o", "args=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// listFromPS lists processes with ps, splitting arguments as cmdlineFromPS
+// does
+func listFromPS() ([]Info, error) {
+	output, err := exec.Command("ps", "-e", "-o", "pid=,args=").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var procs []Info
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		procs = append(procs, Info{PID: pid, Args: fields[1:]})
+	}
+	return procs, nil
+}
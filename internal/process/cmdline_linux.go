@@ -0,0 +1,57 @@
+//go:build linux
+
+package process
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Cmdline returns the command line of pid from /proc/<pid>/cmdline, or
+// from ps if /proc is not mounted
+func Cmdline(pid int) ([]string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		if _, statErr := os.Stat("/proc/self"); statErr != nil {
+			return cmdlineFromPS(pid)
+		}
+		return nil, err
+	}
+	return splitCmdline(data), nil
+}
+
+// List returns the running processes from /proc, or from ps if /proc is
+// not mounted. Processes that exit while listing are left out.
+func List() ([]Info, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return listFromPS()
+	}
+
+	var procs []Info
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+		if err != nil || len(data) == 0 {
+			// Gone, or a kernel thread
+			continue
+		}
+		procs = append(procs, Info{PID: pid, Args: splitCmdline(data)})
+	}
+	return procs, nil
+}
+
+// splitCmdline splits the NUL-separated arguments of /proc/<pid>/cmdline
+func splitCmdline(data []byte) []string {
+	data = bytes.TrimRight(data, "\x00")
+	var args []string
+	for _, arg := range bytes.Split(data, []byte{0}) {
+		args = append(args, string(arg))
+	}
+	return args
+}
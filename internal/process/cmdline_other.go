@@ -0,0 +1,13 @@
+//go:build !linux
+
+package process
+
+// Cmdline returns the command line of pid, read with ps
+func Cmdline(pid int) ([]string, error) {
+	return cmdlineFromPS(pid)
+}
+
+// List returns the running processes, read with ps
+func List() ([]Info, error) {
+	return listFromPS()
+}
@@ -0,0 +1,43 @@
+// Package process signals and identifies the child processes this program
+// starts. ffmpeg and MediaMTX are started in their own process groups
+// (Setpgid), so that helpers they spawn can be signalled along with them.
+package process
+
+import (
+	"errors"
+	"syscall"
+)
+
+// OwnsGroup reports whether pid leads a process group other than ours, as
+// a child started with Setpgid does. Only such groups are safe to signal
+// as a whole.
+func OwnsGroup(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	pgid, err := syscall.Getpgid(pid)
+	return err == nil && pgid == pid && pgid != syscall.Getpgrp()
+}
+
+// Signal sends sig to pid, or to every process in the group pid leads if
+// group is set. A process or group that no longer exists is not an error.
+func Signal(pid int, group bool, sig syscall.Signal) error {
+	target := pid
+	if group {
+		target = -pid
+	}
+	if err := syscall.Kill(target, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	return nil
+}
+
+// Alive reports whether pid, or any process in the group it leads if group
+// is set, still exists
+func Alive(pid int, group bool) bool {
+	target := pid
+	if group {
+		target = -pid
+	}
+	return syscall.Kill(target, 0) == nil
+}
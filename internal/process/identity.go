@@ -0,0 +1,17 @@
+package process
+
+// Matches reports whether pid is still the process that had startTime when
+// it was recorded. It is true if either start time is unknown, so PIDs
+// recorded by older versions or on platforms without StartTime are
+// trusted as before.
+func Matches(pid int, startTime uint64) bool {
+	if startTime == 0 {
+		return true
+	}
+	current, err := StartTime(pid)
+	if err != nil {
+		// Gone, or unknown on this platform; liveness is checked separately
+		return true
+	}
+	return current == startTime
+}
@@ -0,0 +1,39 @@
+//go:build linux
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// StartTime returns when pid started, in clock ticks since boot, read from
+// /proc/<pid>/stat. Together with the PID it identifies a process: a PID
+// reused after the process exited or the host rebooted has a different
+// start time.
+func StartTime(pid int) (uint64, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	return parseStartTime(string(data))
+}
+
+// parseStartTime reads the starttime field (the 22nd) of a /proc/<pid>/stat
+// line. The command name in the 2nd field may contain spaces and
+// parentheses, so fields are counted from its closing parenthesis.
+func parseStartTime(stat string) (uint64, error) {
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, fmt.Errorf("malformed stat: no command name")
+	}
+	// Fields after the command name start with the 3rd (state)
+	fields := strings.Fields(stat[end+1:])
+	const startTimeField = 22 - 3
+	if len(fields) <= startTimeField {
+		return 0, fmt.Errorf("malformed stat: %d fields", len(fields)+2)
+	}
+	return strconv.ParseUint(fields[startTimeField], 10, 64)
+}
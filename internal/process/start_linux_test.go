@@ -0,0 +1,92 @@
+//go:build linux
+
+package process
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestParseStartTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		stat    string
+		want    uint64
+		wantErr bool
+	}{
+		{
+			name: "plain command",
+			stat: "4242 (ffmpeg) S 1 4242 4242 0 -1 4194560 1234 0 0 0 50 10 0 0 20 0 1 0 987654 123456789 2048 18446744073709551615",
+			want: 987654,
+		},
+		{
+			name: "command with spaces and parentheses",
+			stat: "77 (my (odd) cmd) R 1 77 77 0 -1 0 0 0 0 0 0 0 0 0 20 0 1 0 1122334455 0 0",
+			want: 1122334455,
+		},
+		{
+			name: "trailing newline",
+			stat: "1 (init) S 0 1 1 0 -1 0 0 0 0 0 0 0 0 0 20 0 1 0 3 0 0\n",
+			want: 3,
+		},
+		{
+			name:    "no command name",
+			stat:    "4242 ffmpeg S 1",
+			wantErr: true,
+		},
+		{
+			name:    "too few fields",
+			stat:    "4242 (ffmpeg) S 1 4242",
+			wantErr: true,
+		},
+		{
+			name:    "start time not a number",
+			stat:    "4242 (ffmpeg) S 1 4242 4242 0 -1 0 0 0 0 0 0 0 0 0 20 0 1 0 soon 0 0",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStartTime(tt.stat)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseStartTime = %d, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("parseStartTime = %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	self := os.Getpid()
+	start, err := StartTime(self)
+	if err != nil {
+		t.Fatalf("StartTime(self): %v", err)
+	}
+
+	if !Matches(self, start) {
+		t.Error("Matches with the current start time = false")
+	}
+	if Matches(self, start+1) {
+		t.Error("Matches with another start time = true, want a reused PID detected")
+	}
+	if !Matches(self, 0) {
+		t.Error("Matches with an unknown start time = false")
+	}
+}
+
+func TestMatchesExitedProcess(t *testing.T) {
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("cannot run true: %v", err)
+	}
+	// Liveness is checked separately, so a gone process is not a mismatch
+	if !Matches(cmd.Process.Pid, 12345) {
+		t.Error("Matches of an exited process = false")
+	}
+}
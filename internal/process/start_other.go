@@ -0,0 +1,14 @@
+//go:build !linux
+
+package process
+
+import (
+	"errors"
+	"runtime"
+)
+
+// StartTime is not supported on this platform. Callers treat its error as
+// "unknown" and trust the PID alone.
+func StartTime(pid int) (uint64, error) {
+	return 0, errors.New("process start time is not available on " + runtime.GOOS)
+}
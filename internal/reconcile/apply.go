@@ -0,0 +1,77 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+)
+
+// Manager is the part of stream.Manager that applying a plan uses
+type Manager interface {
+	Start(ctx context.Context, youtubeURL, name string, port int, opts stream.StartOptions) error
+	StartOnDemand(youtubeURL, name string, port int, opts stream.StartOptions) error
+	Stop(name string) error
+	Remove(name string) error
+}
+
+// Apply carries out the plan: declared streams are started, changed ones
+// restarted and managed streams no longer declared are removed. Every
+// action is attempted; the failures are returned together. Progress is
+// written to w.
+func (p *Plan) Apply(ctx context.Context, m Manager, w io.Writer) error {
+	var errs []error
+	for _, a := range p.Actions {
+		var err error
+		switch a.Type {
+		case ActionStart:
+			fmt.Fprintf(w, "  + %s: starting\n", a.Name)
+			err = startDefinition(ctx, m, a.Definition)
+		case ActionRestart:
+			fmt.Fprintf(w, "  ~ %s: restarting\n", a.Name)
+			if err = m.Stop(a.Name); err == nil {
+				err = startDefinition(ctx, m, a.Definition)
+			}
+		case ActionPrune:
+			fmt.Fprintf(w, "  - %s: removing\n", a.Name)
+			err = m.Remove(a.Name)
+		default:
+			continue
+		}
+
+		if err != nil {
+			fmt.Fprintf(w, "    failed: %v\n", err)
+			errs = append(errs, fmt.Errorf("%s: %w", a.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// startDefinition starts a declared stream as managed
+func startDefinition(ctx context.Context, m Manager, def *config.StreamDefinition) error {
+	maxBitrate, err := config.ParseBitrate(def.MaxBitrate)
+	if err != nil {
+		return err
+	}
+	opts := stream.StartOptions{
+		AudioOnly: def.AudioOnly,
+		Loop:      def.Loop,
+		Transport: def.Transport,
+		Managed:   true,
+		Format:    def.Format,
+
+		MaxBitrate: maxBitrate,
+		Outputs:    def.Outputs,
+		Substream:  def.Substream,
+
+		RequireH264: def.RequireH264,
+		AudioCopy:   def.AudioCopy,
+	}
+	if def.OnDemand {
+		return m.StartOnDemand(def.URL, def.Name, def.Port, opts)
+	}
+	return m.Start(ctx, def.URL, def.Name, def.Port, opts)
+}
@@ -0,0 +1,186 @@
+package reconcile
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+)
+
+// ActionType is the kind of change a reconciliation would make
+type ActionType string
+
+const (
+	ActionStart   ActionType = "start"   // declared but not running
+	ActionManual  ActionType = "manual"  // declared without autostart and not running
+	ActionStopped ActionType = "stopped" // declared but stopped by hand
+	ActionKeep    ActionType = "keep"    // running and matching the declaration
+	ActionRestart ActionType = "restart" // running with options differing from the declaration
+	ActionPrune   ActionType = "prune"   // managed stream no longer declared
+	ActionIgnore  ActionType = "ignore"  // ad-hoc stream not managed by the config
+)
+
+// Action is a single planned change for one stream
+type Action struct {
+	Type        ActionType
+	Name        string
+	Definition  *config.StreamDefinition
+	Differences []string
+}
+
+// Plan is the set of actions needed to reconcile running streams with the
+// declared ones
+type Plan struct {
+	Actions []Action
+}
+
+// Compute builds a plan from the declared streams and the currently known
+// streams, stopped ones included. Only streams previously started from the
+// config (Managed) are pruned; ad-hoc streams are left alone. Declared
+// streams stopped by hand stay stopped unless force is set.
+func Compute(defs []config.StreamDefinition, current []stream.Info, defaultPort int, force bool) *Plan {
+	running := make(map[string]stream.Info, len(current))
+	for _, info := range current {
+		running[info.Name] = info
+	}
+
+	plan := &Plan{}
+	declared := make(map[string]bool, len(defs))
+
+	for i := range defs {
+		def := defs[i]
+		if def.Port == 0 {
+			def.Port = defaultPort
+		}
+		declared[def.Name] = true
+
+		info, exists := running[def.Name]
+		stopped := exists && info.StateString == stream.StateStopped.String()
+		if stopped && !force {
+			plan.Actions = append(plan.Actions, Action{Type: ActionStopped, Name: def.Name, Definition: &def})
+			continue
+		}
+		if !exists || stopped {
+			actionType := ActionStart
+			if !def.ShouldAutostart() {
+				actionType = ActionManual
+			}
+			plan.Actions = append(plan.Actions, Action{Type: actionType, Name: def.Name, Definition: &def})
+			continue
+		}
+
+		diffs := differences(&def, info)
+		if len(diffs) > 0 {
+			plan.Actions = append(plan.Actions, Action{Type: ActionRestart, Name: def.Name, Definition: &def, Differences: diffs})
+		} else {
+			plan.Actions = append(plan.Actions, Action{Type: ActionKeep, Name: def.Name, Definition: &def})
+		}
+	}
+
+	for _, info := range current {
+		if declared[info.Name] {
+			continue
+		}
+		if info.Managed {
+			plan.Actions = append(plan.Actions, Action{Type: ActionPrune, Name: info.Name})
+		} else {
+			plan.Actions = append(plan.Actions, Action{Type: ActionIgnore, Name: info.Name})
+		}
+	}
+
+	sort.SliceStable(plan.Actions, func(i, j int) bool {
+		return plan.Actions[i].Name < plan.Actions[j].Name
+	})
+
+	return plan
+}
+
+// differences lists how a running stream differs from its declaration
+func differences(def *config.StreamDefinition, info stream.Info) []string {
+	// The config is validated, so the bitrate parses
+	maxBitrate, _ := config.ParseBitrate(def.MaxBitrate)
+
+	var diffs []string
+	if def.URL != info.YouTubeURL {
+		diffs = append(diffs, fmt.Sprintf("url: %s -> %s", info.YouTubeURL, def.URL))
+	}
+	if def.Port != info.Port {
+		diffs = append(diffs, fmt.Sprintf("port: %d -> %d", info.Port, def.Port))
+	}
+	if def.AudioOnly != info.AudioOnly {
+		diffs = append(diffs, fmt.Sprintf("audio_only: %v -> %v", info.AudioOnly, def.AudioOnly))
+	}
+	if def.Loop != info.Loop {
+		diffs = append(diffs, fmt.Sprintf("loop: %v -> %v", info.Loop, def.Loop))
+	}
+	if def.OnDemand != info.OnDemand {
+		diffs = append(diffs, fmt.Sprintf("on_demand: %v -> %v", info.OnDemand, def.OnDemand))
+	}
+	// An empty transport means the configured default, which the stream
+	// recorded when it started
+	if def.Transport != "" && def.Transport != info.Transport {
+		diffs = append(diffs, fmt.Sprintf("transport: %s -> %s", info.Transport, def.Transport))
+	}
+	if def.Format != info.YtdlpFormat {
+		diffs = append(diffs, fmt.Sprintf("format: %q -> %q", info.YtdlpFormat, def.Format))
+	}
+	if maxBitrate != info.MaxBitrate {
+		diffs = append(diffs, fmt.Sprintf("max_bitrate: %d -> %d", info.MaxBitrate, maxBitrate))
+	}
+	if !slices.Equal(def.Outputs, info.Outputs) {
+		diffs = append(diffs, fmt.Sprintf("outputs: %v -> %v", info.Outputs, def.Outputs))
+	}
+	if def.Substream != info.Substream {
+		diffs = append(diffs, fmt.Sprintf("substream: %q -> %q", info.Substream, def.Substream))
+	}
+	if def.AudioCopy != info.AudioCopy {
+		diffs = append(diffs, fmt.Sprintf("audio_copy: %v -> %v", info.AudioCopy, def.AudioCopy))
+	}
+	if def.RequireH264 != info.RequireH264 {
+		diffs = append(diffs, fmt.Sprintf("require_h264: %v -> %v", info.RequireH264, def.RequireH264))
+	}
+	return diffs
+}
+
+// HasChanges reports whether applying the plan would change anything
+func (p *Plan) HasChanges() bool {
+	for _, a := range p.Actions {
+		if a.Type == ActionStart || a.Type == ActionRestart || a.Type == ActionPrune {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes a diff-style summary of the plan
+func (p *Plan) Print(w io.Writer) {
+	if len(p.Actions) == 0 {
+		fmt.Fprintln(w, "  No streams declared or running.")
+		return
+	}
+
+	for _, a := range p.Actions {
+		switch a.Type {
+		case ActionStart:
+			fmt.Fprintf(w, "  + %-20s start (%s)\n", a.Name, a.Definition.URL)
+		case ActionManual:
+			fmt.Fprintf(w, "    %-20s not started (autostart: false)\n", a.Name)
+		case ActionStopped:
+			fmt.Fprintf(w, "    %-20s stopped by hand, left stopped (apply --force starts it)\n", a.Name)
+		case ActionKeep:
+			fmt.Fprintf(w, "  = %-20s running, matches declaration\n", a.Name)
+		case ActionRestart:
+			fmt.Fprintf(w, "  ~ %-20s restart\n", a.Name)
+			for _, d := range a.Differences {
+				fmt.Fprintf(w, "      %s\n", d)
+			}
+		case ActionPrune:
+			fmt.Fprintf(w, "  - %-20s remove (no longer declared)\n", a.Name)
+		case ActionIgnore:
+			fmt.Fprintf(w, "    %-20s not managed by config, left alone\n", a.Name)
+		}
+	}
+}
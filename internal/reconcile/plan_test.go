@@ -0,0 +1,219 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/stream"
+)
+
+// fakeManager keeps streams in memory and records the calls Apply makes
+type fakeManager struct {
+	streams map[string]stream.Info
+	calls   []string
+}
+
+func newFakeManager(current []stream.Info) *fakeManager {
+	m := &fakeManager{streams: make(map[string]stream.Info)}
+	for _, info := range current {
+		m.streams[info.Name] = info
+	}
+	return m
+}
+
+func (m *fakeManager) start(youtubeURL, name string, port int, opts stream.StartOptions) {
+	m.streams[name] = stream.Info{
+		Name:        name,
+		YouTubeURL:  youtubeURL,
+		Port:        port,
+		Managed:     opts.Managed,
+		AudioOnly:   opts.AudioOnly,
+		Loop:        opts.Loop,
+		Transport:   opts.Transport,
+		YtdlpFormat: opts.Format,
+		MaxBitrate:  opts.MaxBitrate,
+		Outputs:     opts.Outputs,
+		Substream:   opts.Substream,
+		RequireH264: opts.RequireH264,
+		AudioCopy:   opts.AudioCopy,
+		StateString: stream.StateRunning.String(),
+	}
+}
+
+func (m *fakeManager) Start(ctx context.Context, youtubeURL, name string, port int, opts stream.StartOptions) error {
+	m.calls = append(m.calls, "start "+name)
+	m.start(youtubeURL, name, port, opts)
+	return nil
+}
+
+func (m *fakeManager) StartOnDemand(youtubeURL, name string, port int, opts stream.StartOptions) error {
+	m.calls = append(m.calls, "start "+name)
+	m.start(youtubeURL, name, port, opts)
+	info := m.streams[name]
+	info.OnDemand = true
+	m.streams[name] = info
+	return nil
+}
+
+func (m *fakeManager) Stop(name string) error {
+	m.calls = append(m.calls, "stop "+name)
+	info, ok := m.streams[name]
+	if !ok {
+		return fmt.Errorf("stream '%s' not found", name)
+	}
+	info.StateString = stream.StateStopped.String()
+	m.streams[name] = info
+	return nil
+}
+
+func (m *fakeManager) Remove(name string) error {
+	m.calls = append(m.calls, "remove "+name)
+	delete(m.streams, name)
+	return nil
+}
+
+func (m *fakeManager) list() []stream.Info {
+	var list []stream.Info
+	for _, info := range m.streams {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// expectedCalls lists the manager calls a plan says it will make
+func expectedCalls(p *Plan) []string {
+	var calls []string
+	for _, a := range p.Actions {
+		switch a.Type {
+		case ActionStart:
+			calls = append(calls, "start "+a.Name)
+		case ActionRestart:
+			calls = append(calls, "stop "+a.Name, "start "+a.Name)
+		case ActionPrune:
+			calls = append(calls, "remove "+a.Name)
+		}
+	}
+	return calls
+}
+
+func actionTypes(p *Plan) map[string]ActionType {
+	types := make(map[string]ActionType)
+	for _, a := range p.Actions {
+		types[a.Name] = a.Type
+	}
+	return types
+}
+
+func running(name, url string) stream.Info {
+	return stream.Info{Name: name, YouTubeURL: url, Port: 8554, Managed: true, StateString: stream.StateRunning.String()}
+}
+
+func testState() ([]config.StreamDefinition, []stream.Info) {
+	no := false
+	defs := []config.StreamDefinition{
+		{Name: "new", URL: "https://youtu.be/new", MaxBitrate: "2M", Outputs: []string{"rtsp://relay/new"}},
+		{Name: "lazy", URL: "https://youtu.be/lazy", OnDemand: true},
+		{Name: "same", URL: "https://youtu.be/same"},
+		{Name: "moved", URL: "https://youtu.be/moved-v2"},
+		{Name: "rebitrated", URL: "https://youtu.be/rb", MaxBitrate: "1500k"},
+		{Name: "held", URL: "https://youtu.be/held"},
+		{Name: "manual", URL: "https://youtu.be/manual", Autostart: &no},
+	}
+	held := running("held", "https://youtu.be/held")
+	held.StateString = stream.StateStopped.String()
+	adhoc := running("adhoc", "https://youtu.be/adhoc")
+	adhoc.Managed = false
+	current := []stream.Info{
+		running("same", "https://youtu.be/same"),
+		running("moved", "https://youtu.be/moved-v1"),
+		running("rebitrated", "https://youtu.be/rb"),
+		running("gone", "https://youtu.be/gone"),
+		held,
+		adhoc,
+	}
+	return defs, current
+}
+
+func TestCompute(t *testing.T) {
+	defs, current := testState()
+	plan := Compute(defs, current, 8554, false)
+
+	want := map[string]ActionType{
+		"new":        ActionStart,
+		"lazy":       ActionStart,
+		"same":       ActionKeep,
+		"moved":      ActionRestart,
+		"rebitrated": ActionRestart,
+		"held":       ActionStopped,
+		"manual":     ActionManual,
+		"gone":       ActionPrune,
+		"adhoc":      ActionIgnore,
+	}
+	got := actionTypes(plan)
+	for name, typ := range want {
+		if got[name] != typ {
+			t.Errorf("%s: action %q, want %q", name, got[name], typ)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("plan has %d actions, want %d", len(got), len(want))
+	}
+
+	for _, a := range plan.Actions {
+		if a.Name == "rebitrated" && !slices.Equal(a.Differences, []string{"max_bitrate: 0 -> 1500000"}) {
+			t.Errorf("rebitrated differences = %q", a.Differences)
+		}
+	}
+}
+
+func TestComputeForceStartsStopped(t *testing.T) {
+	defs, current := testState()
+	if typ := actionTypes(Compute(defs, current, 8554, true))["held"]; typ != ActionStart {
+		t.Errorf("held with force: action %q, want start", typ)
+	}
+}
+
+func TestApplyMatchesPlan(t *testing.T) {
+	for _, force := range []bool{false, true} {
+		t.Run(fmt.Sprintf("force=%v", force), func(t *testing.T) {
+			defs, current := testState()
+			m := newFakeManager(current)
+
+			plan := Compute(defs, m.list(), 8554, force)
+			if err := plan.Apply(context.Background(), m, io.Discard); err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+
+			// Apply makes exactly the calls the plan announced, in order
+			if want := expectedCalls(plan); !slices.Equal(m.calls, want) {
+				t.Errorf("Apply made calls\n  %s\nwant the planned\n  %s",
+					strings.Join(m.calls, ", "), strings.Join(want, ", "))
+			}
+
+			// and leaves nothing for a second plan to change
+			again := Compute(defs, m.list(), 8554, force)
+			if again.HasChanges() {
+				var sb strings.Builder
+				again.Print(&sb)
+				t.Errorf("plan after Apply still has changes:\n%s", sb.String())
+			}
+		})
+	}
+}
+
+func TestApplyReportsFailures(t *testing.T) {
+	defs := []config.StreamDefinition{{Name: "bad", URL: "https://youtu.be/bad", MaxBitrate: "fast"}}
+	m := newFakeManager(nil)
+
+	err := Compute(defs, nil, 8554, false).Apply(context.Background(), m, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "bad:") {
+		t.Errorf("Apply = %v, want an error naming the stream", err)
+	}
+}
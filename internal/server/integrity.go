@@ -0,0 +1,153 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// integrityFile is the name of the file (in the data dir) recording the
+// verified MediaMTX binary. It is also written by scripts/install.sh.
+const integrityFile = "mediamtx.integrity"
+
+// BinaryRecord describes a verified MediaMTX binary
+type BinaryRecord struct {
+	SHA256  string
+	Version string
+	Path    string
+}
+
+// SetTrustBinary allows Start to accept (and record) a MediaMTX binary whose
+// hash differs from the previously recorded one
+func (s *MediaMTXServer) SetTrustBinary(trust bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trustBinary = trust
+}
+
+// verifyBinary checks the MediaMTX binary against the recorded hash.
+// The first verified binary is recorded (trust on first use); afterwards a
+// changed hash is refused unless trustBinary is set.
+func (s *MediaMTXServer) verifyBinary() error {
+	binaryPath, err := exec.LookPath(s.config.BinaryPath)
+	if err != nil {
+		return fmt.Errorf("mediamtx not found: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(binaryPath); err == nil {
+		binaryPath = resolved
+	}
+
+	hash, err := fileSHA256(binaryPath)
+	if err != nil {
+		return fmt.Errorf("failed to hash mediamtx binary: %w", err)
+	}
+
+	recordPath := filepath.Join(s.dataDir, integrityFile)
+	record, err := readBinaryRecord(recordPath)
+	if err == nil && record.SHA256 == hash {
+		return nil
+	}
+
+	if err == nil && !s.trustBinary {
+		return fmt.Errorf("mediamtx binary %s has changed (recorded sha256 %s, now %s); "+
+			"accept it with 'youtube-rtsp-proxy server start --trust' or set mediamtx.verify_checksum: false",
+			binaryPath, record.SHA256, hash)
+	}
+
+	if err == nil {
+		s.log.Warn("accepting changed mediamtx binary", "path", binaryPath, "sha256", hash)
+	}
+
+	version, _ := s.CheckBinary()
+	return writeBinaryRecord(recordPath, &BinaryRecord{
+		SHA256:  hash,
+		Version: version,
+		Path:    binaryPath,
+	})
+}
+
+// readBinaryRecord reads an integrity record file
+func readBinaryRecord(path string) (*BinaryRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var record BinaryRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "sha256":
+			record.SHA256 = strings.ToLower(value)
+		case "version":
+			record.Version = value
+		case "path":
+			record.Path = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if record.SHA256 == "" {
+		return nil, fmt.Errorf("invalid integrity record: %s", path)
+	}
+	return &record, nil
+}
+
+// writeBinaryRecord writes an integrity record file
+func writeBinaryRecord(path string, record *BinaryRecord) error {
+	content := fmt.Sprintf("sha256=%s\nversion=%s\npath=%s\n", record.SHA256, record.Version, record.Path)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write integrity record: %w", err)
+	}
+	return nil
+}
+
+// WriteSegmentChecksum writes the SHA-256 of a finished recording segment
+// to <segment>.sha256, in the format of sha256sum, so archival tooling can
+// detect segments truncated by a crash
+func WriteSegmentChecksum(segmentPath string) error {
+	hash, err := fileSHA256(segmentPath)
+	if err != nil {
+		return fmt.Errorf("failed to hash segment: %w", err)
+	}
+
+	sidecar := segmentPath + ".sha256"
+	content := fmt.Sprintf("%s  %s\n", hash, filepath.Base(segmentPath))
+	tmp := sidecar + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write checksum: %w", err)
+	}
+	if err := os.Rename(tmp, sidecar); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write checksum: %w", err)
+	}
+	return nil
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 of a file
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+)
+
+// newTestServer returns a server for a fake mediamtx binary printing version
+func newTestServer(t *testing.T, version string) (*MediaMTXServer, string) {
+	t.Helper()
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "mediamtx")
+	writeFakeBinary(t, binary, version)
+
+	cfg := &config.MediaMTXConfig{BinaryPath: binary, VerifyChecksum: true, LogLevel: "info"}
+	serverCfg := &config.ServerConfig{RTSPPort: 8554, APIPort: 9997}
+	dataDir := filepath.Join(dir, "data")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewMediaMTXServer(cfg, serverCfg, dataDir, log), binary
+}
+
+func writeFakeBinary(t *testing.T, path, version string) {
+	t.Helper()
+	script := "#!/bin/sh\necho " + version + "\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sha256Hex(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestVerifyBinaryRecordsFirstUse(t *testing.T) {
+	s, binary := newTestServer(t, "v1.9.0")
+
+	if err := s.verifyBinary(); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+
+	record, err := readBinaryRecord(filepath.Join(s.dataDir, integrityFile))
+	if err != nil {
+		t.Fatalf("record not written: %v", err)
+	}
+	if want := sha256Hex(t, binary); record.SHA256 != want {
+		t.Errorf("recorded sha256 %s, want %s", record.SHA256, want)
+	}
+	if record.Version != "v1.9.0" {
+		t.Errorf("recorded version %q, want v1.9.0", record.Version)
+	}
+
+	// The same binary passes again
+	if err := s.verifyBinary(); err != nil {
+		t.Errorf("unchanged binary refused: %v", err)
+	}
+}
+
+func TestVerifyBinaryRefusesChangedBinary(t *testing.T) {
+	s, binary := newTestServer(t, "v1.9.0")
+	if err := s.verifyBinary(); err != nil {
+		t.Fatal(err)
+	}
+	recorded := sha256Hex(t, binary)
+
+	writeFakeBinary(t, binary, "v1.9.1")
+	err := s.verifyBinary()
+	if err == nil {
+		t.Fatal("changed binary accepted without --trust")
+	}
+	if !strings.Contains(err.Error(), "server start --trust") {
+		t.Errorf("error %q does not point at server start --trust", err)
+	}
+
+	record, err := readBinaryRecord(filepath.Join(s.dataDir, integrityFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.SHA256 != recorded {
+		t.Errorf("refused binary overwrote the record")
+	}
+}
+
+func TestVerifyBinaryTrustAcceptsChangedBinary(t *testing.T) {
+	s, binary := newTestServer(t, "v1.9.0")
+	if err := s.verifyBinary(); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFakeBinary(t, binary, "v1.9.1")
+	s.SetTrustBinary(true)
+	if err := s.verifyBinary(); err != nil {
+		t.Fatalf("trusted binary refused: %v", err)
+	}
+
+	record, err := readBinaryRecord(filepath.Join(s.dataDir, integrityFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := sha256Hex(t, binary); record.SHA256 != want {
+		t.Errorf("recorded sha256 %s, want the trusted %s", record.SHA256, want)
+	}
+	if record.Version != "v1.9.1" {
+		t.Errorf("recorded version %q, want v1.9.1", record.Version)
+	}
+
+	// Once recorded, the new binary passes without trust
+	s.SetTrustBinary(false)
+	if err := s.verifyBinary(); err != nil {
+		t.Errorf("recorded binary refused: %v", err)
+	}
+}
+
+func TestWriteSegmentChecksum(t *testing.T) {
+	segment := filepath.Join(t.TempDir(), "2026-10-16_10-00-00-000000.mp4")
+	if err := os.WriteFile(segment, []byte("segment data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteSegmentChecksum(segment); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(segment + ".sha256")
+	if err != nil {
+		t.Fatalf("sidecar not written: %v", err)
+	}
+	want := sha256Hex(t, segment) + "  2026-10-16_10-00-00-000000.mp4\n"
+	if string(data) != want {
+		t.Errorf("sidecar = %q, want %q", data, want)
+	}
+	if _, err := os.Stat(segment + ".sha256.tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary sidecar left behind")
+	}
+}
+
+func TestWriteSegmentChecksumMissingSegment(t *testing.T) {
+	segment := filepath.Join(t.TempDir(), "missing.mp4")
+	if err := WriteSegmentChecksum(segment); err == nil {
+		t.Fatal("no error for a missing segment")
+	}
+	if _, err := os.Stat(segment + ".sha256"); !os.IsNotExist(err) {
+		t.Errorf("sidecar written for a missing segment")
+	}
+}
+
+func TestEnsureConfigRecording(t *testing.T) {
+	tests := []struct {
+		name      string
+		record    bool
+		checksums bool
+		want      []string
+		notWant   []string
+	}{
+		{
+			name:      "checksums",
+			record:    true,
+			checksums: true,
+			want: []string{
+				"record: yes",
+				"recordSegmentDuration: 1h0m0s",
+				`runOnRecordSegmentComplete: '/bin/proxy _seal "$MTX_SEGMENT_PATH"'`,
+			},
+		},
+		{
+			name:    "no checksums",
+			record:  true,
+			want:    []string{"record: yes"},
+			notWant: []string{"runOnRecordSegmentComplete"},
+		},
+		{
+			name:      "not recording",
+			checksums: true,
+			notWant:   []string{"record", "pathDefaults"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, "v1.9.0")
+			s.config.Record = tt.record
+			s.config.RecordChecksums = tt.checksums
+			s.config.RecordSegmentDuration = time.Hour
+			s.SetSegmentCommand(`/bin/proxy _seal "$MTX_SEGMENT_PATH"`)
+
+			path := filepath.Join(s.dataDir, "mediamtx.yml")
+			if err := s.ensureConfig(path); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			yml := string(data)
+
+			if tt.record {
+				want := "recordPath: '" + filepath.Join(s.dataDir, "recordings", "%path", "%Y-%m-%d_%H-%M-%S-%f") + "'"
+				if !strings.Contains(yml, want) {
+					t.Errorf("missing %q in:\n%s", want, yml)
+				}
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(yml, want) {
+					t.Errorf("missing %q in:\n%s", want, yml)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(yml, notWant) {
+					t.Errorf("unexpected %q in:\n%s", notWant, yml)
+				}
+			}
+		})
+	}
+}
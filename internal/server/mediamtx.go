@@ -1,10 +1,12 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
@@ -15,6 +17,7 @@ import (
 	"time"
 
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/process"
 )
 
 // MediaMTXServer manages the MediaMTX RTSP server process
@@ -29,15 +32,20 @@ type MediaMTXServer struct {
 	pidFile    string
 	running    bool
 	cancel     context.CancelFunc
+
+	trustBinary    bool
+	segmentCommand string
+	log            *slog.Logger
 }
 
 // NewMediaMTXServer creates a new MediaMTX server manager
-func NewMediaMTXServer(cfg *config.MediaMTXConfig, serverCfg *config.ServerConfig, dataDir string) *MediaMTXServer {
+func NewMediaMTXServer(cfg *config.MediaMTXConfig, serverCfg *config.ServerConfig, dataDir string, log *slog.Logger) *MediaMTXServer {
 	return &MediaMTXServer{
 		config:    cfg,
 		serverCfg: serverCfg,
 		dataDir:   dataDir,
 		pidFile:   filepath.Join(dataDir, "mediamtx.pid"),
+		log:       log.With("component", "mediamtx"),
 	}
 }
 
@@ -61,6 +69,13 @@ func (s *MediaMTXServer) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to create data directory: %w", err)
 	}
 
+	// Refuse to run a binary whose hash changed unexpectedly
+	if s.config.VerifyChecksum {
+		if err := s.verifyBinary(); err != nil {
+			return err
+		}
+	}
+
 	// Create MediaMTX config file if needed
 	configPath := s.getConfigPath()
 	if err := s.ensureConfig(configPath); err != nil {
@@ -111,20 +126,24 @@ func (s *MediaMTXServer) Start(ctx context.Context) error {
 	// Save PID file
 	if err := os.WriteFile(s.pidFile, []byte(fmt.Sprintf("%d", s.pid)), 0644); err != nil {
 		// Non-fatal error
-		fmt.Fprintf(os.Stderr, "warning: failed to write PID file: %v\n", err)
+		s.log.Warn("failed to write PID file", "path", s.pidFile, "error", err)
 	}
 
 	// Wait for server to be ready
-	if err := s.waitForReady(5 * time.Second); err != nil {
+	if err := s.waitForReady(s.config.ReadyTimeout); err != nil {
 		s.stopLocked() // Use stopLocked to avoid mutex deadlock
 		return fmt.Errorf("mediamtx failed to start: %w", err)
 	}
+	s.log.Info("mediamtx started", "pid", s.pid, "rtsp_port", s.serverCfg.RTSPPort)
 
 	// Monitor process in background
 	go func() {
-		cmd.Wait()
+		err := cmd.Wait()
 		logFile.Close()
 		s.mu.Lock()
+		if s.cmd == cmd {
+			s.log.Warn("mediamtx exited", "pid", cmd.Process.Pid, "error", err)
+		}
 		s.running = false
 		s.mu.Unlock()
 	}()
@@ -148,16 +167,19 @@ func (s *MediaMTXServer) stopLocked() error {
 	}
 
 	// Cancel context
+	// MediaMTX leads its own process group, which includes the publishers
+	// it runs for on-demand streams; signal all of it. Check before
+	// cancelling, which kills MediaMTX and ends its group.
+	group := process.OwnsGroup(s.pid)
+
 	if s.cancel != nil {
 		s.cancel()
 	}
 
 	// Try graceful shutdown
 	if s.cmd != nil && s.cmd.Process != nil {
-		if err := s.cmd.Process.Signal(syscall.SIGTERM); err != nil {
-			if !strings.Contains(err.Error(), "process already finished") {
-				s.cmd.Process.Kill()
-			}
+		if err := process.Signal(s.cmd.Process.Pid, group, syscall.SIGTERM); err != nil {
+			process.Signal(s.cmd.Process.Pid, group, syscall.SIGKILL)
 		}
 
 		// Wait for process to exit
@@ -170,22 +192,21 @@ func (s *MediaMTXServer) stopLocked() error {
 		select {
 		case <-done:
 		case <-time.After(5 * time.Second):
-			s.cmd.Process.Kill()
+			process.Signal(s.cmd.Process.Pid, group, syscall.SIGKILL)
 		}
 	}
 
 	// Also kill by PID if needed (for processes from previous sessions)
 	if s.pid > 0 {
-		if process, err := os.FindProcess(s.pid); err == nil {
-			process.Signal(syscall.SIGTERM)
-			time.Sleep(500 * time.Millisecond)
-			process.Kill()
-		}
+		process.Signal(s.pid, group, syscall.SIGTERM)
+		time.Sleep(500 * time.Millisecond)
+		process.Signal(s.pid, group, syscall.SIGKILL)
 	}
 
 	// Remove PID file
 	os.Remove(s.pidFile)
 
+	s.log.Info("mediamtx stopped", "pid", s.pid)
 	s.running = false
 	s.pid = 0
 	s.cmd = nil
@@ -229,7 +250,7 @@ func (s *MediaMTXServer) IsRunning() bool {
 
 // HealthCheck performs a health check on the MediaMTX API
 func (s *MediaMTXServer) HealthCheck() error {
-	url := fmt.Sprintf("http://localhost:%d/v3/config/global/get", s.serverCfg.APIPort)
+	url := s.serverCfg.APIURL("/v3/config/global/get")
 
 	client := &http.Client{Timeout: 5 * time.Second}
 	resp, err := client.Get(url)
@@ -259,6 +280,101 @@ type PathInfo struct {
 	ReadyTime     string `json:"readyTime"`
 	BytesReceived int64  `json:"bytesReceived"`
 	BytesSent     int64  `json:"bytesSent"`
+
+	// Source is the publisher of the path (nil if nothing is publishing)
+	Source  *PathPeer  `json:"source"`
+	Tracks  []string   `json:"tracks"`
+	Readers []PathPeer `json:"readers"`
+}
+
+// PathPeer is a session publishing to or reading from a path
+type PathPeer struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// ReaderCount returns the number of sessions reading the path
+func (p *PathInfo) ReaderCount() int {
+	return len(p.Readers)
+}
+
+// UnmarshalJSON decodes a path, tolerating the different shapes MediaMTX
+// versions use for source, tracks and readers. Fields that cannot be
+// understood are left empty rather than failing the whole response.
+func (p *PathInfo) UnmarshalJSON(data []byte) error {
+	type plain PathInfo
+	var raw struct {
+		plain
+		Source  json.RawMessage `json:"source"`
+		Tracks  json.RawMessage `json:"tracks"`
+		Readers json.RawMessage `json:"readers"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*p = PathInfo(raw.plain)
+	p.Source = parsePeer(raw.Source)
+	p.Tracks = parseTracks(raw.Tracks)
+	p.Readers = parsePeers(raw.Readers)
+	return nil
+}
+
+// parsePeer decodes a session given either as an object or as a bare type name
+func parsePeer(data json.RawMessage) *PathPeer {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+
+	var peer PathPeer
+	if err := json.Unmarshal(data, &peer); err == nil {
+		return &peer
+	}
+	if err := json.Unmarshal(data, &peer.Type); err == nil {
+		return &peer
+	}
+	return nil
+}
+
+// parsePeers decodes a list of sessions
+func parsePeers(data json.RawMessage) []PathPeer {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil
+	}
+
+	peers := make([]PathPeer, 0, len(items))
+	for _, item := range items {
+		if peer := parsePeer(item); peer != nil {
+			peers = append(peers, *peer)
+		}
+	}
+	return peers
+}
+
+// parseTracks decodes track codecs given either as strings or as objects
+// with a codec field
+func parseTracks(data json.RawMessage) []string {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil
+	}
+
+	tracks := make([]string, 0, len(items))
+	for _, item := range items {
+		var name string
+		if err := json.Unmarshal(item, &name); err == nil {
+			tracks = append(tracks, name)
+			continue
+		}
+		var track struct {
+			Codec string `json:"codec"`
+		}
+		if err := json.Unmarshal(item, &track); err == nil && track.Codec != "" {
+			tracks = append(tracks, track.Codec)
+		}
+	}
+	return tracks
 }
 
 // GetPathInfo retrieves information about a specific path
@@ -266,7 +382,7 @@ func (s *MediaMTXServer) GetPathInfo(path string) (*PathInfo, error) {
 	// Remove leading slash
 	path = strings.TrimPrefix(path, "/")
 
-	url := fmt.Sprintf("http://localhost:%d/v3/paths/get/%s", s.serverCfg.APIPort, path)
+	url := s.serverCfg.APIURL("/v3/paths/get/" + path)
 
 	client := &http.Client{Timeout: 5 * time.Second}
 	resp, err := client.Get(url)
@@ -298,7 +414,7 @@ func (s *MediaMTXServer) GetPathInfo(path string) (*PathInfo, error) {
 
 // ListPaths lists all active paths
 func (s *MediaMTXServer) ListPaths() ([]PathInfo, error) {
-	url := fmt.Sprintf("http://localhost:%d/v3/paths/list", s.serverCfg.APIPort)
+	url := s.serverCfg.APIURL("/v3/paths/list")
 
 	client := &http.Client{Timeout: 5 * time.Second}
 	resp, err := client.Get(url)
@@ -326,6 +442,191 @@ func (s *MediaMTXServer) ListPaths() ([]PathInfo, error) {
 	return result.Items, nil
 }
 
+// RTSPSession is an RTSP client session connected to MediaMTX
+type RTSPSession struct {
+	ID            string `json:"id"`
+	Created       string `json:"created"`
+	RemoteAddr    string `json:"remoteAddr"`
+	State         string `json:"state"` // "idle", "read" or "publish"
+	Path          string `json:"path"`
+	Transport     string `json:"transport"`
+	BytesReceived int64  `json:"bytesReceived"`
+	BytesSent     int64  `json:"bytesSent"`
+}
+
+// ListRTSPSessions lists all RTSP sessions
+func (s *MediaMTXServer) ListRTSPSessions() ([]RTSPSession, error) {
+	url := s.serverCfg.APIURL("/v3/rtspsessions/list")
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var result struct {
+		Items []RTSPSession `json:"items"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return result.Items, nil
+}
+
+// KickRTSPSession disconnects an RTSP session
+func (s *MediaMTXServer) KickRTSPSession(id string) error {
+	url := s.serverCfg.APIURL("/v3/rtspsessions/kick/" + id)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post(url, "application/json", nil)
+	if err != nil {
+		return fmt.Errorf("failed to kick session: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("session not found: %s", id)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Listeners is the part of the MediaMTX global configuration that tells
+// which protocols streams can be read with, and where
+type Listeners struct {
+	RTSP             bool   `json:"rtsp"`
+	RTSPEncryption   string `json:"rtspEncryption"` // no, optional or strict
+	RTSPAddress      string `json:"rtspAddress"`
+	RTSPSAddress     string `json:"rtspsAddress"`
+	HLS              bool   `json:"hls"`
+	HLSAddress       string `json:"hlsAddress"`
+	HLSEncryption    bool   `json:"hlsEncryption"`
+	WebRTC           bool   `json:"webrtc"`
+	WebRTCAddress    string `json:"webrtcAddress"`
+	WebRTCEncryption bool   `json:"webrtcEncryption"`
+}
+
+// GetListeners returns the protocol listeners enabled in MediaMTX
+func (s *MediaMTXServer) GetListeners() (*Listeners, error) {
+	url := s.serverCfg.APIURL("/v3/config/global/get")
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MediaMTX config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	var listeners Listeners
+	if err := json.NewDecoder(resp.Body).Decode(&listeners); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &listeners, nil
+}
+
+// PathConfig is the part of a MediaMTX path configuration managed by the
+// proxy for on-demand streams
+type PathConfig struct {
+	RunOnDemand             string `json:"runOnDemand"`
+	RunOnDemandRestart      bool   `json:"runOnDemandRestart"`
+	RunOnDemandStartTimeout string `json:"runOnDemandStartTimeout,omitempty"`
+	RunOnDemandCloseAfter   string `json:"runOnDemandCloseAfter,omitempty"`
+	MaxReaders              int    `json:"maxReaders,omitempty"`
+}
+
+// MaxReaders returns the readers allowed per path (0 = unlimited)
+func (s *MediaMTXServer) MaxReaders() int {
+	return s.config.MaxReaders
+}
+
+// HasPathConfig reports whether a path is configured in MediaMTX
+func (s *MediaMTXServer) HasPathConfig(name string) (bool, error) {
+	url := s.serverCfg.APIURL("/v3/config/paths/get/" + name)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return false, fmt.Errorf("failed to get path config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+}
+
+// AddPathConfig adds a path configuration at runtime, without restarting
+// MediaMTX. Paths added this way are lost when MediaMTX restarts.
+func (s *MediaMTXServer) AddPathConfig(name string, conf PathConfig) error {
+	url := s.serverCfg.APIURL("/v3/config/paths/add/" + name)
+
+	body, err := json.Marshal(conf)
+	if err != nil {
+		return fmt.Errorf("failed to encode path config: %w", err)
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to add path config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// DeletePathConfig removes a path configuration, disconnecting its
+// publisher and readers. A missing path is not an error.
+func (s *MediaMTXServer) DeletePathConfig(name string) error {
+	url := s.serverCfg.APIURL("/v3/config/paths/delete/" + name)
+
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete path config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
 // getConfigPath returns the MediaMTX config file path
 func (s *MediaMTXServer) getConfigPath() string {
 	if s.config.ConfigPath != "" {
@@ -334,23 +635,72 @@ func (s *MediaMTXServer) getConfigPath() string {
 	return filepath.Join(s.dataDir, "mediamtx.yml")
 }
 
+// SetSegmentCommand sets the shell command line MediaMTX runs when it
+// finishes a recording segment, with the segment in $MTX_SEGMENT_PATH
+func (s *MediaMTXServer) SetSegmentCommand(command string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.segmentCommand = command
+}
+
+// recordPath returns where MediaMTX records segments
+func (s *MediaMTXServer) recordPath() string {
+	if s.config.RecordPath != "" {
+		return s.config.RecordPath
+	}
+	return filepath.Join(s.dataDir, "recordings", "%path", "%Y-%m-%d_%H-%M-%S-%f")
+}
+
+// yamlQuote quotes s as a YAML single-quoted scalar
+func yamlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
 // ensureConfig ensures MediaMTX config file exists
 func (s *MediaMTXServer) ensureConfig(configPath string) error {
 	if _, err := os.Stat(configPath); err == nil {
 		return nil // Config already exists
 	}
 
+	// Quoted, as YAML would read a bracketed IPv6 address as a list
+	apiAddress := yamlQuote(config.ListenAddress(s.serverCfg.APIAddress, s.serverCfg.APIPort))
+	rtspAddress := yamlQuote(config.ListenAddress(s.serverCfg.RTSPAddress, s.serverCfg.RTSPPort))
+
 	// Create minimal config
 	config := fmt.Sprintf(`# MediaMTX configuration for youtube-rtsp-proxy
 api: yes
-apiAddress: :%d
-rtspAddress: :%d
+apiAddress: %s
+rtspAddress: %s
 logLevel: %s
+`, apiAddress, rtspAddress, s.config.LogLevel)
+
+	var pathDefaults string
+	if s.config.MaxReaders > 0 {
+		pathDefaults += fmt.Sprintf(`  # Refuse readers beyond this many per path
+  maxReaders: %d
+`, s.config.MaxReaders)
+	}
+	if s.config.Record {
+		pathDefaults += fmt.Sprintf(`  # Record every path into segments
+  record: yes
+  recordPath: %s
+  recordSegmentDuration: %s
+`, yamlQuote(s.recordPath()), s.config.RecordSegmentDuration)
+		if s.config.RecordChecksums && s.segmentCommand != "" {
+			pathDefaults += fmt.Sprintf(`  # Write a SHA-256 sidecar for each finished segment
+  runOnRecordSegmentComplete: %s
+`, yamlQuote(s.segmentCommand))
+		}
+	}
+	if pathDefaults != "" {
+		config += "\npathDefaults:\n" + pathDefaults
+	}
 
+	config += `
 paths:
   all:
     # Allow any path
-`, s.serverCfg.APIPort, s.serverCfg.RTSPPort, s.config.LogLevel)
+`
 
 	return os.WriteFile(configPath, []byte(config), 0644)
 }
@@ -395,11 +745,19 @@ func (s *MediaMTXServer) isAlreadyRunning() bool {
 	return false
 }
 
-// CheckBinary verifies that mediamtx binary exists and is executable
-func (s *MediaMTXServer) CheckBinary() error {
-	cmd := exec.Command(s.config.BinaryPath, "--help")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("mediamtx not found or not executable: %w", err)
+// CheckBinary verifies that mediamtx binary exists and is executable,
+// returning the reported version
+func (s *MediaMTXServer) CheckBinary() (string, error) {
+	cmd := exec.Command(s.config.BinaryPath, "--version")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("mediamtx not found or not executable: %w", err)
 	}
-	return nil
+	return ParseMediaMTXVersion(string(output)), nil
+}
+
+// ParseMediaMTXVersion extracts the version from `mediamtx --version` output
+func ParseMediaMTXVersion(output string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(line)
 }
@@ -0,0 +1,278 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEnsureConfigAddresses(t *testing.T) {
+	tests := []struct {
+		name                    string
+		apiAddress, rtspAddress string
+		wantAPI, wantRTSP       string
+	}{
+		{"all interfaces", "", "", "apiAddress: ':9997'", "rtspAddress: ':8554'"},
+		{"loopback API, NIC RTSP", "127.0.0.1", "192.168.1.10", "apiAddress: '127.0.0.1:9997'", "rtspAddress: '192.168.1.10:8554'"},
+		{"IPv6", "::1", "fd00::10", "apiAddress: '[::1]:9997'", "rtspAddress: '[fd00::10]:8554'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, "v1.9.0")
+			s.serverCfg.APIAddress = tt.apiAddress
+			s.serverCfg.RTSPAddress = tt.rtspAddress
+
+			path := filepath.Join(s.dataDir, "mediamtx.yml")
+			if err := s.ensureConfig(path); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []string{tt.wantAPI, tt.wantRTSP} {
+				if !strings.Contains(string(data), want+"\n") {
+					t.Errorf("missing %q in:\n%s", want, data)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIUsesConfiguredAddress(t *testing.T) {
+	// Listen on a loopback address other than the one localhost resolves to
+	ln, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("cannot listen on 127.0.0.2: %v", err)
+	}
+	var requested []string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.Host+r.URL.Path)
+		if strings.HasPrefix(r.URL.Path, "/v3/paths/get/") {
+			json.NewEncoder(w).Encode(map[string]any{"name": "news", "ready": true})
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	ts.Listener.Close()
+	ts.Listener = ln
+	ts.Start()
+	defer ts.Close()
+
+	s, _ := newTestServer(t, "v1.9.0")
+	s.serverCfg.APIAddress = "127.0.0.2"
+	s.serverCfg.APIPort = ln.Addr().(*net.TCPAddr).Port
+
+	if err := s.HealthCheck(); err != nil {
+		t.Errorf("HealthCheck: %v", err)
+	}
+	if info, err := s.GetPathInfo("news"); err != nil || !info.Ready {
+		t.Errorf("GetPathInfo = %+v, %v; want the ready path", info, err)
+	}
+
+	host := "127.0.0.2:" + strconv.Itoa(s.serverCfg.APIPort)
+	want := []string{host + "/v3/config/global/get", host + "/v3/paths/get/news"}
+	if strings.Join(requested, ",") != strings.Join(want, ",") {
+		t.Errorf("requested %v, want %v", requested, want)
+	}
+}
+
+// apiAnswering points s at an API that fails the first failures health
+// checks and passes the rest
+func apiAnswering(t *testing.T, s *MediaMTXServer, failures int32) {
+	t.Helper()
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(ts.Close)
+	addr := ts.Listener.Addr().(*net.TCPAddr)
+	s.serverCfg.APIAddress = addr.IP.String()
+	s.serverCfg.APIPort = addr.Port
+}
+
+func TestWaitForReady(t *testing.T) {
+	const timeout = 500 * time.Millisecond
+	tests := []struct {
+		name     string
+		failures int32
+		wantErr  bool
+	}{
+		{"ready at once", 0, false},
+		{"ready after a few checks", 2, false},
+		{"never ready", 1 << 30, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, "v1.9.0")
+			apiAnswering(t, s, tt.failures)
+
+			start := time.Now()
+			err := s.waitForReady(timeout)
+			elapsed := time.Since(start)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "timeout") {
+					t.Errorf("waitForReady = %v, want a timeout error", err)
+				}
+				if elapsed < timeout || elapsed > timeout+time.Second {
+					t.Errorf("waitForReady gave up after %v, want about %v", elapsed, timeout)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("waitForReady: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseMediaMTXVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"v1.9.3\n", "v1.9.3"},
+		{"  v1.11.0\r\n", "v1.11.0"},
+		{"v1.9.3\nextra line\n", "v1.9.3"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ParseMediaMTXVersion(tt.output); got != tt.want {
+			t.Errorf("ParseMediaMTXVersion(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestPathInfoUnmarshal(t *testing.T) {
+	tests := []struct {
+		name, body  string
+		wantReaders int
+		wantSource  string
+		wantTracks  []string
+	}{
+		{
+			name: "v1",
+			body: `{
+				"name": "news",
+				"confName": "all_others",
+				"source": {"type": "rtspSession", "id": "4f3c8b2a-1d6e-4c1b-9a57-2f1e0c9d8b7a"},
+				"ready": true,
+				"readyTime": "2025-01-15T10:04:12.345678Z",
+				"tracks": ["H264", "MPEG-4 Audio"],
+				"bytesReceived": 48213921,
+				"bytesSent": 96427842,
+				"readers": [
+					{"type": "rtspSession", "id": "7a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"},
+					{"type": "rtspsSession", "id": "0d9c8b7a-6f5e-4d3c-2b1a-0f9e8d7c6b5a"},
+					{"type": "hlsMuxer", "id": ""}
+				]
+			}`,
+			wantReaders: 3,
+			wantSource:  "rtspSession",
+			wantTracks:  []string{"H264", "MPEG-4 Audio"},
+		},
+		{
+			name: "track objects and bare types",
+			body: `{
+				"name": "news",
+				"source": "rtspSession",
+				"ready": true,
+				"tracks": [{"codec": "H265"}, {"codec": "Opus"}],
+				"readers": ["rtspSession"]
+			}`,
+			wantReaders: 1,
+			wantSource:  "rtspSession",
+			wantTracks:  []string{"H265", "Opus"},
+		},
+		{
+			name:       "not publishing",
+			body:       `{"name": "news", "source": null, "ready": false, "tracks": [], "readers": []}`,
+			wantTracks: []string{},
+		},
+		{
+			name: "unknown shapes",
+			body: `{"name": "news", "ready": true, "source": 42, "tracks": {"video": "H264"}, "readers": 2}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var info PathInfo
+			if err := json.Unmarshal([]byte(tt.body), &info); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if info.Name != "news" {
+				t.Errorf("Name = %q, want news", info.Name)
+			}
+			if got := info.ReaderCount(); got != tt.wantReaders {
+				t.Errorf("ReaderCount = %d, want %d", got, tt.wantReaders)
+			}
+			source := ""
+			if info.Source != nil {
+				source = info.Source.Type
+			}
+			if source != tt.wantSource {
+				t.Errorf("source type = %q, want %q", source, tt.wantSource)
+			}
+			if strings.Join(info.Tracks, ",") != strings.Join(tt.wantTracks, ",") {
+				t.Errorf("Tracks = %q, want %q", info.Tracks, tt.wantTracks)
+			}
+		})
+	}
+}
+
+func TestEnsureConfigMaxReaders(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxReaders int
+		record     bool
+		want       []string
+		wantNot    []string
+	}{
+		{"unlimited", 0, false, nil, []string{"pathDefaults:", "maxReaders"}},
+		{"limited", 5, false, []string{"\npathDefaults:\n  # Refuse readers beyond this many per path\n  maxReaders: 5\n"}, nil},
+		{"limited and recording", 5, true, []string{"\npathDefaults:\n  # Refuse readers beyond this many per path\n  maxReaders: 5\n  # Record every path into segments\n  record: yes\n"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, "v1.9.0")
+			s.config.MaxReaders = tt.maxReaders
+			s.config.Record = tt.record
+			s.config.RecordSegmentDuration = time.Hour
+
+			path := filepath.Join(s.dataDir, "mediamtx.yml")
+			if err := s.ensureConfig(path); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("missing %q in:\n%s", want, data)
+				}
+			}
+			for _, unwanted := range tt.wantNot {
+				if strings.Contains(string(data), unwanted) {
+					t.Errorf("unexpected %q in:\n%s", unwanted, data)
+				}
+			}
+			if got := strings.Count(string(data), "pathDefaults:"); got > 1 {
+				t.Errorf("pathDefaults appears %d times in:\n%s", got, data)
+			}
+		})
+	}
+}
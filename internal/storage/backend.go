@@ -0,0 +1,31 @@
+package storage
+
+import "fmt"
+
+// Storage backends selectable with storage.backend
+const (
+	BackendFile   = "file"
+	BackendSQLite = "sqlite"
+)
+
+// Open returns the stream storage of a data dir for backend
+func Open(backend, dataDir string) (Storage, error) {
+	switch backend {
+	case BackendFile, "":
+		return NewFileStorage(dataDir)
+	case BackendSQLite:
+		return NewSQLiteStorage(dataDir)
+	}
+	return nil, fmt.Errorf("unknown storage backend: %s", backend)
+}
+
+// OpenFavorites returns the favorites storage of a data dir for backend
+func OpenFavorites(backend, dataDir string) (Favorites, error) {
+	switch backend {
+	case BackendFile, "":
+		return NewFavoritesStorage(dataDir)
+	case BackendSQLite:
+		return NewSQLiteFavorites(dataDir)
+	}
+	return nil, fmt.Errorf("unknown storage backend: %s", backend)
+}
@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// forEachBackend runs test against a fresh storage of every backend
+func forEachBackend(t *testing.T, test func(t *testing.T, s Storage)) {
+	for _, backend := range []string{BackendFile, BackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			s, err := Open(backend, t.TempDir())
+			if err != nil {
+				t.Fatalf("Open(%s): %v", backend, err)
+			}
+			if closer, ok := s.(interface{ Close() error }); ok {
+				t.Cleanup(func() { closer.Close() })
+			}
+			test(t, s)
+		})
+	}
+}
+
+func names(streams []*StreamData) []string {
+	var list []string
+	for _, data := range streams {
+		list = append(list, data.Name)
+	}
+	return list
+}
+
+func TestStorageSaveLoad(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Storage) {
+		saved := &StreamData{
+			ID:         "abc123",
+			Name:       "news",
+			YouTubeURL: "https://www.youtube.com/watch?v=xyz",
+			RTSPPath:   "/news",
+			Port:       8554,
+			Outputs:    []string{"rtsp://relay/news"},
+			MaxBitrate: 2_000_000,
+			FFmpegPID:  4242,
+			CreatedAt:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			Progress:   &Progress{},
+			LogLevel:   "debug",
+		}
+		if err := s.Save(saved); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+
+		loaded, err := s.Load("news")
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if loaded.ID != saved.ID || loaded.YouTubeURL != saved.YouTubeURL || loaded.Port != saved.Port ||
+			loaded.MaxBitrate != saved.MaxBitrate || loaded.FFmpegPID != saved.FFmpegPID ||
+			loaded.LogLevel != saved.LogLevel || !loaded.CreatedAt.Equal(saved.CreatedAt) ||
+			!slices.Equal(loaded.Outputs, saved.Outputs) || loaded.Progress == nil {
+			t.Errorf("Load = %+v, want %+v", loaded, saved)
+		}
+
+		// Saving again replaces the entry
+		saved.Port = 8555
+		if err := s.Save(saved); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		if loaded, _ := s.Load("news"); loaded == nil || loaded.Port != 8555 {
+			t.Errorf("Load after second Save = %+v, want port 8555", loaded)
+		}
+	})
+}
+
+func TestStorageLoadMissing(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Storage) {
+		if _, err := s.Load("missing"); err == nil {
+			t.Error("Load(missing) = nil error, want not found")
+		}
+	})
+}
+
+func TestStorageList(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Storage) {
+		streams, err := s.List()
+		if err != nil || len(streams) != 0 {
+			t.Fatalf("List of empty storage = %v, %v", names(streams), err)
+		}
+
+		for _, name := range []string{"b", "c", "a"} {
+			if err := s.Save(&StreamData{Name: name}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		streams, err = s.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := names(streams); !slices.Equal(got, []string{"a", "b", "c"}) {
+			t.Errorf("List = %v, want [a b c]", got)
+		}
+	})
+}
+
+func TestStorageDelete(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Storage) {
+		if err := s.Save(&StreamData{Name: "news", FFmpegPID: 10}); err != nil {
+			t.Fatal(err)
+		}
+		logPath := filepath.Join(s.GetDataDir(), "news.log")
+		for _, path := range []string{logPath, logPath + ".1"} {
+			if err := os.WriteFile(path, []byte("log\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := s.Delete("news"); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := s.Load("news"); err == nil {
+			t.Error("Load after Delete succeeded")
+		}
+		for _, path := range []string{logPath, logPath + ".1"} {
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("%s still exists after Delete", filepath.Base(path))
+			}
+		}
+
+		// Deleting again is not an error
+		if err := s.Delete("news"); err != nil {
+			t.Errorf("second Delete: %v", err)
+		}
+	})
+}
+
+func TestStorageUpdatePID(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Storage) {
+		if err := s.Save(&StreamData{Name: "news", FFmpegPID: 10, FFmpegStartTime: 99}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.UpdatePID("news", 20); err != nil {
+			t.Fatalf("UpdatePID: %v", err)
+		}
+		loaded, err := s.Load("news")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The start time belonged to the old process
+		if loaded.FFmpegPID != 20 || loaded.FFmpegStartTime != 0 {
+			t.Errorf("after UpdatePID: pid %d, start time %d, want 20, 0", loaded.FFmpegPID, loaded.FFmpegStartTime)
+		}
+
+		// Streams not saved yet are ignored
+		if err := s.UpdatePID("unsaved", 30); err != nil {
+			t.Errorf("UpdatePID(unsaved) = %v, want nil", err)
+		}
+	})
+}
+
+func TestStorageCleanup(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Storage) {
+		for _, data := range []*StreamData{
+			{Name: "alive", FFmpegPID: 1},
+			{Name: "dead", FFmpegPID: 2},
+			{Name: "stopped", Stopped: true},
+			{Name: "ondemand", OnDemand: true},
+			{Name: "retrying", ReconnectAttempt: 2},
+		} {
+			if err := s.Save(data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		alive := func(data *StreamData) bool { return data.Name == "alive" }
+
+		stale, err := Stale(s, alive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(stale, []string{"dead"}) {
+			t.Errorf("Stale = %v, want [dead]", stale)
+		}
+
+		removed, err := s.Cleanup(alive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(removed, []string{"dead"}) {
+			t.Errorf("Cleanup = %v, want [dead]", removed)
+		}
+		streams, err := s.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := names(streams); !slices.Equal(got, []string{"alive", "ondemand", "retrying", "stopped"}) {
+			t.Errorf("List after Cleanup = %v", got)
+		}
+	})
+}
+
+func TestLeftoverFiles(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Storage) {
+		if err := s.Save(&StreamData{Name: "news"}); err != nil {
+			t.Fatal(err)
+		}
+		dir := s.GetDataDir()
+		for _, name := range []string{"news.log", "gone.pid", "gone.log", "gone.log.1", "mediamtx.log", "app.log"} {
+			if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		leftovers, err := LeftoverFiles(s, filepath.Join(dir, "app.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, path := range leftovers {
+			got = append(got, filepath.Base(path))
+		}
+		slices.Sort(got)
+		if want := []string{"gone.log", "gone.log.1", "gone.pid"}; !slices.Equal(got, want) {
+			t.Errorf("LeftoverFiles = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestSQLiteImportsFileStreams(t *testing.T) {
+	dir := t.TempDir()
+	files, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := files.Save(&StreamData{Name: "news", Port: 8554}); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := NewSQLiteStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	loaded, err := db.Load("news")
+	if err != nil {
+		t.Fatalf("stream saved by the file backend not imported: %v", err)
+	}
+	if loaded.Port != 8554 {
+		t.Errorf("imported port = %d, want 8554", loaded.Port)
+	}
+}
+
+func TestFavorites(t *testing.T) {
+	for _, backend := range []string{BackendFile, BackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			favs, err := OpenFavorites(backend, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if closer, ok := favs.(interface{ Close() error }); ok {
+				t.Cleanup(func() { closer.Close() })
+			}
+
+			if err := favs.Add(&Favorite{Name: "lofi", URL: "https://youtu.be/lofi"}); err != nil {
+				t.Fatalf("Add: %v", err)
+			}
+			if err := favs.Add(&Favorite{Name: "lofi", URL: "https://youtu.be/other"}); err == nil {
+				t.Error("second Add of the same name succeeded")
+			}
+
+			fav, err := favs.Get("lofi")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fav.URL != "https://youtu.be/lofi" || fav.CreatedAt.IsZero() {
+				t.Errorf("Get = %+v", fav)
+			}
+
+			if err := favs.SetAutoStart("lofi", true); err != nil {
+				t.Fatal(err)
+			}
+			if err := favs.UpdateLastUsed("lofi"); err != nil {
+				t.Fatal(err)
+			}
+			if fav, _ := favs.Get("lofi"); fav == nil || !fav.AutoStart || fav.LastUsed.IsZero() {
+				t.Errorf("after SetAutoStart and UpdateLastUsed: %+v", fav)
+			}
+
+			list, err := favs.List()
+			if err != nil || len(list) != 1 {
+				t.Errorf("List = %d favorites, %v, want 1", len(list), err)
+			}
+
+			if err := favs.Remove("lofi"); err != nil {
+				t.Fatal(err)
+			}
+			if err := favs.Remove("lofi"); err == nil {
+				t.Error("Remove of a missing favorite succeeded")
+			}
+			if _, err := favs.Get("lofi"); err == nil {
+				t.Error("Get after Remove succeeded")
+			}
+		})
+	}
+}
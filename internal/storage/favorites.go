@@ -13,13 +13,27 @@ import (
 type Favorite struct {
 	Name      string    `json:"name"`
 	URL       string    `json:"url"`
+	AudioOnly bool      `json:"audio_only,omitempty"`
+	AutoStart bool      `json:"autostart,omitempty"`
+	Format    string    `json:"format,omitempty"` // yt-dlp format selector
 	CreatedAt time.Time `json:"created_at"`
 	LastUsed  time.Time `json:"last_used,omitempty"`
 }
 
+// Favorites defines the interface for favorite persistence
+type Favorites interface {
+	Add(fav *Favorite) error
+	Get(name string) (*Favorite, error)
+	Remove(name string) error
+	List() ([]*Favorite, error)
+	UpdateLastUsed(name string) error
+	SetAutoStart(name string, autoStart bool) error
+}
+
 // FavoritesStorage manages favorite URLs
 type FavoritesStorage struct {
 	mu       sync.RWMutex
+	lock     fileLock
 	filePath string
 }
 
@@ -30,29 +44,33 @@ func NewFavoritesStorage(dataDir string) (*FavoritesStorage, error) {
 	}
 
 	return &FavoritesStorage{
+		lock:     fileLock{path: filepath.Join(dataDir, favoritesLockFile)},
 		filePath: filepath.Join(dataDir, "favorites.json"),
 	}, nil
 }
 
-// Add adds a new favorite
-func (s *FavoritesStorage) Add(name, url string) error {
+// Add adds a new favorite, setting its creation time
+func (s *FavoritesStorage) Add(fav *Favorite) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	unlock, err := s.lock.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	favorites, err := s.loadUnsafe()
 	if err != nil {
 		favorites = make(map[string]*Favorite)
 	}
 
-	if _, exists := favorites[name]; exists {
-		return fmt.Errorf("favorite '%s' already exists", name)
+	if _, exists := favorites[fav.Name]; exists {
+		return fmt.Errorf("favorite '%s' already exists", fav.Name)
 	}
 
-	favorites[name] = &Favorite{
-		Name:      name,
-		URL:       url,
-		CreatedAt: time.Now(),
-	}
+	fav.CreatedAt = time.Now()
+	favorites[fav.Name] = fav
 
 	return s.saveUnsafe(favorites)
 }
@@ -62,6 +80,12 @@ func (s *FavoritesStorage) Get(name string) (*Favorite, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	unlock, err := s.lock.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	favorites, err := s.loadUnsafe()
 	if err != nil {
 		return nil, err
@@ -80,6 +104,12 @@ func (s *FavoritesStorage) Remove(name string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	unlock, err := s.lock.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	favorites, err := s.loadUnsafe()
 	if err != nil {
 		return err
@@ -98,6 +128,12 @@ func (s *FavoritesStorage) List() ([]*Favorite, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	unlock, err := s.lock.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	favorites, err := s.loadUnsafe()
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -119,6 +155,12 @@ func (s *FavoritesStorage) UpdateLastUsed(name string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	unlock, err := s.lock.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	favorites, err := s.loadUnsafe()
 	if err != nil {
 		return err
@@ -133,6 +175,31 @@ func (s *FavoritesStorage) UpdateLastUsed(name string) error {
 	return s.saveUnsafe(favorites)
 }
 
+// SetAutoStart sets whether a favorite is started by "server start"
+func (s *FavoritesStorage) SetAutoStart(name string, autoStart bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lock.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	favorites, err := s.loadUnsafe()
+	if err != nil {
+		return err
+	}
+
+	fav, exists := favorites[name]
+	if !exists {
+		return fmt.Errorf("favorite '%s' not found", name)
+	}
+
+	fav.AutoStart = autoStart
+	return s.saveUnsafe(favorites)
+}
+
 // loadUnsafe loads favorites from file (no locking)
 func (s *FavoritesStorage) loadUnsafe() (map[string]*Favorite, error) {
 	data, err := os.ReadFile(s.filePath)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -16,10 +17,82 @@ type StreamData struct {
 	YouTubeURL     string    `json:"youtube_url"`
 	RTSPPath       string    `json:"rtsp_path"`
 	Port           int       `json:"port"`
+	Managed        bool      `json:"managed,omitempty"`
+	AudioOnly      bool      `json:"audio_only,omitempty"`
+	Loop           bool      `json:"loop,omitempty"`
+	OnDemand       bool      `json:"on_demand,omitempty"`
+	Transport      string    `json:"transport,omitempty"`
+	YtdlpFormat    string    `json:"ytdlp_format,omitempty"`
+	MaxBitrate     int64     `json:"max_bitrate,omitempty"`
+	Outputs        []string  `json:"outputs,omitempty"`
+	Substream      string    `json:"substream,omitempty"`
+	RequireH264    bool      `json:"require_h264,omitempty"`
+	AudioCopy      bool      `json:"audio_copy,omitempty"`
 	FFmpegPID      int       `json:"ffmpeg_pid"`
 	CreatedAt      time.Time `json:"created_at"`
 	StartedAt      time.Time `json:"started_at"`
 	LastURLRefresh time.Time `json:"last_url_refresh"`
+
+	VideoID          string    `json:"video_id,omitempty"`
+	Title            string    `json:"title,omitempty"`
+	VideoIDChangedAt time.Time `json:"video_id_changed_at,omitempty"`
+	Resolution       string    `json:"resolution,omitempty"`
+	Format           string    `json:"format,omitempty"`
+	VideoCodec       string    `json:"video_codec,omitempty"`
+	IsLive           bool      `json:"is_live,omitempty"`
+
+	Usage    []UsageBucket `json:"usage,omitempty"`
+	Progress *Progress     `json:"progress,omitempty"`
+
+	// Past runs of the stream, oldest first
+	History []Session `json:"history,omitempty"`
+
+	// Stopped streams keep their definition so they can be started again
+	Stopped bool `json:"stopped,omitempty"`
+
+	// Pending reconnect, kept so a restarted proxy resumes the backoff
+	ReconnectAttempt int       `json:"reconnect_attempt,omitempty"`
+	NextRetryAt      time.Time `json:"next_retry_at,omitempty"`
+
+	// Health counters, kept so the monitor remembers a flaky stream
+	ErrorCount        int    `json:"error_count,omitempty"`
+	ConsecutiveErrors int    `json:"consecutive_errors,omitempty"`
+	LastError         string `json:"last_error,omitempty"`
+	StallCount        int    `json:"stall_count,omitempty"`
+
+	// Threshold of the stream log, overriding logging.level
+	LogLevel string `json:"log_level,omitempty"`
+
+	// Start time of the ffmpeg process, to tell it from a later process
+	// that reused its PID (0 = unknown)
+	FFmpegStartTime uint64 `json:"ffmpeg_start_time,omitempty"`
+}
+
+// Progress is the latest encoding progress reported by ffmpeg through
+// -progress. Zero values mean ffmpeg did not report the field (e.g. fps for
+// audio-only streams, or "N/A" while starting up).
+type Progress struct {
+	Frame       int64         `json:"frame"`
+	FPS         float64       `json:"fps"`
+	BitrateKbps float64       `json:"bitrate_kbps"`
+	Speed       float64       `json:"speed"`
+	OutTime     time.Duration `json:"out_time"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// UsageBucket accumulates ingest usage of a stream over one time slice
+type UsageBucket struct {
+	Start       time.Time `json:"start"`
+	IngestBytes int64     `json:"ingest_bytes"`
+	WastedBytes int64     `json:"wasted_bytes"` // ingested while nobody was reading
+}
+
+// Session is one past run of a stream, from ffmpeg becoming ready until it
+// stopped, failed or reached the end of the video
+type Session struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason"`
 }
 
 // Storage defines the interface for stream state persistence
@@ -28,12 +101,15 @@ type Storage interface {
 	Load(name string) (*StreamData, error)
 	Delete(name string) error
 	List() ([]*StreamData, error)
+	UpdatePID(name string, pid int) error
+	Cleanup(alive func(*StreamData) bool) ([]string, error)
 	GetDataDir() string
 }
 
 // FileStorage implements file-based stream state storage
 type FileStorage struct {
 	mu      sync.RWMutex
+	lock    fileLock
 	dataDir string
 }
 
@@ -44,6 +120,7 @@ func NewFileStorage(dataDir string) (*FileStorage, error) {
 	}
 
 	return &FileStorage{
+		lock:    fileLock{path: filepath.Join(dataDir, streamsLockFile)},
 		dataDir: dataDir,
 	}, nil
 }
@@ -53,6 +130,12 @@ func (s *FileStorage) Save(data *StreamData) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	unlock, err := s.lock.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Save info file (JSON)
 	infoPath := filepath.Join(s.dataDir, data.Name+".json")
 	infoData, err := json.MarshalIndent(data, "", "  ")
@@ -80,6 +163,12 @@ func (s *FileStorage) Load(name string) (*StreamData, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	unlock, err := s.lock.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	infoPath := filepath.Join(s.dataDir, name+".json")
 	infoData, err := os.ReadFile(infoPath)
 	if err != nil {
@@ -102,6 +191,12 @@ func (s *FileStorage) Delete(name string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	unlock, err := s.lock.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Remove info file
 	infoPath := filepath.Join(s.dataDir, name+".json")
 	if err := os.Remove(infoPath); err != nil && !os.IsNotExist(err) {
@@ -112,18 +207,33 @@ func (s *FileStorage) Delete(name string) error {
 	pidPath := filepath.Join(s.dataDir, name+".pid")
 	os.Remove(pidPath) // Ignore errors
 
-	// Remove log file
-	logPath := filepath.Join(s.dataDir, name+".log")
-	os.Remove(logPath) // Ignore errors
+	removeLogFiles(s.dataDir, name)
 
 	return nil
 }
 
+// removeLogFiles removes the log file of a stream and its archives,
+// ignoring errors
+func removeLogFiles(dataDir, name string) {
+	logPath := filepath.Join(dataDir, name+".log")
+	os.Remove(logPath)
+	archives, _ := filepath.Glob(logPath + ".[0-9]*")
+	for _, archive := range archives {
+		os.Remove(archive)
+	}
+}
+
 // List returns all stored stream data
 func (s *FileStorage) List() ([]*StreamData, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	unlock, err := s.lock.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	pattern := filepath.Join(s.dataDir, "*.json")
 	matches, err := filepath.Glob(pattern)
 	if err != nil {
@@ -132,8 +242,8 @@ func (s *FileStorage) List() ([]*StreamData, error) {
 
 	var streams []*StreamData
 	for _, match := range matches {
-		// Skip mediamtx config if stored as json
-		if filepath.Base(match) == "mediamtx.json" {
+		// Skip mediamtx config if stored as json, and the favorites
+		if base := filepath.Base(match); base == "mediamtx.json" || base == "favorites.json" {
 			continue
 		}
 
@@ -163,6 +273,12 @@ func (s *FileStorage) GetPID(name string) (int, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	unlock, err := s.lock.rlock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
 	pidPath := filepath.Join(s.dataDir, name+".pid")
 	data, err := os.ReadFile(pidPath)
 	if err != nil {
@@ -182,6 +298,12 @@ func (s *FileStorage) UpdatePID(name string, pid int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	unlock, err := s.lock.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Update PID file
 	pidPath := filepath.Join(s.dataDir, name+".pid")
 	if pid > 0 {
@@ -205,6 +327,7 @@ func (s *FileStorage) UpdatePID(name string, pid int) error {
 	}
 
 	data.FFmpegPID = pid
+	data.FFmpegStartTime = 0
 	newData, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
 		return nil
@@ -218,29 +341,94 @@ func (s *FileStorage) GetLogPath(name string) string {
 	return filepath.Join(s.dataDir, name+".log")
 }
 
-// Cleanup removes orphaned files (streams that are no longer running)
-func (s *FileStorage) Cleanup() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// Cleanup removes the entries of streams that should be running but whose
+// process is gone according to alive, and returns their names. Stopped
+// streams, on-demand streams and streams with a pending reconnect are kept.
+func (s *FileStorage) Cleanup(alive func(*StreamData) bool) ([]string, error) {
+	return cleanup(s, alive)
+}
+
+// cleanup implements Cleanup for any storage
+func cleanup(s Storage, alive func(*StreamData) bool) ([]string, error) {
+	stale, err := Stale(s, alive)
+	if err != nil {
+		return nil, err
+	}
 
+	var removed []string
+	for _, name := range stale {
+		if err := s.Delete(name); err != nil {
+			return removed, err
+		}
+		removed = append(removed, name)
+	}
+
+	return removed, nil
+}
+
+// Stale returns the names of the entries Cleanup would remove, without
+// removing them
+func Stale(s Storage, alive func(*StreamData) bool) ([]string, error) {
 	streams, err := s.List()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, stream := range streams {
-		if stream.FFmpegPID > 0 {
-			// Check if process is still running
-			if process, err := os.FindProcess(stream.FFmpegPID); err == nil {
-				if err := process.Signal(os.Signal(nil)); err != nil {
-					// Process is not running, clean up
-					s.Delete(stream.Name)
-				}
+	var stale []string
+	for _, data := range streams {
+		if data.Stopped || data.OnDemand || data.ReconnectAttempt > 0 || alive(data) {
+			continue
+		}
+		stale = append(stale, data.Name)
+	}
+
+	return stale, nil
+}
+
+// LeftoverFiles returns the stream .pid and .log files and log archives in
+// the data dir of s that belong to no stored stream, such as those left
+// behind when a stream entry was removed by hand or the proxy crashed while
+// deleting it. The files of MediaMTX and the paths in keep are never
+// returned.
+func LeftoverFiles(s Storage, keep ...string) ([]string, error) {
+	streams, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{"mediamtx": true}
+	for _, data := range streams {
+		known[data.Name] = true
+	}
+	kept := make(map[string]bool)
+	for _, path := range keep {
+		if abs, err := filepath.Abs(path); err == nil {
+			kept[abs] = true
+		}
+	}
+
+	var leftovers []string
+	for _, pattern := range []string{"*.pid", "*.log", "*.log.[0-9]*"} {
+		matches, err := filepath.Glob(filepath.Join(s.GetDataDir(), pattern))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list stream files: %w", err)
+		}
+		for _, match := range matches {
+			name := filepath.Base(match)
+			if pattern == "*.pid" {
+				name = strings.TrimSuffix(name, ".pid")
 			} else {
-				s.Delete(stream.Name)
+				name = name[:strings.LastIndex(name, ".log")]
+			}
+			if known[name] {
+				continue
 			}
+			if abs, err := filepath.Abs(match); err == nil && kept[abs] {
+				continue
+			}
+			leftovers = append(leftovers, match)
 		}
 	}
 
-	return nil
+	return leftovers, nil
 }
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Lock files in the data dir. Each storage has its own, so a process using
+// both never waits on a lock it holds itself.
+const (
+	streamsLockFile   = "streams.lock"
+	favoritesLockFile = "favorites.lock"
+	instanceLockFile  = "server.lock"
+)
+
+// ErrInstanceRunning is returned by LockInstance when another process
+// holds the instance lock
+var ErrInstanceRunning = errors.New("another instance is running")
+
+// fileLock serializes access to files in the data dir across processes
+// with an advisory lock (flock) on a lock file
+type fileLock struct {
+	path string
+}
+
+// lock takes the lock exclusively, for operations that write
+func (l fileLock) lock() (unlock func(), err error) {
+	return l.acquire(syscall.LOCK_EX)
+}
+
+// rlock takes the lock shared, for operations that only read
+func (l fileLock) rlock() (unlock func(), err error) {
+	return l.acquire(syscall.LOCK_SH)
+}
+
+func (l fileLock) acquire(how int) (func(), error) {
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", l.path, err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// InstanceLock is held by the foreground server for as long as it runs
+type InstanceLock struct {
+	file *os.File
+}
+
+// LockInstance takes the instance lock of a data dir without waiting. If
+// another process holds it, the error wraps ErrInstanceRunning and names
+// that process.
+func LockInstance(dataDir string) (*InstanceLock, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	path := filepath.Join(dataDir, instanceLockFile)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			if pid := readLockPID(path); pid > 0 {
+				return nil, fmt.Errorf("%w (PID %d)", ErrInstanceRunning, pid)
+			}
+			return nil, ErrInstanceRunning
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	// Record our PID for the error above; the lock itself is the flock
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &InstanceLock{file: f}, nil
+}
+
+// Release gives up the instance lock
+func (l *InstanceLock) Release() {
+	if l == nil || l.file == nil {
+		return
+	}
+	l.file.Truncate(0)
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	l.file.Close()
+	l.file = nil
+}
+
+// readLockPID returns the PID recorded in a lock file, or 0
+func readLockPID(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,371 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+// sqliteFile is the database of the sqlite backend in the data dir
+const sqliteFile = "state.db"
+
+// Rows are kept as JSON documents, so new StreamData and Favorite fields
+// need no schema changes
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS streams (name TEXT PRIMARY KEY, data TEXT NOT NULL);
+CREATE TABLE IF NOT EXISTS favorites (name TEXT PRIMARY KEY, data TEXT NOT NULL);
+CREATE TABLE IF NOT EXISTS meta (key TEXT PRIMARY KEY, value TEXT NOT NULL);
+`
+
+// openSQLite opens the database of a data dir, creating it if needed
+func openSQLite(dataDir string) (*sql.DB, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	// Concurrent CLI invocations wait for each other's writes
+	dsn := "file:" + filepath.Join(dataDir, sqliteFile) +
+		"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	return db, nil
+}
+
+// migrateOnce runs load in a transaction unless a migration named key
+// already ran, and records that it did
+func migrateOnce(db *sql.DB, key string, load func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Writing first takes the write lock, so two processes opening a new
+	// database cannot both migrate
+	res, err := tx.Exec(`INSERT OR IGNORE INTO meta (key, value) VALUES (?, ?)`, key, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	if err := load(tx); err != nil {
+		return fmt.Errorf("failed to migrate %s: %w", key, err)
+	}
+	return tx.Commit()
+}
+
+// SQLiteStorage implements stream state storage in a SQLite database
+type SQLiteStorage struct {
+	db      *sql.DB
+	dataDir string
+}
+
+// NewSQLiteStorage opens the stream storage in the SQLite database of a
+// data dir. On first use, streams saved by the file backend are imported.
+func NewSQLiteStorage(dataDir string) (*SQLiteStorage, error) {
+	db, err := openSQLite(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	err = migrateOnce(db, "streams_migrated", func(tx *sql.Tx) error {
+		files, err := NewFileStorage(dataDir)
+		if err != nil {
+			return err
+		}
+		streams, err := files.List()
+		if err != nil {
+			return err
+		}
+		for _, data := range streams {
+			if data.Name == "" {
+				continue
+			}
+			if err := upsertJSON(tx, "streams", data.Name, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStorage{db: db, dataDir: dataDir}, nil
+}
+
+// Save persists stream data
+func (s *SQLiteStorage) Save(data *StreamData) error {
+	if err := upsertJSON(s.db, "streams", data.Name, data); err != nil {
+		return fmt.Errorf("failed to save stream data: %w", err)
+	}
+	return nil
+}
+
+// Load retrieves stream data
+func (s *SQLiteStorage) Load(name string) (*StreamData, error) {
+	var data StreamData
+	if err := selectJSON(s.db, "streams", name, &data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("stream not found: %s", name)
+		}
+		return nil, fmt.Errorf("failed to read stream data: %w", err)
+	}
+	return &data, nil
+}
+
+// Delete removes stream data and the stream's log file
+func (s *SQLiteStorage) Delete(name string) error {
+	if _, err := s.db.Exec(`DELETE FROM streams WHERE name = ?`, name); err != nil {
+		return fmt.Errorf("failed to delete stream data: %w", err)
+	}
+
+	removeLogFiles(s.dataDir, name)
+
+	return nil
+}
+
+// List returns all stored stream data, ordered by name
+func (s *SQLiteStorage) List() ([]*StreamData, error) {
+	rows, err := s.db.Query(`SELECT data FROM streams ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list streams: %w", err)
+	}
+	defer rows.Close()
+
+	var streams []*StreamData
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var data StreamData
+		if err := json.Unmarshal([]byte(raw), &data); err != nil {
+			continue
+		}
+		streams = append(streams, &data)
+	}
+	return streams, rows.Err()
+}
+
+// UpdatePID updates just the PID for a stream
+func (s *SQLiteStorage) UpdatePID(name string, pid int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var data StreamData
+	if err := selectJSON(tx, "streams", name, &data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil // stream might not be saved yet
+		}
+		return err
+	}
+
+	data.FFmpegPID = pid
+	data.FFmpegStartTime = 0
+	if err := upsertJSON(tx, "streams", name, &data); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Cleanup removes the entries of streams whose process is gone (see
+// FileStorage.Cleanup)
+func (s *SQLiteStorage) Cleanup(alive func(*StreamData) bool) ([]string, error) {
+	return cleanup(s, alive)
+}
+
+// GetDataDir returns the data directory
+func (s *SQLiteStorage) GetDataDir() string {
+	return s.dataDir
+}
+
+// Close closes the database
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+// SQLiteFavorites implements favorites storage in a SQLite database
+type SQLiteFavorites struct {
+	db *sql.DB
+}
+
+// NewSQLiteFavorites opens the favorites storage in the SQLite database of
+// a data dir. On first use, favorites.json is imported.
+func NewSQLiteFavorites(dataDir string) (*SQLiteFavorites, error) {
+	db, err := openSQLite(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	err = migrateOnce(db, "favorites_migrated", func(tx *sql.Tx) error {
+		files, err := NewFavoritesStorage(dataDir)
+		if err != nil {
+			return err
+		}
+		favorites, err := files.List()
+		if err != nil {
+			return err
+		}
+		for _, fav := range favorites {
+			if err := upsertJSON(tx, "favorites", fav.Name, fav); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteFavorites{db: db}, nil
+}
+
+// Add adds a new favorite, setting its creation time
+func (s *SQLiteFavorites) Add(fav *Favorite) error {
+	fav.CreatedAt = time.Now()
+	data, err := json.Marshal(fav)
+	if err != nil {
+		return fmt.Errorf("failed to marshal favorite: %w", err)
+	}
+
+	res, err := s.db.Exec(`INSERT OR IGNORE INTO favorites (name, data) VALUES (?, ?)`, fav.Name, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to write favorite: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("favorite '%s' already exists", fav.Name)
+	}
+	return nil
+}
+
+// Get retrieves a favorite by name
+func (s *SQLiteFavorites) Get(name string) (*Favorite, error) {
+	var fav Favorite
+	if err := selectJSON(s.db, "favorites", name, &fav); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("favorite '%s' not found", name)
+		}
+		return nil, fmt.Errorf("failed to read favorite: %w", err)
+	}
+	return &fav, nil
+}
+
+// Remove removes a favorite
+func (s *SQLiteFavorites) Remove(name string) error {
+	res, err := s.db.Exec(`DELETE FROM favorites WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("failed to remove favorite: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("favorite '%s' not found", name)
+	}
+	return nil
+}
+
+// List returns all favorites, ordered by name
+func (s *SQLiteFavorites) List() ([]*Favorite, error) {
+	rows, err := s.db.Query(`SELECT data FROM favorites ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list favorites: %w", err)
+	}
+	defer rows.Close()
+
+	result := []*Favorite{}
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var fav Favorite
+		if err := json.Unmarshal([]byte(raw), &fav); err != nil {
+			return nil, fmt.Errorf("failed to parse favorite: %w", err)
+		}
+		result = append(result, &fav)
+	}
+	return result, rows.Err()
+}
+
+// UpdateLastUsed updates the last used timestamp
+func (s *SQLiteFavorites) UpdateLastUsed(name string) error {
+	return s.update(name, func(fav *Favorite) { fav.LastUsed = time.Now() })
+}
+
+// SetAutoStart sets whether a favorite is started by "server start"
+func (s *SQLiteFavorites) SetAutoStart(name string, autoStart bool) error {
+	return s.update(name, func(fav *Favorite) { fav.AutoStart = autoStart })
+}
+
+// Close closes the database
+func (s *SQLiteFavorites) Close() error {
+	return s.db.Close()
+}
+
+// update applies change to a favorite in a transaction
+func (s *SQLiteFavorites) update(name string, change func(*Favorite)) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var fav Favorite
+	if err := selectJSON(tx, "favorites", name, &fav); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("favorite '%s' not found", name)
+		}
+		return err
+	}
+
+	change(&fav)
+	if err := upsertJSON(tx, "favorites", name, &fav); err != nil {
+		return fmt.Errorf("failed to write favorite: %w", err)
+	}
+	return tx.Commit()
+}
+
+// execer is a *sql.DB or *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// upsertJSON stores v as the document of name in table
+func upsertJSON(db execer, table, name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO `+table+` (name, data) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET data = excluded.data`, name, string(data))
+	return err
+}
+
+// selectJSON loads the document of name in table into v. It returns
+// sql.ErrNoRows if there is none.
+func selectJSON(db execer, table, name string, v any) error {
+	var raw string
+	if err := db.QueryRow(`SELECT data FROM `+table+` WHERE name = ?`, name).Scan(&raw); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(raw), v)
+}
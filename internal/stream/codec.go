@@ -0,0 +1,62 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+)
+
+// h264Options are the output options re-encoding video to H.264, for
+// sources in other codecs when H.264 is required
+var h264Options = []string{
+	"-c:v", "libx264", "-preset", "veryfast", "-tune", "zerolatency",
+	"-pix_fmt", "yuv420p",
+}
+
+// IsH264 reports whether a codec as yt-dlp reports it (e.g. "avc1.64001F")
+// is H.264
+func IsH264(codec string) bool {
+	codec = strings.ToLower(codec)
+	return strings.HasPrefix(codec, "avc1") || strings.HasPrefix(codec, "avc3") || strings.HasPrefix(codec, "h264")
+}
+
+// knownVideoCodec reports whether codec names a video codec, rather than
+// being unknown or "none" for a source without video
+func knownVideoCodec(codec string) bool {
+	return codec != "" && codec != "none"
+}
+
+// videoOutputOptions returns the output options for a stream: the
+// configured ones, re-encoding to H.264 instead of copying video in
+// another codec if the stream requires H.264. Copying such video fails
+// unless required, as many NVRs only decode H.264. The re-encoding is
+// reported to warn.
+func videoOutputOptions(outputOptions []string, stream *Stream, warn func(format string, args ...interface{})) ([]string, error) {
+	codec := stream.GetVideoCodec()
+	if stream.AudioOnly || !CopiesVideo(outputOptions) || !knownVideoCodec(codec) || IsH264(codec) {
+		return outputOptions, nil
+	}
+	if !stream.RequireH264 {
+		return nil, fmt.Errorf("source video is %s, not H.264, and ffmpeg.output_options copy it as is, "+
+			"which many NVRs cannot decode: start with --require-h264 to re-encode it, "+
+			"or select an H.264 format, e.g. --format \"best[vcodec^=avc1]\"", codec)
+	}
+
+	warn("Source video is %s, not H.264: re-encoding to H.264", codec)
+	return append(stripCodecOptions(outputOptions), h264Options...), nil
+}
+
+// stripCodecOptions removes the video codec options and their values from
+// opts. A codec given for all streams with -c still applies to audio; the
+// -c:v added after it overrides it for video.
+func stripCodecOptions(opts []string) []string {
+	var result []string
+	for i := 0; i < len(opts); i++ {
+		switch opts[i] {
+		case "-c:v", "-codec:v", "-vcodec":
+			i++ // skip the option's value
+			continue
+		}
+		result = append(result, opts[i])
+	}
+	return result
+}
@@ -0,0 +1,47 @@
+package stream
+
+import "strings"
+
+// audioCopySuffix is appended to the RTSP path of a stream for its
+// audio-only copy, e.g. /lofi_audio
+const audioCopySuffix = "_audio"
+
+// Companion is an extra path a stream's ffmpeg publishes to next to the
+// stream's own, with a differently encoded copy of the same input
+type Companion struct {
+	Name string // what the copy is, e.g. "substream"
+	Path string
+}
+
+// Companions returns the companion paths of a stream published at
+// rtspPath with the given substream and audio copy options
+func Companions(rtspPath, substream string, audioCopy bool) []Companion {
+	var companions []Companion
+	if substream != "" {
+		companions = append(companions, Companion{Name: "substream", Path: SubstreamPath(rtspPath)})
+	}
+	if audioCopy {
+		companions = append(companions, Companion{Name: "audio copy", Path: AudioCopyPath(rtspPath)})
+	}
+	return companions
+}
+
+// AudioCopyPath returns the RTSP path of the audio-only copy of a stream
+// path
+func AudioCopyPath(rtspPath string) string {
+	return rtspPath + audioCopySuffix
+}
+
+// audioCopyArgs returns the ffmpeg output arguments publishing the audio
+// from audioStream (an ffmpeg -map stream specifier) alone to output, for
+// clients such as speakers that only play audio. The audio is required,
+// as a copy without it would be empty.
+func audioCopyArgs(output, audioStream, transport string) []string {
+	return []string{
+		"-map", strings.TrimSuffix(audioStream, "?"),
+		"-vn",
+		"-c:a", "aac",
+		"-rtsp_transport", transport,
+		output,
+	}
+}
@@ -0,0 +1,89 @@
+package stream
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType is the kind of a stream lifecycle event
+type EventType string
+
+const (
+	EventStarted      EventType = "started"       // stream is running
+	EventStopped      EventType = "stopped"       // stream was stopped
+	EventReconnecting EventType = "reconnecting"  // stream failed and is being restarted
+	EventError        EventType = "error"         // stream gave up
+	EventURLRefreshed EventType = "url_refreshed" // stream source URL was refreshed
+)
+
+// eventBuffer is how many events a subscriber can fall behind by before
+// further events to it are dropped
+const eventBuffer = 64
+
+// Event is a change in the lifecycle of a stream
+type Event struct {
+	Type   EventType
+	Stream string
+	Time   time.Time
+}
+
+// eventBus fans events out to subscribers without blocking the publisher
+type eventBus struct {
+	mu   sync.Mutex
+	subs []chan Event
+}
+
+// subscribe returns a new buffered channel receiving every later event
+func (b *eventBus) subscribe() <-chan Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan Event, eventBuffer)
+	b.subs = append(b.subs, ch)
+	return ch
+}
+
+// publish sends an event to every subscriber with room for it; events to
+// subscribers that fell behind are dropped
+func (b *eventBus) publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Events returns a channel receiving the lifecycle events of all streams
+// from now on. The channel is buffered; a consumer that falls behind by
+// more than its buffer misses events rather than stalling the manager.
+func (m *Manager) Events() <-chan Event {
+	return m.events.subscribe()
+}
+
+// publish emits an event for a stream
+func (m *Manager) publish(t EventType, name string) {
+	m.events.publish(Event{Type: t, Stream: name, Time: time.Now()})
+}
+
+// track makes state changes of a stream held by the manager emit events
+func (m *Manager) track(stream *Stream) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	stream.onState = m.stateChanged
+}
+
+// stateChanged emits the event for a stream entering state, if any
+func (m *Manager) stateChanged(name string, state State) {
+	switch state {
+	case StateRunning:
+		m.publish(EventStarted, name)
+	case StateStopped:
+		m.publish(EventStopped, name)
+	case StateReconnecting:
+		m.publish(EventReconnecting, name)
+	case StateError:
+		m.publish(EventError, name)
+	}
+}
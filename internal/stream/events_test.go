@@ -0,0 +1,140 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
+)
+
+// fakeExtractor resolves every URL to a fixed live source
+type fakeExtractor struct{}
+
+func (fakeExtractor) Extract(ctx context.Context, youtubeURL string, opts extractor.ExtractOptions) (*extractor.StreamInfo, error) {
+	return &extractor.StreamInfo{ID: "abc123", URL: "https://example.com/video.m3u8", IsLive: true}, nil
+}
+
+func (fakeExtractor) IsLiveStream(ctx context.Context, youtubeURL string) (bool, error) {
+	return true, nil
+}
+
+// newTestManager returns a manager whose ffmpeg is a script that idles
+// until it is killed, and whose state lives in a temporary data dir
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	if err := os.WriteFile(ffmpeg, []byte("#!/bin/sh\nexec sleep 60\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("storage:\n  data_dir: "+dir+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.FFmpeg.BinaryPath = ffmpeg
+
+	store, err := storage.NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(cfg, fakeExtractor{}, nil, store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() {
+		m.StopAll()
+		m.GetLoggerManager().CloseAll()
+	})
+	return m
+}
+
+// nextEvent returns the next event of ch, failing after a second
+func nextEvent(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event within a second")
+		return Event{}
+	}
+}
+
+func TestEventsStartStopCycle(t *testing.T) {
+	m := newTestManager(t)
+	events := m.Events()
+
+	before := time.Now()
+	if err := m.Start(context.Background(), "https://youtu.be/abc123", "news", 0, StartOptions{NoWait: true}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := m.Stop("news"); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	for _, want := range []EventType{EventStarted, EventStopped} {
+		event := nextEvent(t, events)
+		if event.Type != want || event.Stream != "news" {
+			t.Errorf("event = %s %q, want %s \"news\"", event.Type, event.Stream, want)
+		}
+		if event.Time.Before(before) {
+			t.Errorf("%s event time %v predates the cycle", event.Type, event.Time)
+		}
+	}
+	select {
+	case event := <-events:
+		t.Errorf("unexpected event after the cycle: %s %q", event.Type, event.Stream)
+	default:
+	}
+}
+
+func TestEventsStateTransitions(t *testing.T) {
+	m := newTestManager(t)
+	events := m.Events()
+
+	stream := NewStream("news", "https://youtu.be/abc123", 8554)
+	m.track(stream)
+	for _, state := range []State{StateReconnecting, StateStarting, StateRunning, StateError} {
+		stream.SetState(state)
+	}
+
+	for _, want := range []EventType{EventReconnecting, EventStarted, EventError} {
+		if event := nextEvent(t, events); event.Type != want {
+			t.Errorf("event = %s, want %s", event.Type, want)
+		}
+	}
+}
+
+func TestEventBusDoesNotBlock(t *testing.T) {
+	var bus eventBus
+	slow := bus.subscribe()
+	fast := bus.subscribe()
+
+	// Nobody reads slow; publishing past its buffer must not stall
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range eventBuffer + 10 {
+			bus.publish(Event{Type: EventStarted, Stream: "news"})
+			<-fast
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked on a subscriber that fell behind")
+	}
+
+	if len(slow) != eventBuffer {
+		t.Errorf("slow subscriber holds %d events, want its buffer of %d", len(slow), eventBuffer)
+	}
+}
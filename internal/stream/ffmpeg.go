@@ -1,32 +1,56 @@
 package stream
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/logger"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/process"
 )
 
+// stderrHistory is the number of recent ffmpeg stderr lines kept in memory
+const stderrHistory = 200
+
+// maxStderrLine is the length at which a stderr line is cut; the rest of a
+// longer line is dropped
+const maxStderrLine = 4096
+
+// stopTimeout is how long Stop waits for ffmpeg to exit before killing it
+const stopTimeout = 5 * time.Second
+
 // FFmpegProcess manages an FFmpeg process for a stream
 type FFmpegProcess struct {
 	mu sync.Mutex
 
 	cmd       *exec.Cmd
+	args      []string
 	pid       int
 	inputURL  string
 	outputURL string
 	startTime time.Time
-	stderr    *bytes.Buffer
+	stdin     io.WriteCloser // set with ffmpeg.graceful_quit
 	cancel    context.CancelFunc
 	done      chan struct{}
+	exitErr   error // result of cmd.Wait, valid once done is closed
+
+	// The stderr reader records lines until ffmpeg exits, also while Stop
+	// waits for that, so the ring buffer has its own lock
+	stderrMu  sync.Mutex
+	stderr    []string // ring buffer of the last stderrHistory lines
+	stderrPos int
 }
 
 // FFmpegManager handles FFmpeg process lifecycle
@@ -41,27 +65,44 @@ func NewFFmpegManager(cfg *config.FFmpegConfig) *FFmpegManager {
 	}
 }
 
-// Start starts an FFmpeg process for streaming
-func (m *FFmpegManager) Start(ctx context.Context, stream *Stream) (*FFmpegProcess, error) {
-	streamURL := stream.GetStreamURL()
-	if streamURL == "" {
-		return nil, fmt.Errorf("stream URL is empty")
+// Start starts an FFmpeg process for streaming.
+//
+// The process lifetime is intentionally not tied to ctx: ctx only guards the
+// launch itself. Streams started from a short-lived CLI invocation must keep
+// running after that CLI exits (or receives SIGINT), and are later found again
+// through their stored PID by Manager.RecoverStreams. Use Stop or KillByPID to
+// end the process.
+//
+// Each stderr line is written to log, prefixed with "ffmpeg:", and the most
+// recent lines are kept for GetStderr.
+func (m *FFmpegManager) Start(ctx context.Context, stream *Stream, log *logger.StreamLogger) (*FFmpegProcess, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	rtspOutput := fmt.Sprintf("rtsp://localhost:%d%s", stream.Port, stream.RTSPPath)
-
-	// Build FFmpeg arguments
-	args := m.buildArgs(streamURL, rtspOutput)
+	args, err := m.Args(stream, log.Warn)
+	if err != nil {
+		return nil, err
+	}
+	streamURL, _ := stream.GetStreamURLs()
+	targets := stream.PublishTargets()
 
-	// Create cancellable context
-	procCtx, cancel := context.WithCancel(ctx)
+	// Create cancellable context detached from the caller's context
+	procCtx, cancel := context.WithCancel(context.Background())
 
 	cmd := exec.CommandContext(procCtx, m.config.BinaryPath, args...)
 
-	// Capture stderr for error analysis
-	stderr := &bytes.Buffer{}
-	cmd.Stderr = stderr
-	cmd.Stdout = io.Discard
+	// Stream stderr into the stream log and parse -progress from stdout
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
 
 	// Ensure process gets its own process group
 	cmd.SysProcAttr = &syscall.SysProcAttr{
@@ -70,13 +111,23 @@ func (m *FFmpegManager) Start(ctx context.Context, stream *Stream) (*FFmpegProce
 
 	proc := &FFmpegProcess{
 		cmd:       cmd,
+		args:      args,
 		inputURL:  streamURL,
-		outputURL: rtspOutput,
-		stderr:    stderr,
+		outputURL: targets[0],
 		cancel:    cancel,
 		done:      make(chan struct{}),
 	}
 
+	// Keep ffmpeg's stdin to ask it to quit
+	if m.config.GracefulQuit {
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+		}
+		proc.stdin = stdin
+	}
+
 	if err := cmd.Start(); err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
@@ -85,79 +136,401 @@ func (m *FFmpegManager) Start(ctx context.Context, stream *Stream) (*FFmpegProce
 	proc.pid = cmd.Process.Pid
 	proc.startTime = time.Now()
 
+	// Limits are best effort: a stream without them beats no stream
+	if err := applyLimits(proc.pid, m.config); err != nil {
+		log.Warn("Resource limits not applied: %v", err)
+	}
+
 	// Update stream with FFmpeg info
 	stream.SetFFmpegPID(proc.pid)
 	stream.FFmpegCmd = cmd
 
-	// Start goroutine to wait for process exit
+	// Read output until the process exits, then reap it
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		proc.readStderr(stderr, log)
+	}()
 	go func() {
-		cmd.Wait()
+		defer readers.Done()
+		parseProgress(stdout, stream.SetProgress)
+		io.Copy(io.Discard, stdout)
+	}()
+	go func() {
+		readers.Wait()
+		proc.exitErr = cmd.Wait()
 		close(proc.done)
 	}()
 
 	return proc, nil
 }
 
+// Args returns the ffmpeg arguments publishing a stream from its current
+// URLs. Options that do not apply to the stream are dropped, reporting why
+// to warn.
+func (m *FFmpegManager) Args(stream *Stream, warn func(format string, args ...interface{})) ([]string, error) {
+	streamURL, audioURL := stream.GetStreamURLs()
+	if streamURL == "" {
+		return nil, fmt.Errorf("stream URL is empty")
+	}
+
+	targets := stream.PublishTargets()
+
+	// Looping only makes sense for finite, file-like inputs
+	loop := stream.Loop && !stream.GetIsLive() && !isManifestURL(streamURL)
+	if stream.Loop && !loop {
+		warn("Loop ignored: source is live or an HLS manifest")
+	}
+
+	opts := argsOptions{
+		inputURL:  streamURL,
+		audioURL:  audioURL,
+		headers:   stream.GetHTTPHeaders(),
+		targets:   targets,
+		audioOnly: stream.AudioOnly,
+		loop:      loop,
+		transport: stream.Transport,
+	}
+	if opts.transport == "" {
+		opts.transport = m.config.RTSPTransport
+	}
+
+	outputOptions, err := videoOutputOptions(m.config.OutputOptions, stream, warn)
+	if err != nil {
+		return nil, err
+	}
+	opts.outputOptions = outputOptions
+
+	opts.maxBitrate = stream.MaxBitrate
+	if opts.maxBitrate > 0 && (stream.AudioOnly || CopiesVideo(outputOptions)) {
+		warn("Max bitrate ignored: video is not re-encoded (set a video encoder in ffmpeg.output_options)")
+		opts.maxBitrate = 0
+	}
+	if stream.Substream != "" {
+		parsed, err := ParseSubstream(stream.Substream)
+		if err != nil {
+			return nil, err
+		}
+		opts.substream = parsed
+		opts.subTarget = PublishURL(stream.publishHost, stream.Port, SubstreamPath(stream.RTSPPath))
+	}
+	if stream.AudioCopy {
+		opts.audioTarget = PublishURL(stream.publishHost, stream.Port, AudioCopyPath(stream.RTSPPath))
+	}
+	return m.buildArgs(opts), nil
+}
+
+// argsOptions is what an FFmpeg command line is built from, as Args
+// resolves it from a stream and the config
+type argsOptions struct {
+	inputURL string
+	// audioURL is the separate audio input of merged formats (empty =
+	// inputURL carries audio)
+	audioURL string
+	// headers are the HTTP headers the extractor recommends for both inputs
+	headers map[string]string
+
+	targets       []string
+	outputOptions []string
+	audioOnly     bool
+	loop          bool
+	transport     string // empty = tcp
+	maxBitrate    int64  // bits/s, 0 = unlimited
+
+	// substream is published to subTarget as well, if set
+	substream *Substream
+	subTarget string
+	// audioTarget is where the audio is published alone (empty = nowhere)
+	audioTarget string
+}
+
 // buildArgs constructs FFmpeg command line arguments
-func (m *FFmpegManager) buildArgs(inputURL, outputURL string) []string {
+func (m *FFmpegManager) buildArgs(opts argsOptions) []string {
+	inputURL, audioURL := opts.inputURL, opts.audioURL
 	args := []string{
-		"-re", // Read input at native frame rate
+		"-nostats", // Progress is reported through -progress instead
+		"-progress", "pipe:1",
 	}
 
-	// Add input options (reconnect settings, etc.)
-	args = append(args, m.config.InputOptions...)
+	// Audio-only streams need no separate video
+	if opts.audioOnly && audioURL != "" {
+		inputURL, audioURL = audioURL, ""
+	}
 
-	// Input URL
-	args = append(args, "-i", inputURL)
+	// Input URLs, and which streams to take from each
+	args = append(args, m.inputArgs(inputURL, opts.headers, opts.loop)...)
+	audioStream := "0:a:0?"
+	if audioURL != "" {
+		args = append(args, m.inputArgs(audioURL, opts.headers, opts.loop)...)
+		audioStream = "1:a:0"
+		args = append(args, "-map", "0:v:0", "-map", audioStream)
+	}
 
 	// Output options (codec settings)
-	args = append(args, m.config.OutputOptions...)
+	if opts.audioOnly {
+		args = append(args, "-vn", "-c:a", "aac")
+		args = append(args, stripVideoOptions(opts.outputOptions)...)
+	} else {
+		args = append(args, opts.outputOptions...)
+	}
+
+	// Cap the encoder's bitrate, with a buffer of two seconds at that rate
+	if opts.maxBitrate > 0 {
+		args = append(args,
+			"-maxrate", strconv.FormatInt(opts.maxBitrate, 10),
+			"-bufsize", strconv.FormatInt(2*opts.maxBitrate, 10),
+		)
+	}
 
 	// RTSP transport
-	args = append(args, "-rtsp_transport", "tcp")
+	transport := opts.transport
+	if transport == "" {
+		transport = "tcp"
+	}
+
+	// Output URL, or the tee of several
+	args = append(args, outputArgs(opts.targets, transport)...)
+
+	// Second, low-resolution output
+	if opts.substream != nil {
+		args = append(args, opts.substream.args(opts.subTarget, audioStream, transport)...)
+	}
 
-	// Output URL
-	args = append(args, outputURL)
+	// Audio-only copy
+	if opts.audioTarget != "" {
+		args = append(args, audioCopyArgs(opts.audioTarget, audioStream, transport)...)
+	}
 
 	return args
 }
 
+// inputArgs returns the arguments reading one input. Input options apply
+// to the input that follows them, so each input gets its own.
+func (m *FFmpegManager) inputArgs(inputURL string, headers map[string]string, loop bool) []string {
+	args := []string{"-re"} // Read input at native frame rate
+
+	// Restart the input from the beginning when it ends
+	if loop {
+		args = append(args, "-stream_loop", "-1")
+	}
+
+	// How much of the input to probe for its stream parameters
+	args = append(args, m.probeArgs()...)
+
+	// Add input options (reconnect settings, etc.). The defaults are HTTP
+	// protocol options, which RTSP/RTMP inputs reject.
+	if isHTTPURL(inputURL) {
+		args = append(args, m.config.InputOptions...)
+		args = append(args, m.headerArgs(headers)...)
+	}
+
+	return append(args, "-i", inputURL)
+}
+
+// probeArgs returns the input options setting how much of an input is
+// probed, for sources whose parameters FFmpeg otherwise fails to detect
+func (m *FFmpegManager) probeArgs() []string {
+	var args []string
+	if size, err := config.ParseByteSize(m.config.Probesize); err == nil && size > 0 {
+		args = append(args, "-probesize", strconv.FormatInt(size, 10))
+	}
+	if d := m.config.AnalyzeDuration; d > 0 {
+		args = append(args, "-analyzeduration", strconv.FormatInt(d.Microseconds(), 10))
+	}
+	return args
+}
+
+// headerArgs returns the input options setting the HTTP request headers:
+// those the extractor recommends, overridden by the configured ones with
+// the same name
+func (m *FFmpegManager) headerArgs(recommended map[string]string) []string {
+	userAgent := m.config.UserAgent
+	var headers []string
+	for _, name := range slices.Sorted(maps.Keys(recommended)) {
+		if strings.EqualFold(name, "User-Agent") {
+			if userAgent == "" {
+				userAgent = recommended[name]
+			}
+			continue
+		}
+		if !hasHeader(m.config.Headers, name) {
+			headers = append(headers, name+": "+recommended[name])
+		}
+	}
+	headers = append(headers, m.config.Headers...)
+
+	var args []string
+	if userAgent != "" {
+		args = append(args, "-user_agent", userAgent)
+	}
+	if len(headers) > 0 {
+		args = append(args, "-headers", strings.Join(headers, "\r\n")+"\r\n")
+	}
+	return args
+}
+
+// hasHeader reports whether "Name: value" headers set name
+func hasHeader(headers []string, name string) bool {
+	for _, h := range headers {
+		if n, _, _ := strings.Cut(h, ":"); strings.EqualFold(strings.TrimSpace(n), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactHeaders returns ffmpeg arguments with the values of -headers
+// replaced, for printing command lines whose headers may carry cookies
+func RedactHeaders(args []string) []string {
+	redacted := slices.Clone(args)
+	for i := 0; i+1 < len(redacted); i++ {
+		if redacted[i] == "-headers" {
+			redacted[i+1] = "<redacted>"
+		}
+	}
+	return redacted
+}
+
+// inputPlaceholders stand in for the input URLs of a redacted command line,
+// in the order buildArgs adds the inputs
+var inputPlaceholders = []string{"<STREAM_URL>", "<AUDIO_URL>"}
+
+// RedactCommand returns ffmpeg arguments with the headers redacted as by
+// RedactHeaders and the input URLs, which are signed for this host,
+// replaced by placeholders
+func RedactCommand(args []string) []string {
+	redacted := RedactHeaders(args)
+	inputs := 0
+	for i := 0; i+1 < len(redacted); i++ {
+		if redacted[i] == "-i" && inputs < len(inputPlaceholders) {
+			redacted[i+1] = inputPlaceholders[inputs]
+			inputs++
+		}
+	}
+	return redacted
+}
+
+// isManifestURL reports whether url is an HLS manifest (as yt-dlp returns
+// for live streams) rather than a direct media file
+func isManifestURL(url string) bool {
+	return strings.Contains(url, ".m3u8") || strings.Contains(url, "/manifest/")
+}
+
+// isHTTPURL reports whether url is read over HTTP(S)
+func isHTTPURL(url string) bool {
+	lower := strings.ToLower(url)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// CopiesVideo reports whether output options copy the video stream as is,
+// as the default "-c:v copy" does, rather than re-encoding it
+func CopiesVideo(outputOptions []string) bool {
+	codec := ""
+	for i := 0; i+1 < len(outputOptions); i++ {
+		switch outputOptions[i] {
+		case "-c:v", "-codec:v", "-vcodec", "-c", "-codec":
+			codec = outputOptions[i+1]
+		}
+	}
+	return codec == "copy"
+}
+
+// videoOptions are output options (taking one value) that configure the
+// video stream or override the audio codec, dropped in audio-only mode
+var videoOptions = map[string]bool{
+	"-c:v": true, "-codec:v": true, "-vcodec": true,
+	"-b:v": true, "-maxrate:v": true, "-bufsize:v": true,
+	"-vf": true, "-filter:v": true, "-r": true, "-s": true,
+	"-pix_fmt": true, "-profile:v": true, "-preset": true, "-tune": true,
+	"-g": true, "-crf": true,
+	"-c:a": true, "-codec:a": true, "-acodec": true,
+}
+
+// stripVideoOptions removes video options and their values from opts
+func stripVideoOptions(opts []string) []string {
+	var result []string
+	for i := 0; i < len(opts); i++ {
+		if videoOptions[opts[i]] {
+			i++ // skip the option's value
+			continue
+		}
+		result = append(result, opts[i])
+	}
+	return result
+}
+
 // Stop stops the FFmpeg process
 func (p *FFmpegProcess) Stop() error {
+	// Waiting for the exit happens without the lock: the reaper closes
+	// done only after the output readers finish, and ffmpeg keeps writing
+	// until it exits
 	p.mu.Lock()
-	defer p.mu.Unlock()
-
 	if p.cmd == nil || p.cmd.Process == nil {
+		p.mu.Unlock()
 		return nil
 	}
-
-	// Cancel the context first
-	if p.cancel != nil {
-		p.cancel()
+	pid, cancel, stdin := p.cmd.Process.Pid, p.cancel, p.stdin
+	p.mu.Unlock()
+
+	// Signal the whole process group so helpers ffmpeg spawned exit too.
+	// Check before cancelling, which kills ffmpeg and ends its group.
+	group := process.OwnsGroup(pid)
+
+	if quit(stdin) {
+		// Cancelling kills ffmpeg; that is the fallback here
+		select {
+		case <-p.done:
+		case <-time.After(stopTimeout):
+			process.Signal(pid, group, syscall.SIGKILL)
+			<-p.done
+		}
+		if cancel != nil {
+			cancel()
+		}
+		if group {
+			process.Signal(pid, true, syscall.SIGKILL)
+		}
+		return nil
 	}
 
-	// Try graceful shutdown with SIGTERM
-	if err := p.cmd.Process.Signal(syscall.SIGTERM); err != nil {
-		// Process might already be dead
-		if !strings.Contains(err.Error(), "process already finished") {
-			// Force kill
-			p.cmd.Process.Kill()
-		}
+	// Try graceful shutdown with SIGTERM. The context is cancelled only
+	// afterwards, as cancelling kills ffmpeg before it can finish.
+	if err := process.Signal(pid, group, syscall.SIGTERM); err != nil {
+		process.Signal(pid, group, syscall.SIGKILL)
 	}
 
 	// Wait for process to exit with timeout
 	select {
 	case <-p.done:
 		// Process exited
-	case <-time.After(5 * time.Second):
+	case <-time.After(stopTimeout):
 		// Force kill after timeout
-		p.cmd.Process.Kill()
+		process.Signal(pid, group, syscall.SIGKILL)
 		<-p.done
 	}
+	if cancel != nil {
+		cancel()
+	}
+
+	// Kill whatever is left of the group
+	if group {
+		process.Signal(pid, true, syscall.SIGKILL)
+	}
 
 	return nil
 }
 
+// quit asks ffmpeg to finish by typing "q" on its stdin, reporting whether
+// that was possible
+func quit(stdin io.Writer) bool {
+	if stdin == nil {
+		return false
+	}
+	_, err := io.WriteString(stdin, "q")
+	return err == nil
+}
+
 // IsRunning checks if the FFmpeg process is still running
 func (p *FFmpegProcess) IsRunning() bool {
 	p.mu.Lock()
@@ -185,11 +558,105 @@ func (p *FFmpegProcess) GetPID() int {
 	return p.pid
 }
 
-// GetStderr returns captured stderr output
+// GetArgs returns the arguments ffmpeg was started with. They include the
+// signed source URLs; print them through RedactCommand.
+func (p *FFmpegProcess) GetArgs() []string {
+	return p.args
+}
+
+// readStderr logs each stderr line and records it in the ring buffer.
+// Carriage-return progress updates are logged (throttled by the logger)
+// but not recorded.
+func (p *FFmpegProcess) readStderr(r io.Reader, log *logger.StreamLogger) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanLogLines)
+	cut := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Drop the rest of a line cut at maxStderrLine
+		rest := cut
+		cut = !strings.HasSuffix(line, "\n") && !strings.HasSuffix(line, "\r")
+		if rest {
+			continue
+		}
+		isProgress := strings.HasSuffix(line, "\r")
+		line = strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if isProgress {
+			log.Info("ffmpeg: %s\r", line)
+			continue
+		}
+		log.Info("ffmpeg: %s", line)
+		// The ring buffer ends up in stream errors
+		p.recordStderr(log.Redact(line))
+	}
+
+	// Keep draining so ffmpeg never blocks on a full pipe
+	io.Copy(io.Discard, r)
+}
+
+// scanLogLines is a bufio.SplitFunc splitting on \n or \r, keeping the
+// terminator so progress updates (\r) can be told apart. Lines longer than
+// maxStderrLine come in pieces without a terminator, so that one huge line
+// cannot stop the scanner.
+func scanLogLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 && i < maxStderrLine {
+		return i + 1, data[:i+1], nil
+	}
+	if len(data) >= maxStderrLine {
+		return maxStderrLine, data[:maxStderrLine], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// recordStderr appends a line to the ring buffer
+func (p *FFmpegProcess) recordStderr(line string) {
+	p.stderrMu.Lock()
+	defer p.stderrMu.Unlock()
+
+	if len(p.stderr) < stderrHistory {
+		p.stderr = append(p.stderr, line)
+		return
+	}
+	p.stderr[p.stderrPos] = line
+	p.stderrPos = (p.stderrPos + 1) % stderrHistory
+}
+
+// StderrTail returns up to n of the most recent stderr lines, oldest first
+func (p *FFmpegProcess) StderrTail(n int) []string {
+	p.stderrMu.Lock()
+	defer p.stderrMu.Unlock()
+
+	lines := make([]string, 0, len(p.stderr))
+	lines = append(lines, p.stderr[p.stderrPos:]...)
+	lines = append(lines, p.stderr[:p.stderrPos]...)
+	if n > 0 && n < len(lines) {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// GetStderr returns the most recent stderr output
 func (p *FFmpegProcess) GetStderr() string {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	return p.stderr.String()
+	return strings.Join(p.StderrTail(0), "\n")
+}
+
+// ExitedCleanly reports whether the process has exited with status 0, as
+// ffmpeg does when it reaches the end of a finite input
+func (p *FFmpegProcess) ExitedCleanly() bool {
+	// Allow the reaper a moment if the process only just exited
+	select {
+	case <-p.done:
+		return p.exitErr == nil
+	case <-time.After(time.Second):
+		return false
+	}
 }
 
 // GetStartTime returns when the process was started
@@ -204,45 +671,86 @@ func (p *FFmpegProcess) Done() <-chan struct{} {
 	return p.done
 }
 
-// CheckBinary verifies that ffmpeg binary exists and is executable
-func (m *FFmpegManager) CheckBinary() error {
+// CheckBinary verifies that ffmpeg binary exists and is executable,
+// returning the reported version
+func (m *FFmpegManager) CheckBinary() (string, error) {
 	cmd := exec.Command(m.config.BinaryPath, "-version")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("ffmpeg not found or not executable: %w", err)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg not found or not executable: %w", err)
 	}
-	return nil
+	return ParseFFmpegVersion(string(output)), nil
 }
 
-// KillByPID kills an FFmpeg process by PID
-func KillByPID(pid int) error {
-	if pid <= 0 {
-		return nil
+// ParseFFmpegVersion extracts the version from `ffmpeg -version` output
+// (e.g. "ffmpeg version 6.1.1-3ubuntu5 Copyright ..." -> "6.1.1-3ubuntu5")
+func ParseFFmpegVersion(output string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	fields := strings.Fields(line)
+	if len(fields) >= 3 && fields[0] == "ffmpeg" && fields[1] == "version" {
+		return fields[2]
 	}
+	return strings.TrimSpace(line)
+}
 
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		return nil // Process doesn't exist
+// KillByPID kills the FFmpeg process recorded with pid and startTime that
+// publishes to target. Stored PIDs are killed when ffmpeg is likely dead
+// already, so a process that has since reused the PID is left alone.
+func KillByPID(pid int, startTime uint64, target string) error {
+	if !IsStreamProcessAlive(pid, startTime, target) {
+		return nil
 	}
 
+	// Streams started by ffmpeg lead their own process group; signal all
+	// of it so no helper processes are left behind
+	group := process.OwnsGroup(pid)
+
 	// Try SIGTERM first
-	if err := process.Signal(syscall.SIGTERM); err != nil {
-		if !strings.Contains(err.Error(), "process already finished") {
-			// Force kill
-			process.Kill()
-		}
+	if err := process.Signal(pid, group, syscall.SIGTERM); err != nil {
+		// Force kill
+		process.Signal(pid, group, syscall.SIGKILL)
 	}
 
 	// Wait a bit for graceful shutdown
 	time.Sleep(500 * time.Millisecond)
 
 	// Check if still alive and force kill
-	if err := process.Signal(syscall.Signal(0)); err == nil {
-		process.Kill()
+	if process.Alive(pid, group) {
+		process.Signal(pid, group, syscall.SIGKILL)
 	}
 
 	return nil
 }
 
+// PublishURL returns the URL ffmpeg publishes a stream to, on the MediaMTX
+// at host
+func PublishURL(host string, port int, rtspPath string) string {
+	return config.RTSPURL(host, port, rtspPath)
+}
+
+// IsStreamProcessAlive checks that the ffmpeg process recorded with pid and
+// startTime is alive and publishing to output. A process that reused the
+// PID does not count.
+func IsStreamProcessAlive(pid int, startTime uint64, output string) bool {
+	return IsProcessAlive(pid) && process.Matches(pid, startTime) && publishesTo(pid, output)
+}
+
+// publishesTo reports whether the command line of pid has output as an
+// argument, alone or as one of the targets of the tee muxer. It is true if
+// the command line cannot be read.
+func publishesTo(pid int, output string) bool {
+	args, err := process.Cmdline(pid)
+	if err != nil {
+		return true
+	}
+	for _, arg := range args {
+		if slices.Contains(teeTargets(arg), output) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsProcessAlive checks if a process with given PID is alive
 func IsProcessAlive(pid int) bool {
 	if pid <= 0 {
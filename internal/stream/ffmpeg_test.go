@@ -0,0 +1,578 @@
+package stream
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/logger"
+)
+
+// writeScript writes an executable shell script into dir
+func writeScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// startFake starts script as the ffmpeg of a stream, with the given
+// ffmpeg config
+func startFake(t *testing.T, script string, cfg config.FFmpegConfig) *FFmpegProcess {
+	t.Helper()
+	cfg.BinaryPath = script
+	log := logger.NewLoggerManager(t.TempDir(), 100)
+	t.Cleanup(log.CloseAll)
+
+	s := NewStream("news", "https://youtu.be/abc123", 8554)
+	s.SetStreamURLs("https://example.com/video.m3u8", "", nil)
+	proc, err := NewFFmpegManager(&cfg).Start(context.Background(), s, log.GetLogger("news"))
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return proc
+}
+
+// stopWithin runs proc.Stop, failing if it does not return within limit
+func stopWithin(t *testing.T, proc *FFmpegProcess, limit time.Duration) time.Duration {
+	t.Helper()
+	start := time.Now()
+	stopped := make(chan struct{})
+	go func() {
+		proc.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return time.Since(start)
+	case <-time.After(limit):
+		t.Fatalf("Stop did not return within %v", limit)
+		return 0
+	}
+}
+
+// waitForFile waits until path exists, so a script's trap is installed
+func waitForFile(t *testing.T, path string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s was not created", filepath.Base(path))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStopWhileFFmpegWritesStderr(t *testing.T) {
+	dir := t.TempDir()
+	ready := filepath.Join(dir, "ready")
+	// Like ffmpeg, report the signal on stderr while exiting
+	script := writeScript(t, dir, "ffmpeg", `
+trap 'i=0; while [ $i -lt 300 ]; do echo "Exiting normally, received signal 15." >&2; i=$((i+1)); done; exit 255' TERM
+touch "`+ready+`"
+while :; do sleep 0.05; done
+`)
+	proc := startFake(t, script, config.FFmpegConfig{})
+	waitForFile(t, ready)
+
+	if elapsed := stopWithin(t, proc, 2*stopTimeout); elapsed >= stopTimeout {
+		t.Errorf("Stop took %v, ffmpeg was killed instead of exiting on SIGTERM", elapsed)
+	}
+	tail := proc.StderrTail(1)
+	if len(tail) != 1 || !strings.Contains(tail[0], "received signal 15") {
+		t.Errorf("StderrTail after Stop = %q, want the exit message", tail)
+	}
+}
+
+func TestStopGracefulQuit(t *testing.T) {
+	dir := t.TempDir()
+	ready := filepath.Join(dir, "ready")
+	stdinLog := filepath.Join(dir, "stdin")
+	// Finish cleanly on the first byte of stdin, writing to stderr on the
+	// way out as ffmpeg does; signals are ignored to show none was needed
+	script := writeScript(t, dir, "ffmpeg", `
+trap '' TERM
+touch "`+ready+`"
+head -c 1 > "`+stdinLog+`"
+echo "Exiting normally, received q" >&2
+exit 0
+`)
+	proc := startFake(t, script, config.FFmpegConfig{GracefulQuit: true})
+	waitForFile(t, ready)
+
+	if elapsed := stopWithin(t, proc, 2*stopTimeout); elapsed >= stopTimeout {
+		t.Errorf("Stop took %v, ffmpeg was killed instead of quitting", elapsed)
+	}
+	got, err := os.ReadFile(stdinLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "q" {
+		t.Errorf("ffmpeg read %q on stdin, want \"q\"", got)
+	}
+	if !proc.ExitedCleanly() {
+		t.Error("ffmpeg did not exit on its own")
+	}
+}
+
+func TestStderrPathologicalOutput(t *testing.T) {
+	dir := t.TempDir()
+	done := filepath.Join(dir, "done")
+	// A megabyte-long line, a progress bar redrawn with \r and an error
+	// wrapped in ANSI colours
+	script := writeScript(t, dir, "ffmpeg", `
+head -c 1048576 /dev/zero | tr '\0' x >&2
+echo >&2
+i=0; while [ $i -lt 50 ]; do printf 'frame=%d fps=30\r' $i >&2; i=$((i+1)); done
+printf '\033[31m[https @ 0x55] HTTP error 403 Forbidden\033[0m\n' >&2
+touch "`+done+`"
+while :; do sleep 0.05; done
+`)
+	proc := startFake(t, script, config.FFmpegConfig{})
+	defer proc.Stop()
+	waitForFile(t, done)
+
+	// The error shows up after the long line, without the progress updates
+	var tail []string
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		tail = proc.StderrTail(0)
+		if len(tail) > 0 && strings.Contains(tail[len(tail)-1], "403 Forbidden") || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(tail) != 2 {
+		t.Fatalf("stderr tail has %d lines, want the long line and the error", len(tail))
+	}
+	if len(tail[0]) != maxStderrLine || strings.Trim(tail[0], "x") != "" {
+		t.Errorf("long line recorded with %d bytes, want its first %d", len(tail[0]), maxStderrLine)
+	}
+	if !strings.Contains(tail[1], "HTTP error 403 Forbidden") {
+		t.Errorf("last stderr line = %q, want the error", tail[1])
+	}
+}
+
+func TestProbeOptionsPrecedeInputs(t *testing.T) {
+	m := NewFFmpegManager(&config.FFmpegConfig{Probesize: "10M", AnalyzeDuration: 5 * time.Second})
+	args := m.buildArgs(argsOptions{
+		inputURL: "https://example.com/video.m3u8",
+		audioURL: "https://example.com/audio.m3u8",
+		targets:  []string{"rtsp://localhost:8554/news"},
+	})
+
+	// Each input gets the probe options of its own, before its -i
+	var inputs int
+	probesize, analyzeduration := -1, -1
+	for i, arg := range args {
+		switch arg {
+		case "-probesize":
+			probesize = i
+			if args[i+1] != "10485760" {
+				t.Errorf("-probesize %s, want 10485760", args[i+1])
+			}
+		case "-analyzeduration":
+			analyzeduration = i
+			if args[i+1] != "5000000" {
+				t.Errorf("-analyzeduration %s, want 5000000 (microseconds)", args[i+1])
+			}
+		case "-i":
+			inputs++
+			if probesize < 0 || analyzeduration < 0 {
+				t.Errorf("input %d (%s) has no probe options before it: %q", inputs, args[i+1], args)
+			}
+			probesize, analyzeduration = -1, -1
+		}
+	}
+	if inputs != 2 {
+		t.Errorf("%d inputs, want 2: %q", inputs, args)
+	}
+}
+
+func TestProbeOptionsUnsetByDefault(t *testing.T) {
+	m := NewFFmpegManager(&config.FFmpegConfig{})
+	args := m.buildArgs(argsOptions{inputURL: "https://example.com/video.m3u8", targets: []string{"rtsp://localhost:8554/news"}})
+	for _, arg := range args {
+		if arg == "-probesize" || arg == "-analyzeduration" {
+			t.Errorf("unset probe option %s in args %q", arg, args)
+		}
+	}
+}
+
+// inputOptions returns the options given before each -i in args, by input
+// URL
+func inputOptions(args []string) map[string][]string {
+	inputs := make(map[string][]string)
+	start := 0
+	for i, arg := range args {
+		if arg == "-i" && i+1 < len(args) {
+			inputs[args[i+1]] = args[start:i]
+			start = i + 2
+		}
+	}
+	return inputs
+}
+
+// optionValue returns the value of option in args, or "" if it is absent
+func optionValue(args []string, option string) string {
+	if i := slices.Index(args, option); i >= 0 && i+1 < len(args) {
+		return args[i+1]
+	}
+	return ""
+}
+
+func TestHeadersReachEachInput(t *testing.T) {
+	recommended := map[string]string{
+		"User-Agent":      "yt-dlp's agent",
+		"Accept-Language": "en-us,en;q=0.5",
+		"Referer":         "https://example.com/",
+	}
+	tests := []struct {
+		name          string
+		cfg           config.FFmpegConfig
+		headers       map[string]string
+		wantUserAgent string
+		wantHeaders   string
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:          "configured",
+			cfg:           config.FFmpegConfig{UserAgent: "Mozilla/5.0", Headers: []string{"Referer: https://www.youtube.com/"}},
+			wantUserAgent: "Mozilla/5.0",
+			wantHeaders:   "Referer: https://www.youtube.com/\r\n",
+		},
+		{
+			name:          "recommended",
+			headers:       recommended,
+			wantUserAgent: "yt-dlp's agent",
+			wantHeaders:   "Accept-Language: en-us,en;q=0.5\r\nReferer: https://example.com/\r\n",
+		},
+		{
+			name:          "configured override recommended",
+			cfg:           config.FFmpegConfig{UserAgent: "Mozilla/5.0", Headers: []string{"referer: https://www.youtube.com/"}},
+			headers:       recommended,
+			wantUserAgent: "Mozilla/5.0",
+			wantHeaders:   "Accept-Language: en-us,en;q=0.5\r\nreferer: https://www.youtube.com/\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := NewFFmpegManager(&tt.cfg).buildArgs(argsOptions{
+				inputURL: "https://example.com/video.m3u8",
+				audioURL: "https://example.com/audio.m3u8",
+				headers:  tt.headers,
+				targets:  []string{"rtsp://localhost:8554/news"},
+			})
+			inputs := inputOptions(args)
+			if len(inputs) != 2 {
+				t.Fatalf("%d inputs, want 2: %q", len(inputs), args)
+			}
+			for url, options := range inputs {
+				if got := optionValue(options, "-user_agent"); got != tt.wantUserAgent {
+					t.Errorf("input %s: -user_agent %q, want %q", url, got, tt.wantUserAgent)
+				}
+				if got := optionValue(options, "-headers"); got != tt.wantHeaders {
+					t.Errorf("input %s: -headers %q, want %q", url, got, tt.wantHeaders)
+				}
+			}
+		})
+	}
+}
+
+func TestHeadersOnlyForHTTPInputs(t *testing.T) {
+	m := NewFFmpegManager(&config.FFmpegConfig{UserAgent: "Mozilla/5.0", Headers: []string{"Referer: https://www.youtube.com/"}})
+	args := m.buildArgs(argsOptions{inputURL: "rtsp://camera.lan/live", targets: []string{"rtsp://localhost:8554/news"}})
+	options, ok := inputOptions(args)["rtsp://camera.lan/live"]
+	if !ok {
+		t.Fatalf("no RTSP input in %q", args)
+	}
+	for _, option := range options {
+		if option == "-user_agent" || option == "-headers" {
+			t.Errorf("RTSP input has HTTP option %s: %q", option, args)
+		}
+	}
+}
+
+func TestParseFFmpegVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{
+			"ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers\nbuilt with gcc 13 (Ubuntu 13.2.0-23ubuntu3)\n",
+			"6.1.1-3ubuntu5",
+		},
+		{"ffmpeg version n7.1 Copyright (c) 2000-2024 the FFmpeg developers\n", "n7.1"},
+		{"ffmpeg version N-118049-g4cbdb5b Copyright (c) 2000-2025 the FFmpeg developers\n", "N-118049-g4cbdb5b"},
+		// Anything else is reported as the first line
+		{"avconv version 12.3\n", "avconv version 12.3"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ParseFFmpegVersion(tt.output); got != tt.want {
+			t.Errorf("ParseFFmpegVersion(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestAudioOnlyArgs(t *testing.T) {
+	m := NewFFmpegManager(&config.FFmpegConfig{OutputOptions: []string{"-c:v", "copy", "-c:a", "aac", "-b:v", "4M", "-f", "rtsp"}})
+	for _, tt := range []struct {
+		name       string
+		audioOnly  bool
+		audioURL   string
+		wantInputs []string
+	}{
+		{"video", false, "", []string{"https://example.com/video.m3u8"}},
+		{"audio only", true, "", []string{"https://example.com/video.m3u8"}},
+		{"audio only, merged format", true, "https://example.com/audio.m3u8", []string{"https://example.com/audio.m3u8"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStream("news", "https://youtu.be/abc123", 8554)
+			s.AudioOnly = tt.audioOnly
+			s.SetStreamURLs("https://example.com/video.m3u8", tt.audioURL, nil)
+			args, err := m.Args(s, t.Logf)
+			if err != nil {
+				t.Fatalf("Args: %v", err)
+			}
+			joined := strings.Join(args, " ")
+
+			var inputs []string
+			for i, arg := range args[:len(args)-1] {
+				if arg == "-i" {
+					inputs = append(inputs, args[i+1])
+				}
+			}
+			if !slices.Equal(inputs, tt.wantInputs) {
+				t.Errorf("inputs = %q, want %q", inputs, tt.wantInputs)
+			}
+
+			if !tt.audioOnly {
+				if strings.Contains(joined, "-vn") || !strings.Contains(joined, "-c:v copy") {
+					t.Errorf("args = %q, want the video copied", args)
+				}
+				return
+			}
+			if !strings.Contains(joined, "-vn -c:a aac") {
+				t.Errorf("args = %q, want -vn -c:a aac", args)
+			}
+			for _, video := range []string{"-c:v", "-b:v", "0:v:0"} {
+				if slices.Contains(args, video) {
+					t.Errorf("args = %q, want no %s", args, video)
+				}
+			}
+		})
+	}
+}
+
+func TestLoopArgs(t *testing.T) {
+	m := NewFFmpegManager(&config.FFmpegConfig{})
+	for _, tt := range []struct {
+		name     string
+		loop     bool
+		live     bool
+		url      string
+		want     bool
+		wantWarn bool
+	}{
+		{"off", false, false, "https://example.com/video.mp4", false, false},
+		{"video file", true, false, "https://example.com/video.mp4", true, false},
+		{"live source", true, true, "https://example.com/video.mp4", false, true},
+		{"HLS manifest", true, false, "https://example.com/live/index.m3u8", false, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStream("news", "https://youtu.be/abc123", 8554)
+			s.Loop = tt.loop
+			s.IsLive = tt.live
+			s.SetStreamURLs(tt.url, "", nil)
+			var warnings []string
+			args, err := m.Args(s, func(format string, args ...interface{}) {
+				warnings = append(warnings, format)
+			})
+			if err != nil {
+				t.Fatalf("Args: %v", err)
+			}
+
+			if got := optionValue(inputOptions(args)[tt.url], "-stream_loop"); (got == "-1") != tt.want {
+				t.Errorf("args = %q, want looping %v", args, tt.want)
+			}
+			if (len(warnings) > 0) != tt.wantWarn {
+				t.Errorf("warnings = %q, want a warning %v", warnings, tt.wantWarn)
+			}
+		})
+	}
+}
+
+func TestTransportArgs(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		configured string
+		stream     string
+		outputs    []string
+		want       string
+	}{
+		{"configured", "udp", "", nil, "-rtsp_transport udp rtsp://localhost:8554/news"},
+		{"overridden", "tcp", "udp", nil, "-rtsp_transport udp rtsp://localhost:8554/news"},
+		{"unset", "", "", nil, "-rtsp_transport tcp rtsp://localhost:8554/news"},
+		{"tee", "tcp", "udp", []string{LocalOutput, "rtsp://relay.lan:8554/news"}, "[f=rtsp:rtsp_transport=udp]rtsp://relay.lan:8554/news"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewFFmpegManager(&config.FFmpegConfig{RTSPTransport: tt.configured})
+			s := NewStream("news", "https://youtu.be/abc123", 8554)
+			s.Transport = tt.stream
+			s.Outputs = tt.outputs
+			s.SetStreamURLs("https://example.com/video.m3u8", "", nil)
+			args, err := m.Args(s, t.Logf)
+			if err != nil {
+				t.Fatalf("Args: %v", err)
+			}
+			if !strings.Contains(strings.Join(args, " "), tt.want) {
+				t.Errorf("args = %q, want %q", args, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxBitrateArgs(t *testing.T) {
+	transcode := []string{"-c:v", "libx264", "-preset", "veryfast", "-c:a", "aac", "-f", "rtsp"}
+	copyVideo := []string{"-c:v", "copy", "-c:a", "aac", "-f", "rtsp"}
+	for _, tt := range []struct {
+		name          string
+		outputOptions []string
+		maxBitrate    int64
+		audioOnly     bool
+		wantLimit     bool
+		wantWarn      bool
+	}{
+		{"transcode", transcode, 4000000, false, true, false},
+		{"transcode, no limit", transcode, 0, false, false, false},
+		{"copy", copyVideo, 4000000, false, false, true},
+		{"audio only", transcode, 4000000, true, false, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewFFmpegManager(&config.FFmpegConfig{OutputOptions: tt.outputOptions})
+			s := NewStream("news", "https://youtu.be/abc123", 8554)
+			s.MaxBitrate = tt.maxBitrate
+			s.AudioOnly = tt.audioOnly
+			s.SetStreamURLs("https://example.com/video.m3u8", "", nil)
+			var warnings []string
+			args, err := m.Args(s, func(format string, args ...interface{}) {
+				warnings = append(warnings, format)
+			})
+			if err != nil {
+				t.Fatalf("Args: %v", err)
+			}
+
+			maxrate, bufsize := optionValue(args, "-maxrate"), optionValue(args, "-bufsize")
+			if tt.wantLimit {
+				if maxrate != "4000000" || bufsize != "8000000" {
+					t.Errorf("args = %q, want -maxrate 4000000 -bufsize 8000000", args)
+				}
+			} else if maxrate != "" || bufsize != "" {
+				t.Errorf("args = %q, want no bitrate limit", args)
+			}
+			if (len(warnings) > 0) != tt.wantWarn {
+				t.Errorf("warnings = %q, want a warning %v", warnings, tt.wantWarn)
+			}
+		})
+	}
+}
+
+// outputOptions maps each output URL in args to the options preceding it,
+// back to the previous output or the last input
+func outputOptions(args []string) map[string][]string {
+	outputs := make(map[string][]string)
+	start := 0
+	for i, arg := range args {
+		switch {
+		case arg == "-i" && i+1 < len(args):
+			start = i + 2
+		case i >= start && strings.HasPrefix(arg, "rtsp://"):
+			outputs[arg] = args[start:i]
+			start = i + 1
+		}
+	}
+	return outputs
+}
+
+func TestCompanionOutputArgs(t *testing.T) {
+	m := NewFFmpegManager(&config.FFmpegConfig{OutputOptions: []string{"-c:v", "copy", "-c:a", "aac", "-f", "rtsp"}})
+	const (
+		main  = "rtsp://localhost:8554/news"
+		audio = "rtsp://localhost:8554/news_audio"
+		sub   = "rtsp://localhost:8554/news_sub"
+	)
+	for _, tt := range []struct {
+		name      string
+		audioURL  string
+		substream string
+		want      map[string][]string // maps of each output
+	}{
+		{"audio copy", "", "", map[string][]string{main: nil, audio: {"0:a:0"}}},
+		{"audio copy, merged format", "https://example.com/audio.m3u8", "", map[string][]string{
+			main:  {"0:v:0", "1:a:0"},
+			audio: {"1:a:0"},
+		}},
+		{"audio copy and substream", "", "640x360@15", map[string][]string{
+			main:  nil,
+			sub:   {"0:v:0", "0:a:0?"},
+			audio: {"0:a:0"},
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStream("news", "https://youtu.be/abc123", 8554)
+			s.AudioCopy = true
+			s.Substream = tt.substream
+			s.SetStreamURLs("https://example.com/video.m3u8", tt.audioURL, nil)
+			args, err := m.Args(s, t.Logf)
+			if err != nil {
+				t.Fatalf("Args: %v", err)
+			}
+
+			// One ffmpeg reads the source once for all outputs
+			wantInputs := 1
+			if tt.audioURL != "" {
+				wantInputs = 2
+			}
+			if got := strings.Count(" "+strings.Join(args, " ")+" ", " -i "); got != wantInputs {
+				t.Errorf("args = %q, want %d inputs", args, wantInputs)
+			}
+
+			outputs := outputOptions(args)
+			if len(outputs) != len(tt.want) {
+				t.Fatalf("args = %q, want %d outputs", args, len(tt.want))
+			}
+			for url, wantMaps := range tt.want {
+				opts, ok := outputs[url]
+				if !ok {
+					t.Errorf("args = %q, want an output to %s", args, url)
+					continue
+				}
+				var maps []string
+				for i, opt := range opts[:max(len(opts)-1, 0)] {
+					if opt == "-map" {
+						maps = append(maps, opts[i+1])
+					}
+				}
+				if !slices.Equal(maps, wantMaps) {
+					t.Errorf("output %s maps %q, want %q", url, maps, wantMaps)
+				}
+			}
+			if opts := outputs[audio]; !slices.Contains(opts, "-vn") || optionValue(opts, "-c:a") != "aac" {
+				t.Errorf("audio output options = %q, want -vn and AAC", opts)
+			}
+		})
+	}
+}
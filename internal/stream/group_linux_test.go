@@ -0,0 +1,87 @@
+//go:build linux
+
+package stream
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+)
+
+// gone reports whether pid has exited. An exited process not yet reaped by
+// its new parent counts as gone.
+func gone(pid int) bool {
+	if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+		return true
+	}
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+	_, rest, _ := strings.Cut(string(stat), ") ")
+	return strings.HasPrefix(rest, "Z")
+}
+
+// startForking starts a fake ffmpeg that spawns a helper process, and
+// returns it with the PID of the helper
+func startForking(t *testing.T) (*FFmpegProcess, int) {
+	t.Helper()
+	dir := t.TempDir()
+	helper := filepath.Join(dir, "helper")
+	script := writeScript(t, dir, "ffmpeg", `
+sleep 30 &
+echo $! > `+helper+`.tmp && mv `+helper+`.tmp `+helper+`
+while :; do sleep 0.05; done
+`)
+	proc := startFake(t, script, config.FFmpegConfig{})
+	waitForFile(t, helper)
+	data, err := os.ReadFile(helper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Kill(pid, syscall.SIGKILL) })
+	return proc, pid
+}
+
+// assertGone fails unless every pid exits within a second
+func assertGone(t *testing.T, pids ...int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for _, pid := range pids {
+		for !gone(pid) {
+			if time.Now().After(deadline) {
+				t.Errorf("process %d still runs", pid)
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestStopSignalsProcessGroup(t *testing.T) {
+	proc, helper := startForking(t)
+	stopWithin(t, proc, 5*time.Second)
+	assertGone(t, proc.GetPID(), helper)
+}
+
+func TestKillByPIDSignalsProcessGroup(t *testing.T) {
+	proc, helper := startForking(t)
+	t.Cleanup(func() { proc.Stop() })
+
+	target := NewStream("news", "https://youtu.be/abc123", 8554).PublishTargets()[0]
+	if err := KillByPID(proc.GetPID(), 0, target); err != nil {
+		t.Fatalf("KillByPID: %v", err)
+	}
+	assertGone(t, proc.GetPID(), helper)
+}
@@ -0,0 +1,63 @@
+package stream
+
+import (
+	"slices"
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
+)
+
+// historyLimit is the number of past sessions kept per stream
+const historyLimit = 100
+
+// EndSession records the run that started at StartedAt as ended at now for
+// reason. A run is only recorded once, however often it is ended.
+func (s *Stream) EndSession(now time.Time, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.History = appendSession(s.History, s.StartedAt, now, reason)
+}
+
+// EndSourceSession records that the source video changed for reason. The
+// current run ends for it; if the stream is being restarted onto the new
+// source, the run the restart just ended is given reason instead.
+func (s *Stream) EndSourceSession(now time.Time, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.History)
+	if !s.StartedAt.IsZero() && (n == 0 || s.StartedAt.After(s.History[n-1].Start)) {
+		s.History = appendSession(s.History, s.StartedAt, now, reason)
+		return
+	}
+	if n > 0 && (s.History[n-1].Reason == "restarted" || s.History[n-1].Reason == "source refreshed") {
+		// Copy so a carried history is never changed in place
+		s.History = slices.Clone(s.History)
+		s.History[n-1].Reason = reason
+	}
+}
+
+// appendSession adds the run from start to end to history, unless it is
+// already there, dropping the oldest runs beyond historyLimit
+func appendSession(history []storage.Session, start, end time.Time, reason string) []storage.Session {
+	if start.IsZero() || (len(history) > 0 && !start.After(history[len(history)-1].Start)) {
+		return history
+	}
+	// Clip so a carried history is never appended to in place
+	history = append(slices.Clip(history), storage.Session{Start: start, End: end, Reason: reason})
+	if len(history) > historyLimit {
+		history = history[len(history)-historyLimit:]
+	}
+	return history
+}
+
+// Sessions returns the past runs of the stream, oldest first, followed by
+// the current one with a zero End if it is running
+func (i *Info) Sessions() []storage.Session {
+	sessions := slices.Clone(i.History)
+	if i.StateString == StateRunning.String() && !i.StartedAt.IsZero() &&
+		(len(sessions) == 0 || i.StartedAt.After(sessions[len(sessions)-1].Start)) {
+		sessions = append(sessions, storage.Session{Start: i.StartedAt})
+	}
+	return sessions
+}
@@ -0,0 +1,132 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
+)
+
+func TestAppendSession(t *testing.T) {
+	start := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	history := appendSession(nil, start, end, "restarted")
+	if len(history) != 1 || history[0] != (storage.Session{Start: start, End: end, Reason: "restarted"}) {
+		t.Fatalf("history = %+v, want the one run", history)
+	}
+
+	// Ending the same run again, e.g. stopping a stream whose restart
+	// failed, keeps the first end
+	if again := appendSession(history, start, end.Add(time.Minute), "stopped"); len(again) != 1 {
+		t.Errorf("run recorded twice: %+v", again)
+	}
+	if none := appendSession(nil, time.Time{}, end, "stopped"); len(none) != 0 {
+		t.Errorf("run that never started recorded: %+v", none)
+	}
+
+	// The original is not appended to in place, as carried histories share it
+	shared := appendSession(history, end, end.Add(time.Hour), "stopped")
+	appendSession(history, end, end.Add(time.Minute), "video ended")
+	if shared[1].Reason != "stopped" {
+		t.Errorf("appending to a shared history changed another: %+v", shared)
+	}
+
+	for i := range historyLimit + 5 {
+		at := end.Add(time.Duration(i+1) * time.Hour)
+		history = appendSession(history, at, at.Add(time.Minute), "restarted")
+	}
+	if len(history) != historyLimit {
+		t.Errorf("%d runs kept, want %d", len(history), historyLimit)
+	}
+	if last := history[len(history)-1].Start; !last.Equal(end.Add((historyLimit + 5) * time.Hour)) {
+		t.Errorf("latest run starts at %v, want the last appended", last)
+	}
+}
+
+func TestInfoSessionsIncludesCurrentRun(t *testing.T) {
+	start := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	past := []storage.Session{{Start: start.Add(-time.Hour), End: start.Add(-time.Minute), Reason: "stopped"}}
+
+	running := Info{StateString: "running", StartedAt: start, History: past}
+	if got := running.Sessions(); len(got) != 2 || got[1] != (storage.Session{Start: start}) {
+		t.Errorf("running stream sessions = %+v, want the past run and an open one", got)
+	}
+	stopped := Info{StateString: "stopped", StartedAt: start.Add(-time.Hour), History: past}
+	if got := stopped.Sessions(); len(got) != 1 {
+		t.Errorf("stopped stream sessions = %+v, want the past run only", got)
+	}
+}
+
+func TestHistoryKeptAcrossStops(t *testing.T) {
+	m := newTestManager(t)
+
+	for range 2 {
+		if err := m.Start(context.Background(), "https://youtu.be/abc123", "news", 0, StartOptions{NoWait: true}); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Stop("news"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := m.Status("news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.History) != 2 {
+		t.Fatalf("history = %+v, want two runs", info.History)
+	}
+	for _, s := range info.History {
+		if s.Reason != "stopped" || s.End.Before(s.Start) {
+			t.Errorf("run %+v, want a stopped run ending after its start", s)
+		}
+	}
+}
+
+func TestSourceChangeEndsRun(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.Start(context.Background(), "https://youtu.be/abc123", "news", 0, StartOptions{NoWait: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := m.GetStream("news")
+	m.ApplyRefreshedSource(s, &extractor.StreamInfo{ID: "abc123", URL: "https://example.com/video-2.m3u8", IsLive: true})
+	m.ApplyRefreshedSource(s, &extractor.StreamInfo{ID: "def456", URL: "https://example.com/video-3.m3u8", IsLive: true})
+
+	data, err := m.storage.Load("news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.History) != 1 || data.History[0].Reason != "source changed: abc123 -> def456" {
+		t.Errorf("stored history = %+v, want one run ended by the source change", data.History)
+	}
+}
+
+func TestSourceChangeRelabelsRestartedRun(t *testing.T) {
+	start := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	carried := []storage.Session{
+		{Start: start, End: start.Add(time.Hour), Reason: "stopped"},
+		{Start: start.Add(2 * time.Hour), End: start.Add(3 * time.Hour), Reason: "restarted"},
+	}
+
+	// A stream restarted onto the new source has not started yet
+	s := NewStream("news", "https://youtu.be/abc123", 8554)
+	s.History = carried
+	s.EndSourceSession(start.Add(3*time.Hour), "source changed: abc123 -> def456")
+	if len(s.History) != 2 || s.History[1].Reason != "source changed: abc123 -> def456" {
+		t.Errorf("history = %+v, want the restarted run relabeled", s.History)
+	}
+	if carried[1].Reason != "restarted" {
+		t.Error("the carried history was changed in place")
+	}
+
+	// Runs that ended for other reasons keep them
+	s.History = carried[:1]
+	s.EndSourceSession(start.Add(3*time.Hour), "source changed: abc123 -> def456")
+	if len(s.History) != 1 || s.History[0].Reason != "stopped" {
+		t.Errorf("history = %+v, want the stopped run unchanged", s.History)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build linux
+
+package stream
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+)
+
+// applyLimits sets the configured niceness and address space limit of a
+// started ffmpeg process
+func applyLimits(pid int, cfg *config.FFmpegConfig) error {
+	if cfg.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, pid, cfg.Nice); err != nil {
+			return fmt.Errorf("failed to set nice %d: %w", cfg.Nice, err)
+		}
+	}
+
+	limit, err := config.ParseByteSize(cfg.MemoryLimit)
+	if err != nil {
+		return err
+	}
+	if limit > 0 {
+		rlim := syscall.Rlimit{Cur: uint64(limit), Max: uint64(limit)}
+		_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64,
+			uintptr(pid), syscall.RLIMIT_AS, uintptr(unsafe.Pointer(&rlim)), 0, 0, 0)
+		if errno != 0 {
+			return fmt.Errorf("failed to set memory limit %s: %w", cfg.MemoryLimit, errno)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+//go:build linux
+
+package stream
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+)
+
+func TestStartAppliesLimits(t *testing.T) {
+	script := writeScript(t, t.TempDir(), "ffmpeg", "exec sleep 60\n")
+	proc := startFake(t, script, config.FFmpegConfig{Nice: 10, MemoryLimit: "512M"})
+	t.Cleanup(func() { proc.Stop() })
+	pid := proc.GetPID()
+
+	// The raw syscall returns 20 - nice
+	prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nice := 20 - prio; nice != 10 {
+		t.Errorf("ffmpeg runs at nice %d, want 10", nice)
+	}
+
+	limits, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/limits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strconv.Itoa(512 << 20)
+	for _, line := range strings.Split(string(limits), "\n") {
+		if strings.HasPrefix(line, "Max address space") {
+			if fields := strings.Fields(line); fields[3] != want || fields[4] != want {
+				t.Errorf("address space limit %q, want %s bytes", line, want)
+			}
+			return
+		}
+	}
+	t.Error("no address space limit in /proc limits")
+}
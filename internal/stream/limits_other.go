@@ -0,0 +1,19 @@
+//go:build !linux
+
+package stream
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
+)
+
+// applyLimits reports that resource limits are not supported on this
+// platform, if any are configured
+func applyLimits(pid int, cfg *config.FFmpegConfig) error {
+	if cfg.Nice != 0 || cfg.MemoryLimit != "" {
+		return fmt.Errorf("ffmpeg.nice and ffmpeg.memory_limit are not supported on %s", runtime.GOOS)
+	}
+	return nil
+}
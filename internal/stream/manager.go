@@ -2,17 +2,52 @@ package stream
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/config"
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/hooks"
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/logger"
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/server"
 	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
 )
 
+// ErrStreamStopped is returned when restarting a stream that was stopped
+// in the meantime
+var ErrStreamStopped = errors.New("stream was stopped")
+
+// ErrNoHandover is returned by SwapSource for streams published only to
+// remote targets, which cannot be handed over to a new ffmpeg
+var ErrNoHandover = errors.New("stream publishes to remote targets only")
+
+// ErrStreamBusy is returned when a stream is not running steadily, because
+// it is being started, restarted or recovered
+var ErrStreamBusy = errors.New("stream is busy")
+
+// ErrDraining is returned when starting a stream on a manager that is
+// draining
+var ErrDraining = errors.New("manager draining")
+
+// ErrInvalidName is returned when starting a stream whose name is not a
+// valid stream name (see ValidateName)
+var ErrInvalidName = errors.New("invalid stream name")
+
+// ErrStreamNotFound is returned for a stream that is neither running nor
+// stored
+var ErrStreamNotFound = errors.New("not found")
+
+// notFound returns the ErrStreamNotFound error for a stream name
+func notFound(name string) error {
+	return fmt.Errorf("stream '%s' %w", name, ErrStreamNotFound)
+}
+
 // Manager manages all streams
 type Manager struct {
 	mu sync.RWMutex
@@ -20,12 +55,36 @@ type Manager struct {
 	streams   map[string]*Stream
 	processes map[string]*FFmpegProcess
 
+	// generations counts user-requested stops per stream name, so recovery
+	// started before a stop can tell it must not bring the stream back
+	generations map[string]uint64
+
+	// starting reserves the names of streams being started or restarted
+	// while their source is extracted outside the lock
+	starting map[string]struct{}
+
+	// draining rejects new streams while leaving running ones alone
+	draining bool
+
+	// sources shares extractions between streams of the same source
+	sources *sourceRegistry
+
+	// publishHost is where ffmpeg reaches MediaMTX's RTSP listener
+	publishHost string
+
 	config        *config.Config
 	extractor     extractor.Extractor
 	ffmpeg        *FFmpegManager
 	server        *server.MediaMTXServer
-	storage       *storage.FileStorage
+	storage       storage.Storage
 	loggerManager *logger.LoggerManager
+	hooks         *hooks.Runner
+	appLog        *slog.Logger
+	events        eventBus
+
+	// publishCommand returns the command MediaMTX runs to publish an
+	// on-demand stream
+	publishCommand func(name string) string
 }
 
 // NewManager creates a new stream manager
@@ -33,138 +92,742 @@ func NewManager(
 	cfg *config.Config,
 	ext extractor.Extractor,
 	srv *server.MediaMTXServer,
-	store *storage.FileStorage,
+	store storage.Storage,
+	appLog *slog.Logger,
 ) *Manager {
+	loggerManager := logger.NewLoggerManager(store.GetDataDir(), 100)
+	loggerManager.SetMaxLineLength(cfg.Logging.MaxLineLength)
+	loggerManager.SetRedactURLs(cfg.Logging.RedactURLs)
+	if level, err := logger.ParseLevel(cfg.Logging.Level); err == nil {
+		loggerManager.SetLevel(level)
+	}
+	if cfg.Logging.Rotation == "size" {
+		maxSize, _ := config.ParseByteSize(cfg.Logging.MaxSize) // validated on load
+		loggerManager.SetSizeRotation(maxSize, cfg.Logging.MaxArchives, cfg.Logging.CompressArchives)
+	}
+
 	return &Manager{
 		streams:       make(map[string]*Stream),
 		processes:     make(map[string]*FFmpegProcess),
+		generations:   make(map[string]uint64),
+		starting:      make(map[string]struct{}),
+		sources:       newSourceRegistry(),
+		publishHost:   config.DialHost(cfg.Server.RTSPAddress),
 		config:        cfg,
 		extractor:     ext,
 		ffmpeg:        NewFFmpegManager(&cfg.FFmpeg),
 		server:        srv,
 		storage:       store,
-		loggerManager: logger.NewLoggerManager(store.GetDataDir(), 100),
+		loggerManager: loggerManager,
+		hooks:         hooks.NewRunner(&cfg.Hooks),
+		appLog:        appLog.With("component", "manager"),
 	}
 }
 
+// carriedState is the part of a stream's state kept across a restart
+type carriedState struct {
+	videoID   string
+	title     string
+	changedAt time.Time
+	isLive    bool
+	usage     []storage.UsageBucket
+	history   []storage.Session
+
+	errorCount int
+	lastError  string
+	logLevel   string
+}
+
+// StartOptions holds per-stream options kept across reconnects
+type StartOptions struct {
+	AudioOnly bool   // proxy the audio track only
+	Loop      bool   // restart non-live sources when they end
+	Transport string // RTSP transport to MediaMTX (empty = ffmpeg.rtsp_transport)
+	Managed   bool   // started from the config's streams section
+	NoWait    bool   // return once ffmpeg runs, without waiting for MediaMTX to receive the stream
+	Format    string // yt-dlp format selector (empty = ytdlp.format, or audio with AudioOnly)
+
+	// MaxBitrate caps the video bitrate in bits/s when ffmpeg re-encodes
+	// (0 = unlimited). Copied video cannot be limited.
+	MaxBitrate int64
+
+	// Outputs are the targets to publish to instead of the local MediaMTX
+	// path; LocalOutput adds that path (empty = the local path only)
+	Outputs []string
+
+	// Substream publishes a low-resolution copy to <path>_sub, e.g.
+	// "640x360@15" (see ParseSubstream; empty = none)
+	Substream string
+
+	// RequireH264 re-encodes sources whose video is not H.264 when
+	// ffmpeg.output_options copy video, instead of failing to start
+	RequireH264 bool
+
+	// AudioCopy publishes the audio alone to <path>_audio as well
+	AudioCopy bool
+}
+
 // Start starts a new stream
-func (m *Manager) Start(ctx context.Context, youtubeURL, name string, port int) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+func (m *Manager) Start(ctx context.Context, youtubeURL, name string, port int, opts StartOptions) error {
+	if err := m.start(ctx, youtubeURL, name, port, opts, nil); err != nil {
+		return err
+	}
 
-	log := m.loggerManager.GetLogger(name)
+	if stream := m.GetStream(name); stream != nil {
+		m.FireHook(hooks.EventStart, stream, "")
+	}
+	return nil
+}
 
-	// Check if stream already exists
+// start starts a new stream. The name is reserved while the stream is
+// launched, so other streams can start and be listed meanwhile.
+func (m *Manager) start(ctx context.Context, youtubeURL, name string, port int, opts StartOptions, prev *carriedState) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if m.draining {
+		m.mu.Unlock()
+		return ErrDraining
+	}
 	if _, exists := m.streams[name]; exists {
+		m.mu.Unlock()
 		return fmt.Errorf("stream '%s' already exists", name)
 	}
+	if _, starting := m.starting[name]; starting {
+		m.mu.Unlock()
+		return fmt.Errorf("stream '%s' is already starting", name)
+	}
+	m.starting[name] = struct{}{}
+	generation := m.generations[name]
+	m.mu.Unlock()
 
-	// Use default port if not specified
-	if port == 0 {
-		port = m.config.Server.RTSPPort
+	stream, proc, err := m.launch(ctx, youtubeURL, name, port, opts, prev)
+
+	m.mu.Lock()
+	delete(m.starting, name)
+	if err != nil {
+		m.mu.Unlock()
+		return err
 	}
+	err = m.register(stream, proc, generation)
+	m.mu.Unlock()
+	if err != nil {
+		proc.Stop()
+	}
+	return err
+}
+
+// register stores a launched stream, unless it was stopped while it was
+// launching. Then it returns ErrStreamStopped, and the caller stops proc
+// once the lock is released (must be called with lock held).
+func (m *Manager) register(stream *Stream, proc *FFmpegProcess, generation uint64) error {
+	if m.generations[stream.Name] != generation {
+		m.loggerManager.GetLogger(stream.Name).Info("Stream was stopped while starting")
+		return ErrStreamStopped
+	}
+
+	m.track(stream)
+	m.streams[stream.Name] = stream
+	m.processes[stream.Name] = proc
+	m.saveStream(stream)
+	m.publish(EventStarted, stream.Name)
+	return nil
+}
+
+// launch extracts the source of a stream and starts ffmpeg for it, seeding
+// its source tracking from prev if given. It does not touch the manager's
+// maps, so it is called without the lock.
+func (m *Manager) launch(ctx context.Context, youtubeURL, name string, port int, opts StartOptions, prev *carriedState) (*Stream, *FFmpegProcess, error) {
+	log := m.loggerManager.GetLogger(name)
 
 	// Create new stream
-	stream := NewStream(name, youtubeURL, port)
+	stream, err := m.newStream(youtubeURL, name, port, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	m.inherit(stream, prev)
 	stream.SetState(StateStarting)
-	log.Info("Starting stream from %s", youtubeURL)
+	if opts.AudioOnly {
+		log.Info("Starting audio-only stream from %s", youtubeURL)
+	} else {
+		log.Info("Starting stream from %s", youtubeURL)
+	}
 
 	// Extract stream URL
-	info, err := m.extractor.Extract(ctx, youtubeURL)
+	info, err := m.extract(ctx, log, youtubeURL, stream.ExtractOptions())
 	if err != nil {
-		log.Error("Failed to extract stream URL: %v", err)
-		return fmt.Errorf("failed to extract stream URL: %w", err)
+		stream.SetLastError(log.Redact(err.Error()))
+		return nil, nil, fmt.Errorf("failed to extract stream URL: %w", err)
+	}
+	if prev != nil {
+		stream.VideoID = prev.videoID
+		stream.Title = prev.title
+		stream.VideoIDChangedAt = prev.changedAt
+		stream.IsLive = prev.isLive
+		stream.Usage = prev.usage
+		stream.ErrorCount = prev.errorCount
+		stream.LastError = prev.lastError
+	} else if info.ID == "" {
+		// The metadata fetch failed, so ask for the live status directly.
+		// Assume live when unknown, so the stream is kept up as before.
+		live, err := m.extractor.IsLiveStream(ctx, youtubeURL)
+		if err != nil {
+			log.Warn("Could not determine live status, assuming live: %v", err)
+			live = true
+		}
+		stream.IsLive = live
+	}
+	m.applySource(stream, info)
+	if !stream.IsLive {
+		log.Info("Source is not live (VOD); the stream ends with the video")
 	}
-	stream.SetStreamURL(info.URL)
 	log.Info("Extracted stream URL successfully")
+	log.Debug("Source: format %q, resolution %q, video codec %q (selector %q)",
+		info.Format, info.Resolution, info.VideoCodec, stream.YtdlpFormat)
 
 	// Start FFmpeg process
-	proc, err := m.ffmpeg.Start(ctx, stream)
+	proc, err := m.ffmpeg.Start(ctx, stream, log)
 	if err != nil {
 		log.Error("Failed to start FFmpeg: %v", err)
-		return fmt.Errorf("failed to start ffmpeg: %w", err)
+		return nil, nil, fmt.Errorf("failed to start ffmpeg: %w", err)
 	}
+	m.logCommand(name, proc)
 
-	// Wait a bit for FFmpeg to initialize
-	time.Sleep(2 * time.Second)
-
-	// Verify process is running
-	if !proc.IsRunning() {
-		stderr := proc.GetStderr()
-		log.Error("FFmpeg exited prematurely: %s", stderr)
-		return fmt.Errorf("ffmpeg exited prematurely: %s", stderr)
+	// Wait until MediaMTX receives the stream, unless the caller leaves
+	// failures to the monitor. Remote targets are not ours to ask.
+	if !opts.NoWait && PublishesLocally(stream.Outputs) {
+		if err := m.waitReady(ctx, stream, proc, nil); err != nil {
+			proc.Stop()
+			log.Error("FFmpeg did not become ready: %v", err)
+			return nil, nil, err
+		}
 	}
 
 	stream.SetState(StateRunning)
 	stream.SetStartedAt(time.Now())
-	log.Info("Stream started successfully (PID: %d, RTSP: %s)", proc.GetPID(), stream.RTSPPath)
+	if PublishesLocally(stream.Outputs) {
+		log.Info("Stream started successfully (PID: %d, RTSP: %s)", proc.GetPID(), stream.RTSPPath)
+	} else {
+		log.Info("Stream started successfully (PID: %d, outputs: %s)", proc.GetPID(), strings.Join(stream.Outputs, ", "))
+	}
+	m.appLog.Debug("stream started", "stream", name, "pid", proc.GetPID(), "rtsp_path", stream.RTSPPath)
 
-	// Store stream and process
-	m.streams[name] = stream
-	m.processes[name] = proc
+	return stream, proc, nil
+}
+
+// newStream creates a stream from start options, validating them and
+// filling in the configured defaults
+func (m *Manager) newStream(youtubeURL, name string, port int, opts StartOptions) (*Stream, error) {
+	// Use default port if not specified
+	if port == 0 {
+		port = m.config.Server.RTSPPort
+	}
+
+	transport, err := m.resolveTransport(opts.Transport)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateOutputs(opts.Outputs); err != nil {
+		return nil, err
+	}
+	if err := validateCompanions(opts); err != nil {
+		return nil, err
+	}
+
+	stream := NewStream(name, youtubeURL, port)
+	stream.publishHost = m.publishHost
+	stream.AudioOnly = opts.AudioOnly
+	stream.Loop = opts.Loop
+	stream.Transport = transport
+	stream.Managed = opts.Managed
+	stream.YtdlpFormat = opts.Format
+	stream.MaxBitrate = opts.MaxBitrate
+	stream.Outputs = opts.Outputs
+	stream.Substream = opts.Substream
+	stream.RequireH264 = opts.RequireH264
+	stream.AudioCopy = opts.AudioCopy
+	return stream, nil
+}
+
+// inherit gives a starting stream the log level and run history it had
+// before restarting, or before it was stopped, and applies the level to its
+// log
+func (m *Manager) inherit(stream *Stream, prev *carriedState) {
+	if prev != nil {
+		stream.LogLevel = prev.logLevel
+		stream.History = prev.history
+	} else if data, err := m.storage.Load(stream.Name); err == nil {
+		stream.LogLevel = data.LogLevel
+		stream.History = data.History
+	}
+	m.applyLogLevel(stream)
+}
+
+// applyLogLevel sets the threshold of a stream's log to its own level, or
+// the default one
+func (m *Manager) applyLogLevel(stream *Stream) {
+	level := m.loggerManager.DefaultLevel()
+	if own, err := logger.ParseLevel(stream.GetLogLevel()); err == nil {
+		level = own
+	}
+	m.loggerManager.GetLogger(stream.Name).SetLevel(level)
+}
+
+// SetLogLevel sets the level of a stream's log, overriding logging.level
+// until it is set to "" again. It is kept with the stream, including a
+// stopped one.
+func (m *Manager) SetLogLevel(name, level string) error {
+	if level != "" {
+		parsed, err := logger.ParseLevel(level)
+		if err != nil {
+			return err
+		}
+		level = strings.ToLower(string(parsed))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stream, exists := m.streams[name]; exists {
+		stream.SetLogLevel(level)
+		m.applyLogLevel(stream)
+		m.saveStream(stream)
+		return nil
+	}
+
+	data, err := m.storage.Load(name)
+	if err != nil {
+		return notFound(name)
+	}
+	data.LogLevel = level
+	return m.storage.Save(data)
+}
+
+// logCommand logs the command line of a started ffmpeg at debug level,
+// without its signed URLs and headers
+func (m *Manager) logCommand(name string, proc *FFmpegProcess) {
+	command := append([]string{m.config.FFmpeg.BinaryPath}, RedactCommand(proc.GetArgs())...)
+	m.appLog.Debug("ffmpeg started", "stream", name, "pid", proc.GetPID(), "command", strings.Join(command, " "))
+}
+
+// extract extracts the source of a stream, retrying transient failures
+// with exponential backoff. Each attempt is logged to the stream log.
+// Failures that retrying cannot fix, such as an unavailable or private
+// video, are returned at once. Streams of the same source share the
+// extraction.
+func (m *Manager) extract(ctx context.Context, log *logger.StreamLogger, youtubeURL string, opts extractor.ExtractOptions) (*extractor.StreamInfo, error) {
+	key := sourceKey{url: youtubeURL, opts: opts}
+	return m.sources.extract(ctx, log, key, func(ctx context.Context) (*extractor.StreamInfo, error) {
+		return m.extractWithRetries(ctx, log, youtubeURL, opts)
+	})
+}
+
+// extractWithRetries runs the extraction for extract
+func (m *Manager) extractWithRetries(ctx context.Context, log *logger.StreamLogger, youtubeURL string, opts extractor.ExtractOptions) (*extractor.StreamInfo, error) {
+	attempts := max(m.config.Ytdlp.RetryAttempts, 1)
+	backoff := m.config.Ytdlp.RetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		info, err := m.extractor.Extract(ctx, youtubeURL, opts)
+		if err == nil {
+			return info, nil
+		}
+		if extractor.IsPermanent(err) {
+			log.Error("Extraction failed permanently: %v", err)
+			return nil, err
+		}
+		if attempt >= attempts || ctx.Err() != nil {
+			log.Error("Extraction failed after %d attempt(s): %v", attempt, err)
+			return nil, err
+		}
+
+		log.Warn("Extraction attempt %d/%d failed, retrying in %v: %v", attempt, attempts, backoff, err)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// SetPublishCommand sets how MediaMTX is told to publish on-demand streams.
+// command returns a shell command line for a stream name.
+func (m *Manager) SetPublishCommand(command func(name string) string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.publishCommand = command
+}
+
+// Drain makes the manager reject new streams with ErrDraining, so the
+// instance can be taken out of a rolling restart without dropping the
+// streams it runs. Running streams keep reconnecting and can be stopped.
+func (m *Manager) Drain() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.draining {
+		m.appLog.Info("draining: new streams are rejected")
+	}
+	m.draining = true
+}
+
+// IsDraining reports whether Drain was called
+func (m *Manager) IsDraining() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.draining
+}
+
+// StartOnDemand registers a stream that MediaMTX publishes only while it
+// has readers. Nothing is extracted or run until the first client connects;
+// MediaMTX then runs the publish command, which calls Publish.
+func (m *Manager) StartOnDemand(youtubeURL, name string, port int, opts StartOptions) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.draining {
+		return ErrDraining
+	}
+	if _, exists := m.streams[name]; exists {
+		return fmt.Errorf("stream '%s' already exists", name)
+	}
+	if m.publishCommand == nil {
+		return fmt.Errorf("on-demand streams are not supported here")
+	}
+	if len(opts.Outputs) > 0 {
+		return fmt.Errorf("on-demand streams publish to the local MediaMTX only (drop --output)")
+	}
+	if opts.Substream != "" || opts.AudioCopy {
+		return fmt.Errorf("on-demand streams cannot have a substream or audio copy")
+	}
+
+	if port == 0 {
+		port = m.config.Server.RTSPPort
+	}
+
+	transport, err := m.resolveTransport(opts.Transport)
+	if err != nil {
+		return err
+	}
 
-	// Persist to storage
+	stream := NewStream(name, youtubeURL, port)
+	stream.publishHost = m.publishHost
+	stream.AudioOnly = opts.AudioOnly
+	stream.Loop = opts.Loop
+	stream.Transport = transport
+	stream.Managed = opts.Managed
+	stream.YtdlpFormat = opts.Format
+	stream.MaxBitrate = opts.MaxBitrate
+	stream.RequireH264 = opts.RequireH264
+	stream.OnDemand = true
+	m.inherit(stream, nil)
+
+	if err := m.registerOnDemand(stream); err != nil {
+		return err
+	}
+
+	m.loggerManager.GetLogger(name).Info("Registered on-demand stream for %s", youtubeURL)
+	m.appLog.Debug("on-demand stream registered", "stream", name, "rtsp_path", stream.RTSPPath)
+
+	m.track(stream)
+	m.streams[name] = stream
 	m.saveStream(stream)
+	return nil
+}
+
+// registerOnDemand adds the MediaMTX path of an on-demand stream, if it is
+// not configured already (must be called with lock held)
+func (m *Manager) registerOnDemand(stream *Stream) error {
+	if m.publishCommand == nil {
+		return nil
+	}
+
+	exists, err := m.server.HasPathConfig(stream.Name)
+	if err != nil {
+		return fmt.Errorf("failed to check on-demand path: %w", err)
+	}
+	if exists {
+		return nil
+	}
 
+	// Extraction happens inside the publish command, so allow for it
+	err = m.server.AddPathConfig(stream.Name, server.PathConfig{
+		RunOnDemand:             m.publishCommand(stream.Name),
+		RunOnDemandRestart:      true,
+		RunOnDemandStartTimeout: (m.config.Ytdlp.ExtractTimeout() + 10*time.Second).String(),
+		RunOnDemandCloseAfter:   m.config.MediaMTX.OnDemandCloseAfter.String(),
+		MaxReaders:              m.config.MediaMTX.MaxReaders,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register on-demand path: %w", err)
+	}
 	return nil
 }
 
-// Stop stops a stream
-func (m *Manager) Stop(name string) error {
+// SyncOnDemandPaths re-registers on-demand streams with MediaMTX, whose
+// runtime path configuration is lost when it restarts
+func (m *Manager) SyncOnDemandPaths() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	return m.stopStream(name)
+	for name, stream := range m.streams {
+		if !stream.OnDemand {
+			continue
+		}
+		if err := m.registerOnDemand(stream); err != nil {
+			m.appLog.Warn("failed to register on-demand stream", "stream", name, "error", err)
+		}
+	}
 }
 
-// stopStream stops a stream (internal, must be called with lock held)
-func (m *Manager) stopStream(name string) error {
+// Publish extracts the stream URL of an on-demand stream and runs ffmpeg
+// in the foreground until ctx is cancelled (MediaMTX stops the publish
+// command when the last reader leaves) or ffmpeg exits.
+func (m *Manager) Publish(ctx context.Context, name string) error {
+	stored := m.GetStream(name)
+	if stored == nil || !stored.OnDemand {
+		return fmt.Errorf("on-demand stream '%s' %w", name, ErrStreamNotFound)
+	}
+
+	log := m.loggerManager.GetLogger(name)
+	log.Info("Client connected, publishing on demand")
+
+	stream := NewStream(name, stored.YouTubeURL, stored.Port)
+	stream.publishHost = m.publishHost
+	stream.AudioOnly = stored.AudioOnly
+	stream.Loop = stored.Loop
+	stream.Transport = stored.Transport
+	stream.YtdlpFormat = stored.YtdlpFormat
+	stream.MaxBitrate = stored.MaxBitrate
+	stream.RequireH264 = stored.RequireH264
+	stream.LogLevel = stored.GetLogLevel()
+	stream.IsLive = stored.GetIsLive()
+
+	info, err := m.extract(ctx, log, stream.YouTubeURL, stream.ExtractOptions())
+	if err != nil {
+		return fmt.Errorf("failed to extract stream URL: %w", err)
+	}
+	m.applySource(stream, info)
+
+	proc, err := m.ffmpeg.Start(ctx, stream, log)
+	if err != nil {
+		log.Error("Failed to start FFmpeg: %v", err)
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+	m.logCommand(name, proc)
+
+	select {
+	case <-ctx.Done():
+		proc.Stop()
+		log.Info("Last client left, stopped publishing")
+		return nil
+	case <-proc.Done():
+		if proc.ExitedCleanly() {
+			log.Info("FFmpeg finished")
+			return nil
+		}
+		log.Error("FFmpeg exited: %s", proc.GetStderr())
+		return fmt.Errorf("ffmpeg exited: %s", proc.GetStderr())
+	}
+}
+
+// resolveTransport validates a per-stream RTSP transport, defaulting to the
+// configured one, so the choice is recorded and kept across reconnects
+func (m *Manager) resolveTransport(transport string) (string, error) {
+	if transport == "" {
+		return m.config.FFmpeg.RTSPTransport, nil
+	}
+	if transport != "tcp" && transport != "udp" {
+		return "", fmt.Errorf("invalid RTSP transport %q (must be tcp or udp)", transport)
+	}
+	return transport, nil
+}
+
+// Stop stops a stream. FFmpeg processes outlive the CLI invocation that
+// started them, so Stop (or StopAll) is the canonical way to end a stream.
+// The stream's definition is kept as stopped; Remove deletes it.
+func (m *Manager) Stop(name string) error {
+	m.mu.Lock()
+	m.generations[name]++
+	stream := m.streams[name]
+	finish, err := m.stopStream(name)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	finish()
+
+	if stream != nil {
+		m.FireHook(hooks.EventStop, stream, "stopped by user")
+	}
+	return nil
+}
+
+// stopStream begins stopping a stream (internal, must be called with lock
+// held). It returns the rest of the stop, which waits for ffmpeg to exit and
+// must be called without the lock; meanwhile the stream is listed as
+// stopping.
+func (m *Manager) stopStream(name string) (finish func(), err error) {
 	log := m.loggerManager.GetLogger(name)
 	stream, exists := m.streams[name]
+	if _, starting := m.starting[name]; starting && !exists {
+		// The start sees the new generation and stops what it launched
+		log.Info("Cancelling stream start")
+		return func() {}, nil
+	}
 	if !exists {
 		// Try to load from storage and kill by PID
-		if data, err := m.storage.Load(name); err == nil && data.FFmpegPID > 0 {
-			log.Info("Stopping orphaned stream (PID: %d)", data.FFmpegPID)
-			KillByPID(data.FFmpegPID)
-			m.storage.Delete(name)
-			return nil
+		data, err := m.storage.Load(name)
+		if err != nil {
+			return nil, notFound(name)
+		}
+		if data.Stopped {
+			return nil, fmt.Errorf("stream '%s' is already stopped", name)
 		}
-		return fmt.Errorf("stream '%s' not found", name)
+		pid, startTime, target := data.FFmpegPID, data.FFmpegStartTime, m.storedTarget(data)
+		if IsStreamProcessAlive(pid, startTime, target) {
+			data.History = appendSession(data.History, data.StartedAt, time.Now(), "stopped")
+		}
+		data.Stopped = true
+		data.FFmpegPID = 0
+		data.FFmpegStartTime = 0
+		data.ReconnectAttempt = 0
+		data.NextRetryAt = time.Time{}
+		m.storage.Save(data)
+		m.publish(EventStopped, name)
+		return func() {
+			if IsStreamProcessAlive(pid, startTime, target) {
+				log.Info("Stopping orphaned stream (PID: %d)", pid)
+				KillByPID(pid, startTime, target)
+			}
+		}, nil
+	}
+	if stream.GetState() == StateStopping {
+		return nil, fmt.Errorf("stream '%s' is already stopping", name)
 	}
 
 	log.Info("Stopping stream")
 	stream.SetState(StateStopping)
+	proc := m.processes[name]
+	delete(m.processes, name)
 
-	// Stop FFmpeg process
-	if proc, exists := m.processes[name]; exists {
-		proc.Stop()
-		delete(m.processes, name)
+	return func() {
+		// Unregister on-demand path, which also stops its publisher
+		if stream.OnDemand {
+			if err := m.server.DeletePathConfig(name); err != nil {
+				log.Warn("Failed to remove on-demand path: %v", err)
+			}
+		}
+
+		// Stop FFmpeg process
+		if proc != nil {
+			proc.Stop()
+		}
+
+		// Kill by PID if process reference is lost
+		stream.KillFFmpeg()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		// Clean up, keeping the definition
+		if m.streams[name] == stream {
+			delete(m.streams, name)
+		}
+		m.pruneSources()
+		stream.EndSession(time.Now(), "stopped")
+		stream.SetState(StateStopped)
+		stream.SetFFmpegPID(0)
+		stream.SetRetry(0, time.Time{})
+		m.saveStream(stream)
+		log.Info("Stream stopped")
+		m.appLog.Debug("stream stopped", "stream", name)
+		m.loggerManager.RemoveLogger(name)
+	}, nil
+}
+
+// Remove stops a stream if it is running and deletes its definition
+func (m *Manager) Remove(name string) error {
+	m.mu.Lock()
+	stream, exists := m.streams[name]
+	data, err := m.storage.Load(name)
+	if !exists && err != nil {
+		if _, starting := m.starting[name]; !starting {
+			m.mu.Unlock()
+			return notFound(name)
+		}
+	}
+
+	finish := func() {}
+	stopping := exists || data == nil || !data.Stopped
+	if stopping {
+		m.generations[name]++
+		if finish, err = m.stopStream(name); err != nil {
+			m.mu.Unlock()
+			return err
+		}
 	}
+	m.mu.Unlock()
 
-	// Kill by PID if process reference is lost
-	if pid := stream.GetFFmpegPID(); pid > 0 {
-		KillByPID(pid)
+	finish()
+	if stopping && stream != nil {
+		m.FireHook(hooks.EventStop, stream, "removed by user")
 	}
 
-	// Clean up
-	delete(m.streams, name)
-	m.storage.Delete(name)
-	log.Info("Stream stopped")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.streams[name]; exists {
+		return fmt.Errorf("stream '%s' was started again while being removed", name)
+	}
 
+	// Close the stream log before its files are deleted; writing to it
+	// afterwards would create it again
+	m.loggerManager.RemoveLogger(name)
+	if err := m.storage.Delete(name); err != nil {
+		return fmt.Errorf("failed to remove stream definition: %w", err)
+	}
+	m.appLog.Debug("stream definition removed", "stream", name)
 	return nil
 }
 
-// StopAll stops all streams
+// StopAll stops all streams, waiting for their ffmpeg processes to exit
+// in parallel
 func (m *Manager) StopAll() error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	type stop struct {
+		stream *Stream
+		finish func()
+	}
 
+	m.mu.Lock()
+	var stops []stop
 	var lastErr error
-	for name := range m.streams {
-		if err := m.stopStream(name); err != nil {
+	for name, stream := range m.streams {
+		m.generations[name]++
+		finish, err := m.stopStream(name)
+		if err != nil {
 			lastErr = err
+			continue
 		}
+		stops = append(stops, stop{stream: stream, finish: finish})
 	}
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, s := range stops {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.finish()
+			m.FireHook(hooks.EventStop, s.stream, "stopping all streams")
+		}()
+	}
+	wg.Wait()
 
 	return lastErr
 }
@@ -189,20 +852,8 @@ func (m *Manager) List() []Info {
 			}
 
 			// Check if process is still running
-			if data.FFmpegPID > 0 && IsProcessAlive(data.FFmpegPID) {
-				infos = append(infos, Info{
-					ID:             data.ID,
-					Name:           data.Name,
-					YouTubeURL:     data.YouTubeURL,
-					RTSPPath:       data.RTSPPath,
-					Port:           data.Port,
-					State:          StateRunning,
-					StateString:    "running",
-					FFmpegPID:      data.FFmpegPID,
-					CreatedAt:      data.CreatedAt,
-					StartedAt:      data.StartedAt,
-					LastURLRefresh: data.LastURLRefresh,
-				})
+			if IsStreamProcessAlive(data.FFmpegPID, data.FFmpegStartTime, m.storedTarget(data)) {
+				infos = append(infos, infoFromData(data, StateRunning))
 			}
 		}
 	}
@@ -223,29 +874,93 @@ func (m *Manager) Status(name string) (*Info, error) {
 	// Try storage
 	data, err := m.storage.Load(name)
 	if err != nil {
-		return nil, fmt.Errorf("stream '%s' not found", name)
+		return nil, notFound(name)
 	}
 
 	state := StateError
-	stateStr := "error"
-	if data.FFmpegPID > 0 && IsProcessAlive(data.FFmpegPID) {
+	switch {
+	case data.Stopped:
+		state = StateStopped
+	case IsStreamProcessAlive(data.FFmpegPID, data.FFmpegStartTime, m.storedTarget(data)):
 		state = StateRunning
-		stateStr = "running"
-	}
-
-	return &Info{
-		ID:             data.ID,
-		Name:           data.Name,
-		YouTubeURL:     data.YouTubeURL,
-		RTSPPath:       data.RTSPPath,
-		Port:           data.Port,
-		State:          state,
-		StateString:    stateStr,
-		FFmpegPID:      data.FFmpegPID,
-		CreatedAt:      data.CreatedAt,
-		StartedAt:      data.StartedAt,
-		LastURLRefresh: data.LastURLRefresh,
-	}, nil
+	}
+	info := infoFromData(data, state)
+	return &info, nil
+}
+
+// ListStopped returns the streams that were stopped and kept for starting
+// again, from storage
+func (m *Manager) ListStopped() []Info {
+	stored, err := m.storage.List()
+	if err != nil {
+		return nil
+	}
+
+	var infos []Info
+	for _, data := range stored {
+		if data.Stopped {
+			infos = append(infos, infoFromData(data, StateStopped))
+		}
+	}
+	return infos
+}
+
+// infoFromData returns the information of a stream known only from storage
+func infoFromData(data *storage.StreamData, state State) Info {
+	ingest, wasted := sumUsage(data.Usage, time.Now())
+	return Info{
+		ID:                data.ID,
+		Name:              data.Name,
+		YouTubeURL:        data.YouTubeURL,
+		RTSPPath:          data.RTSPPath,
+		Port:              data.Port,
+		Managed:           data.Managed,
+		AudioOnly:         data.AudioOnly,
+		Loop:              data.Loop,
+		OnDemand:          data.OnDemand,
+		Transport:         data.Transport,
+		YtdlpFormat:       data.YtdlpFormat,
+		MaxBitrate:        data.MaxBitrate,
+		Outputs:           data.Outputs,
+		Substream:         data.Substream,
+		RequireH264:       data.RequireH264,
+		AudioCopy:         data.AudioCopy,
+		LogLevel:          data.LogLevel,
+		State:             state,
+		StateString:       state.String(),
+		FFmpegPID:         data.FFmpegPID,
+		CreatedAt:         data.CreatedAt,
+		StartedAt:         data.StartedAt,
+		LastURLRefresh:    data.LastURLRefresh,
+		VideoID:           data.VideoID,
+		Title:             data.Title,
+		VideoIDChangedAt:  data.VideoIDChangedAt,
+		Resolution:        data.Resolution,
+		Format:            data.Format,
+		VideoCodec:        data.VideoCodec,
+		IsLive:            data.IsLive,
+		IngestBytes:       ingest,
+		WastedBytes:       wasted,
+		Progress:          data.Progress,
+		History:           data.History,
+		ErrorCount:        data.ErrorCount,
+		ConsecutiveErrors: data.ConsecutiveErrors,
+		LastError:         data.LastError,
+		ReconnectAttempt:  data.ReconnectAttempt,
+		NextRetryAt:       data.NextRetryAt,
+	}
+}
+
+// FindByURL returns the names of active streams proxying youtubeURL, sorted
+func (m *Manager) FindByURL(youtubeURL string) []string {
+	var names []string
+	for _, info := range m.List() {
+		if info.YouTubeURL == youtubeURL {
+			names = append(names, info.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
 }
 
 // GetStream returns a stream by name (for monitor access)
@@ -262,33 +977,308 @@ func (m *Manager) GetProcess(name string) *FFmpegProcess {
 	return m.processes[name]
 }
 
-// RestartStream restarts a stream (for reconnection)
-func (m *Manager) RestartStream(ctx context.Context, name string) error {
+// Generation returns the stop generation of a stream name. Recovery code
+// reads it before acting and passes it to RestartStream.
+func (m *Manager) Generation(name string) uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.generations[name]
+}
+
+// RestartStream restarts a stream (for reconnection). It returns
+// ErrStreamStopped if the stream was stopped after generation was read.
+// If the restart fails the stream stays known in the reconnecting state,
+// so it can be retried or stopped.
+func (m *Manager) RestartStream(ctx context.Context, name string, generation uint64) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 
 	log := m.loggerManager.GetLogger(name)
+	if m.generations[name] != generation {
+		m.mu.Unlock()
+		return ErrStreamStopped
+	}
 	stream, exists := m.streams[name]
-	if !exists {
-		return fmt.Errorf("stream '%s' not found", name)
+	if !exists || stream.GetState() == StateStopping {
+		m.mu.Unlock()
+		return fmt.Errorf("%w: %w", notFound(name), ErrStreamStopped)
+	}
+
+	// On-demand streams are restarted by MediaMTX; make sure it knows them
+	if stream.OnDemand {
+		defer m.mu.Unlock()
+		return m.registerOnDemand(stream)
+	}
+	if _, starting := m.starting[name]; starting {
+		m.mu.Unlock()
+		return fmt.Errorf("stream '%s' is already restarting", name)
 	}
 
 	log.Warn("Restarting stream")
+	m.appLog.Info("restarting stream", "stream", name)
 	youtubeURL := stream.YouTubeURL
 	port := stream.Port
+	opts := stream.startOptions()
+	stream.EndSession(time.Now(), "restarted")
+	prev := carriedFrom(stream)
 
-	// Stop existing stream
-	m.stopStream(name)
-
-	// Release lock temporarily for start
+	// Keep the stream known while the new ffmpeg is launched, so it is
+	// still listed and a concurrent Stop cancels the restart. The old one
+	// is stopped without the lock, as that waits for it to exit.
+	oldProc := m.processes[name]
+	delete(m.processes, name)
+	stream.SetState(StateReconnecting)
+	m.starting[name] = struct{}{}
 	m.mu.Unlock()
-	err := m.Start(ctx, youtubeURL, name, port)
+
+	if oldProc != nil {
+		oldProc.Stop()
+	}
+	stream.KillFFmpeg()
+	stream.SetFFmpegPID(0)
+
+	newStream, proc, err := m.launch(ctx, youtubeURL, name, port, opts, prev)
+
 	m.mu.Lock()
+	delete(m.starting, name)
+	if err == nil {
+		err = m.register(newStream, proc, generation)
+		m.mu.Unlock()
+		if err != nil {
+			proc.Stop()
+		}
+		return err
+	}
+	defer m.mu.Unlock()
+	if m.generations[name] != generation {
+		return ErrStreamStopped
+	}
 
+	log.Error("Restart failed: %v", err)
+	m.appLog.Error("stream restart failed", "stream", name, "error", err)
+	m.saveStream(stream)
+	return err
+}
+
+// Reconnect restarts a stream with a freshly extracted URL and waits for it.
+// A stream only known from storage, such as one started by another process
+// whose ffmpeg has since died, is loaded first so its old process is killed
+// and replaced through the normal start path.
+func (m *Manager) Reconnect(ctx context.Context, name string) error {
+	if _, err := m.Adopt(name); err != nil {
+		return err
+	}
+	return m.RestartStream(ctx, name, m.Generation(name))
+}
+
+// Adopt returns a stream by name, loading one only known from storage into
+// the manager in the reconnecting state, so it can be restarted. It returns
+// an ErrStreamNotFound error if the stream is not stored either.
+func (m *Manager) Adopt(name string) (*Stream, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stream, exists := m.streams[name]; exists {
+		return stream, nil
+	}
+	data, err := m.storage.Load(name)
 	if err != nil {
-		log.Error("Restart failed: %v", err)
+		return nil, notFound(name)
+	}
+	stream := m.streamFromData(data, StateReconnecting)
+	m.track(stream)
+	m.streams[name] = stream
+	return stream, nil
+}
+
+// carriedFrom returns the state of stream to keep across a restart
+func carriedFrom(stream *Stream) *carriedState {
+	stream.mu.RLock()
+	defer stream.mu.RUnlock()
+	return &carriedState{
+		videoID:   stream.VideoID,
+		title:     stream.Title,
+		changedAt: stream.VideoIDChangedAt,
+		isLive:    stream.IsLive,
+		usage:     stream.Usage,
+		history:   stream.History,
+
+		errorCount: stream.ErrorCount,
+		lastError:  stream.LastError,
+		logLevel:   stream.LogLevel,
+	}
+}
+
+// SwapSource refreshes the URL of a running stream without tearing down its
+// MediaMTX path: a second ffmpeg publishes the fresh URL to the same path,
+// MediaMTX hands the path over to it (overridePublisher, on by default) and
+// only then is the old process stopped. Viewers see the handover instead of
+// the gap of a full restart. If the new process does not take over, the
+// error is returned and the old one is left as it is. (for monitor access)
+func (m *Manager) SwapSource(ctx context.Context, name string) error {
+	m.mu.Lock()
+	stream, exists := m.streams[name]
+	if !exists {
+		m.mu.Unlock()
+		return fmt.Errorf("%w: %w", notFound(name), ErrStreamStopped)
+	}
+	if _, starting := m.starting[name]; starting || stream.OnDemand || stream.GetState() != StateRunning {
+		m.mu.Unlock()
+		return ErrStreamBusy
+	}
+	if !PublishesLocally(stream.Outputs) {
+		m.mu.Unlock()
+		return ErrNoHandover
+	}
+	m.starting[name] = struct{}{}
+	generation := m.generations[name]
+	oldProc := m.processes[name]
+	youtubeURL := stream.YouTubeURL
+	port := stream.Port
+	opts := stream.startOptions()
+	prev := carriedFrom(stream)
+	prev.history = appendSession(prev.history, stream.GetStartedAt(), time.Now(), "source refreshed")
+
+	// The old publisher keeps the paths ready, so launch's wait cannot tell
+	// whether the new process took over; wait for the publishers to change
+	// instead
+	opts.NoWait = true
+	replaced := m.publishers(stream)
+
+	// The old publisher exits once it is replaced; keep the health checks
+	// from treating that as a failure
+	stream.SetState(StateReconnecting)
+	m.mu.Unlock()
+
+	log := m.loggerManager.GetLogger(name)
+	log.Info("Refreshing stream URL with a source handover")
+
+	newStream, proc, err := m.launch(ctx, youtubeURL, name, port, opts, prev)
+	if err == nil {
+		if err = m.waitReady(ctx, newStream, proc, replaced); err != nil {
+			proc.Stop()
+		}
+	}
+
+	m.mu.Lock()
+	delete(m.starting, name)
+	if err != nil {
+		log.Warn("Source handover failed: %v", err)
+		if oldProc != nil && oldProc.IsRunning() {
+			stream.SetState(StateRunning)
+		}
+		m.mu.Unlock()
+		return fmt.Errorf("source handover failed: %w", err)
+	}
+	if err := m.register(newStream, proc, generation); err != nil {
+		m.mu.Unlock()
+		proc.Stop()
+		return err
+	}
+	m.publish(EventURLRefreshed, name)
+	m.mu.Unlock()
+
+	// MediaMTX has disconnected the old publisher; make sure it is gone
+	if oldProc != nil {
+		oldProc.Stop()
+	}
+	log.Info("Source handed over to new FFmpeg (PID: %d)", proc.GetPID())
+	m.appLog.Info("stream source swapped", "stream", name, "pid", proc.GetPID())
+	return nil
+}
+
+// waitReady waits until MediaMTX receives a stream launched with proc, and
+// its companion paths if it has any. The paths in replaced must also have
+// a publisher other than the one they map to, which proc takes over from.
+func (m *Manager) waitReady(ctx context.Context, stream *Stream, proc *FFmpegProcess, replaced map[string]string) error {
+	timeout := m.config.FFmpeg.StartTimeout
+	if err := m.waitPathReady(ctx, stream.RTSPPath, proc, replaced[stream.RTSPPath], timeout); err != nil {
+		return err
+	}
+	for _, c := range Companions(stream.RTSPPath, stream.Substream, stream.AudioCopy) {
+		if err := m.waitPathReady(ctx, c.Path, proc, replaced[c.Path], timeout); err != nil {
+			return fmt.Errorf("%s: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+// publishers returns the publisher of the path of a stream and of its
+// companion paths, keyed by path. Paths nothing publishes to are left out.
+func (m *Manager) publishers(stream *Stream) map[string]string {
+	paths := []string{stream.RTSPPath}
+	for _, c := range Companions(stream.RTSPPath, stream.Substream, stream.AudioCopy) {
+		paths = append(paths, c.Path)
+	}
+
+	publishers := make(map[string]string)
+	for _, path := range paths {
+		if info, err := m.server.GetPathInfo(path); err == nil && info.Ready {
+			publishers[path] = publisherOf(info)
+		}
+	}
+	return publishers
+}
+
+// publisherOf identifies the session publishing to a path by its ID, or by
+// when the path became ready if MediaMTX does not report the source
+func publisherOf(info *server.PathInfo) string {
+	if info.Source != nil && info.Source.ID != "" {
+		return info.Source.ID
+	}
+	return info.ReadyTime
+}
+
+// validateCompanions checks the substream and audio copy of start options
+func validateCompanions(opts StartOptions) error {
+	if opts.AudioCopy && opts.AudioOnly {
+		return fmt.Errorf("audio-only streams need no audio copy")
+	}
+	if opts.Substream == "" {
+		return nil
+	}
+	if _, err := ParseSubstream(opts.Substream); err != nil {
+		return err
+	}
+	if opts.AudioOnly {
+		return fmt.Errorf("audio-only streams cannot have a substream")
+	}
+	if !PublishesLocally(opts.Outputs) {
+		return fmt.Errorf("the substream is published to the local MediaMTX (add --output local)")
+	}
+	return nil
+}
+
+// waitPathReady waits up to timeout until MediaMTX reports path ready
+// while proc is still publishing to it. If replaced is set, the path must
+// also have a different publisher. The error includes what ffmpeg printed.
+func (m *Manager) waitPathReady(ctx context.Context, path string, proc *FFmpegProcess, replaced string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if !proc.IsRunning() {
+			// Let the stderr reader catch up with the exited process
+			select {
+			case <-proc.Done():
+			case <-time.After(time.Second):
+			}
+			return fmt.Errorf("ffmpeg exited prematurely: %s", proc.GetStderr())
+		}
+		if info, err := m.server.GetPathInfo(path); err == nil && info.Ready &&
+			(replaced == "" || publisherOf(info) != replaced) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if replaced != "" {
+				return fmt.Errorf("new publisher did not take over after %v: %s", timeout, strings.Join(proc.StderrTail(5), "\n"))
+			}
+			return fmt.Errorf("stream not ready after %v: %s", timeout, strings.Join(proc.StderrTail(5), "\n"))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(250 * time.Millisecond):
+		}
 	}
-	return err
 }
 
 // RefreshURL extracts a new stream URL for a stream
@@ -298,29 +1288,159 @@ func (m *Manager) RefreshURL(ctx context.Context, name string) error {
 	stream, exists := m.streams[name]
 	if !exists {
 		m.mu.Unlock()
-		return fmt.Errorf("stream '%s' not found", name)
+		return notFound(name)
 	}
 
 	log.Info("Refreshing stream URL")
 	stream.SetState(StateReconnecting)
 	youtubeURL := stream.YouTubeURL
+	extractOpts := stream.ExtractOptions()
 	m.mu.Unlock()
 
 	// Extract new URL
-	info, err := m.extractor.Extract(ctx, youtubeURL)
+	info, err := m.extract(ctx, log, youtubeURL, extractOpts)
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if err != nil {
 		log.Error("Failed to refresh URL: %v", err)
+		stream.SetLastError(log.Redact(err.Error()))
+		if _, exists := m.streams[name]; exists {
+			m.saveStream(stream)
+		}
 		return fmt.Errorf("failed to extract new URL: %w", err)
 	}
 
+	m.applySource(stream, info)
+	m.saveStream(stream)
+	m.publish(EventURLRefreshed, name)
+	log.Info("URL refreshed successfully")
+	return nil
+}
+
+// ApplyRefreshedSource updates a stream with freshly extracted source info
+// (for monitor access)
+func (m *Manager) ApplyRefreshedSource(stream *Stream, info *extractor.StreamInfo) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	stream.SetStreamURL(info.URL)
-	log.Info("URL refreshed successfully")
+	m.applySource(stream, info)
+	if _, exists := m.streams[stream.Name]; exists {
+		m.saveStream(stream)
+	}
+	m.publish(EventURLRefreshed, stream.Name)
+}
+
+// FireHook runs the configured hook for a lifecycle event of a stream in
+// the background (for monitor access)
+func (m *Manager) FireHook(event hooks.Event, stream *Stream, reason string) {
+	m.hooks.Fire(event, hooks.Payload{
+		StreamName:  stream.Name,
+		StreamState: stream.GetState().String(),
+		RTSPURL:     m.config.GetRTSPURL(stream.Port, stream.RTSPPath),
+		Reason:      reason,
+	}, m.loggerManager.GetLogger(stream.Name))
+}
+
+// FFmpegOutput returns up to n of the most recent ffmpeg stderr lines of a
+// stream started by this process
+func (m *Manager) FFmpegOutput(name string, n int) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if proc, exists := m.processes[name]; exists {
+		return proc.StderrTail(n)
+	}
 	return nil
 }
 
+// FFmpegExitedCleanly reports whether the ffmpeg process of a stream started
+// by this process exited with status 0 (for monitor access)
+func (m *Manager) FFmpegExitedCleanly(name string) bool {
+	m.mu.RLock()
+	proc, exists := m.processes[name]
+	m.mu.RUnlock()
+
+	return exists && proc.ExitedCleanly()
+}
+
+// MarkEnded records that a VOD stream reached the end of its video. The
+// stream stays listed as idle instead of being reconnected (for monitor
+// access).
+func (m *Manager) MarkEnded(stream *Stream) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stream.EndSession(time.Now(), "video ended")
+	stream.SetState(StateIdle)
+	stream.SetFFmpegPID(0)
+	if current, exists := m.streams[stream.Name]; exists && current == stream {
+		m.saveStream(stream)
+	}
+	m.loggerManager.GetLogger(stream.Name).Info("Video ended, stream is now idle")
+	m.appLog.Info("stream ended", "stream", stream.Name)
+}
+
+// RecordRetry records the pending reconnect attempt of a stream and
+// persists it, so a restarted proxy resumes the backoff instead of
+// retrying at once. attempt 0 clears it. (for monitor access)
+func (m *Manager) RecordRetry(name string, attempt int, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stream, exists := m.streams[name]; exists {
+		stream.SetRetry(attempt, at)
+		m.saveStream(stream)
+	}
+}
+
+// SaveHealth persists the health counters of a stream after the monitor
+// updated them (for monitor access)
+func (m *Manager) SaveHealth(stream *Stream) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if current, exists := m.streams[stream.Name]; exists && current == stream {
+		m.saveStream(stream)
+	}
+}
+
+// RecordUsage samples a stream's ingest counter and reader count into its
+// usage window and persists it (for monitor access)
+func (m *Manager) RecordUsage(stream *Stream, bytesReceived int64, readers int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stream.RecordIngest(time.Now(), bytesReceived, readers)
+	if current, exists := m.streams[stream.Name]; exists && current == stream {
+		m.saveStream(stream)
+	}
+}
+
+// WaitHooks blocks until running hooks have finished
+func (m *Manager) WaitHooks() {
+	m.hooks.Wait()
+}
+
+// applySource sets the stream URL and source video, logging when the URL
+// now resolves to a different video than before and ending the run in the
+// history with the change
+func (m *Manager) applySource(stream *Stream, info *extractor.StreamInfo) {
+	stream.SetStreamURLs(info.URL, info.AudioURL, info.HTTPHeaders)
+
+	previous := stream.SetSource(info.ID, info.Title)
+	if info.ID != "" {
+		// Only a successful metadata fetch reports these
+		stream.SetMediaInfo(info.Resolution, info.Format, info.VideoCodec, info.IsLive)
+	}
+	if previous != "" && info.ID != "" && previous != info.ID {
+		m.loggerManager.GetLogger(stream.Name).Warn(
+			"Source video changed: %s -> %s (%s)", previous, info.ID, info.Title)
+		m.appLog.Warn("source video changed", "stream", stream.Name,
+			"previous_id", previous, "video_id", info.ID, "title", info.Title)
+		stream.EndSourceSession(time.Now(), fmt.Sprintf("source changed: %s -> %s", previous, info.ID))
+	}
+}
+
 // RecoverStreams attempts to recover streams from storage
 func (m *Manager) RecoverStreams() {
 	m.mu.Lock()
@@ -332,30 +1452,153 @@ func (m *Manager) RecoverStreams() {
 	}
 
 	for _, data := range stored {
-		// Skip if already in memory
-		if _, exists := m.streams[data.Name]; exists {
+		// Skip if already in memory; stopped streams stay in storage only
+		if _, exists := m.streams[data.Name]; exists || data.Stopped {
 			continue
 		}
 
-		// Check if process is still running
-		if data.FFmpegPID > 0 && IsProcessAlive(data.FFmpegPID) {
-			stream := &Stream{
-				ID:             data.ID,
-				Name:           data.Name,
-				YouTubeURL:     data.YouTubeURL,
-				RTSPPath:       data.RTSPPath,
-				Port:           data.Port,
-				State:          StateRunning,
-				FFmpegPID:      data.FFmpegPID,
-				CreatedAt:      data.CreatedAt,
-				StartedAt:      data.StartedAt,
-				LastURLRefresh: data.LastURLRefresh,
+		// Streams whose process is gone are kept for the monitor to
+		// reconnect, which it does for streams with a pending attempt; only
+		// cleanup and purge remove their entries. On-demand streams have no
+		// process of their own while idle.
+		state := StateRunning
+		switch {
+		case IsStreamProcessAlive(data.FFmpegPID, data.FFmpegStartTime, m.storedTarget(data)):
+		case data.OnDemand:
+			state = StateIdle
+		default:
+			state = StateReconnecting
+			data.ReconnectAttempt = max(data.ReconnectAttempt, 1)
+		}
+		stream := m.streamFromData(data, state)
+		m.track(stream)
+		m.applyLogLevel(stream)
+		m.streams[data.Name] = stream
+	}
+}
+
+// CleanupStorage removes the stored entries of streams that should be
+// running but whose ffmpeg process is gone, and returns their names
+func (m *Manager) CleanupStorage() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.cleanupStorage()
+}
+
+// cleanupStorage implements CleanupStorage (must be called with lock held)
+func (m *Manager) cleanupStorage() ([]string, error) {
+	removed, err := m.storage.Cleanup(m.storedAlive)
+	for _, name := range removed {
+		m.loggerManager.RemoveLogger(name)
+	}
+	return removed, err
+}
+
+// storedAlive reports whether a stored stream is known to this manager or
+// its ffmpeg process is still running (must be called with lock held)
+func (m *Manager) storedAlive(data *storage.StreamData) bool {
+	if _, exists := m.streams[data.Name]; exists {
+		return true
+	}
+	if _, starting := m.starting[data.Name]; starting {
+		return true
+	}
+	return IsStreamProcessAlive(data.FFmpegPID, data.FFmpegStartTime, m.storedTarget(data))
+}
+
+// PurgeResult lists what Purge removed, or would remove in a dry run
+type PurgeResult struct {
+	// Entries are the names of stale stream entries (see CleanupStorage)
+	Entries []string
+	// Files are leftover .pid and .log files of streams that no longer exist
+	Files []string
+}
+
+// Purge removes stale stream entries like CleanupStorage, then the .pid and
+// .log files left in the data dir by streams that no longer exist. Paths
+// in keep, such as the application log, are never removed. With dryRun,
+// nothing is removed.
+func (m *Manager) Purge(dryRun bool, keep ...string) (*PurgeResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := &PurgeResult{}
+	var err error
+	if dryRun {
+		result.Entries, err = storage.Stale(m.storage, m.storedAlive)
+	} else {
+		result.Entries, err = m.cleanupStorage()
+	}
+	if err != nil {
+		return result, fmt.Errorf("failed to clean up stream entries: %w", err)
+	}
+
+	files, err := storage.LeftoverFiles(m.storage, keep...)
+	if err != nil {
+		return result, err
+	}
+
+	for _, path := range files {
+		if !dryRun {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return result, fmt.Errorf("failed to remove %s: %w", path, err)
 			}
-			m.streams[data.Name] = stream
-		} else {
-			// Clean up orphaned storage entry
-			m.storage.Delete(data.Name)
 		}
+		result.Files = append(result.Files, path)
+	}
+	return result, nil
+}
+
+// storedTarget returns the first target the ffmpeg of a stored stream
+// publishes to, by which its process is recognized
+func (m *Manager) storedTarget(data *storage.StreamData) string {
+	return PublishTargets(m.publishHost, data.Port, data.RTSPPath, data.Outputs)[0]
+}
+
+// streamFromData rebuilds a stream from its persisted state
+func (m *Manager) streamFromData(data *storage.StreamData, state State) *Stream {
+	return &Stream{
+		ID:                data.ID,
+		Name:              data.Name,
+		YouTubeURL:        data.YouTubeURL,
+		RTSPPath:          data.RTSPPath,
+		Port:              data.Port,
+		Managed:           data.Managed,
+		AudioOnly:         data.AudioOnly,
+		Loop:              data.Loop,
+		OnDemand:          data.OnDemand,
+		Transport:         data.Transport,
+		YtdlpFormat:       data.YtdlpFormat,
+		MaxBitrate:        data.MaxBitrate,
+		Outputs:           data.Outputs,
+		Substream:         data.Substream,
+		RequireH264:       data.RequireH264,
+		AudioCopy:         data.AudioCopy,
+		LogLevel:          data.LogLevel,
+		State:             state,
+		FFmpegPID:         data.FFmpegPID,
+		FFmpegStart:       data.FFmpegStartTime,
+		CreatedAt:         data.CreatedAt,
+		StartedAt:         data.StartedAt,
+		LastURLRefresh:    data.LastURLRefresh,
+		VideoID:           data.VideoID,
+		Title:             data.Title,
+		VideoIDChangedAt:  data.VideoIDChangedAt,
+		Resolution:        data.Resolution,
+		Format:            data.Format,
+		VideoCodec:        data.VideoCodec,
+		IsLive:            data.IsLive,
+		Usage:             data.Usage,
+		History:           data.History,
+		ErrorCount:        data.ErrorCount,
+		ConsecutiveErrors: data.ConsecutiveErrors,
+		LastError:         data.LastError,
+		StallCount:        data.StallCount,
+		ReconnectAttempt:  data.ReconnectAttempt,
+		NextRetryAt:       data.NextRetryAt,
+
+		publishHost: m.publishHost,
 	}
 }
 
@@ -367,11 +1610,46 @@ func (m *Manager) saveStream(stream *Stream) {
 		YouTubeURL:     stream.YouTubeURL,
 		RTSPPath:       stream.RTSPPath,
 		Port:           stream.Port,
+		Managed:        stream.Managed,
+		AudioOnly:      stream.AudioOnly,
+		Loop:           stream.Loop,
+		OnDemand:       stream.OnDemand,
+		Transport:      stream.Transport,
+		YtdlpFormat:    stream.YtdlpFormat,
+		MaxBitrate:     stream.MaxBitrate,
+		Outputs:        stream.Outputs,
+		Substream:      stream.Substream,
+		RequireH264:    stream.RequireH264,
+		AudioCopy:      stream.AudioCopy,
 		FFmpegPID:      stream.GetFFmpegPID(),
 		CreatedAt:      stream.CreatedAt,
 		StartedAt:      stream.StartedAt,
 		LastURLRefresh: stream.GetLastURLRefresh(),
+		Stopped:        stream.GetState() == StateStopped,
+	}
+	stream.mu.RLock()
+	data.FFmpegStartTime = stream.FFmpegStart
+	data.VideoID = stream.VideoID
+	data.Title = stream.Title
+	data.VideoIDChangedAt = stream.VideoIDChangedAt
+	data.Resolution = stream.Resolution
+	data.Format = stream.Format
+	data.VideoCodec = stream.VideoCodec
+	data.IsLive = stream.IsLive
+	data.Usage = stream.Usage
+	data.History = stream.History
+	data.ReconnectAttempt = stream.ReconnectAttempt
+	data.NextRetryAt = stream.NextRetryAt
+	data.ErrorCount = stream.ErrorCount
+	data.LogLevel = stream.LogLevel
+	data.ConsecutiveErrors = stream.ConsecutiveErrors
+	data.LastError = stream.LastError
+	data.StallCount = stream.StallCount
+	if !stream.Progress.UpdatedAt.IsZero() {
+		progress := stream.Progress
+		data.Progress = &progress
 	}
+	stream.mu.RUnlock()
 	m.storage.Save(data)
 }
 
@@ -0,0 +1,689 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/server"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
+)
+
+func TestConcurrentStartSameName(t *testing.T) {
+	m := newTestManager(t)
+
+	const starts = 8
+	errs := make(chan error, starts)
+	var wg sync.WaitGroup
+	for range starts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- m.Start(context.Background(), "https://youtu.be/abc123", "news", 0, StartOptions{NoWait: true})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var started int
+	for err := range errs {
+		switch {
+		case err == nil:
+			started++
+		case strings.Contains(err.Error(), "already exists"), strings.Contains(err.Error(), "already starting"):
+		default:
+			t.Errorf("Start: %v", err)
+		}
+	}
+	if started != 1 {
+		t.Errorf("%d starts of the same name succeeded, want 1", started)
+	}
+	if n := len(m.List()); n != 1 {
+		t.Errorf("%d streams listed, want 1", n)
+	}
+}
+
+func TestConcurrentStartDifferentNames(t *testing.T) {
+	m := newTestManager(t)
+
+	names := []string{"news", "weather", "sports", "music"}
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.Start(context.Background(), "https://youtu.be/"+name, name, 0, StartOptions{NoWait: true}); err != nil {
+				t.Errorf("Start %s: %v", name, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(m.List()); n != len(names) {
+		t.Errorf("%d streams listed, want %d", n, len(names))
+	}
+}
+
+func TestStopDoesNotBlockReaders(t *testing.T) {
+	m := newTestManager(t)
+	// An ffmpeg that ignores SIGTERM makes Stop wait out stopTimeout
+	dir := t.TempDir()
+	ready := filepath.Join(dir, "ready")
+	m.config.FFmpeg.BinaryPath = writeScript(t, dir, "ffmpeg", "trap '' TERM\ntouch \""+ready+"\"\nwhile :; do sleep 0.05; done\n")
+	if err := m.Start(context.Background(), "https://youtu.be/abc123", "news", 0, StartOptions{NoWait: true}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitForFile(t, ready)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- m.Stop("news") }()
+
+	// Wait for Stop to be under way
+	deadline := time.Now().Add(time.Second)
+	for {
+		info, err := m.Status("news")
+		if err == nil && info.State == StateStopping {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stream not listed as stopping: %v, %v", info, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	listed := make(chan []Info, 1)
+	go func() { listed <- m.List() }()
+	select {
+	case infos := <-listed:
+		if len(infos) != 1 || infos[0].State != StateStopping {
+			t.Errorf("List during Stop = %+v, want the stream stopping", infos)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("List blocked while Stop waited for ffmpeg")
+	}
+	if err := m.Start(context.Background(), "https://youtu.be/abc123", "news", 0, StartOptions{NoWait: true}); err == nil {
+		t.Error("Start of a stream being stopped succeeded")
+	}
+
+	if err := <-stopped; err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if info, err := m.Status("news"); err != nil || info.State != StateStopped {
+		t.Errorf("Status after Stop = %+v, %v, want stopped", info, err)
+	}
+}
+
+func TestRestartDoesNotBlockReaders(t *testing.T) {
+	m := newTestManager(t)
+	serveReadyPaths(t, m)
+	// The first ffmpeg ignores SIGTERM, making the restart wait out
+	// stopTimeout; the next one does not
+	dir := t.TempDir()
+	ready := filepath.Join(dir, "ready")
+	m.config.FFmpeg.BinaryPath = writeScript(t, dir, "ffmpeg",
+		"[ -e \""+ready+"\" ] && exec sleep 60\ntrap '' TERM\ntouch \""+ready+"\"\nwhile :; do sleep 0.05; done\n")
+	if err := m.Start(context.Background(), "https://youtu.be/abc123", "news", 0, StartOptions{NoWait: true}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitForFile(t, ready)
+	oldPID := m.GetProcess("news").GetPID()
+
+	restarted := make(chan error, 1)
+	go func() { restarted <- m.RestartStream(context.Background(), "news", m.Generation("news")) }()
+
+	// Wait for the restart to be under way; it must be seen before the old
+	// ffmpeg is given up on
+	deadline := time.Now().Add(time.Second)
+	for {
+		info, err := m.Status("news")
+		if err == nil && info.State == StateReconnecting && time.Now().Before(deadline) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stream not listed as reconnecting within a second (err %v)", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	listed := make(chan []Info, 1)
+	go func() { listed <- m.List() }()
+	select {
+	case infos := <-listed:
+		if len(infos) != 1 || infos[0].State != StateReconnecting {
+			t.Errorf("List during restart = %+v, want the stream reconnecting", infos)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("List blocked while the restart waited for the old ffmpeg")
+	}
+	if m.GetStream("news") == nil {
+		t.Error("stream not found during the restart")
+	}
+
+	if err := <-restarted; err != nil {
+		t.Fatalf("RestartStream: %v", err)
+	}
+	if proc := m.GetProcess("news"); proc == nil || proc.GetPID() == oldPID || !proc.IsRunning() {
+		t.Error("restart did not replace ffmpeg")
+	}
+	if IsProcessAlive(oldPID) {
+		t.Error("old ffmpeg still runs after the restart")
+	}
+}
+
+func TestPublishTargetsFollowRTSPAddress(t *testing.T) {
+	for _, tt := range []struct {
+		address string
+		want    string
+	}{
+		{"", "rtsp://localhost:8554/news"},
+		{"0.0.0.0", "rtsp://localhost:8554/news"},
+		{"192.168.1.10", "rtsp://192.168.1.10:8554/news"},
+		{"fd00::10", "rtsp://[fd00::10]:8554/news"},
+	} {
+		base := newTestManager(t)
+		cfg := *base.config
+		cfg.Server.RTSPAddress = tt.address
+		m := NewManager(&cfg, fakeExtractor{}, nil, base.storage, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		defer m.GetLoggerManager().CloseAll()
+
+		s, err := m.newStream("https://youtu.be/abc123", "news", 0, StartOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.PublishTargets(); len(got) != 1 || got[0] != tt.want {
+			t.Errorf("rtsp_address %q: targets %v, want %s", tt.address, got, tt.want)
+		}
+		stored := m.streamFromData(&storage.StreamData{Name: "news", RTSPPath: "/news", Port: 8554}, StateStopped)
+		if got := stored.PublishTargets()[0]; got != tt.want {
+			t.Errorf("rtsp_address %q: stored stream target %s, want %s", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestPurgeRemovesDeadStream(t *testing.T) {
+	m := newTestManager(t)
+
+	// A stream left by a crashed process: its ffmpeg has exited
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []*storage.StreamData{
+		{ID: "1", Name: "dead", RTSPPath: "/dead", Port: 8554, FFmpegPID: cmd.Process.Pid},
+		{ID: "2", Name: "stopped", RTSPPath: "/stopped", Port: 8554, Stopped: true},
+	} {
+		if err := m.storage.Save(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The files of the dead stream go with its entry; those of a stream
+	// removed before are leftovers
+	dir := m.storage.GetDataDir()
+	for _, name := range []string{"dead.pid", "dead.log", "gone.log"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A dry run lists the stream without removing it
+	result, err := m.Purge(true)
+	if err != nil {
+		t.Fatalf("Purge dry run: %v", err)
+	}
+	if !slices.Equal(result.Entries, []string{"dead"}) {
+		t.Errorf("dry run entries = %v, want [dead]", result.Entries)
+	}
+	if _, err := m.storage.Load("dead"); err != nil {
+		t.Errorf("dry run removed the entry: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gone.log")); err != nil {
+		t.Errorf("dry run removed a leftover file: %v", err)
+	}
+
+	result, err = m.Purge(false)
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if !slices.Equal(result.Entries, []string{"dead"}) {
+		t.Errorf("purged entries = %v, want [dead]", result.Entries)
+	}
+	var files []string
+	for _, path := range result.Files {
+		files = append(files, filepath.Base(path))
+	}
+	slices.Sort(files)
+	if want := []string{"gone.log"}; !slices.Equal(files, want) {
+		t.Errorf("purged files = %v, want %v", files, want)
+	}
+	if _, err := m.storage.Load("dead"); err == nil {
+		t.Error("dead stream is still stored after the purge")
+	}
+	for _, name := range []string{"dead.pid", "dead.log", "gone.log"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after the purge", name)
+		}
+	}
+	if _, err := m.storage.Load("stopped"); err != nil {
+		t.Errorf("stopped stream was purged: %v", err)
+	}
+}
+
+func TestFindByURL(t *testing.T) {
+	m := newTestManager(t)
+	const news, other = "https://youtu.be/abc123", "https://youtu.be/def456"
+	for _, s := range []struct{ url, name string }{
+		{news, "news-hd"},
+		{news, "news-sd"},
+		{other, "other"},
+	} {
+		if err := m.Start(context.Background(), s.url, s.name, 0, StartOptions{NoWait: true}); err != nil {
+			t.Fatalf("Start %s: %v", s.name, err)
+		}
+	}
+	// Stopped streams do not proxy their URL
+	err := m.storage.Save(&storage.StreamData{ID: "4", Name: "news-old", YouTubeURL: news, RTSPPath: "/news-old", Port: 8554, Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{news, []string{"news-hd", "news-sd"}},
+		{other, []string{"other"}},
+		{"https://youtu.be/unknown", nil},
+	}
+	for _, tt := range tests {
+		if got := m.FindByURL(tt.url); !slices.Equal(got, tt.want) {
+			t.Errorf("FindByURL(%s) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+
+	if err := m.Stop("news-sd"); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.FindByURL(news); !slices.Equal(got, []string{"news-hd"}) {
+		t.Errorf("FindByURL after stopping news-sd = %v, want [news-hd]", got)
+	}
+}
+
+// mediaExtractor resolves every URL to a source with full metadata
+type mediaExtractor struct{}
+
+func (mediaExtractor) Extract(ctx context.Context, youtubeURL string, opts extractor.ExtractOptions) (*extractor.StreamInfo, error) {
+	return &extractor.StreamInfo{
+		ID:         "abc123",
+		URL:        "https://example.com/video.m3u8",
+		Title:      "Lofi Radio",
+		Format:     "301 - 1920x1080",
+		Resolution: "1920x1080",
+		VideoCodec: "avc1.640028",
+		IsLive:     true,
+	}, nil
+}
+
+func (mediaExtractor) IsLiveStream(ctx context.Context, youtubeURL string) (bool, error) {
+	return true, nil
+}
+
+// reopen returns a new manager on the config and storage of m, as after
+// the program restarted
+func reopen(t *testing.T, m *Manager) *Manager {
+	t.Helper()
+	reopened := NewManager(m.config, fakeExtractor{}, nil, m.storage, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(reopened.GetLoggerManager().CloseAll)
+	return reopened
+}
+
+func TestMetadataPersists(t *testing.T) {
+	m := newTestManager(t)
+	m.extractor = mediaExtractor{}
+	if err := m.Start(context.Background(), "https://youtu.be/abc123", "news", 0, StartOptions{NoWait: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(where string, info Info) {
+		t.Helper()
+		if info.VideoID != "abc123" || info.Title != "Lofi Radio" || info.Resolution != "1920x1080" ||
+			info.Format != "301 - 1920x1080" || info.VideoCodec != "avc1.640028" || !info.IsLive {
+			t.Errorf("%s: video %q, title %q, resolution %q, format %q, codec %q, live %v; want the extracted metadata",
+				where, info.VideoID, info.Title, info.Resolution, info.Format, info.VideoCodec, info.IsLive)
+		}
+	}
+	check("running stream", m.GetStream("news").GetInfo())
+
+	// Stored streams report it without being loaded
+	data, err := m.storage.Load("news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("stored stream", infoFromData(data, StateRunning))
+
+	// And recovered streams carry it on
+	recovered := reopen(t, m)
+	recovered.RecoverStreams()
+	s := recovered.GetStream("news")
+	if s == nil {
+		t.Fatal("stream was not recovered")
+	}
+	check("recovered stream", s.GetInfo())
+}
+
+func TestHealthPersists(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.Start(context.Background(), "https://youtu.be/abc123", "news", 0, StartOptions{NoWait: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Three failed checks, two of them stalls, since the last healthy one
+	s := m.GetStream("news")
+	for range 3 {
+		s.IncrementErrorCount()
+	}
+	s.SetLastError("stream stalled (no data flow)")
+	for range 3 {
+		s.UpdateBytesReceived(4096)
+	}
+	m.SaveHealth(s)
+
+	recovered := reopen(t, m)
+	recovered.RecoverStreams()
+	r := recovered.GetStream("news")
+	if r == nil {
+		t.Fatal("stream was not recovered")
+	}
+	info := r.GetInfo()
+	if info.ErrorCount != 3 || info.ConsecutiveErrors != 3 || info.LastError != "stream stalled (no data flow)" {
+		t.Errorf("recovered errors: %d in all, %d consecutive, last %q; want 3, 3 and the stall",
+			info.ErrorCount, info.ConsecutiveErrors, info.LastError)
+	}
+	if got := r.GetStallCount(); got != 2 {
+		t.Errorf("recovered stall count %d, want 2", got)
+	}
+
+	// A healthy check clears the consecutive errors but not the total
+	r.RecordHealthyCheck(1)
+	if info := r.GetInfo(); info.ConsecutiveErrors != 0 || info.ErrorCount != 3 {
+		t.Errorf("after a healthy check: %d consecutive errors, %d in all; want 0 and 3", info.ConsecutiveErrors, info.ErrorCount)
+	}
+}
+
+func TestStreamOutlivesStartContext(t *testing.T) {
+	m := newTestManager(t)
+
+	// The context of a CLI invocation, cancelled when it exits or gets SIGINT
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.Start(ctx, "https://youtu.be/abc123", "news", 0, StartOptions{NoWait: true}); err != nil {
+		t.Fatal(err)
+	}
+	proc := m.GetProcess("news")
+	cancel()
+
+	select {
+	case <-proc.Done():
+		t.Fatal("ffmpeg exited when the start context was cancelled")
+	case <-time.After(200 * time.Millisecond):
+	}
+	if !proc.IsRunning() || !IsProcessAlive(proc.GetPID()) {
+		t.Error("ffmpeg is not running after the start context was cancelled")
+	}
+
+	// Stopping is what ends it
+	if err := m.Stop("news"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-proc.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("ffmpeg still runs after Stop")
+	}
+}
+
+// fileExtractor resolves every URL to a video file that is not live
+type fileExtractor struct{}
+
+func (fileExtractor) Extract(ctx context.Context, youtubeURL string, opts extractor.ExtractOptions) (*extractor.StreamInfo, error) {
+	return &extractor.StreamInfo{ID: "abc123", URL: "https://example.com/video.mp4"}, nil
+}
+
+func (fileExtractor) IsLiveStream(ctx context.Context, youtubeURL string) (bool, error) {
+	return false, nil
+}
+
+// serveReadyPaths gives m a MediaMTX API reporting every path ready, as
+// restarts wait for that
+func serveReadyPaths(t *testing.T, m *Manager) {
+	t.Helper()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"name": "news", "ready": true}`))
+	}))
+	t.Cleanup(api.Close)
+	addr := api.Listener.Addr().(*net.TCPAddr)
+	m.config.Server.APIAddress = addr.IP.String()
+	m.config.Server.APIPort = addr.Port
+	m.server = server.NewMediaMTXServer(&m.config.MediaMTX, &m.config.Server, t.TempDir(), m.appLog)
+}
+
+// reconnectedArgs starts a stream with opts and returns its ffmpeg
+// arguments, first as started and then after the program restarted and
+// reconnected it
+func reconnectedArgs(t *testing.T, m *Manager, opts StartOptions) (started, reconnected []string) {
+	t.Helper()
+	opts.NoWait = true
+	if err := m.Start(context.Background(), "https://youtu.be/abc123", "news", 0, opts); err != nil {
+		t.Fatal(err)
+	}
+	started = m.GetProcess("news").GetArgs()
+
+	// A reconnect waits for MediaMTX to report the path ready
+	r := reopen(t, m)
+	r.extractor = m.extractor
+	serveReadyPaths(t, r)
+	t.Cleanup(func() { r.StopAll() })
+	if err := r.Reconnect(context.Background(), "news"); err != nil {
+		t.Fatalf("Reconnect: %v", err)
+	}
+	return started, r.GetProcess("news").GetArgs()
+}
+
+func TestLoopSurvivesReconnect(t *testing.T) {
+	m := newTestManager(t)
+	m.extractor = fileExtractor{}
+	started, reconnected := reconnectedArgs(t, m, StartOptions{Loop: true})
+	for _, args := range [][]string{started, reconnected} {
+		if !strings.Contains(strings.Join(args, " "), "-stream_loop -1 ") {
+			t.Errorf("args = %q, want the input looped", args)
+		}
+	}
+}
+
+func TestTransportSurvivesReconnect(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.Start(context.Background(), "https://youtu.be/abc123", "news", 0, StartOptions{Transport: "http", NoWait: true}); err == nil {
+		t.Error("Start accepted the transport http")
+	}
+
+	started, reconnected := reconnectedArgs(t, m, StartOptions{Transport: "udp"})
+	for _, args := range [][]string{started, reconnected} {
+		if got := optionValue(args, "-rtsp_transport"); got != "udp" {
+			t.Errorf("args = %q, want -rtsp_transport udp", args)
+		}
+	}
+}
+
+func TestMaxBitrateSurvivesReconnect(t *testing.T) {
+	m := newTestManager(t)
+	m.config.FFmpeg.OutputOptions = []string{"-c:v", "libx264", "-c:a", "aac", "-f", "rtsp"}
+
+	started, reconnected := reconnectedArgs(t, m, StartOptions{MaxBitrate: 800000})
+	for _, args := range [][]string{started, reconnected} {
+		if got := optionValue(args, "-maxrate"); got != "800000" {
+			t.Errorf("args = %q, want -maxrate 800000", args)
+		}
+	}
+}
+
+func TestDrain(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.Start(context.Background(), "https://youtu.be/abc123", "news", 0, StartOptions{NoWait: true}); err != nil {
+		t.Fatal(err)
+	}
+	proc := m.GetProcess("news")
+
+	m.Drain()
+	if !m.IsDraining() {
+		t.Error("IsDraining = false after Drain")
+	}
+	if err := m.Start(context.Background(), "https://youtu.be/def456", "weather", 0, StartOptions{NoWait: true}); !errors.Is(err, ErrDraining) {
+		t.Errorf("Start while draining = %v, want ErrDraining", err)
+	}
+	if err := m.StartOnDemand("https://youtu.be/def456", "weather", 0, StartOptions{}); !errors.Is(err, ErrDraining) {
+		t.Errorf("StartOnDemand while draining = %v, want ErrDraining", err)
+	}
+	if m.GetStream("weather") != nil {
+		t.Error("a stream was added while draining")
+	}
+
+	// The running stream is left alone
+	select {
+	case <-proc.Done():
+		t.Fatal("ffmpeg of the running stream exited after Drain")
+	case <-time.After(200 * time.Millisecond):
+	}
+	if info, err := m.Status("news"); err != nil || info.State != StateRunning {
+		t.Errorf("running stream after Drain: %+v, %v", info, err)
+	}
+
+	// And can still be stopped
+	if err := m.StopAll(); err != nil {
+		t.Fatalf("StopAll while draining: %v", err)
+	}
+	select {
+	case <-proc.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("ffmpeg still runs after StopAll")
+	}
+}
+
+func TestBackgroundStartRecordsPID(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.Start(context.Background(), "https://youtu.be/abc123", "news", 0, StartOptions{NoWait: true}); err != nil {
+		t.Fatal(err)
+	}
+	pid := m.GetStream("news").GetFFmpegPID()
+	if pid == 0 || pid != m.GetProcess("news").GetPID() {
+		t.Fatalf("stream PID %d, want the PID of its ffmpeg", pid)
+	}
+	data, err := m.storage.Load("news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.FFmpegPID != pid {
+		t.Errorf("stored PID %d, want %d", data.FFmpegPID, pid)
+	}
+
+	// The next invocation finds the stream still running
+	recovered := reopen(t, m)
+	recovered.RecoverStreams()
+	s := recovered.GetStream("news")
+	if s == nil {
+		t.Fatal("stream was not recovered")
+	}
+	if got := s.GetFFmpegPID(); got != pid || !IsProcessAlive(got) {
+		t.Errorf("recovered PID %d (alive %v), want %d", got, IsProcessAlive(got), pid)
+	}
+}
+
+// freshExtractor hands out a new URL on every extraction, as yt-dlp does
+type freshExtractor struct {
+	calls atomic.Int32
+}
+
+func (e *freshExtractor) Extract(ctx context.Context, youtubeURL string, opts extractor.ExtractOptions) (*extractor.StreamInfo, error) {
+	n := e.calls.Add(1)
+	return &extractor.StreamInfo{ID: "abc123", URL: fmt.Sprintf("https://example.com/video-%d.m3u8", n), IsLive: true}, nil
+}
+
+func (e *freshExtractor) IsLiveStream(ctx context.Context, youtubeURL string) (bool, error) {
+	return true, nil
+}
+
+func TestExtractSourceSkipsFailedURL(t *testing.T) {
+	m := newTestManager(t)
+	ext := &freshExtractor{}
+	m.extractor = ext
+	opts := StartOptions{NoWait: true}
+	for _, name := range []string{"news", "news-sub"} {
+		if err := m.Start(context.Background(), "https://youtu.be/abc123", name, 0, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := ext.calls.Load(); calls != 1 {
+		t.Fatalf("yt-dlp ran %d times for two streams of one source, want once", calls)
+	}
+
+	// A refresh within the reuse window still gets a new URL, not the
+	// one that failed
+	news := m.GetStream("news")
+	failed, _ := news.GetStreamURLs()
+	info, err := m.ExtractSource(context.Background(), news)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.URL == failed {
+		t.Fatalf("refresh handed back the failed URL %s", failed)
+	}
+
+	// The other stream of the source, refreshed with it, shares the new URL
+	sub, err := m.ExtractSource(context.Background(), m.GetStream("news-sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.URL != info.URL || ext.calls.Load() != 2 {
+		t.Errorf("sharer got %s after %d extractions, want %s after 2", sub.URL, ext.calls.Load(), info.URL)
+	}
+}
+
+func TestStopKeepsData(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.Start(context.Background(), "https://youtu.be/abc123", "news", 8555, StartOptions{NoWait: true}); err != nil {
+		t.Fatal(err)
+	}
+	pid := m.GetStream("news").GetFFmpegPID()
+	if err := m.Stop("news"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(m.storage.GetDataDir(), "news.json")); err != nil {
+		t.Fatalf("stream file after stop: %v", err)
+	}
+	data, err := m.storage.Load("news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !data.Stopped || data.FFmpegPID != 0 {
+		t.Errorf("stored stopped %v, PID %d; want stopped with PID 0", data.Stopped, data.FFmpegPID)
+	}
+	if data.YouTubeURL != "https://youtu.be/abc123" || data.Port != 8555 {
+		t.Errorf("stored URL %q, port %d; want the stream's", data.YouTubeURL, data.Port)
+	}
+	if IsProcessAlive(pid) {
+		t.Errorf("ffmpeg %d still runs after stop", pid)
+	}
+}
@@ -0,0 +1,134 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+)
+
+// DefaultName names a stream when no name is given and none can be derived
+const DefaultName = "stream"
+
+// maxSlugLength caps names derived from video titles
+const maxSlugLength = 40
+
+// namePattern matches valid stream names. Names become file names in the
+// data dir and RTSP paths, so they cannot contain separators or dots.
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// reservedNames are the names of files in the data dir that are not
+// streams (favorites.json, mediamtx.yml, mediamtx.log, ...), so streams
+// cannot be named after them
+var reservedNames = []string{"favorites", "mediamtx"}
+
+// ValidateName returns an ErrInvalidName error if name is not a valid
+// stream name: letters, digits, '-' and '_', starting with a letter or
+// digit, and not one of the reserved names
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("%w %q: use letters, digits, '-' and '_', starting with a letter or digit", ErrInvalidName, name)
+	}
+	for _, reserved := range reservedNames {
+		if strings.EqualFold(name, reserved) {
+			return fmt.Errorf("%w %q: the name is reserved", ErrInvalidName, name)
+		}
+	}
+	return nil
+}
+
+// NameFor names a stream of youtubeURL after its video title (see Slugify),
+// with a numeric suffix if the name is taken. If the title cannot be read,
+// the name is based on DefaultName and err says why. The extraction is
+// shared with a Start or PlanStart of the same source that follows, so
+// yt-dlp runs once.
+func (m *Manager) NameFor(ctx context.Context, youtubeURL string, opts StartOptions) (name string, err error) {
+	base := DefaultName
+	stream, err := m.newStream(youtubeURL, base, 0, opts)
+	if err == nil {
+		var info *extractor.StreamInfo
+		info, err = m.extractShared(ctx, youtubeURL, stream.ExtractOptions())
+		if err == nil {
+			if slug := Slugify(info.Title); slug != "" {
+				base = slug
+			}
+		}
+	}
+	return UniqueName(base, m.takenNames()), err
+}
+
+// extractShared extracts a source once, without retries or logging,
+// through the registry that starting streams extract through
+func (m *Manager) extractShared(ctx context.Context, youtubeURL string, opts extractor.ExtractOptions) (*extractor.StreamInfo, error) {
+	key := sourceKey{url: youtubeURL, opts: opts}
+	return m.sources.extract(ctx, nil, key, func(ctx context.Context) (*extractor.StreamInfo, error) {
+		return m.extractor.Extract(ctx, youtubeURL, opts)
+	})
+}
+
+// takenNames returns the names of the streams that are running, starting
+// or stored, and the reserved names
+func (m *Manager) takenNames() map[string]bool {
+	taken := make(map[string]bool)
+	for _, name := range reservedNames {
+		taken[name] = true
+	}
+	if stored, err := m.storage.List(); err == nil {
+		for _, data := range stored {
+			taken[data.Name] = true
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for name := range m.streams {
+		taken[name] = true
+	}
+	for name := range m.starting {
+		taken[name] = true
+	}
+	return taken
+}
+
+// Slugify turns a title into a stream name: lowercase ASCII letters and
+// digits, with other runs of characters replaced by single hyphens, cut
+// to maxSlugLength. It returns "" if nothing is left.
+func Slugify(title string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(r)
+		default:
+			hyphen = true
+		}
+	}
+
+	slug := b.String()
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+	return slug
+}
+
+// UniqueName returns base, or base with the lowest suffix "-2", "-3", ...
+// that is not taken
+func UniqueName(base string, taken map[string]bool) string {
+	if !taken[base] {
+		return base
+	}
+	for i := 2; ; i++ {
+		name := base + "-" + strconv.Itoa(i)
+		if !taken[name] {
+			return name
+		}
+	}
+}
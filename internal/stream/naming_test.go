@@ -0,0 +1,163 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"lofi hip hop radio 📚 beats to relax/study to", "lofi-hip-hop-radio-beats-to-relax-study"},
+		{"NASA Live: Official Stream of NASA TV", "nasa-live-official-stream-of-nasa-tv"},
+		{"  --Breaking News--  ", "breaking-news"},
+		{"Café 24/7", "caf-24-7"},
+		{"뉴스 특보", ""},
+		{"", ""},
+		{strings.Repeat("a", 50), strings.Repeat("a", maxSlugLength)},
+		// Cut at the cap without leaving a trailing hyphen
+		{strings.Repeat("a", maxSlugLength-1) + " bcd", strings.Repeat("a", maxSlugLength-1)},
+	}
+	for _, tt := range tests {
+		if got := Slugify(tt.title); got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"news", "lofi-radio", "cam_2", "News24", "0"} {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("ValidateName(%q) = %v, want valid", name, err)
+		}
+	}
+	for _, name := range []string{"", "../x", "a/b", "..", ".hidden", "-news", "_news", "news.json", "two words", "뉴스", "favorites", "mediamtx", "Favorites"} {
+		if err := ValidateName(name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("ValidateName(%q) = %v, want ErrInvalidName", name, err)
+		}
+	}
+}
+
+func TestStartRejectsInvalidName(t *testing.T) {
+	m := newTestManager(t)
+	dir := m.storage.GetDataDir()
+
+	if err := m.Start(context.Background(), "https://youtu.be/abc123", "../escaped", 0, StartOptions{NoWait: true}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Start = %v, want ErrInvalidName", err)
+	}
+	if err := m.StartOnDemand("https://youtu.be/abc123", "../escaped", 0, StartOptions{}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("StartOnDemand = %v, want ErrInvalidName", err)
+	}
+	if _, err := m.PlanStart(context.Background(), "https://youtu.be/abc123", "../escaped", 0, StartOptions{}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("PlanStart = %v, want ErrInvalidName", err)
+	}
+	for _, file := range []string{"escaped.json", "escaped.log"} {
+		if _, err := os.Stat(filepath.Join(dir, "..", file)); err == nil {
+			t.Errorf("%s was written outside the data dir", file)
+		}
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	tests := []struct {
+		base  string
+		taken []string
+		want  string
+	}{
+		{"news", nil, "news"},
+		{"news", []string{"news"}, "news-2"},
+		{"news", []string{"news", "news-2", "news-3"}, "news-4"},
+		{"news", []string{"news", "news-3"}, "news-2"},
+		{"news", []string{"news-2"}, "news"},
+	}
+	for _, tt := range tests {
+		taken := make(map[string]bool)
+		for _, name := range tt.taken {
+			taken[name] = true
+		}
+		if got := UniqueName(tt.base, taken); got != tt.want {
+			t.Errorf("UniqueName(%q, %v) = %q, want %q", tt.base, tt.taken, got, tt.want)
+		}
+	}
+}
+
+// titledExtractor resolves every URL to a live source with a title,
+// counting the extractions
+type titledExtractor struct {
+	title string
+	err   error
+	calls atomic.Int32
+}
+
+func (e *titledExtractor) Extract(ctx context.Context, youtubeURL string, opts extractor.ExtractOptions) (*extractor.StreamInfo, error) {
+	e.calls.Add(1)
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &extractor.StreamInfo{ID: "abc123", Title: e.title, URL: "https://example.com/video.m3u8", IsLive: true}, nil
+}
+
+func (e *titledExtractor) IsLiveStream(ctx context.Context, youtubeURL string) (bool, error) {
+	return true, nil
+}
+
+func TestNameForSharesExtraction(t *testing.T) {
+	m := newTestManager(t)
+	ext := &titledExtractor{title: "Lofi Radio"}
+	m.extractor = ext
+	opts := StartOptions{NoWait: true}
+
+	name, err := m.NameFor(context.Background(), "https://youtu.be/abc123", opts)
+	if err != nil || name != "lofi-radio" {
+		t.Fatalf("NameFor = %q, %v; want lofi-radio", name, err)
+	}
+	if err := m.Start(context.Background(), "https://youtu.be/abc123", name, 0, opts); err != nil {
+		t.Fatal(err)
+	}
+	if calls := ext.calls.Load(); calls != 1 {
+		t.Errorf("yt-dlp ran %d times for naming and starting, want once", calls)
+	}
+
+	// Running and stored streams both take their names
+	if err := m.storage.Save(&storage.StreamData{Name: "lofi-radio-2", Stopped: true}); err != nil {
+		t.Fatal(err)
+	}
+	if name, _ := m.NameFor(context.Background(), "https://youtu.be/abc123", opts); name != "lofi-radio-3" {
+		t.Errorf("NameFor with lofi-radio and lofi-radio-2 taken = %q, want lofi-radio-3", name)
+	}
+}
+
+func TestNameForWithoutTitle(t *testing.T) {
+	m := newTestManager(t)
+
+	m.extractor = &titledExtractor{err: errors.New("video unavailable")}
+	if name, err := m.NameFor(context.Background(), "https://youtu.be/gone", StartOptions{}); err == nil || name != DefaultName {
+		t.Errorf("NameFor of a failing source = %q, %v; want %q and the error", name, err, DefaultName)
+	}
+
+	m.extractor = &titledExtractor{title: "뉴스"}
+	if name, err := m.NameFor(context.Background(), "https://youtu.be/korean", StartOptions{}); err != nil || name != DefaultName {
+		t.Errorf("NameFor of a title without ASCII = %q, %v; want %q", name, err, DefaultName)
+	}
+}
+
+func TestNameForSkipsReservedNames(t *testing.T) {
+	m := newTestManager(t)
+
+	for title, want := range map[string]string{"Favorites": "favorites-2", "MediaMTX": "mediamtx-2"} {
+		m.extractor = &titledExtractor{title: title}
+		if name, err := m.NameFor(context.Background(), "https://youtu.be/"+want, StartOptions{}); err != nil || name != want {
+			t.Errorf("NameFor of %q = %q, %v; want %q", title, name, err, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package stream
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/process"
+)
+
+// Orphan is an ffmpeg process publishing to MediaMTX that belongs to no
+// known stream, e.g. because its stored state was deleted
+type Orphan struct {
+	PID       int
+	StartTime uint64 // see process.StartTime
+	Path      string // RTSP path it publishes to
+	Target    string // URL it publishes to
+	Args      []string
+}
+
+// FindOrphans returns the ffmpeg processes publishing to the RTSP port that
+// no running or stored stream accounts for. Processes publishing to the
+// path of an on-demand stream are left out, as their PIDs are not stored.
+func (m *Manager) FindOrphans() ([]Orphan, error) {
+	procs, err := process.List()
+	if err != nil {
+		return nil, err
+	}
+
+	knownPIDs := make(map[int]bool)
+	onDemandPaths := make(map[string]bool)
+	m.mu.RLock()
+	for _, s := range m.streams {
+		knownPIDs[s.GetFFmpegPID()] = true
+		if s.OnDemand {
+			onDemandPaths[s.RTSPPath] = true
+		}
+	}
+	m.mu.RUnlock()
+	if stored, err := m.storage.List(); err == nil {
+		for _, data := range stored {
+			knownPIDs[data.FFmpegPID] = true
+			if data.OnDemand {
+				onDemandPaths[data.RTSPPath] = true
+			}
+		}
+	}
+
+	prefix := PublishURL(m.publishHost, m.config.Server.RTSPPort, "/")
+	var orphans []Orphan
+	for _, p := range procs {
+		if len(p.Args) == 0 || !strings.Contains(filepath.Base(p.Args[0]), "ffmpeg") {
+			continue
+		}
+		for _, arg := range p.Args[1:] {
+			if !strings.HasPrefix(arg, prefix) {
+				continue
+			}
+			path := "/" + strings.TrimPrefix(arg, prefix)
+			if !knownPIDs[p.PID] && !onDemandPaths[path] {
+				start, _ := process.StartTime(p.PID)
+				orphans = append(orphans, Orphan{PID: p.PID, StartTime: start, Path: path, Target: arg, Args: p.Args})
+			}
+			break
+		}
+	}
+	return orphans, nil
+}
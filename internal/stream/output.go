@@ -0,0 +1,129 @@
+package stream
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// LocalOutput is the output naming the stream's path on the local
+// MediaMTX, to publish there in addition to remote targets
+const LocalOutput = "local"
+
+// outputFormats maps the schemes of publish targets to their ffmpeg muxer
+var outputFormats = map[string]string{
+	"rtsp":  "rtsp",
+	"rtsps": "rtsp",
+	"rtmp":  "flv",
+	"rtmps": "flv",
+	"srt":   "mpegts",
+}
+
+// ValidateOutputs checks the publish targets given for a stream: LocalOutput
+// or rtsp(s)://, rtmp(s):// and srt:// URLs, each given once
+func ValidateOutputs(outputs []string) error {
+	seen := make(map[string]bool)
+	for _, output := range outputs {
+		if seen[output] {
+			return fmt.Errorf("output %q given twice", output)
+		}
+		seen[output] = true
+
+		if output == LocalOutput {
+			continue
+		}
+		u, err := url.Parse(output)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid output %q (want a URL such as rtsp://host:8554/path, or %q)", output, LocalOutput)
+		}
+		if _, ok := outputFormats[strings.ToLower(u.Scheme)]; !ok {
+			return fmt.Errorf("unsupported output %q (use rtsp, rtsps, rtmp, rtmps or srt)", output)
+		}
+		// "|" separates the targets of ffmpeg's tee muxer
+		if strings.Contains(output, "|") {
+			return fmt.Errorf("invalid output %q: must not contain |", output)
+		}
+	}
+	return nil
+}
+
+// PublishTargets returns the URLs ffmpeg publishes a stream to: outputs,
+// with LocalOutput standing for the stream's path on the MediaMTX at host,
+// or just that path if there are no outputs
+func PublishTargets(host string, port int, rtspPath string, outputs []string) []string {
+	if len(outputs) == 0 {
+		return []string{PublishURL(host, port, rtspPath)}
+	}
+
+	targets := make([]string, len(outputs))
+	for i, output := range outputs {
+		if output == LocalOutput {
+			output = PublishURL(host, port, rtspPath)
+		}
+		targets[i] = output
+	}
+	return targets
+}
+
+// PublishesLocally reports whether a stream with outputs publishes to the
+// local MediaMTX, whose path status then tells how the stream is doing
+func PublishesLocally(outputs []string) bool {
+	if len(outputs) == 0 {
+		return true
+	}
+	for _, output := range outputs {
+		if output == LocalOutput {
+			return true
+		}
+	}
+	return false
+}
+
+// outputArgs returns the ffmpeg output arguments publishing to targets.
+// Several targets are published through the tee muxer, so the input is
+// read and encoded once.
+func outputArgs(targets []string, transport string) []string {
+	if len(targets) == 1 {
+		target := targets[0]
+		format := outputFormat(target)
+		if format == "rtsp" {
+			return []string{"-rtsp_transport", transport, target}
+		}
+		return []string{"-f", format, target}
+	}
+
+	slaves := make([]string, len(targets))
+	for i, target := range targets {
+		format := outputFormat(target)
+		options := "f=" + format
+		if format == "rtsp" {
+			options += ":rtsp_transport=" + transport
+		}
+		slaves[i] = "[" + options + "]" + target
+	}
+	return []string{"-f", "tee", strings.Join(slaves, "|")}
+}
+
+// outputFormat returns the ffmpeg muxer for a publish target
+func outputFormat(target string) string {
+	scheme, _, _ := strings.Cut(target, "://")
+	if format, ok := outputFormats[strings.ToLower(scheme)]; ok {
+		return format
+	}
+	return "rtsp"
+}
+
+// teeTargets returns the targets of an ffmpeg output argument, which is a
+// single URL or a tee muxer specification
+func teeTargets(arg string) []string {
+	var targets []string
+	for _, slave := range strings.Split(arg, "|") {
+		if strings.HasPrefix(slave, "[") {
+			if end := strings.Index(slave, "]"); end >= 0 {
+				slave = slave[end+1:]
+			}
+		}
+		targets = append(targets, slave)
+	}
+	return targets
+}
@@ -0,0 +1,52 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+)
+
+// StartPlan is what starting a stream would do, for dry runs
+type StartPlan struct {
+	// Stream is the stream as it would be started, with its source
+	Stream *Stream
+	Info   *extractor.StreamInfo
+
+	// Args are the ffmpeg arguments publishing the stream, or ArgsErr
+	// why ffmpeg would not be started. Warnings are the options ffmpeg
+	// would ignore, and why.
+	Args     []string
+	ArgsErr  error
+	Warnings []string
+}
+
+// PlanStart extracts the source of a stream and works out the ffmpeg
+// command publishing it, without starting, storing or logging anything
+func (m *Manager) PlanStart(ctx context.Context, youtubeURL, name string, port int, opts StartOptions) (*StartPlan, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	stream, err := m.newStream(youtubeURL, name, port, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := m.extractShared(ctx, youtubeURL, stream.ExtractOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract stream URL: %w", err)
+	}
+	if info.ID == "" {
+		// The metadata fetch failed, so ask for the live status directly
+		if live, err := m.extractor.IsLiveStream(ctx, youtubeURL); err == nil {
+			stream.IsLive = live
+		}
+	}
+	m.applySource(stream, info)
+
+	plan := &StartPlan{Stream: stream, Info: info}
+	plan.Args, plan.ArgsErr = m.ffmpeg.Args(stream, func(format string, args ...interface{}) {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf(format, args...))
+	})
+	return plan, nil
+}
@@ -0,0 +1,43 @@
+package stream
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPlanStartRunsNoFFmpeg(t *testing.T) {
+	m := newTestManager(t)
+	m.extractor = mediaExtractor{}
+	ran := filepath.Join(t.TempDir(), "ran")
+	m.config.FFmpeg.BinaryPath = writeScript(t, t.TempDir(), "ffmpeg", "touch "+ran+"\nexec sleep 60\n")
+
+	plan, err := m.PlanStart(context.Background(), "https://youtu.be/abc123", "news", 0, StartOptions{})
+	if err != nil {
+		t.Fatalf("PlanStart: %v", err)
+	}
+	if plan.Info.Title != "Lofi Radio" || plan.Info.Resolution != "1920x1080" || !plan.Stream.GetIsLive() {
+		t.Errorf("plan info = %+v, want the extracted metadata", plan.Info)
+	}
+	if plan.ArgsErr != nil || !slices.Contains(plan.Args, "https://example.com/video.m3u8") {
+		t.Errorf("plan args = %q, %v, want ffmpeg reading the extracted URL", plan.Args, plan.ArgsErr)
+	}
+
+	// Give an ffmpeg that was started time to show itself
+	time.Sleep(200 * time.Millisecond)
+	if _, err := os.Stat(ran); err == nil {
+		t.Error("ffmpeg was started by a dry run")
+	}
+	if m.GetProcess("news") != nil || len(m.List()) != 0 {
+		t.Error("dry run left a stream in the manager")
+	}
+	if stored, err := m.storage.List(); err != nil || len(stored) != 0 {
+		t.Errorf("dry run stored %d streams (%v), want none", len(stored), err)
+	}
+	if _, err := os.Stat(filepath.Join(m.storage.GetDataDir(), "news.log")); err == nil {
+		t.Error("dry run created a stream log")
+	}
+}
@@ -0,0 +1,113 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
+)
+
+// ProbeResult is what a short test read of a source found
+type ProbeResult struct {
+	// Streams are ffmpeg's descriptions of the input streams, e.g.
+	// "Video: h264 (High), yuv420p, 1280x720, 30 fps"
+	Streams []string
+
+	BitrateKbps float64       // of the data read (0 = unknown)
+	Read        time.Duration // media time read
+}
+
+// probeStreamLine matches ffmpeg's description of an input stream, e.g.
+// "  Stream #0:0: Video: h264 (High), yuv420p, 1280x720, 30 fps"
+var probeStreamLine = regexp.MustCompile(`^\s*Stream #\d+:\d+\S*: ((?:Video|Audio): .*)$`)
+
+// Probe reads duration of a source with ffmpeg, copying it to nowhere, to
+// check that it actually plays. The inputs are read as for publishing:
+// audioURL is a separate audio input (empty = none) and headers are the
+// HTTP headers the extractor recommends.
+func (m *FFmpegManager) Probe(ctx context.Context, inputURL, audioURL string, headers map[string]string, duration time.Duration) (*ProbeResult, error) {
+	args := []string{"-hide_banner", "-nostats", "-progress", "pipe:1"}
+	args = append(args, m.inputArgs(inputURL, headers, false)...)
+	args = append(args, "-map", "0")
+	if audioURL != "" {
+		args = append(args, m.inputArgs(audioURL, headers, false)...)
+		args = append(args, "-map", "1")
+	}
+	// Matroska takes any codec, so nothing needs to be re-encoded
+	args = append(args,
+		"-t", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64),
+		"-c", "copy", "-f", "matroska", "-y", os.DevNull,
+	)
+
+	// Reading starts at real time, after connecting to the source
+	ctx, cancel := context.WithTimeout(ctx, duration+30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, m.config.BinaryPath, args...)
+	cmd.WaitDelay = time.Second
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	result := &ProbeResult{}
+	var last storage.Progress
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		parseProgress(stdout, func(p storage.Progress) { last = p })
+	}()
+	tail := readProbeStderr(stderr, result)
+	wg.Wait()
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("ffmpeg did not finish reading within %v", duration+30*time.Second)
+		}
+		return nil, fmt.Errorf("ffmpeg could not read the source: %s", strings.Join(tail, "\n"))
+	}
+	result.BitrateKbps = last.BitrateKbps
+	result.Read = last.OutTime
+	if result.Read == 0 {
+		return nil, fmt.Errorf("ffmpeg read no media from the source: %s", strings.Join(tail, "\n"))
+	}
+	return result, nil
+}
+
+// readProbeStderr collects the input stream descriptions ffmpeg prints
+// into result, returning the last lines for errors
+func readProbeStderr(r io.Reader, result *ProbeResult) []string {
+	var tail []string
+	inputs := true
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "Output #") {
+			inputs = false // the same streams follow as written
+		}
+		if match := probeStreamLine.FindStringSubmatch(line); match != nil && inputs {
+			result.Streams = append(result.Streams, match[1])
+		}
+		tail = append(tail, strings.TrimSpace(line))
+		if len(tail) > 5 {
+			tail = tail[1:]
+		}
+	}
+	return tail
+}
@@ -0,0 +1,100 @@
+package stream
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
+)
+
+// parseProgress reads ffmpeg -progress output, calling onBlock for every
+// completed block. Blocks end with a "progress=" line; builds that omit it
+// are handled by treating a repeated key as the start of a new block.
+// Unknown keys and unparsable values are ignored.
+func parseProgress(r io.Reader, onBlock func(storage.Progress)) {
+	scanner := bufio.NewScanner(r)
+
+	var current storage.Progress
+	seen := make(map[string]bool)
+	flush := func() {
+		if len(seen) == 0 {
+			return
+		}
+		current.UpdatedAt = time.Now()
+		onBlock(current)
+		current = storage.Progress{}
+		seen = make(map[string]bool)
+	}
+
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		if key == "progress" {
+			flush()
+			continue
+		}
+		if seen[key] {
+			flush()
+		}
+		seen[key] = true
+
+		switch key {
+		case "frame":
+			current.Frame, _ = strconv.ParseInt(value, 10, 64)
+		case "fps":
+			current.FPS = parseProgressFloat(value, "")
+		case "bitrate":
+			current.BitrateKbps = parseProgressFloat(value, "kbits/s")
+		case "speed":
+			current.Speed = parseProgressFloat(value, "x")
+		case "out_time":
+			if d, ok := parseProgressTime(value); ok {
+				current.OutTime = d
+			}
+		case "out_time_us", "out_time_ms":
+			// Both are in microseconds (out_time_ms is misnamed in ffmpeg)
+			if current.OutTime == 0 {
+				if us, err := strconv.ParseInt(value, 10, 64); err == nil && us > 0 {
+					current.OutTime = time.Duration(us) * time.Microsecond
+				}
+			}
+		}
+	}
+	flush()
+}
+
+// parseProgressFloat parses a number with an optional unit suffix,
+// returning 0 for "N/A" or malformed values
+func parseProgressFloat(value, suffix string) float64 {
+	value = strings.TrimSpace(strings.TrimSuffix(value, suffix))
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		return 0
+	}
+	return f
+}
+
+// parseProgressTime parses an HH:MM:SS.micro timestamp
+func parseProgressTime(value string) (time.Duration, bool) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	hours, err1 := strconv.Atoi(parts[0])
+	minutes, err2 := strconv.Atoi(parts[1])
+	seconds, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil || hours < 0 {
+		return 0, false
+	}
+	return time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(seconds*float64(time.Second)), true
+}
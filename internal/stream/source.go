@@ -0,0 +1,171 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/logger"
+)
+
+// sourceReuseWindow is how long an extraction is handed to other streams
+// of the same source instead of extracting again
+const sourceReuseWindow = time.Minute
+
+// sourceKey identifies what a stream extracts, so streams with the same
+// key can share an extraction
+type sourceKey struct {
+	url  string
+	opts extractor.ExtractOptions
+}
+
+// sharedSource is the latest extraction of a source
+type sharedSource struct {
+	done chan struct{} // closed when the extraction ends
+	info *extractor.StreamInfo
+	err  error
+	at   time.Time // when the extraction ended
+}
+
+// sourceRegistry shares extractions between streams of the same source,
+// such as a main, a substream and a recording pipeline of one video: an
+// extraction in progress is joined and a recent one reused, so yt-dlp runs
+// once for all of them
+type sourceRegistry struct {
+	mu      sync.Mutex
+	sources map[sourceKey]*sharedSource
+}
+
+func newSourceRegistry() *sourceRegistry {
+	return &sourceRegistry{sources: make(map[sourceKey]*sharedSource)}
+}
+
+// extract returns the source of key, running extract only if no other
+// stream is extracting it or did so within sourceReuseWindow. A failed
+// extraction is not kept, so the next stream tries again. Reuse is logged
+// to log, unless it is nil.
+func (r *sourceRegistry) extract(ctx context.Context, log *logger.StreamLogger, key sourceKey,
+	extract func(ctx context.Context) (*extractor.StreamInfo, error)) (*extractor.StreamInfo, error) {
+	for {
+		r.mu.Lock()
+		src, ok := r.sources[key]
+		if !ok || (src.info != nil && time.Since(src.at) > sourceReuseWindow) {
+			break // leave the lock held for starting a new extraction
+		}
+		r.mu.Unlock()
+
+		select {
+		case <-src.done:
+		default:
+			if log != nil {
+				log.Info("Waiting for the extraction of the same source by another stream")
+			}
+			select {
+			case <-src.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if src.err == nil {
+			if log != nil {
+				log.Info("Using the source extracted for another stream %v ago", time.Since(src.at).Round(time.Second))
+			}
+			return src.info, nil
+		}
+		// The other stream's caller may have given up on it; extract
+		// anew unless the source itself failed
+		if !errors.Is(src.err, context.Canceled) && !errors.Is(src.err, context.DeadlineExceeded) {
+			return nil, src.err
+		}
+	}
+
+	src := &sharedSource{done: make(chan struct{})}
+	r.sources[key] = src
+	r.mu.Unlock()
+
+	info, err := extract(ctx)
+
+	r.mu.Lock()
+	src.info, src.err, src.at = info, err, time.Now()
+	if err != nil && r.sources[key] == src {
+		delete(r.sources, key)
+	}
+	r.mu.Unlock()
+	close(src.done)
+	return info, err
+}
+
+// invalidate drops the extraction of key if it handed out url, so a stream
+// whose url failed extracts anew instead of getting it back. Extractions
+// that did not hand out url, such as one a sharer made after the failure,
+// are kept.
+func (r *sourceRegistry) invalidate(key sourceKey, url string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if src, ok := r.sources[key]; ok && src.info != nil && src.info.URL == url {
+		delete(r.sources, key)
+	}
+}
+
+// prune drops the extracted sources that no stream uses any more. Ones
+// still being extracted are left to the stream starting with them.
+func (r *sourceRegistry) prune(inUse func(key sourceKey) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, src := range r.sources {
+		if src.info != nil && !inUse(key) {
+			delete(r.sources, key)
+		}
+	}
+}
+
+// sourceOf returns the source key of a stream
+func sourceOf(stream *Stream) sourceKey {
+	return sourceKey{url: stream.YouTubeURL, opts: stream.ExtractOptions()}
+}
+
+// ExtractSource extracts a fresh source for a stream once, sharing the
+// extraction with other streams of the same source (for monitor access).
+// The URL the stream has is never handed back, as it is refreshed because
+// that URL failed or aged.
+func (m *Manager) ExtractSource(ctx context.Context, stream *Stream) (*extractor.StreamInfo, error) {
+	log := m.loggerManager.GetLogger(stream.Name)
+	key := sourceOf(stream)
+	url, _ := stream.GetStreamURLs()
+	m.sources.invalidate(key, url)
+	return m.sources.extract(ctx, log, key, func(ctx context.Context) (*extractor.StreamInfo, error) {
+		return m.extractor.Extract(ctx, stream.YouTubeURL, stream.ExtractOptions())
+	})
+}
+
+// SourceSharers returns the other streams extracting the same source as
+// the named one, so the monitor refreshes them together
+func (m *Manager) SourceSharers(name string) []*Stream {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stream, exists := m.streams[name]
+	if !exists {
+		return nil
+	}
+	key := sourceOf(stream)
+	var sharers []*Stream
+	for other, s := range m.streams {
+		if other != name && !s.OnDemand && sourceOf(s) == key {
+			sharers = append(sharers, s)
+		}
+	}
+	return sharers
+}
+
+// pruneSources drops the shared sources of streams no longer running
+// (must be called with lock held)
+func (m *Manager) pruneSources() {
+	used := make(map[sourceKey]bool, len(m.streams))
+	for _, s := range m.streams {
+		used[sourceOf(s)] = true
+	}
+	m.sources.prune(func(key sourceKey) bool { return used[key] })
+}
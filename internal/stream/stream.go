@@ -1,8 +1,13 @@
 package stream
 
 import (
+	"slices"
 	"sync"
 	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/extractor"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/process"
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
 )
 
 // State represents the current state of a stream
@@ -15,6 +20,7 @@ const (
 	StateReconnecting
 	StateStopping
 	StateError
+	StateStopped
 )
 
 // String returns a string representation of the state
@@ -32,6 +38,8 @@ func (s State) String() string {
 		return "stopping"
 	case StateError:
 		return "error"
+	case StateStopped:
+		return "stopped"
 	default:
 		return "unknown"
 	}
@@ -44,24 +52,86 @@ type Stream struct {
 	ID         string
 	Name       string
 	YouTubeURL string
-	StreamURL  string // Extracted direct stream URL
+	StreamURL  string // Extracted direct stream URL (video, if AudioURL is set)
+	AudioURL   string // Extracted direct audio URL of merged formats (empty = none)
 	RTSPPath   string // RTSP path (e.g., /stream1)
 	Port       int
-
-	State         State
-	FFmpegPID     int
-	FFmpegCmd     interface{} // *exec.Cmd, stored as interface to avoid import cycle
-	CreatedAt     time.Time
-	StartedAt     time.Time
-	LastChecked   time.Time
+	Managed    bool   // Declared in the config's streams section
+	AudioOnly  bool   // Proxy the audio track only
+	Loop       bool   // Restart non-live sources when they end
+	OnDemand   bool   // Published by MediaMTX only while clients are reading
+	Transport  string // RTSP transport used to publish (tcp or udp)
+
+	// HTTP headers to request the extracted URLs with (not logged or stored)
+	httpHeaders map[string]string
+
+	// yt-dlp format selector chosen with --quality or --format (empty =
+	// ytdlp.format)
+	YtdlpFormat string
+	// Cap on the re-encoded video bitrate in bits/s (0 = unlimited)
+	MaxBitrate int64
+	// Targets published to instead of the local MediaMTX path (see
+	// StartOptions.Outputs)
+	Outputs []string
+	// Low-resolution copy published to <path>_sub, e.g. "640x360@15"
+	Substream string
+	// Re-encode video in codecs other than H.264 instead of failing
+	RequireH264 bool
+	// Audio-only copy published to <path>_audio
+	AudioCopy bool
+	// Threshold of the stream log, overriding logging.level (empty = the
+	// default)
+	LogLevel string
+
+	State          State
+	FFmpegPID      int
+	FFmpegStart    uint64      // start time of the ffmpeg process (see process.StartTime)
+	FFmpegCmd      interface{} // *exec.Cmd, stored as interface to avoid import cycle
+	CreatedAt      time.Time
+	StartedAt      time.Time
+	LastChecked    time.Time
 	LastURLRefresh time.Time
 
+	// Source video tracking (channel live URLs may resolve to new videos)
+	VideoID          string
+	Title            string
+	VideoIDChangedAt time.Time
+	Resolution       string
+	Format           string
+	VideoCodec       string
+	IsLive           bool
+
+	// Latest ffmpeg -progress report
+	Progress storage.Progress
+
+	// Ingest usage over the rolling UsageWindow
+	Usage        []storage.UsageBucket
+	usageCounter int64
+
+	// Past runs, oldest first (see EndSession)
+	History []storage.Session
+
 	// Health tracking
-	ErrorCount         int
-	ConsecutiveErrors  int
-	LastError          string
-	LastBytesReceived  int64
-	StallCount         int
+	ErrorCount        int
+	ConsecutiveErrors int
+	LastError         string
+	LastBytesReceived int64
+	LastBytesAt       time.Time // when LastBytesReceived was sampled
+	ByteRate          float64   // bytes/s received between the last two samples
+	StallCount        int
+	HealthyCount      int // consecutive healthy checks since the last error
+	SlowCount         int
+
+	// Pending reconnect: the attempt to make next and when
+	ReconnectAttempt int
+	NextRetryAt      time.Time
+
+	// onState is called after State changes, outside the lock
+	onState func(name string, state State)
+
+	// publishHost is where MediaMTX listens for RTSP, set by the manager
+	// from server.rtsp_address
+	publishHost string
 }
 
 // NewStream creates a new stream instance
@@ -74,26 +144,60 @@ func NewStream(name, youtubeURL string, port int) *Stream {
 		Port:       port,
 		State:      StateIdle,
 		CreatedAt:  time.Now(),
+
+		publishHost: "localhost",
 	}
 }
 
+// PublishTargets returns the URLs the stream's ffmpeg publishes to (see
+// the PublishTargets function)
+func (s *Stream) PublishTargets() []string {
+	return PublishTargets(s.publishHost, s.Port, s.RTSPPath, s.Outputs)
+}
+
 // Info returns a copy of stream information (thread-safe)
 type Info struct {
-	ID                string
-	Name              string
-	YouTubeURL        string
-	RTSPPath          string
-	Port              int
-	State             State
-	StateString       string
-	FFmpegPID         int
-	CreatedAt         time.Time
-	StartedAt         time.Time
-	LastChecked       time.Time
-	LastURLRefresh    time.Time
-	ErrorCount        int
-	ConsecutiveErrors int
-	LastError         string
+	ID                string            `json:"id"`
+	Name              string            `json:"name"`
+	YouTubeURL        string            `json:"youtube_url"`
+	RTSPPath          string            `json:"rtsp_path"`
+	Port              int               `json:"port"`
+	Managed           bool              `json:"managed"`
+	AudioOnly         bool              `json:"audio_only"`
+	Loop              bool              `json:"loop"`
+	OnDemand          bool              `json:"on_demand"`
+	Transport         string            `json:"transport,omitempty"`
+	YtdlpFormat       string            `json:"ytdlp_format,omitempty"`
+	MaxBitrate        int64             `json:"max_bitrate,omitempty"`
+	Outputs           []string          `json:"outputs,omitempty"`
+	Substream         string            `json:"substream,omitempty"`
+	RequireH264       bool              `json:"require_h264,omitempty"`
+	AudioCopy         bool              `json:"audio_copy,omitempty"`
+	LogLevel          string            `json:"log_level,omitempty"`
+	State             State             `json:"-"`
+	StateString       string            `json:"state"`
+	FFmpegPID         int               `json:"ffmpeg_pid"`
+	CreatedAt         time.Time         `json:"created_at"`
+	StartedAt         time.Time         `json:"started_at"`
+	LastChecked       time.Time         `json:"last_checked"`
+	LastURLRefresh    time.Time         `json:"last_url_refresh"`
+	VideoID           string            `json:"video_id,omitempty"`
+	Title             string            `json:"title,omitempty"`
+	VideoIDChangedAt  time.Time         `json:"video_id_changed_at"`
+	Resolution        string            `json:"resolution,omitempty"`
+	Format            string            `json:"format,omitempty"`
+	VideoCodec        string            `json:"video_codec,omitempty"`
+	IsLive            bool              `json:"is_live"`
+	IngestBytes       int64             `json:"ingest_bytes"` // within UsageWindow
+	ByteRate          float64           `json:"byte_rate"`    // bytes/s between the last two health checks
+	WastedBytes       int64             `json:"wasted_bytes"` // ingested with no readers, within UsageWindow
+	Progress          *storage.Progress `json:"progress,omitempty"`
+	History           []storage.Session `json:"history,omitempty"`
+	ErrorCount        int               `json:"error_count"`
+	ConsecutiveErrors int               `json:"consecutive_errors"`
+	LastError         string            `json:"last_error,omitempty"`
+	ReconnectAttempt  int               `json:"reconnect_attempt,omitempty"`
+	NextRetryAt       time.Time         `json:"next_retry_at"`
 }
 
 // GetInfo returns stream information
@@ -101,30 +205,72 @@ func (s *Stream) GetInfo() Info {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	ingest, wasted := sumUsage(s.Usage, time.Now())
+	stateString := s.State.String()
+	if s.OnDemand && s.State == StateIdle {
+		stateString = "ready (on-demand)"
+	}
+	var progress *storage.Progress
+	if !s.Progress.UpdatedAt.IsZero() {
+		p := s.Progress
+		progress = &p
+	}
 	return Info{
 		ID:                s.ID,
 		Name:              s.Name,
 		YouTubeURL:        s.YouTubeURL,
 		RTSPPath:          s.RTSPPath,
 		Port:              s.Port,
+		Managed:           s.Managed,
+		AudioOnly:         s.AudioOnly,
+		Loop:              s.Loop,
+		OnDemand:          s.OnDemand,
+		Transport:         s.Transport,
+		YtdlpFormat:       s.YtdlpFormat,
+		MaxBitrate:        s.MaxBitrate,
+		Outputs:           s.Outputs,
+		Substream:         s.Substream,
+		RequireH264:       s.RequireH264,
+		AudioCopy:         s.AudioCopy,
+		LogLevel:          s.LogLevel,
 		State:             s.State,
-		StateString:       s.State.String(),
+		StateString:       stateString,
 		FFmpegPID:         s.FFmpegPID,
 		CreatedAt:         s.CreatedAt,
 		StartedAt:         s.StartedAt,
 		LastChecked:       s.LastChecked,
 		LastURLRefresh:    s.LastURLRefresh,
+		VideoID:           s.VideoID,
+		Title:             s.Title,
+		VideoIDChangedAt:  s.VideoIDChangedAt,
+		Resolution:        s.Resolution,
+		Format:            s.Format,
+		VideoCodec:        s.VideoCodec,
+		IsLive:            s.IsLive,
+		IngestBytes:       ingest,
+		ByteRate:          s.ByteRate,
+		WastedBytes:       wasted,
+		Progress:          progress,
+		History:           slices.Clone(s.History),
 		ErrorCount:        s.ErrorCount,
 		ConsecutiveErrors: s.ConsecutiveErrors,
 		LastError:         s.LastError,
+		ReconnectAttempt:  s.ReconnectAttempt,
+		NextRetryAt:       s.NextRetryAt,
 	}
 }
 
 // SetState updates the stream state
 func (s *Stream) SetState(state State) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	changed := s.State != state
 	s.State = state
+	onState := s.onState
+	s.mu.Unlock()
+
+	if changed && onState != nil {
+		onState(s.Name, state)
+	}
 }
 
 // GetState returns the current state
@@ -134,26 +280,140 @@ func (s *Stream) GetState() State {
 	return s.State
 }
 
-// SetStreamURL updates the stream URL
-func (s *Stream) SetStreamURL(url string) {
+// SetStreamURLs updates the stream URL, the separate audio URL (empty =
+// none) and the HTTP headers to request them with together
+func (s *Stream) SetStreamURLs(url, audioURL string, headers map[string]string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.StreamURL = url
+	s.AudioURL = audioURL
+	s.httpHeaders = headers
 	s.LastURLRefresh = time.Now()
 }
 
-// GetStreamURL returns the current stream URL
-func (s *Stream) GetStreamURL() string {
+// GetStreamURLs returns the current stream URL and separate audio URL, as
+// extracted together
+func (s *Stream) GetStreamURLs() (url, audioURL string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.StreamURL, s.AudioURL
+}
+
+// GetHTTPHeaders returns the HTTP headers to request the stream URLs with
+func (s *Stream) GetHTTPHeaders() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.httpHeaders
+}
+
+// SetSource records the video the stream URL was resolved from and returns
+// the previous video ID. VideoIDChangedAt is updated when a known ID changes.
+func (s *Stream) SetSource(videoID, title string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.VideoID
+	if videoID == "" {
+		return previous
+	}
+	if previous != "" && previous != videoID {
+		s.VideoIDChangedAt = time.Now()
+	}
+	s.VideoID = videoID
+	if title != "" {
+		s.Title = title
+	}
+	return previous
+}
+
+// SetMediaInfo records the resolution, format, video codec and live status
+// reported for the current source
+func (s *Stream) SetMediaInfo(resolution, format, videoCodec string, isLive bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Resolution = resolution
+	s.Format = format
+	s.VideoCodec = videoCodec
+	s.IsLive = isLive
+}
+
+// ExtractOptions returns how the source of the stream is extracted
+func (s *Stream) ExtractOptions() extractor.ExtractOptions {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return extractor.ExtractOptions{AudioOnly: s.AudioOnly, Format: s.YtdlpFormat}
+}
+
+// startOptions returns the options to start the stream with again, for a
+// restart or a source handover
+func (s *Stream) startOptions() StartOptions {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return StartOptions{
+		AudioOnly:   s.AudioOnly,
+		Loop:        s.Loop,
+		Transport:   s.Transport,
+		Managed:     s.Managed,
+		Format:      s.YtdlpFormat,
+		MaxBitrate:  s.MaxBitrate,
+		Outputs:     s.Outputs,
+		Substream:   s.Substream,
+		RequireH264: s.RequireH264,
+		AudioCopy:   s.AudioCopy,
+	}
+}
+
+// GetIsLive returns whether the source is a live stream
+func (s *Stream) GetIsLive() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.IsLive
+}
+
+// GetVideoCodec returns the video codec of the current source (empty =
+// unknown)
+func (s *Stream) GetVideoCodec() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.VideoCodec
+}
+
+// GetVideoID returns the video ID of the current source
+func (s *Stream) GetVideoID() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.StreamURL
+	return s.VideoID
 }
 
-// SetFFmpegPID updates the FFmpeg process ID
+// SetFFmpegPID updates the FFmpeg process ID and records the start time
+// of that process
 func (s *Stream) SetFFmpegPID(pid int) {
+	var start uint64
+	if pid > 0 {
+		start, _ = process.StartTime(pid)
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.FFmpegPID = pid
+	s.FFmpegStart = start
+}
+
+// FFmpegAlive reports whether the FFmpeg process of the stream is running,
+// and is not an unrelated process that reused its PID
+func (s *Stream) FFmpegAlive() bool {
+	s.mu.RLock()
+	pid, start := s.FFmpegPID, s.FFmpegStart
+	s.mu.RUnlock()
+	return IsStreamProcessAlive(pid, start, s.PublishTargets()[0])
+}
+
+// KillFFmpeg kills the recorded FFmpeg process of the stream, if it still
+// runs (see KillByPID)
+func (s *Stream) KillFFmpeg() error {
+	s.mu.RLock()
+	pid, start := s.FFmpegPID, s.FFmpegStart
+	s.mu.RUnlock()
+	return KillByPID(pid, start, s.PublishTargets()[0])
 }
 
 // GetFFmpegPID returns the FFmpeg process ID
@@ -163,6 +423,13 @@ func (s *Stream) GetFFmpegPID() int {
 	return s.FFmpegPID
 }
 
+// GetStartedAt returns when the stream became ready
+func (s *Stream) GetStartedAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.StartedAt
+}
+
 // SetStartedAt updates the started time
 func (s *Stream) SetStartedAt(t time.Time) {
 	s.mu.Lock()
@@ -176,6 +443,18 @@ func (s *Stream) IncrementErrorCount() {
 	defer s.mu.Unlock()
 	s.ErrorCount++
 	s.ConsecutiveErrors++
+	s.HealthyCount = 0
+}
+
+// RecordHealthyCheck counts a healthy check, clearing the consecutive
+// errors once threshold checks in a row were healthy
+func (s *Stream) RecordHealthyCheck(threshold int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.HealthyCount++
+	if s.HealthyCount >= threshold {
+		s.ConsecutiveErrors = 0
+	}
 }
 
 // ResetConsecutiveErrors resets the consecutive error count
@@ -213,6 +492,20 @@ func (s *Stream) GetConsecutiveErrors() int {
 	return s.ConsecutiveErrors
 }
 
+// SetLogLevel sets the threshold of the stream log (empty = the default)
+func (s *Stream) SetLogLevel(level string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.LogLevel = level
+}
+
+// GetLogLevel returns the threshold of the stream log (empty = the default)
+func (s *Stream) GetLogLevel() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.LogLevel
+}
+
 // GetLastError returns the last error message
 func (s *Stream) GetLastError() string {
 	s.mu.RLock()
@@ -220,11 +513,25 @@ func (s *Stream) GetLastError() string {
 	return s.LastError
 }
 
-// UpdateBytesReceived updates bytes received and returns true if data is flowing
+// UpdateBytesReceived updates bytes received and returns true if data is
+// flowing. The rate since the previous sample is kept in ByteRate.
 func (s *Stream) UpdateBytesReceived(bytes int64) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	now := time.Now()
+	switch {
+	case s.LastBytesAt.IsZero():
+	case bytes < s.LastBytesReceived:
+		// MediaMTX restarted the path's counter
+		s.ByteRate = 0
+	default:
+		if elapsed := now.Sub(s.LastBytesAt).Seconds(); elapsed > 0 {
+			s.ByteRate = float64(bytes-s.LastBytesReceived) / elapsed
+		}
+	}
+	s.LastBytesAt = now
+
 	if bytes == s.LastBytesReceived {
 		s.StallCount++
 		return false
@@ -235,6 +542,41 @@ func (s *Stream) UpdateBytesReceived(bytes int64) bool {
 	return true
 }
 
+// GetByteRate returns the bytes/s received between the last two samples
+func (s *Stream) GetByteRate() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ByteRate
+}
+
+// SetProgress records the latest ffmpeg progress report
+func (s *Stream) SetProgress(p storage.Progress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Progress = p
+}
+
+// GetProgress returns the latest ffmpeg progress report
+func (s *Stream) GetProgress() storage.Progress {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Progress
+}
+
+// UpdateSlowCount counts consecutive checks in which ffmpeg was slower than
+// real time and returns the current count
+func (s *Stream) UpdateSlowCount(slow bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slow {
+		s.SlowCount++
+	} else {
+		s.SlowCount = 0
+	}
+	return s.SlowCount
+}
+
 // GetStallCount returns the stall count
 func (s *Stream) GetStallCount() int {
 	s.mu.RLock()
@@ -257,3 +599,18 @@ func randomString(n int) string {
 	}
 	return string(b)
 }
+
+// SetRetry records the pending reconnect attempt (0 = none)
+func (s *Stream) SetRetry(attempt int, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ReconnectAttempt = attempt
+	s.NextRetryAt = at
+}
+
+// GetRetry returns the pending reconnect attempt and when it is due
+func (s *Stream) GetRetry() (int, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ReconnectAttempt, s.NextRetryAt
+}
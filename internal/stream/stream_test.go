@@ -0,0 +1,53 @@
+package stream
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestByteRate(t *testing.T) {
+	s := NewStream("news", "https://youtu.be/abc123", 8554)
+
+	// sample records bytes as if interval had passed since the last sample
+	sample := func(bytes int64, interval time.Duration) bool {
+		s.mu.Lock()
+		s.LastBytesAt = s.LastBytesAt.Add(-interval)
+		s.mu.Unlock()
+		return s.UpdateBytesReceived(bytes)
+	}
+
+	if !s.UpdateBytesReceived(1_000_000) {
+		t.Error("first sample reported no data flow")
+	}
+	if got := s.GetByteRate(); got != 0 {
+		t.Errorf("rate after one sample = %v, want 0", got)
+	}
+
+	if !sample(3_000_000, 2*time.Second) {
+		t.Error("growing counter reported no data flow")
+	}
+	// Within 1% of 1 MB/s, allowing for the time the calls take
+	if got := s.GetByteRate(); math.Abs(got-1_000_000) > 10_000 {
+		t.Errorf("rate = %.0f bytes/s, want about 1000000", got)
+	}
+	if got := s.GetInfo().ByteRate; got != s.GetByteRate() {
+		t.Errorf("Info.ByteRate = %v, want %v", got, s.GetByteRate())
+	}
+
+	// A stall still counts as one, at a rate of 0
+	if sample(3_000_000, 5*time.Second) {
+		t.Error("unchanged counter reported data flow")
+	}
+	if got, stalls := s.GetByteRate(), s.GetStallCount(); got != 0 || stalls != 1 {
+		t.Errorf("after a stall: rate %v, stall count %d; want 0 and 1", got, stalls)
+	}
+
+	// A counter restarted by MediaMTX is no negative rate
+	if !sample(500_000, 5*time.Second) {
+		t.Error("restarted counter reported no data flow")
+	}
+	if got := s.GetByteRate(); got != 0 {
+		t.Errorf("rate after the counter restarted = %v, want 0", got)
+	}
+}
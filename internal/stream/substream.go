@@ -0,0 +1,71 @@
+package stream
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// substreamSuffix is appended to the RTSP path of a stream for its
+// substream, e.g. /lofi_sub
+const substreamSuffix = "_sub"
+
+// Substream is a low-resolution copy of a stream published next to it,
+// for NVRs that record a main stream and display a substream
+type Substream struct {
+	Width  int
+	Height int
+	FPS    int // 0 = the source frame rate
+}
+
+// ParseSubstream parses a substream specification such as "640x360@15" or
+// "640x360"
+func ParseSubstream(spec string) (*Substream, error) {
+	size, rate, hasRate := strings.Cut(spec, "@")
+	w, h, ok := strings.Cut(size, "x")
+	if !ok {
+		return nil, fmt.Errorf("invalid substream %q (want WIDTHxHEIGHT[@FPS], e.g. 640x360@15)", spec)
+	}
+
+	sub := &Substream{}
+	var err error
+	if sub.Width, err = strconv.Atoi(w); err != nil || sub.Width <= 0 || sub.Width%2 != 0 {
+		return nil, fmt.Errorf("invalid substream width %q (must be a positive even number)", w)
+	}
+	if sub.Height, err = strconv.Atoi(h); err != nil || sub.Height <= 0 || sub.Height%2 != 0 {
+		return nil, fmt.Errorf("invalid substream height %q (must be a positive even number)", h)
+	}
+	if hasRate {
+		if sub.FPS, err = strconv.Atoi(rate); err != nil || sub.FPS <= 0 {
+			return nil, fmt.Errorf("invalid substream frame rate %q", rate)
+		}
+	}
+	return sub, nil
+}
+
+// SubstreamPath returns the RTSP path of the substream of a stream path
+func SubstreamPath(rtspPath string) string {
+	return rtspPath + substreamSuffix
+}
+
+// args returns the ffmpeg output arguments encoding the substream, with
+// audio from audioStream (an ffmpeg -map stream specifier), and publishing
+// it to output. The main output copies or encodes the source as
+// configured; the substream is always re-encoded.
+func (s *Substream) args(output, audioStream, transport string) []string {
+	filter := fmt.Sprintf("scale=%d:%d", s.Width, s.Height)
+	gop := 60
+	if s.FPS > 0 {
+		filter += fmt.Sprintf(",fps=%d", s.FPS)
+		gop = 2 * s.FPS
+	}
+	return []string{
+		"-map", "0:v:0", "-map", audioStream,
+		"-vf", filter,
+		"-c:v", "libx264", "-preset", "veryfast", "-tune", "zerolatency",
+		"-g", strconv.Itoa(gop),
+		"-c:a", "aac",
+		"-rtsp_transport", transport,
+		output,
+	}
+}
@@ -0,0 +1,67 @@
+package stream
+
+import (
+	"time"
+
+	"github.com/zerodice0/youtube-rtsp-proxy/internal/storage"
+)
+
+const (
+	// UsageWindow is the rolling window over which ingest usage is reported
+	UsageWindow = 24 * time.Hour
+	// usageBucket is the granularity of the usage window
+	usageBucket = time.Hour
+)
+
+// RecordIngest adds the bytes ingested since the previous sample to the
+// usage window. Bytes pulled while no client was reading the path count as
+// wasted. bytesReceived is MediaMTX's cumulative counter for the path.
+func (s *Stream) RecordIngest(now time.Time, bytesReceived int64, readers int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delta := bytesReceived - s.usageCounter
+	if s.usageCounter == 0 || delta < 0 {
+		// First sample, or the path was recreated and its counter reset
+		delta = 0
+	}
+	s.usageCounter = bytesReceived
+
+	start := now.Truncate(usageBucket)
+	buckets := pruneUsage(s.Usage, now)
+	if len(buckets) == 0 || !buckets[len(buckets)-1].Start.Equal(start) {
+		buckets = append(buckets, storage.UsageBucket{Start: start})
+	}
+	last := &buckets[len(buckets)-1]
+	last.IngestBytes += delta
+	if readers == 0 {
+		last.WastedBytes += delta
+	}
+	s.Usage = buckets
+}
+
+// GetUsage returns the bytes ingested and wasted within UsageWindow
+func (s *Stream) GetUsage(now time.Time) (ingest, wasted int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sumUsage(s.Usage, now)
+}
+
+// pruneUsage drops buckets that fell out of the usage window
+func pruneUsage(buckets []storage.UsageBucket, now time.Time) []storage.UsageBucket {
+	cutoff := now.Add(-UsageWindow)
+	i := 0
+	for i < len(buckets) && !buckets[i].Start.After(cutoff) {
+		i++
+	}
+	return buckets[i:]
+}
+
+// sumUsage totals the buckets within the usage window
+func sumUsage(buckets []storage.UsageBucket, now time.Time) (ingest, wasted int64) {
+	for _, b := range pruneUsage(buckets, now) {
+		ingest += b.IngestBytes
+		wasted += b.WastedBytes
+	}
+	return ingest, wasted
+}
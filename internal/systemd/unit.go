@@ -0,0 +1,108 @@
+package systemd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UnitName is the name of the generated systemd unit
+const UnitName = "youtube-rtsp-proxy.service"
+
+// UnitOptions holds the values used to render a unit file
+type UnitOptions struct {
+	BinaryPath string
+	ConfigPath string
+	User       bool
+}
+
+// RenderUnit renders a systemd unit file running the server in foreground mode
+func RenderUnit(opts UnitOptions) string {
+	execStart := []string{opts.BinaryPath}
+	if opts.ConfigPath != "" {
+		execStart = append(execStart, "--config", opts.ConfigPath)
+	}
+	execStart = append(execStart, "server", "start", "--foreground")
+
+	wantedBy := "multi-user.target"
+	if opts.User {
+		wantedBy = "default.target"
+	}
+
+	return fmt.Sprintf(`[Unit]
+Description=YouTube to RTSP proxy
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=notify
+NotifyAccess=main
+ExecStart=%s
+ExecReload=/bin/kill -HUP $MAINPID
+Restart=on-failure
+RestartSec=5
+TimeoutStopSec=30
+WatchdogSec=120
+
+[Install]
+WantedBy=%s
+`, execLine(execStart), wantedBy)
+}
+
+// execLine joins args into an Exec*= command line, quoting each one the
+// way systemd splits it again
+func execLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = systemdQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// systemdQuote quotes s for use as a single word of an Exec*= line.
+// Specifiers (%) and variables ($) are escaped too, so paths reach the
+// process literally.
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	s = strings.ReplaceAll(s, "$", "$$")
+	if s != "" && !strings.ContainsAny(s, " \t\n\"'\\;") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	s = strings.ReplaceAll(s, "\t", `\t`)
+	return `"` + s + `"`
+}
+
+// UnitPath returns where the unit file is installed
+func UnitPath(user bool) (string, error) {
+	if !user {
+		return filepath.Join("/etc/systemd/system", UnitName), nil
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve user config directory: %w", err)
+	}
+	return filepath.Join(configDir, "systemd", "user", UnitName), nil
+}
+
+// InstallUnit writes the unit file and returns its path
+func InstallUnit(opts UnitOptions) (string, error) {
+	path, err := UnitPath(opts.User)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create unit directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, []byte(RenderUnit(opts)), 0644); err != nil {
+		return "", fmt.Errorf("failed to write unit file: %w", err)
+	}
+
+	return path, nil
+}
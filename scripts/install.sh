@@ -17,6 +17,7 @@ DATA_DIR="/var/lib/youtube-rtsp-proxy"
 USER_MODE=false
 INSTALL_DEPS=false
 DRY_RUN=false
+SKIP_CHECKSUM=false
 PREFIX=""
 
 # Colors for output
@@ -60,6 +61,8 @@ Options:
   --install-deps      Automatically install missing dependencies
                       (ffmpeg, yt-dlp, mediamtx)
   --dry-run           Show what would be done without making changes
+  --skip-checksum     Do not verify the mediamtx release checksum
+                      (for air-gapped or sideloaded setups)
   -h, --help          Show this help message
 
 Examples:
@@ -104,6 +107,10 @@ parse_args() {
                 DRY_RUN=true
                 shift
                 ;;
+            --skip-checksum)
+                SKIP_CHECKSUM=true
+                shift
+                ;;
             -h|--help)
                 show_help
                 exit 0
@@ -419,6 +426,16 @@ install_mediamtx() {
         wget -O "$archive_path" "$download_url"
     fi
 
+    # Verify release checksum
+    if [ "$SKIP_CHECKSUM" = true ]; then
+        echo_warn "Skipping mediamtx checksum verification"
+    else
+        if ! verify_mediamtx_checksum "$release_info" "$archive_path" "$(basename "$download_url")"; then
+            rm -rf "$tmp_dir"
+            return 1
+        fi
+    fi
+
     # Extract
     mkdir -p "$INSTALL_DIR"
     tar -xzf "$archive_path" -C "$tmp_dir"
@@ -436,9 +453,74 @@ install_mediamtx() {
     # Cleanup
     rm -rf "$tmp_dir"
 
+    # Record the verified binary so youtube-rtsp-proxy can detect later changes
+    if [ "$SKIP_CHECKSUM" != true ]; then
+        local version=""
+        version=$(echo "$download_url" | grep -o "mediamtx_v[^_]*" | sed 's/^mediamtx_//')
+        mkdir -p "$DATA_DIR"
+        cat > "${DATA_DIR}/mediamtx.integrity" << EOF
+sha256=$(sha256_of "${INSTALL_DIR}/mediamtx")
+version=${version}
+path=${INSTALL_DIR}/mediamtx
+EOF
+    fi
+
     echo_info "mediamtx installed to ${INSTALL_DIR}/mediamtx"
 }
 
+# Print the SHA-256 of a file
+sha256_of() {
+    if command -v sha256sum &>/dev/null; then
+        sha256sum "$1" | awk '{print $1}'
+    else
+        shasum -a 256 "$1" | awk '{print $1}'
+    fi
+}
+
+# Verify a downloaded mediamtx archive against the release checksums file
+verify_mediamtx_checksum() {
+    local release_info="$1"
+    local archive_path="$2"
+    local archive_name="$3"
+
+    if ! command -v sha256sum &>/dev/null && ! command -v shasum &>/dev/null; then
+        echo_error "Neither sha256sum nor shasum found. Use --skip-checksum to install anyway."
+        return 1
+    fi
+
+    local checksums_url=""
+    checksums_url=$(echo "$release_info" | grep -o "https://[^\"]*checksums\.sha256" | head -1)
+    if [ -z "$checksums_url" ]; then
+        echo_error "Could not find mediamtx release checksums. Use --skip-checksum to install anyway."
+        return 1
+    fi
+
+    local checksums=""
+    if command -v curl &>/dev/null; then
+        checksums=$(curl -sL "$checksums_url")
+    else
+        checksums=$(wget -qO- "$checksums_url")
+    fi
+
+    local expected=""
+    expected=$(echo "$checksums" | grep "${archive_name}\$" | awk '{print $1}' | head -1)
+    if [ -z "$expected" ]; then
+        echo_error "No checksum listed for ${archive_name}"
+        return 1
+    fi
+
+    local actual=""
+    actual=$(sha256_of "$archive_path")
+    if [ "$expected" != "$actual" ]; then
+        echo_error "Checksum mismatch for ${archive_name}"
+        echo "  expected: $expected"
+        echo "  actual:   $actual"
+        return 1
+    fi
+
+    echo_info "Checksum verified: $actual"
+}
+
 install_missing_dependencies() {
     local missing=($1)
 